@@ -0,0 +1,70 @@
+package dokuwiki
+
+// Anchor is a place in a page that links point to with its ID, like [[page#setup]].
+type Anchor struct {
+	// ID is the id the renderers give to the place, like "setup".
+	ID string
+	// Text is the text of the heading without its formatting, or the name of an explicit anchor.
+	Text     string
+	Position Position
+	// Heading is the heading of the anchor, nil for an explicit anchor.
+	Heading *SectionHeaderContext
+	// Explicit is the explicit anchor, nil for a heading.
+	Explicit *AnchorContext
+}
+
+// Anchors returns the anchors of the unit in document order, the headings and the explicit
+// anchors, with the ids the html and latex renderers give them. Identical headings get numbered
+// ids, three "Section" headings give section, section1 and section2, and so do explicit anchors
+// with the same name. An explicit anchor keeps the id of its name, so a heading whose id it is
+// gets a number instead.
+func (unit *ParseUnit) Anchors() []Anchor {
+	seen := make(map[string]bool)
+	explicitIDs := make(map[*AnchorContext]string)
+	Walk(unit, func(c Context) bool {
+		if explicit, ok := c.(*AnchorContext); ok {
+			explicitIDs[explicit] = sectionID(explicit.Name, seen)
+		}
+		return true
+	})
+
+	anchors := make([]Anchor, 0)
+	Walk(unit, func(c Context) bool {
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			anchors = append(anchors, Anchor{
+				ID:       sectionID(c.HeaderTextPlain(), seen),
+				Text:     c.HeaderTextPlain(),
+				Position: c.GetPosition(),
+				Heading:  c,
+			})
+			return false
+		case *AnchorContext:
+			anchors = append(anchors, Anchor{ID: explicitIDs[c], Text: c.Name, Position: c.GetPosition(), Explicit: c})
+		}
+		return true
+	})
+	return anchors
+}
+
+// headingIDs returns the ids of the headings of the unit, as listed by Anchors.
+func (unit *ParseUnit) headingIDs() map[*SectionHeaderContext]string {
+	ids := make(map[*SectionHeaderContext]string)
+	for _, anchor := range unit.Anchors() {
+		if anchor.Heading != nil {
+			ids[anchor.Heading] = anchor.ID
+		}
+	}
+	return ids
+}
+
+// explicitIDs returns the ids of the explicit anchors of the unit, as listed by Anchors.
+func (unit *ParseUnit) explicitIDs() map[*AnchorContext]string {
+	ids := make(map[*AnchorContext]string)
+	for _, anchor := range unit.Anchors() {
+		if anchor.Explicit != nil {
+			ids[anchor.Explicit] = anchor.ID
+		}
+	}
+	return ids
+}
@@ -0,0 +1,153 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnchors(t *testing.T) {
+	unit := Parse([]byte("== Section ==\n\na\n\n== Section ==\n\n=== **Section** ===\n\n== Other ==\n"), "t")
+	anchors := unit.Anchors()
+	want := []struct {
+		id   string
+		line int
+	}{{"section", 1}, {"section1", 5}, {"section2", 7}, {"other", 9}}
+	if len(anchors) != len(want) {
+		t.Fatalf("got anchors %+v", anchors)
+	}
+	for i, anchor := range anchors {
+		if anchor.ID != want[i].id || anchor.Position.Line != want[i].line || anchor.Heading.GetPosition() != anchor.Position {
+			t.Errorf("anchor %d: got %+v", i, anchor)
+		}
+	}
+	if anchors[2].Text != "Section" {
+		t.Errorf("got text %q", anchors[2].Text)
+	}
+
+	var out bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range want {
+		if !strings.Contains(out.String(), ` id="`+w.id+`"`) {
+			t.Errorf("no id %q in:\n%s", w.id, out.String())
+		}
+	}
+}
+
+func TestSectionNumbers(t *testing.T) {
+	content := "====== Title ======\n\n===== Intro =====\n\n==== Detail ====\n\n==== Detail ====\n\n===== Setup =====\n\n=== Deep ===\n\n==== Back ====\n"
+	unit := Parse([]byte(content), "t")
+	number := func(opts RendererOptions) string {
+		numbers := make([]string, 0)
+		// all the levels, === Deep === is below the table of contents by default.
+		opts.TOCMaxLevel = 5
+		for _, entry := range unit.TOCWithOptions(opts) {
+			numbers = append(numbers, entry.Number+" "+entry.Anchor)
+		}
+		return strings.Join(numbers, ", ")
+	}
+	tests := []struct {
+		opts RendererOptions
+		want string
+	}{
+		{RendererOptions{}, " title,  intro,  detail,  detail1,  setup,  deep,  back"},
+		{RendererOptions{NumberSections: true}, "1 title, 1.1 intro, 1.1.1 detail, 1.1.2 detail1, 1.2 setup, 1.2.0.1 deep, 1.2.1 back"},
+		{RendererOptions{NumberSections: true, NumberSkipTopLevel: true}, " title, 1 intro, 1.1 detail, 1.2 detail1, 2 setup, 2.0.1 deep, 2.1 back"},
+		// the title is pushed down to depth 2, numbers start there.
+		{RendererOptions{NumberSections: true, HeadingOffset: 1}, "1 title, 1.1 intro, 1.1.1 detail, 1.1.2 detail1, 1.2 setup, 1.2.0.1 deep, 1.2.1 back"},
+		{RendererOptions{NumberSections: true, NumberSkipTopLevel: true, HeadingOffset: 1}, "1 title, 1.1 intro, 1.1.1 detail, 1.1.2 detail1, 1.2 setup, 1.2.0.1 deep, 1.2.1 back"},
+	}
+	for _, test := range tests {
+		if got := number(test.opts); got != test.want {
+			t.Errorf("%+v: got %s", test.opts, got)
+		}
+	}
+
+	opts := RendererOptions{NumberSections: true, NumberSkipTopLevel: true}
+	renders := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{NewHTMLRenderer(opts), `<h2 id="setup">2 Setup</h2>`},
+		{NewMarkdownRenderer(opts), "## 2 Setup\n"},
+		{NewTextRenderer(opts), "2 Setup\n"},
+		{NewDokuWikiRenderer(opts), "===== Setup =====\n"},
+	}
+	for _, render := range renders {
+		var out bytes.Buffer
+		if err := render.renderer.Render(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), render.want) {
+			t.Errorf("%T: no %q in:\n%s", render.renderer, render.want, out.String())
+		}
+	}
+}
+
+func TestExplicitAnchors(t *testing.T) {
+	parser := New(WithAnchors())
+	content := "====== Setup ======\nSee {{anchor:setup}} and **{{anchor: My Place }}**.\n"
+	unit := parser.Parse([]byte(content), "target")
+	got := make([]string, 0)
+	for _, anchor := range unit.Anchors() {
+		got = append(got, fmt.Sprintf("%s %q %d %t", anchor.ID, anchor.Text, anchor.Position.Line, anchor.Explicit != nil))
+	}
+	// the explicit anchor keeps its id, the heading gets a number.
+	want := []string{`setup1 "Setup" 1 false`, `setup "setup" 2 true`, `my_place "My Place" 2 true`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got anchors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if toc := unit.TOC(); len(toc) != 1 || toc[0].Anchor != "setup1" {
+		t.Errorf("got TOC %+v", toc)
+	}
+
+	var out bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	if html := out.String(); !strings.Contains(html, `<h1 id="setup1">`) || !strings.Contains(html, `See <span id="setup"></span> and <span id="my_place"></span>.`) {
+		t.Errorf("got html\n%s", html)
+	}
+	out.Reset()
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "See {{anchor:setup}} and {{anchor:My Place}}.") {
+		t.Errorf("got wiki text\n%s", out.String())
+	}
+
+	pages := map[string]*ParseUnit{
+		"target": unit,
+		"from":   parser.Parse([]byte("[[target#My Place]] [[target#setup1]] [[target#nowhere]]\n"), "from"),
+	}
+	broken := CheckLinks(pages, nil)
+	if len(broken) != 1 || broken[0].Anchor != "nowhere" {
+		t.Errorf("got broken links %+v", broken)
+	}
+
+	// a name used twice gets a number like a heading.
+	unit = parser.Parse([]byte("{{anchor:here}}\n\n== Here ==\n\n{{anchor:here}}\n"), "t")
+	got = got[:0]
+	for _, anchor := range unit.Anchors() {
+		got = append(got, anchor.ID)
+	}
+	if want := []string{"here", "here2", "here1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ids %v, want %v", got, want)
+	}
+	out.Reset()
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	if html := out.String(); strings.Count(html, `<span id="here"></span>`) != 1 || !strings.Contains(html, `<span id="here1"></span>`) {
+		t.Errorf("got html\n%s", html)
+	}
+
+	// without the option it is media.
+	if _, ok := Parse([]byte("{{anchor:setup}}"), "t").Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext); !ok {
+		t.Error("{{anchor:setup}} is not media without ParseOptions.Anchors")
+	}
+}
@@ -0,0 +1,72 @@
+package dokuwiki
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultAutolinkSchemes are the schemes of the bare URLs linked when ParseOptions.AutolinkSchemes is nil.
+var DefaultAutolinkSchemes = []string{"http", "https", "ftp", "ftps"}
+
+// autolinkRegexps caches the URL pattern of every list of schemes, keyed by the joined schemes
+// and whether www. hosts are linked.
+var autolinkRegexps = struct {
+	sync.Mutex
+	byKey map[string]*regexp.Regexp
+}{byKey: make(map[string]*regexp.Regexp)}
+
+// autolinkRegexp returns the pattern of the bare URLs with one of schemes, and of the hosts
+// starting with www. when www is true. It is nil when there is nothing to link.
+func autolinkRegexp(schemes []string, www bool) *regexp.Regexp {
+	if schemes == nil {
+		schemes = DefaultAutolinkSchemes
+	}
+	if len(schemes) == 0 && !www {
+		return nil
+	}
+	key := strings.Join(schemes, "\n") + "\n" + strconv.FormatBool(www)
+	autolinkRegexps.Lock()
+	defer autolinkRegexps.Unlock()
+	if re := autolinkRegexps.byKey[key]; re != nil {
+		return re
+	}
+	patterns := make([]string, 0, 2)
+	if len(schemes) > 0 {
+		quoted := make([]string, 0, len(schemes))
+		for _, scheme := range schemes {
+			quoted = append(quoted, regexp.QuoteMeta(scheme))
+		}
+		patterns = append(patterns, `\b(?i:`+strings.Join(quoted, "|")+`)://[^\s/$.?#].[^\s]*`)
+	}
+	if www {
+		patterns = append(patterns, `\bwww\.[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)+(?:[/?#][^\s]*)?`)
+	}
+	re := regexp.MustCompile(strings.Join(patterns, "|"))
+	autolinkRegexps.byKey[key] = re
+	return re
+}
+
+// findAutolinks returns the places in text of the URLs to link, in order, a host starting with
+// www. is only taken at the start of a word, not after a dot or a letter. The text is scanned
+// once, the punctuation left after a URL cannot start another one.
+func findAutolinks(text string, validURL *regexp.Regexp) [][2]int {
+	var links [][2]int
+	for _, match := range validURL.FindAllStringIndex(text, -1) {
+		if strings.HasPrefix(text[match[0]:], "www.") && match[0] > 0 && !strings.ContainsRune(" \t([{<\"'", rune(text[match[0]-1])) {
+			continue
+		}
+		// the punctuation after the URL is kept as text.
+		links = append(links, [2]int{match[0], match[0] + trimURL(text[match[0]:match[1]])})
+	}
+	return links
+}
+
+// autolinkTarget is the target of an autolinked URL, a www. host is linked with http.
+func autolinkTarget(url string) string {
+	if strings.HasPrefix(url, "www.") {
+		return "http://" + url
+	}
+	return url
+}
@@ -0,0 +1,122 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// autolinks returns the targets of the bare URLs linked in content.
+func autolinks(content string, options ParseOptions) []string {
+	targets := make([]string, 0)
+	for _, link := range ParseWithOptions([]byte(content), "t", options).Links() {
+		if link.IsAutoLink {
+			targets = append(targets, link.Target)
+		}
+	}
+	return targets
+}
+
+func TestAutolinkSchemes(t *testing.T) {
+	content := "http://a.example HTTPS://b.example ftps://c.example gopher://d.example sftp://e.example irc://f.example"
+	tests := []struct {
+		options ParseOptions
+		want    []string
+	}{
+		{ParseOptions{}, []string{"http://a.example", "HTTPS://b.example", "ftps://c.example"}},
+		{ParseOptions{AutolinkSchemes: []string{"gopher", "IRC"}}, []string{"gopher://d.example", "irc://f.example"}},
+		{ParseOptions{AutolinkSchemes: []string{}}, []string{}},
+		{ParseOptions{NoAutolink: true}, []string{}},
+	}
+	for _, test := range tests {
+		if got := autolinks(content, test.options); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("with %v got %q, want %q", test.options.AutolinkSchemes, got, test.want)
+		}
+	}
+}
+
+func TestAutolinkTrailingPunctuation(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"see https://example.com/page.", []string{"https://example.com/page"}},
+		{"(https://example.com) and https://example.com/a?b=c!", []string{"https://example.com", "https://example.com/a?b=c"}},
+		{"https://en.wikipedia.org/wiki/Go_(programming_language)", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"(see https://en.wikipedia.org/wiki/Go_(programming_language)).", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"[ftp://example.com/x], gopher://example.com/1;", []string{"ftp://example.com/x"}},
+		{"{https://example.com/{id}}:", []string{"https://example.com/{id}"}},
+	}
+	for _, test := range tests {
+		if got := autolinks(test.content, ParseOptions{}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q linked %q, want %q", test.content, got, test.want)
+		}
+	}
+
+	inlines := Parse([]byte("see https://example.com/page."), "t").Sections[0].(*ParaContext).InnerContexts
+	if text, ok := inlines[len(inlines)-1].(*TextEffectContext); !ok || text.Text != "." {
+		t.Errorf("the period is not kept as text: %+v", inlines[len(inlines)-1])
+	}
+}
+
+func TestAutolinkWWW(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"visit www.example.com.", []string{"http://www.example.com"}},
+		{"(www.example.org/a/b?c=d) and www.sub.example.net/x#y!", []string{"http://www.example.org/a/b?c=d", "http://www.sub.example.net/x#y"}},
+		{"awww.example.com www. wwwexample.com www.localhost foo.www.example.com", []string{}},
+		{"https://www.example.com", []string{"https://www.example.com"}},
+	}
+	for _, test := range tests {
+		if got := autolinks(test.content, ParseOptions{}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q linked %q, want %q", test.content, got, test.want)
+		}
+	}
+	if got := autolinks("www.example.com", ParseOptions{NoWWWAutolink: true}); len(got) != 0 {
+		t.Errorf("NoWWWAutolink linked %q", got)
+	}
+	if got := autolinks("www.example.com", ParseOptions{AutolinkSchemes: []string{}}); !reflect.DeepEqual(got, []string{"http://www.example.com"}) {
+		t.Errorf("without schemes linked %q", got)
+	}
+
+	link := Parse([]byte("www.example.com"), "t").Sections[0].(*ParaContext).InnerContexts[0].(*HyperLinkContext)
+	if link.Text != "www.example.com" || link.HyperLink != "http://www.example.com" {
+		t.Errorf("got text %q and link %q", link.Text, link.HyperLink)
+	}
+}
+
+// TestAutolinkScales checks that linking the URLs of a paragraph allocates in proportion to the
+// paragraph, four times the URLs may not take much more than four times the bytes.
+func TestAutolinkScales(t *testing.T) {
+	allocated := func(n int, parse func(content []byte) int) uint64 {
+		content := []byte(strings.Repeat("see http://example.com/x and ", n))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if got := parse(content); got != n {
+			t.Fatalf("%d URLs linked %d times", n, got)
+		}
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+	parse := func(content []byte) int {
+		return len(Parse(content, "t").Links())
+	}
+	lex := func(content []byte) int {
+		links := 0
+		for _, token := range Lex(content) {
+			if token.Kind == TokenAutoLink {
+				links++
+			}
+		}
+		return links
+	}
+	for name, f := range map[string]func([]byte) int{"Parse": parse, "Lex": lex} {
+		small, large := allocated(1000, f), allocated(4000, f)
+		if large > 8*small {
+			t.Errorf("%s: 1000 URLs allocated %d bytes, 4000 URLs %d", name, small, large)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package dokuwiki
+
+import "sort"
+
+// Backlink is a link from one page to another.
+type Backlink struct {
+	// From is the ID of the page the link is on.
+	From string
+	// Anchor is the section the link points to, without the #.
+	Anchor   string
+	Position Position
+	Link     *HyperLinkContext
+}
+
+// BuildBacklinks indexes the internal links of pages, which are keyed by page ID, by the page
+// they point to. Link targets are resolved relative to the page they are on, like DokuWiki does,
+// and cleaned, links to pages that are not in pages are kept too. Backlinks are sorted by the ID
+// of the linking page, then by their order in that page.
+func BuildBacklinks(pages map[string]*ParseUnit) map[string][]Backlink {
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	exists := func(id string) bool {
+		_, ok := pages[id]
+		return ok
+	}
+
+	backlinks := make(map[string][]Backlink)
+	for _, from := range ids {
+		for _, link := range pages[from].Links() {
+			if link.Kind != LinkInternal {
+				continue
+			}
+			target, anchor := resolveID(from, link.Target, exists)
+			backlinks[target] = append(backlinks[target], Backlink{
+				From:     from,
+				Anchor:   anchor,
+				Position: link.Position,
+				Link:     link.Link,
+			})
+		}
+	}
+	return backlinks
+}
@@ -0,0 +1,37 @@
+package dokuwiki
+
+import "testing"
+
+func TestBuildBacklinks(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:start":  Parse([]byte("See [[syntax]] and [[:wiki:syntax#links|links]].\n\n  * [[missing]] [[https://example.com]]\n"), "start"),
+		"wiki:syntax": Parse([]byte("Back to [[start]].\n"), "syntax"),
+		"other":       Parse([]byte("[[wiki:Syntax]]\n"), "other"),
+	}
+	backlinks := BuildBacklinks(pages)
+	if len(backlinks) != 3 {
+		t.Errorf("unexpected targets %v", backlinks)
+	}
+
+	syntax := backlinks["wiki:syntax"]
+	want := []Backlink{
+		{From: "other", Position: Position{Line: 1}},
+		{From: "wiki:start", Position: Position{Line: 1}},
+		{From: "wiki:start", Anchor: "links", Position: Position{Line: 1}},
+	}
+	if len(syntax) != len(want) {
+		t.Fatalf("got %+v, want %+v", syntax, want)
+	}
+	for i, backlink := range syntax {
+		backlink.Link = nil
+		if backlink != want[i] {
+			t.Errorf("backlink %d is %+v, want %+v", i, backlink, want[i])
+		}
+	}
+	if missing := backlinks["wiki:missing"]; len(missing) != 1 || missing[0].Position.Line != 3 {
+		t.Errorf("unexpected backlinks to a missing page %+v", missing)
+	}
+	if start := backlinks["wiki:start"]; len(start) != 1 || start[0].From != "wiki:syntax" {
+		t.Errorf("unexpected backlinks %+v", start)
+	}
+}
@@ -0,0 +1,78 @@
+package dokuwiki
+
+import "strings"
+
+// BlockSlice is a block of a unit with the part of the input it was parsed from.
+type BlockSlice struct {
+	Block BlockContext
+	// Source is the bytes of the input the block was parsed from, as they were written, tags and
+	// all, and Span is where they are in the input. For a heading, a paragraph or a list they are
+	// its lines, without the last line ending, for the paragraph of a list item, a term or a
+	// definition only its text, without the marks of the list. The paragraph of a quote goes from
+	// its first byte to its last one, the > of its lines after the first included. Source is nil and Span zero when
+	// it is not known, like for blocks built by hand.
+	Source []byte
+	Span   Span
+}
+
+// BlocksWithSource returns the blocks of the unit with their source, in the order of the input:
+// a list comes before its items and sub lists, a definition list before its terms and
+// definitions. Tools changing the text of a page, like a translation, can replace the source of
+// the paragraphs in the content, from the last one to the first so the spans before stay valid,
+// and leave the rest of the page as it was written.
+//
+// The spans are the ones of the input the unit was parsed from, the blocks changed or moved
+// afterwards keep theirs.
+func (unit *ParseUnit) BlocksWithSource() []BlockSlice {
+	slices := make([]BlockSlice, 0, len(unit.Sections))
+	var add func(blocks []BlockContext)
+	addPara := func(para *ParaContext) {
+		if para != nil {
+			add([]BlockContext{para})
+		}
+	}
+	add = func(blocks []BlockContext) {
+		for _, block := range blocks {
+			if block == nil {
+				continue
+			}
+			slice := BlockSlice{Block: block}
+			if spanned, ok := block.(interface{ sourceSpan() Span }); ok {
+				slice.Span = sourceSpan(unit.source, spanned.sourceSpan())
+				if slice.Span != (Span{}) {
+					slice.Source = []byte(unit.source[slice.Span.Start:slice.Span.End])
+				}
+			}
+			slices = append(slices, slice)
+			switch b := block.(type) {
+			case *ListContext:
+				add(b.InnerContexts)
+			case *ListItemContext:
+				add(b.InnerContexts)
+			case *QuoteContext:
+				add(b.InnerContexts)
+			case *DefinitionListContext:
+				for _, entry := range b.Entries {
+					addPara(entry.Term)
+					for _, definition := range entry.Definitions {
+						addPara(definition)
+					}
+				}
+			}
+		}
+	}
+	add(unit.Sections)
+	return slices
+}
+
+// sourceSpan returns span within source, zero when it is not in it, without the line ending of its
+// last line, "\r\n" included. The block of a tag left open takes the rest of the input, its span
+// ends after the new line appended by the parser.
+func sourceSpan(source string, span Span) Span {
+	if span.End == 0 || span.Start >= len(source) {
+		return Span{}
+	}
+	span.End = min(span.End, len(source))
+	span.End = span.Start + len(strings.TrimRight(source[span.Start:span.End], "\r\n"))
+	return span
+}
@@ -0,0 +1,135 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const blocksPage = `====== Title ======
+
+Some //text// with <code go>
+x := 1
+</code> in it.
+
+  * first item
+    * nested <nowiki>**item**</nowiki>
+  - ordered
+  ; term : its definition
+  : another one
+`
+
+func blockSlicesString(slices []BlockSlice) string {
+	var b strings.Builder
+	for _, slice := range slices {
+		fmt.Fprintf(&b, "%T %d-%d %q\n", slice.Block, slice.Span.Start, slice.Span.End, slice.Source)
+	}
+	return b.String()
+}
+
+func TestBlocksWithSource(t *testing.T) {
+	unit := New(WithDefinitionLists()).Parse([]byte(blocksPage), "t")
+	got := blockSlicesString(unit.BlocksWithSource())
+	want := `*dokuwiki.SectionHeaderContext 0-19 "====== Title ======"
+*dokuwiki.ParaContext 21-71 "Some //text// with <code go>\nx := 1\n</code> in it."
+*dokuwiki.ListContext 73-126 "  * first item\n    * nested <nowiki>**item**</nowiki>"
+*dokuwiki.ListItemContext 73-126 "  * first item\n    * nested <nowiki>**item**</nowiki>"
+*dokuwiki.ParaContext 77-87 "first item"
+*dokuwiki.ListContext 88-126 "    * nested <nowiki>**item**</nowiki>"
+*dokuwiki.ListItemContext 88-126 "    * nested <nowiki>**item**</nowiki>"
+*dokuwiki.ParaContext 94-126 "nested <nowiki>**item**</nowiki>"
+*dokuwiki.ListContext 127-138 "  - ordered"
+*dokuwiki.ListItemContext 127-138 "  - ordered"
+*dokuwiki.ParaContext 131-138 "ordered"
+*dokuwiki.DefinitionListContext 139-180 "  ; term : its definition\n  : another one"
+*dokuwiki.ParaContext 143-147 "term"
+*dokuwiki.ParaContext 150-164 "its definition"
+*dokuwiki.ParaContext 169-180 "another one"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the blocks built by hand have no source.
+	para := &ParaContext{}
+	slices := (&ParseUnit{Sections: []BlockContext{para}}).BlocksWithSource()
+	if len(slices) != 1 || slices[0].Block != para || slices[0].Source != nil || slices[0].Span != (Span{}) {
+		t.Errorf("got %+v", slices)
+	}
+
+	// an unclosed tag takes the rest of the input.
+	got = blockSlicesString(Parse([]byte("text <code>\nx\n\n"), "t").BlocksWithSource())
+	if want := "*dokuwiki.ParaContext 0-13 \"text <code>\\nx\"\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// the line endings of windows and the > of an empty last line of a quote are not in the source.
+	got = blockSlicesString(Parse([]byte("a\r\nb\r\n\r\n  * c\r\n\r\n> d\r\n> e\r\n>\r\n"), "t").BlocksWithSource())
+	want = `*dokuwiki.ParaContext 0-4 "a\r\nb"
+*dokuwiki.ListContext 8-13 "  * c"
+*dokuwiki.ListItemContext 8-13 "  * c"
+*dokuwiki.ParaContext 12-13 "c"
+*dokuwiki.QuoteContext 17-28 "> d\r\n> e\r\n>"
+*dokuwiki.ParaContext 19-25 "d\r\n> e"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestBlocksWithSourceTranslation changes the text of the paragraphs in the source and parses it
+// again, like a translation would, the rest of the page is left as written.
+func TestBlocksWithSourceTranslation(t *testing.T) {
+	translate := strings.NewReplacer("text", "texte", "item", "élément", "ordered", "ordonné", "definition", "définition")
+	parser := New(WithDefinitionLists())
+	source := []byte(blocksPage)
+	slices := parser.Parse(source, "t").BlocksWithSource()
+	for i := len(slices) - 1; i >= 0; i-- {
+		if _, ok := slices[i].Block.(*ParaContext); !ok {
+			continue
+		}
+		span := slices[i].Span
+		translated := translate.Replace(string(slices[i].Source))
+		source = append(source[:span.Start:span.Start], append([]byte(translated), source[span.End:]...)...)
+	}
+	if want := translate.Replace(blocksPage); string(source) != want {
+		t.Errorf("got\n%s\nwant\n%s", source, want)
+	}
+	if got := len(parser.Parse(source, "t").BlocksWithSource()); got != len(slices) {
+		t.Errorf("the translated page has %d blocks, not %d", got, len(slices))
+	}
+}
+
+func TestBlocksWithSourceKept(t *testing.T) {
+	want := blockSlicesString(Parse([]byte("intro\n\n"+blocksPage), "t").BlocksWithSource())
+
+	// the blocks reused by an incremental parse are moved.
+	inc := NewIncremental("t", ParseOptions{})
+	inc.Update([]byte(blocksPage))
+	unit, _ := inc.Update([]byte("intro\n\n" + blocksPage))
+	if got := blockSlicesString(unit.BlocksWithSource()); got != want {
+		t.Errorf("incremental parse got\n%s\nwant\n%s", got, want)
+	}
+
+	var encoded bytes.Buffer
+	if err := EncodeUnit(&encoded, unit); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeUnit(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := blockSlicesString(decoded.BlocksWithSource()); got != want {
+		t.Errorf("decoded unit got\n%s\nwant\n%s", got, want)
+	}
+
+	// the blocks moved to another unit lose their source.
+	unit = Parse([]byte("====== A ======\nfirst\n"), "t")
+	if err := unit.ReplaceSection("a", Parse([]byte("====== B ======\nsecond\n"), "t")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := blockSlicesString(unit.BlocksWithSource()), "*dokuwiki.SectionHeaderContext 0-0 \"\"\n*dokuwiki.ParaContext 0-0 \"\"\n"; got != want {
+		t.Errorf("replaced section got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package dokuwiki
+
+import "sort"
+
+// BrokenLink is an internal link to a page or a section that does not exist.
+type BrokenLink struct {
+	// From is the ID of the page the link is on.
+	From string
+	// Target is the link target as written, Text what the link displays.
+	Target string
+	Text   string
+	// PageID and Anchor are the resolved target.
+	PageID string
+	Anchor string
+	// MissingAnchor is true when the page exists but has no heading nor explicit anchor for Anchor.
+	MissingAnchor bool
+	Position      Position
+}
+
+// CheckLinks reports the internal links of pages, which are keyed by page ID, whose target
+// page does not exist according to exists. Anchors are checked against the headings and the
+// explicit anchors of the target page when it is one of pages. Targets are resolved like BuildBacklinks does. When
+// exists is nil a page exists if it is in pages.
+//
+// Broken links are sorted by the ID of the linking page, then by their order in that page.
+func CheckLinks(pages map[string]*ParseUnit, exists func(pageID string) bool) []BrokenLink {
+	if exists == nil {
+		exists = func(pageID string) bool {
+			_, ok := pages[pageID]
+			return ok
+		}
+	}
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// anchors of the pages, collected when first needed.
+	anchors := make(map[string]map[string]bool)
+	hasAnchor := func(pageID, anchor string) bool {
+		if anchors[pageID] == nil {
+			anchors[pageID] = make(map[string]bool)
+			for _, anchor := range pages[pageID].Anchors() {
+				anchors[pageID][anchor.ID] = true
+			}
+		}
+		return anchors[pageID][sectionID(anchor, make(map[string]bool))]
+	}
+
+	broken := make([]BrokenLink, 0)
+	for _, from := range ids {
+		for _, link := range pages[from].Links() {
+			if link.Kind != LinkInternal {
+				continue
+			}
+			pageID, anchor := resolveID(from, link.Target, exists)
+			report := BrokenLink{
+				From:     from,
+				Target:   link.Target,
+				Text:     link.Text,
+				PageID:   pageID,
+				Anchor:   anchor,
+				Position: link.Position,
+			}
+			if !exists(pageID) {
+				broken = append(broken, report)
+			} else if _, known := pages[pageID]; known && anchor != "" && !hasAnchor(pageID, anchor) {
+				report.MissingAnchor = true
+				broken = append(broken, report)
+			}
+		}
+	}
+	return broken
+}
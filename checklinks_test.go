@@ -0,0 +1,30 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:start":  Parse([]byte("====== Start ======\n[[syntax#Links]] [[syntax#tables|Tables]] [[nowhere]]\n\n[[#intro]] [[#Start]] [[remote]]\n"), "start"),
+		"wiki:syntax": Parse([]byte("====== Syntax ======\n===== Links =====\n"), "syntax"),
+	}
+
+	// wiki:remote exists but was not parsed, its anchors can not be checked.
+	exists := func(pageID string) bool {
+		return pages[pageID] != nil || pageID == "wiki:remote"
+	}
+	want := []BrokenLink{
+		{From: "wiki:start", Target: "syntax#tables", Text: "Tables", PageID: "wiki:syntax", Anchor: "tables", MissingAnchor: true, Position: Position{Line: 2}},
+		{From: "wiki:start", Target: "nowhere", Text: "nowhere", PageID: "wiki:nowhere", Position: Position{Line: 2}},
+		{From: "wiki:start", Target: "#intro", Text: "#intro", PageID: "wiki:start", Anchor: "intro", MissingAnchor: true, Position: Position{Line: 4}},
+	}
+	if got := CheckLinks(pages, exists); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if got := CheckLinks(pages, nil); len(got) != 4 || got[3].PageID != "wiki:remote" {
+		t.Errorf("unexpected broken links %+v", got)
+	}
+}
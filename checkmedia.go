@@ -0,0 +1,134 @@
+package dokuwiki
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MissingMedia is a media reference whose file does not exist.
+type MissingMedia struct {
+	// From is the ID of the page the reference is on.
+	From string
+	// ID is the media ID as written, or the URL of external media.
+	ID string
+	// MediaID and Path are the resolved media ID and the path its file was looked for at,
+	// they are empty for external media.
+	MediaID string
+	Path    string
+	// Status is the HTTP status answered for external media, 0 when the request failed.
+	Status   int
+	Position Position
+}
+
+// MediaCheckOptions configures CheckMediaWithOptions, the zero value skips external media.
+type MediaCheckOptions struct {
+	// HTTPClient, when set, is used to check external media with HEAD requests, the answers
+	// with a status of 400 or more are reported.
+	HTTPClient *http.Client
+	// Concurrency is the number of requests made at the same time, 4 when zero.
+	Concurrency int
+	// Timeout limits every request, 10 seconds when zero.
+	Timeout time.Duration
+}
+
+// CheckMedia reports the internal media references of pages, which are keyed by page ID, whose
+// file is missing in fsys. Media IDs are resolved relatively to the namespace of their page like
+// page links are, the ResolvedID of the media is used when the page was parsed with its PageID,
+// and looked for below mediaRoot with DokuWiki's layout: "wiki:logo.png" is
+// stored in "wiki/logo.png". External media is skipped.
+//
+// Missing media is sorted by the ID of the page, then by its order in that page.
+func CheckMedia(pages map[string]*ParseUnit, fsys fs.FS, mediaRoot string) []MissingMedia {
+	return CheckMediaWithOptions(pages, fsys, mediaRoot, MediaCheckOptions{})
+}
+
+func CheckMediaWithOptions(pages map[string]*ParseUnit, fsys fs.FS, mediaRoot string, opts MediaCheckOptions) []MissingMedia {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if mediaRoot == "" {
+		mediaRoot = "."
+	}
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// reports has an entry for every reference, those in broken are returned.
+	reports := make([]MissingMedia, 0)
+	broken := make([]bool, 0)
+	// urls lists the indices in reports of the references to every external URL.
+	urls := make(map[string][]int)
+	for _, from := range ids {
+		for _, ref := range pages[from].MediaRefs() {
+			report := MissingMedia{From: from, ID: ref.Raw, Position: ref.Position}
+			missing := false
+			if ref.IsExternal {
+				if opts.HTTPClient != nil {
+					urls[ref.ID] = append(urls[ref.ID], len(reports))
+				}
+			} else {
+				report.MediaID = ref.ID
+				if ref.Media.ResolvedID == "" {
+					report.MediaID = ResolvePageID(from, ref.Raw)
+				}
+				report.Path = path.Join(mediaRoot, strings.Replace(report.MediaID, ":", "/", -1))
+				_, err := fs.Stat(fsys, report.Path)
+				missing = err != nil
+			}
+			reports = append(reports, report)
+			broken = append(broken, missing)
+		}
+	}
+
+	// every URL is requested once, the goroutines write to different references.
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, opts.Concurrency)
+	for url, indices := range urls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(url string, indices []int) {
+			defer func() { <-slots; wg.Done() }()
+			status := headStatus(opts.HTTPClient, url, opts.Timeout)
+			for _, i := range indices {
+				reports[i].Status = status
+				broken[i] = status == 0 || status >= 400
+			}
+		}(url, indices)
+	}
+	wg.Wait()
+
+	result := make([]MissingMedia, 0)
+	for i, report := range reports {
+		if broken[i] {
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+// headStatus makes a HEAD request to url and returns the status, 0 when the request failed.
+func headStatus(client *http.Client, url string, timeout time.Duration) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,44 @@
+package dokuwiki
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckMedia(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("got a %s request", r.Method)
+		}
+		if r.URL.Path != "/ok.png" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fsys := fstest.MapFS{
+		"data/media/wiki/logo.png":  {},
+		"data/media/shared/pic.jpg": {},
+	}
+	pages := map[string]*ParseUnit{
+		"wiki:start": Parse([]byte("{{logo.png}} {{:shared:pic.jpg}} {{Missing File.png?20}}\n\n[[page|{{..:gone.gif}}]]\n"), "start"),
+		"other":      Parse([]byte("{{"+server.URL+"/ok.png}} {{"+server.URL+"/dead.png}}\n"), "other"),
+	}
+
+	want := []MissingMedia{
+		{From: "wiki:start", ID: "Missing File.png", MediaID: "wiki:missing_file.png", Path: "data/media/wiki/missing_file.png", Position: Position{Line: 1}},
+		{From: "wiki:start", ID: "..:gone.gif", MediaID: "gone.gif", Path: "data/media/gone.gif", Position: Position{Line: 3}},
+	}
+	if got := CheckMedia(pages, fsys, "data/media"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	got := CheckMediaWithOptions(pages, fsys, "data/media", MediaCheckOptions{HTTPClient: server.Client(), Concurrency: 1})
+	want = append([]MissingMedia{{From: "other", ID: server.URL + "/dead.png", Status: http.StatusNotFound, Position: Position{Line: 1}}}, want...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with external media: got %+v\nwant %+v", got, want)
+	}
+}
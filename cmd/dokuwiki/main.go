@@ -0,0 +1,253 @@
+// Command dokuwiki converts and inspects DokuWiki pages.
+//
+// Usage:
+//
+//	dokuwiki render [-f html|markdown|text|latex|dokuwiki] [-base-url url] [-heading-offset n]
+//	                [-sanitize escape|strip|none] [-o output] [-strict] [input]
+//	dokuwiki toc    [-o output] [-strict] [input]
+//	dokuwiki links  [-o output] [-strict] [input]
+//	dokuwiki dump   [-json] [-o output] [-strict] [input]
+//	dokuwiki lint   [-severity info|warning|error] [-disable rule,...] [-format text|json|sarif]
+//	                [-portability target] [-o output] [-strict] [input]
+//
+// The input defaults to stdin and the output to stdout, "-" means the same for both.
+//
+// Exit status is 0 on success, 1 when -strict is given and the parser reported
+// diagnostics or when lint found problems of at least the -severity given, 2 for
+// usage errors, 3 for I/O errors, reading the input or writing the output, and 4 when rendering or
+// encoding the page fails. Diagnostics are always printed to stderr. The json and
+// sarif formats of lint are sorted by position, so their output is stable for CI. dump -json
+// writes the tree with EncodeUnitJSON, with its ASTVersion. lint -portability also warns of
+// what the renderer of the target, html, markdown, text, latex or dokuwiki, can not write, see
+// AnalyzePortability.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+const (
+	exitOK          = 0
+	exitDiagnostics = 1
+	exitUsage       = 2
+	exitIO          = 3
+	exitRender      = 4
+)
+
+const usage = `usage: dokuwiki <command> [flags] [input]
+
+commands:
+  render  render the page, see dokuwiki render -h for the formats
+  toc     print the headings with their anchors
+  links   print the links, one per line
+  dump    print the parsed tree
+  lint    check the page for common problems
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("dokuwiki "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "-", "output file, - for stdout")
+	strict := flags.Bool("strict", false, "exit with status 1 when the parser reports diagnostics")
+
+	var format, sanitize, severity, disable, findingsFormat, portability string
+	var dumpJSON bool
+	var opts dokuwiki.RendererOptions
+	switch command {
+	case "render":
+		flags.StringVar(&format, "f", "html", "output format: html, markdown, text, latex or dokuwiki")
+		flags.StringVar(&opts.BaseURL, "base-url", "", "prefix of internal page and media links")
+		flags.IntVar(&opts.HeadingOffset, "heading-offset", 0, "push headings down by this many levels")
+		flags.StringVar(&sanitize, "sanitize", "escape", "embedded html handling: escape, strip or none")
+	case "lint":
+		flags.StringVar(&severity, "severity", "warning", "lowest severity making the exit status 1: info, warning or error")
+		flags.StringVar(&disable, "disable", "", "comma separated IDs of the rules to skip")
+		flags.StringVar(&findingsFormat, "format", "text", "output format: text, json or sarif")
+		flags.StringVar(&portability, "portability", "", "also check the portability to a target: "+strings.Join(dokuwiki.PortabilityTargets(), ", "))
+	case "dump":
+		flags.BoolVar(&dumpJSON, "json", false, "write the tree as JSON, with its AST version")
+	case "toc", "links":
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "dokuwiki: unknown command %q\n%s", command, usage)
+		return exitUsage
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintf(stderr, "dokuwiki %s: only one input can be given\n", command)
+		return exitUsage
+	}
+
+	var renderer dokuwiki.Renderer
+	if command == "render" {
+		mode, err := dokuwiki.ParseSanitizeMode(sanitize)
+		if err != nil {
+			fmt.Fprintf(stderr, "dokuwiki render: %v\n", err)
+			return exitUsage
+		}
+		opts.Sanitize = mode
+		if renderer = newRenderer(format, opts); renderer == nil {
+			fmt.Fprintf(stderr, "dokuwiki render: unknown format %q\n", format)
+			return exitUsage
+		}
+	}
+
+	var threshold dokuwiki.Severity
+	if command == "lint" {
+		var err error
+		if threshold, err = dokuwiki.ParseSeverity(severity); err != nil {
+			fmt.Fprintf(stderr, "dokuwiki lint: %v\n", err)
+			return exitUsage
+		}
+		if findingsFormat != "text" && findingsFormat != "json" && findingsFormat != "sarif" {
+			fmt.Fprintf(stderr, "dokuwiki lint: unknown format %q\n", findingsFormat)
+			return exitUsage
+		}
+		if portability != "" && !knownPortabilityTarget(portability) {
+			fmt.Fprintf(stderr, "dokuwiki lint: unknown portability target %q\n", portability)
+			return exitUsage
+		}
+	}
+
+	input := flags.Arg(0)
+	content, err := readInput(input, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "dokuwiki %s: %v\n", command, err)
+		return exitIO
+	}
+	title := "stdin"
+	if input != "" && input != "-" {
+		title = filepath.Base(input)
+	}
+	unit := dokuwiki.Parse(content, title)
+
+	var out bytes.Buffer
+	failed := false
+	switch command {
+	case "render":
+		err = renderer.Render(&out, unit)
+	case "toc":
+		for _, entry := range unit.TOC() {
+			fmt.Fprintf(&out, "%s%s #%s\n", strings.Repeat("  ", 6-entry.HeaderLevel), entry.Text, entry.Anchor)
+		}
+	case "links":
+		for _, link := range unit.Links() {
+			fmt.Fprintf(&out, "%s\t%s\n", link.Kind, link.Target)
+		}
+	case "dump":
+		if dumpJSON {
+			err = dokuwiki.EncodeUnitJSON(&out, unit)
+		} else {
+			err = dokuwiki.Dump(&out, unit)
+		}
+	case "lint":
+		rules := dokuwiki.DefaultRules()
+		if portability != "" {
+			rules = append(rules, dokuwiki.PortabilityRule(portability, dokuwiki.PortabilityOptions{}))
+		}
+		if disable != "" {
+			rules = dokuwiki.DisableRules(rules, strings.Split(disable, ",")...)
+		}
+		findings := dokuwiki.Lint(unit, rules...)
+		for i, finding := range findings {
+			findings[i].File = title
+			failed = failed || finding.Severity >= threshold
+		}
+		switch findingsFormat {
+		case "json":
+			err = dokuwiki.WriteFindingsJSON(&out, findings)
+		case "sarif":
+			err = dokuwiki.WriteFindingsSARIF(&out, findings, dokuwiki.ToolInfo{Name: "dokuwiki lint"})
+		default:
+			for _, finding := range findings {
+				fmt.Fprintf(&out, "%s:%d: %s: %s [%s]\n", finding.File, finding.Position.Line, finding.Severity, finding.Message, finding.Rule)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "dokuwiki %s: %v\n", command, err)
+		return exitRender
+	}
+	if err := writeOutput(*output, stdout, out.Bytes()); err != nil {
+		fmt.Fprintf(stderr, "dokuwiki %s: %v\n", command, err)
+		return exitIO
+	}
+
+	for _, diagnostic := range unit.Diagnostics {
+		fmt.Fprintf(stderr, "%s: %s: %s [%s]\n", title, diagnostic.Severity, diagnostic.Message, diagnostic.Code)
+	}
+	if failed || (*strict && len(unit.Diagnostics) > 0) {
+		return exitDiagnostics
+	}
+	return exitOK
+}
+
+func knownPortabilityTarget(target string) bool {
+	for _, known := range dokuwiki.PortabilityTargets() {
+		if target == known {
+			return true
+		}
+	}
+	return false
+}
+
+func newRenderer(format string, opts dokuwiki.RendererOptions) dokuwiki.Renderer {
+	switch format {
+	case "html":
+		return dokuwiki.NewHTMLRenderer(opts)
+	case "markdown", "md":
+		return dokuwiki.NewMarkdownRenderer(opts)
+	case "text", "txt":
+		return dokuwiki.NewTextRenderer(opts)
+	case "latex", "tex":
+		return dokuwiki.NewLaTeXRenderer(opts)
+	case "dokuwiki":
+		return dokuwiki.NewDokuWikiRenderer(opts)
+	}
+	return nil
+}
+
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func writeOutput(name string, stdout io.Writer, content []byte) error {
+	if name == "" || name == "-" {
+		_, err := stdout.Write(content)
+		return err
+	}
+	if err := ioutil.WriteFile(name, content, 0644); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+func runWith(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRenderFromStdin(t *testing.T) {
+	code, out, _ := runWith("== Hi ==\n**x**\n", "render", "-f", "markdown", "-")
+	if code != exitOK || out != "##### Hi\n\n**x**\n" {
+		t.Errorf("got %d, %q", code, out)
+	}
+}
+
+func TestRenderToFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.html")
+	if code, _, stderr := runWith("text", "render", "-o", output); code != exitOK {
+		t.Fatalf("got %d: %s", code, stderr)
+	}
+	content, err := ioutil.ReadFile(output)
+	if err != nil || !strings.Contains(string(content), "<p>\ntext\n</p>") {
+		t.Errorf("got %q, %v", content, err)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"nope"}, exitUsage},
+		{[]string{"render", "-f", "pdf"}, exitUsage},
+		{[]string{"render", "-sanitize", "maybe"}, exitUsage},
+		{[]string{"dump", "a", "b"}, exitUsage},
+		{[]string{"dump", filepath.Join(t.TempDir(), "missing.txt")}, exitIO},
+		{[]string{"dump", "-o", filepath.Join(t.TempDir(), "missing", "out.txt")}, exitIO},
+		{[]string{"dump"}, exitOK},
+		{[]string{"dump", "-strict"}, exitDiagnostics},
+	}
+	for _, test := range tests {
+		if code, _, _ := runWith("<code go>\nunclosed", test.args...); code != test.want {
+			t.Errorf("%v: got exit code %d, want %d", test.args, code, test.want)
+		}
+	}
+}
+
+func TestLinksAndTOC(t *testing.T) {
+	doc := "====== A ======\n[[page]] http://example.com\n==== B ====\n"
+	if _, out, _ := runWith(doc, "links"); out != "internal\tpage\nexternal\thttp://example.com\n" {
+		t.Errorf("links: got %q", out)
+	}
+	if _, out, _ := runWith(doc, "toc"); out != "A #a\n    B #b\n" {
+		t.Errorf("toc: got %q", out)
+	}
+	_, out, _ := runWith(doc, "dump", "-json")
+	if unit, err := dokuwiki.DecodeUnitJSON(strings.NewReader(out)); err != nil || len(unit.Sections) != 3 {
+		t.Errorf("dump -json: got %v from %q", err, out)
+	}
+}
+
+func TestLint(t *testing.T) {
+	doc := "====== A ======\n== B ==\nsee http://example.com\n"
+	code, out, _ := runWith(doc, "lint")
+	want := "stdin:2: warning: heading \"B\" skips 3 level(s) [heading-levels]\nstdin:3: info: bare URL http://example.com, write it as [[http://example.com|label]] [bare-url]\n"
+	if code != exitDiagnostics || out != want {
+		t.Errorf("got %d, %q", code, out)
+	}
+	if code, _, _ := runWith(doc, "lint", "-disable", "heading-levels"); code != exitOK {
+		t.Errorf("with the warning disabled: got exit code %d", code)
+	}
+	if code, _, _ := runWith(doc, "lint", "-severity", "error"); code != exitOK {
+		t.Errorf("with -severity error: got exit code %d", code)
+	}
+	if code, _, _ := runWith(doc, "lint", "-severity", "fatal"); code != exitUsage {
+		t.Errorf("with an unknown severity: got exit code %d", code)
+	}
+	if code, _, _ := runWith(doc, "lint", "-format", "xml"); code != exitUsage {
+		t.Errorf("with an unknown format: got exit code %d", code)
+	}
+	code, out, _ = runWith(doc, "lint", "-format", "json")
+	if code != exitDiagnostics || !strings.Contains(out, `"file": "stdin",
+    "line": 2,
+    "column": 1,
+    "offset": 16,
+    "length": 7,
+    "rule": "heading-levels"`) {
+		t.Errorf("json: got %d, %s", code, out)
+	}
+	if _, out, _ := runWith(doc, "lint", "-format", "sarif"); !strings.Contains(out, `"version": "2.1.0"`) || !strings.Contains(out, `"ruleId": "bare-url"`) {
+		t.Errorf("sarif: got %s", out)
+	}
+
+	page := "====== A ======\n__a__\n"
+	code, out, _ = runWith(page, "lint", "--portability=markdown")
+	if want := "stdin:2: warning: underlined text \"a\": Markdown has no underline, it is written as an html <u> tag [portability-underline]\n"; code != exitDiagnostics || out != want {
+		t.Errorf("portability: got %d, %q", code, out)
+	}
+	if code, out, _ := runWith(page, "lint", "-portability", "latex"); code != exitOK || out != "" {
+		t.Errorf("portability to latex: got %d, %q", code, out)
+	}
+	if code, _, _ := runWith(doc, "lint", "-portability", "pdf"); code != exitUsage {
+		t.Errorf("with an unknown portability target: got exit code %d", code)
+	}
+}
@@ -0,0 +1,231 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCodeAttributes(t *testing.T) {
+	content := "<code php [enable_line_numbers=\"true\", start_line_numbers_at=\"5\", highlight_lines_extra=\"2,3\", custom=\"a b\"]>\n" +
+		"$a = 1;\n$b = 2;\n$c = $a < $b;\n" +
+		"</code>\n" +
+		"\n" +
+		"<file php f.php [highlight_lines_extra=\"1\"]>\nx\n</file>\n"
+	unit := Parse([]byte(content), "code")
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "code"
+  Para
+    Code lang="php" [custom="a b", enable_line_numbers="true", highlight_lines_extra="2,3", start_line_numbers_at="5"] "$a = 1;\n$b = 2;\n$c = $a < $b;\n"
+  Para
+    File lang="php" name="f.php" [highlight_lines_extra="1"] "x\n"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+
+	var out bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<pre class="code php"><ol start="5"><li>$a = 1;</li><li class="hl">$b = 2;</li><li class="hl">$c = $a &lt; $b;</li></ol></pre>`,
+		`<pre class="code file php"><ol class="nonumbers"><li class="hl">x</li></ol></pre>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("html does not contain %s:\n%s", want, out.String())
+		}
+	}
+
+	var wiki bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit); err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	Dump(&again, Parse(wiki.Bytes(), "code"))
+	if again.String() != want {
+		t.Errorf("round trip gave\n%s", again.String())
+	}
+}
+
+func TestCodeLanguages(t *testing.T) {
+	for _, lang := range []string{"c++", "objective-c", "1c", "html5", "c#", "text/x-abc", "my_lang", "v1.2"} {
+		for _, tag := range []string{"code", "file"} {
+			start := "<" + tag + " " + lang + ">"
+			if tag == "file" {
+				start = "<" + tag + " " + lang + " a.txt>"
+			}
+			unit := Parse([]byte(start+"\n**x**\n</"+tag+">\n"), "t")
+			code, ok := unit.Sections[0].(*ParaContext).InnerContexts[0].(*CodeFileContext)
+			if !ok || code.Language != lang || code.Text != "**x**\n" {
+				t.Errorf("%s: got %#v", start, unit.Sections[0].(*ParaContext).InnerContexts[0])
+			}
+		}
+	}
+
+	// a C++ snippet used to be parsed as wiki text, its comments in italics.
+	content := "<code c++>\n" +
+		"int main() { // entry point\n" +
+		"  return a**b; // not bold\n" +
+		"}\n" +
+		"</code>\n"
+	var dump bytes.Buffer
+	if err := Dump(&dump, Parse([]byte(content), "cpp")); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "cpp"
+  Para
+    Code lang="c++" "int main() { // entry point\n  return a**b; // not bold\n}\n"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+
+	// the language and the file name are optional, a file without a name is only its pre.
+	var out bytes.Buffer
+	if err := Render(Parse([]byte("<code>\n**x**\n</code>\n<file php>\ny\n</file>\n"), "t"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<pre class=\"code\">**x**\n</pre>\n<pre class=\"code file php\">y\n</pre>\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("got html\n%s\nwant %q", out.String(), want)
+	}
+}
+
+func TestTagCase(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"<Code java>x</CODE>", `Code lang="java" "x"`},
+		{"<CODE java >x</code >", `Code lang="java" "x"`},
+		{"<FILE txt a.txt>\nx\n</File>", `File lang="txt" name="a.txt" "x\n"`},
+		{"<NOWIKI>**x**</nowiki>", `NoWiki "**x**"`},
+		{"<NoWiki >**x**</NOWIKI  >", `NoWiki "**x**"`},
+		{"<html>x</HTML>", `HTML "x"`},
+		{"<HTML>x</html>", `HTML block "x"`},
+		{"<Html>x</html>", `HTML "x"`},
+		{"< code java>x</code>", `Text "< code java>x</code>"`},
+		{"< nowiki>**x**", `Text "< nowiki>"`},
+	}
+	for _, test := range tests {
+		var dump bytes.Buffer
+		if err := Dump(&dump, Parse([]byte(test.content), "t")); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(dump.String(), "\n"); len(lines) < 3 || strings.TrimSpace(lines[2]) != test.want {
+			t.Errorf("%q parsed as\n%s\nwant %s", test.content, dump.String(), test.want)
+		}
+	}
+}
+
+func TestStrayEndTags(t *testing.T) {
+	for _, tag := range []string{"</code>", "</file>", "</html>", "</HTML>", "</nowiki>", "</Code >"} {
+		for _, content := range []string{tag + " at the start", "in the **middle " + tag + " of** text"} {
+			unit := Parse([]byte(content), "t")
+			var text strings.Builder
+			for _, inline := range unit.Sections[0].(*ParaContext).InnerContexts {
+				text.WriteString(inline.(*TextEffectContext).Text)
+			}
+			if want := strings.ReplaceAll(content, "**", ""); text.String() != want {
+				t.Errorf("%q parsed as %q", content, text.String())
+			}
+			if len(unit.Diagnostics) != 1 || unit.Diagnostics[0].Message != "line 1: stray "+tag+" tag" {
+				t.Errorf("%q gave diagnostics %v", content, unit.Diagnostics)
+			}
+		}
+	}
+
+	// in another tag an end tag is content.
+	unit := Parse([]byte("<nowiki></code></nowiki>\n<code text>\n</file>\n</code>"), "t")
+	if len(unit.Diagnostics) != 0 {
+		t.Errorf("got diagnostics %v", unit.Diagnostics)
+	}
+	inlines := unit.Sections[0].(*ParaContext).InnerContexts
+	if nowiki, ok := inlines[0].(*NoWikiContext); !ok || nowiki.Text != "</code>" {
+		t.Errorf("got %#v", inlines[0])
+	}
+	if code, ok := inlines[2].(*CodeFileContext); !ok || code.Text != "</file>\n" {
+		t.Errorf("got %#v", inlines[2])
+	}
+}
+
+func TestCodeContent(t *testing.T) {
+	tests := []struct {
+		content string
+		keep    bool
+		want    string
+	}{
+		{"<code go>\n\n\tx  \n  \n</code>", false, "\n\tx  \n  \n"},
+		{"<code go>x</code>", false, "x"},
+		{"<code go>\nx\t</code>", false, "x\t"},
+		{"<code go>\r\nx\r\n</code>", false, "x\r\n"},
+		{"<code go>\n\n</code>", false, "\n"},
+		{"<code go>\n\tx\n\n</code>", true, "\n\tx\n\n"},
+		{"<code go>x\ny</code>", true, "x\ny"},
+		{"<file text a.txt>\r\n  a \n</file>\n", true, "\r\n  a \n"},
+		{"<file text a.txt>\n  a \n\tb</file>\n", false, "  a \n\tb"},
+	}
+	for _, test := range tests {
+		unit := NewParser(ParseOptions{KeepCodeNewline: test.keep}).Parse([]byte(test.content), "code")
+		code := unit.Sections[0].(*ParaContext).InnerContexts[0].(*CodeFileContext)
+		if code.Text != test.want {
+			t.Errorf("%q: got %q, want %q", test.content, code.Text, test.want)
+		}
+		var wiki bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+		again := NewParser(ParseOptions{KeepCodeNewline: test.keep}).Parse(wiki.Bytes(), "code").Sections[0].(*ParaContext).InnerContexts[0].(*CodeFileContext)
+		if again.Text != code.Text {
+			t.Errorf("%q: serialized as %q, parsed back %q", test.content, wiki.String(), again.Text)
+		}
+	}
+}
+
+func TestTagsInProtectedTags(t *testing.T) {
+	type tag struct{ kind, start, end string }
+	tags := []tag{
+		{"code", "<code c>", "</code>"},
+		{"code", "<code>", "</code>"},
+		{"file", "<file c f.c>", "</file>"},
+		{"file", "<file>", "</file>"},
+		{"file", "<file php>", "</file>"},
+		{"html", "<html>", "</html>"},
+		{"html", "<HTML>", "</HTML>"},
+		{"nowiki", "<nowiki>", "</nowiki>"},
+	}
+	dumps := map[string]string{
+		"<code c>":     `Code lang="c" %q`,
+		"<code>":       `Code lang="" %q`,
+		"<file c f.c>": `File lang="c" name="f.c" %q`,
+		"<file>":       `File lang="" name="" %q`,
+		"<file php>":   `File lang="php" name="" %q`,
+		"<html>":       `HTML %q`,
+		"<HTML>":       `HTML block %q`,
+		"<nowiki>":     `NoWiki %q`,
+	}
+	for _, outer := range tags {
+		for _, inner := range tags {
+			if inner.kind == outer.kind {
+				continue
+			}
+			for _, text := range []string{"a" + inner.start + "b", "a" + inner.end + "b", inner.end + "\n" + inner.start} {
+				content := outer.start + text + outer.end
+				unit := Parse([]byte(content), "t")
+				var dump bytes.Buffer
+				if err := Dump(&dump, unit); err != nil {
+					t.Fatal(err)
+				}
+				want := fmt.Sprintf(dumps[outer.start], text)
+				if lines := strings.Split(dump.String(), "\n"); len(lines) != 4 || strings.TrimSpace(lines[2]) != want {
+					t.Errorf("%q parsed as\n%s\nwant %s", content, dump.String(), want)
+				}
+				if len(unit.Diagnostics) != 0 {
+					t.Errorf("%q gave diagnostics %v", content, unit.Diagnostics)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package dokuwiki
+
+import (
+	"io"
+	"strings"
+)
+
+// htmlBlockTags are the tags around which white space never shows, it is left out next to them.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "details": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "summary": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "tr": true, "ul": true,
+}
+
+// htmlPreservedTags are the tags whose content is written as is.
+var htmlPreservedTags = map[string]bool{"pre": true, "code": true, "textarea": true, "script": true, "style": true}
+
+// compactWriter writes html with the white space that does not change its rendering left out, for
+// RendererOptions.Compact. A run of white space is written as one space, or not at all next to
+// a block tag. The content of the preserved tags is written as is.
+type compactWriter struct {
+	w io.Writer
+	// tag holds a tag until its >, it is only written once its name is known.
+	tag   []byte
+	inTag bool
+	// space is set by white space not written yet, afterBlock after a block tag and at the start.
+	space      bool
+	afterBlock bool
+	// preserved counts the preserved tags open.
+	preserved int
+	out       []byte
+}
+
+func newCompactWriter(w io.Writer) *compactWriter {
+	return &compactWriter{w: w, afterBlock: true}
+}
+
+func (cw *compactWriter) Write(p []byte) (int, error) {
+	out := cw.out[:0]
+	for _, b := range p {
+		switch {
+		case cw.inTag:
+			cw.tag = append(cw.tag, b)
+			if b == '>' {
+				out = cw.endTag(out)
+			}
+		case b == '<':
+			cw.inTag = true
+			cw.tag = append(cw.tag, b)
+		case cw.preserved > 0:
+			out = append(out, b)
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f':
+			cw.space = true
+		default:
+			if cw.space && !cw.afterBlock {
+				out = append(out, ' ')
+			}
+			cw.space, cw.afterBlock = false, false
+			out = append(out, b)
+		}
+	}
+	cw.out = out
+	if _, err := cw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// endTag appends the tag held to out, with the space before it when it is kept.
+func (cw *compactWriter) endTag(out []byte) []byte {
+	name, closing := htmlTagName(cw.tag)
+	block := htmlBlockTags[name]
+	if cw.space && !block && !cw.afterBlock {
+		out = append(out, ' ')
+	}
+	out = append(out, cw.tag...)
+	if htmlPreservedTags[name] && cw.tag[len(cw.tag)-2] != '/' {
+		if !closing {
+			cw.preserved++
+		} else if cw.preserved > 0 {
+			cw.preserved--
+		}
+	}
+	cw.tag, cw.inTag = cw.tag[:0], false
+	cw.space, cw.afterBlock = false, block
+	return out
+}
+
+// flush writes what is held back, a tag without its >.
+func (cw *compactWriter) flush() error {
+	if !cw.inTag {
+		return nil
+	}
+	_, err := cw.w.Write(cw.tag)
+	cw.tag, cw.inTag = cw.tag[:0], false
+	return err
+}
+
+// htmlTagName returns the name of tag in lower case and whether it is an end tag, the name is
+// empty for comments and the like.
+func htmlTagName(tag []byte) (name string, closing bool) {
+	i := 1
+	if i < len(tag) && tag[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	for i < len(tag) && (tag[i] >= 'a' && tag[i] <= 'z' || tag[i] >= 'A' && tag[i] <= 'Z' || tag[i] >= '0' && tag[i] <= '9') {
+		i++
+	}
+	return strings.ToLower(string(tag[start:i])), closing
+}
@@ -0,0 +1,107 @@
+// Package compat upgrades what was made with the versions of the tree before
+// dokuwiki.ASTVersion: the trees built with the shapes of an older version, and the units an
+// older version encoded.
+package compat
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// encodingMagic is the magic of the units written by dokuwiki.EncodeUnit.
+const encodingMagic = "dokuwiki-parser unit"
+
+// UpgradeLists turns the lists below c that have the shape of version 1, whose InnerContexts are
+// the paragraphs of their items each followed by the sub lists and quotes of the item, into lists
+// of ListItemContext. A paragraph starts an item, and the blocks before the first paragraph are
+// in an item without text. The items have the depth of their list, are indented by two spaces
+// for each level and have no position. The lists already holding items are left as they are, so
+// upgrading twice changes nothing.
+func UpgradeLists(c dokuwiki.Context) {
+	dokuwiki.Walk(c, func(c dokuwiki.Context) bool {
+		if list, ok := c.(*dokuwiki.ListContext); ok {
+			upgradeList(list)
+		}
+		return true
+	})
+}
+
+func upgradeList(list *dokuwiki.ListContext) {
+	items := make([]dokuwiki.BlockContext, 0, len(list.InnerContexts))
+	var item *dokuwiki.ListItemContext
+	for _, block := range list.InnerContexts {
+		if already, ok := block.(*dokuwiki.ListItemContext); ok {
+			items, item = append(items, already), nil
+			continue
+		}
+		if _, isPara := block.(*dokuwiki.ParaContext); isPara || item == nil {
+			item = &dokuwiki.ListItemContext{RawIndent: 2 * list.Level, Depth: list.Level}
+			item.SetParentContext(list)
+			items = append(items, item)
+		}
+		block.SetParentContext(item)
+		item.InnerContexts = append(item.InnerContexts, block)
+	}
+	list.InnerContexts = items
+}
+
+// ListBlocksV1 returns the blocks of list the way version 1 had them in its InnerContexts, the
+// blocks of every item one after the other, for the code reading lists that way. The blocks are
+// not copied, their parents are still the items.
+func ListBlocksV1(list *dokuwiki.ListContext) []dokuwiki.BlockContext {
+	blocks := make([]dokuwiki.BlockContext, 0, len(list.InnerContexts))
+	for _, block := range list.InnerContexts {
+		if item, ok := block.(*dokuwiki.ListItemContext); ok {
+			blocks = append(blocks, item.InnerContexts...)
+		} else {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// DecodeUnit reads a unit written by dokuwiki.EncodeUnit of this version or of an older one. An
+// older unit is parsed again by p, the default parser when nil, from the source it was encoded
+// with, its diagnostics are the ones of the new parse. It fails for an older unit built by hand,
+// which has no source, and for a unit of a newer version, with dokuwiki.ErrEncodingVersion. Like
+// for dokuwiki.DecodeUnit, the types of the extension contexts of the unit must be registered
+// with gob.
+func DecodeUnit(r io.Reader, p *dokuwiki.Parser) (*dokuwiki.ParseUnit, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := dokuwiki.DecodeUnit(bytes.NewReader(content))
+	if !errors.Is(err, dokuwiki.ErrEncodingVersion) {
+		return unit, err
+	}
+
+	// the fields of the older units not read here are skipped by gob.
+	dec := gob.NewDecoder(bytes.NewReader(content))
+	var header struct {
+		Magic   string
+		Version int
+	}
+	if decodeErr := dec.Decode(&header); decodeErr != nil || header.Magic != encodingMagic || header.Version > dokuwiki.ASTVersion {
+		return nil, err
+	}
+	var encoded struct {
+		Title  string
+		Source string
+	}
+	if err := dec.Decode(&encoded); err != nil {
+		return nil, err
+	}
+	if encoded.Source == "" {
+		return nil, fmt.Errorf("compat: unit %q of version %d has no source to parse again", encoded.Title, header.Version)
+	}
+	if p == nil {
+		p = dokuwiki.New()
+	}
+	return p.Parse([]byte(encoded.Source), encoded.Title), nil
+}
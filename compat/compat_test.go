@@ -0,0 +1,95 @@
+package compat
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+func dump(t *testing.T, c dokuwiki.Context) string {
+	var b bytes.Buffer
+	if err := dokuwiki.Dump(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestUpgradeLists(t *testing.T) {
+	parsed := dokuwiki.Parse([]byte("  * a\n    * b\n  > q\n  * c\n"), "t")
+
+	// the same list with the shapes of version 1, built from the parsed blocks.
+	unit := &dokuwiki.ParseUnit{Title: "t"}
+	items := parsed.Sections[0].(*dokuwiki.ListContext).InnerContexts
+	first := items[0].(*dokuwiki.ListItemContext)
+	sub := first.InnerContexts[1].(*dokuwiki.ListContext)
+	list := &dokuwiki.ListContext{Level: 1, InnerContexts: []dokuwiki.BlockContext{
+		first.InnerContexts[0],
+		&dokuwiki.ListContext{Level: 2, InnerContexts: ListBlocksV1(sub)},
+		first.InnerContexts[2],
+		items[1].(*dokuwiki.ListItemContext).InnerContexts[0],
+	}}
+	unit.Sections = []dokuwiki.BlockContext{list}
+	list.SetParentContext(unit)
+	list.InnerContexts[1].SetParentContext(list)
+
+	UpgradeLists(unit)
+	if got, want := dump(t, unit), dump(t, parsed); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if errs := unit.Validate(); errs != nil {
+		t.Errorf("the upgraded unit is not valid: %v", errs)
+	}
+	UpgradeLists(unit)
+	if got, want := dump(t, unit), dump(t, parsed); got != want {
+		t.Errorf("upgrading twice changed the tree:\n%s", got)
+	}
+	if blocks := ListBlocksV1(list); len(blocks) != 4 {
+		t.Errorf("got %d blocks in version 1, want 4", len(blocks))
+	}
+}
+
+// encodeOld writes a unit like an older version of EncodeUnit did, with only the fields the
+// upgrade reads.
+func encodeOld(version int, title, source string) *bytes.Buffer {
+	var b bytes.Buffer
+	enc := gob.NewEncoder(&b)
+	enc.Encode(struct {
+		Magic   string
+		Version int
+	}{encodingMagic, version})
+	enc.Encode(struct {
+		Title    string
+		Sections []struct{ Line int }
+		Source   string
+	}{Title: title, Sections: []struct{ Line int }{{1}}, Source: source})
+	return &b
+}
+
+func TestDecodeUnit(t *testing.T) {
+	source := "====== Title ======\n  * item\n"
+	var current bytes.Buffer
+	if err := dokuwiki.EncodeUnit(&current, dokuwiki.Parse([]byte(source), "t")); err != nil {
+		t.Fatal(err)
+	}
+	want := dump(t, dokuwiki.Parse([]byte(source), "t"))
+	for name, encoded := range map[string]*bytes.Buffer{"current": &current, "version 1": encodeOld(1, "t", source), "version 2": encodeOld(2, "t", source)} {
+		unit, err := DecodeUnit(encoded, nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := dump(t, unit); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+		}
+	}
+
+	if _, err := DecodeUnit(encodeOld(2, "t", ""), nil); err == nil {
+		t.Error("no error for an older unit without source")
+	}
+	if _, err := DecodeUnit(encodeOld(dokuwiki.ASTVersion+1, "t", source), nil); !errors.Is(err, dokuwiki.ErrEncodingVersion) {
+		t.Errorf("got %v for a newer unit", err)
+	}
+}
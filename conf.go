@@ -0,0 +1,146 @@
+package dokuwiki
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// InterWikiMap maps interwiki shortcuts, in lower case, to URL templates, like DokuWiki's
+// conf/interwiki.conf. Set it in RendererOptions.InterWiki to resolve interwiki links.
+type InterWikiMap map[string]string
+
+// Smileys maps the text of smileys, like :-), to the name of their image, like DokuWiki's
+// conf/smileys.conf.
+type Smileys map[string]string
+
+// Acronyms maps acronyms to their meaning, like DokuWiki's conf/acronyms.conf.
+type Acronyms map[string]string
+
+// LoadInterWikiMap reads interwiki.conf files: a shortcut and a URL template per line, separated by
+// whitespace, # starts a comment. Later files override earlier ones, so pass interwiki.conf then
+// interwiki.local.conf to get what DokuWiki uses.
+func LoadInterWikiMap(r ...io.Reader) (InterWikiMap, error) {
+	conf, err := loadConf(r, true)
+	return InterWikiMap(conf), err
+}
+
+// LoadSmileys reads smileys.conf files, see LoadInterWikiMap for the format.
+func LoadSmileys(r ...io.Reader) (Smileys, error) {
+	conf, err := loadConf(r, false)
+	return Smileys(conf), err
+}
+
+// LoadAcronyms reads acronyms.conf files, see LoadInterWikiMap for the format.
+func LoadAcronyms(r ...io.Reader) (Acronyms, error) {
+	conf, err := loadConf(r, false)
+	return Acronyms(conf), err
+}
+
+// confComment is a comment of a conf line, a # escaped with \ or in an html entity like &#39; is kept.
+var confComment = regexp.MustCompile(`(^|[^&\\])#.*$`)
+
+// loadConf reads key value lines like DokuWiki's linesToHash, lower makes the keys lower case.
+func loadConf(readers []io.Reader, lower bool) (map[string]string, error) {
+	conf := make(map[string]string)
+	for _, r := range readers {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := confComment.ReplaceAllString(scanner.Text(), "$1")
+			line = strings.TrimSpace(strings.Replace(line, `\#`, "#", -1))
+			if line == "" {
+				continue
+			}
+			key, value := line, ""
+			if i := strings.IndexAny(line, " \t"); i != -1 {
+				key, value = line[:i], strings.TrimSpace(line[i:])
+			}
+			if lower {
+				key = strings.ToLower(key)
+			}
+			conf[key] = value
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// isAcronymBound tells whether c can be next to an acronym, like DokuWiki: any ASCII character
+// but letters and digits, so HTML5 does not contain HTML.
+func isAcronymBound(c byte) bool {
+	return c < 0x80 && !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z')
+}
+
+// find calls fn with the byte offsets of the acronyms in text, matched exactly and as whole
+// words. Of acronyms starting at the same place the longest one wins.
+func (a Acronyms) find(text string, fn func(start, end int)) {
+	if len(a) == 0 {
+		return
+	}
+	longest := 0
+	for acronym := range a {
+		longest = max(longest, len(acronym))
+	}
+	for start := 0; start < len(text); start++ {
+		if start > 0 && !isAcronymBound(text[start-1]) {
+			continue
+		}
+		for end := min(start+longest, len(text)); end > start; end-- {
+			if end < len(text) && !isAcronymBound(text[end]) {
+				continue
+			}
+			if _, ok := a[text[start:end]]; ok {
+				fn(start, end)
+				start = end - 1
+				break
+			}
+		}
+	}
+}
+
+// interWikiName escapes the characters DokuWiki escapes in the {NAME} of an external URL.
+var interWikiName = regexp.MustCompile("[\\[\\\\\\]^`{|}~<>\"' ]")
+
+// URL resolves an interwiki link target like wp>DokuWiki. It returns false when the target
+// has no shortcut or the shortcut is unknown. Templates starting with a colon, like :user:{NAME},
+// point to a page of the wiki, the returned page ID then starts with a colon.
+//
+// The templates use DokuWiki's placeholders: {URL} is the URL encoded name, {NAME} the name
+// with only the characters not allowed in URLs encoded, and {SCHEME}, {HOST}, {PORT}, {PATH} and
+// {QUERY} the parts of the name parsed as a URL. Without placeholder the URL encoded name is
+// appended to the template.
+func (m InterWikiMap) URL(target string) (string, bool) {
+	shortcut, name, ok := strings.Cut(target, ">")
+	if !ok {
+		return "", false
+	}
+	template, ok := m[strings.ToLower(strings.TrimSpace(shortcut))]
+	if !ok {
+		return "", false
+	}
+	if !strings.Contains(template, "{") {
+		return template + url.PathEscape(name), true
+	}
+
+	var encodedName string
+	if strings.HasPrefix(template, ":") {
+		encodedName = cleanID(name)
+	} else {
+		encodedName = interWikiName.ReplaceAllStringFunc(name, url.PathEscape)
+	}
+	replacements := []string{"{URL}", url.PathEscape(name), "{NAME}", encodedName}
+	if parsed, err := url.Parse(name); err == nil {
+		replacements = append(replacements,
+			"{SCHEME}", parsed.Scheme,
+			"{HOST}", parsed.Hostname(),
+			"{PORT}", parsed.Port(),
+			"{PATH}", parsed.Path,
+			"{QUERY}", parsed.RawQuery,
+		)
+	}
+	return strings.NewReplacer(replacements...).Replace(template), true
+}
@@ -0,0 +1,90 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func openConf(t *testing.T, names ...string) []*os.File {
+	t.Helper()
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		files = append(files, f)
+	}
+	return files
+}
+
+func TestLoadInterWikiMap(t *testing.T) {
+	files := openConf(t, "interwiki.conf", "interwiki.local.conf")
+	interwiki, err := LoadInterWikiMap(files[0], files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interwiki) != 13 {
+		t.Errorf("got %d shortcuts, want 13: %v", len(interwiki), interwiki)
+	}
+
+	tests := map[string]string{
+		"wp>Go (language)":           "https://wiki.example.com/Go%20(language)",
+		"wpfr>Go (langage)":          "https://fr.wikipedia.org/wiki/Go%20(langage)",
+		"doku>syntax":                "https://www.dokuwiki.org/syntax",
+		"google>a b":                 "https://www.google.com/search?q=a%20b",
+		"amazon>0596516177":          "https://www.amazon.com/dp/0596516177?tag=splitbrain-20",
+		"issue>42":                   "https://bugs.example.com/show?id=42",
+		"anchor>top":                 "https://example.com/page#top",
+		"user>Jane Doe":              ":user:jane_doe",
+		"callto>//alice@sip.example": "callto:////alice@sip.example",
+	}
+	for target, want := range tests {
+		if got, ok := interwiki.URL(target); !ok || got != want {
+			t.Errorf("%s: got %q, %v, want %q", target, got, ok, want)
+		}
+	}
+	for _, target := range []string{"nope>x", "no shortcut"} {
+		if got, ok := interwiki.URL(target); ok {
+			t.Errorf("%s: got %q", target, got)
+		}
+	}
+}
+
+func TestLoadSmileysAndAcronyms(t *testing.T) {
+	smileys, err := LoadSmileys(openConf(t, "smileys.conf")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(smileys) != 13 || smileys[":-)"] != "smile.svg" || smileys[`:-\`] != "doubt2.svg" || smileys["LOL"] != "lol.svg" {
+		t.Errorf("got %v", smileys)
+	}
+
+	acronyms, err := LoadAcronyms(openConf(t, "acronyms.conf")[0], strings.NewReader("FAQ  Frequent questions\nNEW\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acronyms) != 10 || acronyms["FAQ"] != "Frequent questions" || acronyms["AFAICS"] != "As far as I can see" {
+		t.Errorf("got %v", acronyms)
+	}
+	if meaning, ok := acronyms["NEW"]; !ok || meaning != "" {
+		t.Errorf("an acronym without meaning: got %q, %v", meaning, ok)
+	}
+}
+
+func TestRenderInterWiki(t *testing.T) {
+	unit := Parse([]byte("[[wp>DokuWiki|the article]] [[user>jane]] [[nope>x]]\n"), "doc")
+	interwiki := InterWikiMap{"wp": "https://en.wikipedia.org/wiki/{NAME}", "user": ":user:{NAME}"}
+	var buf bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{BaseURL: "/wiki/", InterWiki: interwiki}).Render(&buf, unit); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`href="https://en.wikipedia.org/wiki/DokuWiki"`, `href="/wiki/user:jane"`, `href="nope&gt;x"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("no %s in\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package dokuwiki_test
+
+import (
+	"bytes"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+	"github.com/321cyb/dokuwiki-parser/dokuwikitest"
+)
+
+// TestConformance compares the html of the pages of testdata/conformance to the XHTML of
+// DokuWiki, see testdata/conformance/README. Run it with -v for the report of every feature, and
+// with DOKUWIKITEST_UPDATE=1 to write PASSING again after capturing the XHTML.
+func TestConformance(t *testing.T) {
+	dir := "testdata/conformance"
+	update := os.Getenv(dokuwikitest.UpdateEnv) != ""
+	passing := make(map[string]bool)
+	list, err := os.ReadFile(filepath.Join(dir, "PASSING"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, feature := range strings.Fields(string(list)) {
+		passing[feature] = !update
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := make([]string, 0)
+	for _, input := range inputs {
+		feature := strings.TrimSuffix(filepath.Base(input), ".txt")
+		t.Run(feature, func(t *testing.T) {
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := os.ReadFile(strings.TrimSuffix(input, ".txt") + ".xhtml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := dokuwiki.NewHTMLRenderer(dokuwiki.RendererOptions{}).Render(&out, dokuwiki.Parse(content, "conformance")); err != nil {
+				t.Fatal(err)
+			}
+			want, got := normalizeXHTML(string(expected)), normalizeXHTML(out.String())
+			if strings.Join(want, "\n") == strings.Join(got, "\n") {
+				passed = append(passed, feature)
+				if !passing[feature] && !update {
+					t.Logf("%s passes, add it to PASSING", feature)
+				}
+				return
+			}
+			diff := dokuwikitest.Diff(strings.Join(want, "\n"), strings.Join(got, "\n"))
+			if passing[feature] {
+				t.Errorf("%s does not match its XHTML anymore, - expected + got:\n%s", feature, diff)
+				return
+			}
+			t.Skipf("%s differs, %d%% of the tokens match, - expected + got:\n%s", feature, matchShare(want, got), diff)
+		})
+	}
+	t.Logf("%d of %d features match their XHTML", len(passed), len(inputs))
+	if update {
+		if err := os.WriteFile(filepath.Join(dir, "PASSING"), []byte(strings.Join(passed, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var (
+	xhtmlComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	xhtmlToken     = regexp.MustCompile(`<[^>]*>|[^<]+`)
+	xhtmlTag       = regexp.MustCompile(`^<(/?)([a-zA-Z0-9]+)(.*?)/?>$`)
+	xhtmlAttribute = regexp.MustCompile(`([^\s=/>]+)(?:="([^"]*)")?`)
+	sectionEdit    = regexp.MustCompile(`\bsectionedit\d+\b`)
+)
+
+// xhtmlBlocks are the elements the whitespace around does not matter.
+var xhtmlBlocks = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "pre": true, "dl": true, "dt": true, "dd": true,
+	"hr": true, "blockquote": true, "table": true, "thead": true, "tr": true, "th": true, "td": true,
+}
+
+// normalizeXHTML cuts html into tags and texts, without what differs between DokuWiki and the
+// renderer for no visible reason, see testdata/conformance/README.
+func normalizeXHTML(s string) []string {
+	type token struct {
+		text  string
+		block bool
+		isTag bool
+	}
+	tokens := make([]token, 0)
+	for _, raw := range xhtmlToken.FindAllString(xhtmlComment.ReplaceAllString(s, ""), -1) {
+		groups := xhtmlTag.FindStringSubmatch(raw)
+		if groups == nil {
+			tokens = append(tokens, token{text: strings.Join(strings.Fields(html.UnescapeString(raw)), " ")})
+			if strings.TrimSpace(raw) != "" {
+				// the spaces around the text are kept as one.
+				if raw[0] == ' ' || raw[0] == '\n' || raw[0] == '\t' {
+					tokens[len(tokens)-1].text = " " + tokens[len(tokens)-1].text
+				}
+				if last := raw[len(raw)-1]; last == ' ' || last == '\n' || last == '\t' {
+					tokens[len(tokens)-1].text += " "
+				}
+			} else {
+				tokens[len(tokens)-1].text = " "
+			}
+			continue
+		}
+		name := strings.ToLower(groups[2])
+		if name == "div" {
+			continue
+		}
+		attributes := make([]string, 0)
+		for _, attribute := range xhtmlAttribute.FindAllStringSubmatch(groups[3], -1) {
+			key, value := strings.ToLower(attribute[1]), html.UnescapeString(attribute[2])
+			switch key {
+			case "data-wiki-id", "loading":
+				continue
+			case "class":
+				value = strings.Join(strings.Fields(sectionEdit.ReplaceAllString(value, "")), " ")
+				if value == "" {
+					continue
+				}
+			case "href":
+				value = strings.TrimPrefix(value, "/doku.php?id=")
+			}
+			attributes = append(attributes, key+"="+value)
+		}
+		sort.Strings(attributes)
+		tag := "<" + groups[1] + name
+		if len(attributes) > 0 {
+			tag += " " + strings.Join(attributes, " ")
+		}
+		tokens = append(tokens, token{text: tag + ">", block: xhtmlBlocks[name], isTag: true})
+	}
+
+	// texts next to each other, once the divs are gone, are one text.
+	merged := make([]token, 0, len(tokens))
+	for _, tok := range tokens {
+		if n := len(merged); n > 0 && !tok.isTag && !merged[n-1].isTag {
+			merged[n-1].text = strings.Join(strings.Fields(merged[n-1].text+tok.text), " ")
+			if strings.HasSuffix(tok.text, " ") {
+				merged[n-1].text += " "
+			}
+			continue
+		}
+		merged = append(merged, tok)
+	}
+	normalized := make([]string, 0, len(merged))
+	for i, tok := range merged {
+		text := tok.text
+		if !tok.isTag {
+			if i == 0 || merged[i-1].block {
+				text = strings.TrimLeft(text, " ")
+			}
+			if i == len(merged)-1 || merged[i+1].block {
+				text = strings.TrimRight(text, " ")
+			}
+			if text == "" {
+				continue
+			}
+		}
+		normalized = append(normalized, text)
+	}
+	return normalized
+}
+
+// matchShare returns the share of the tokens of want and got that match, in percent.
+func matchShare(want, got []string) int {
+	if len(want)+len(got) == 0 {
+		return 100
+	}
+	// common[j] is the length of the longest common subsequence of the tokens seen of want and got[:j].
+	common := make([]int, len(got)+1)
+	for _, w := range want {
+		previous := 0
+		for j, g := range got {
+			current := common[j+1]
+			if w == g {
+				common[j+1] = previous + 1
+			} else {
+				common[j+1] = max(common[j+1], common[j])
+			}
+			previous = current
+		}
+	}
+	return 200 * common[len(got)] / (len(want) + len(got))
+}
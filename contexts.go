@@ -1,5 +1,7 @@
 package dokuwiki
 
+import "strconv"
+
 const (
 	AlignLeft = iota
 	AlignCenter
@@ -13,6 +15,8 @@ const (
 	TextEffectMonoSpace = 1 << iota
 )
 
+// Context is implemented by pointers to all the context types, the tree is built out of
+// pointers so that a context can be filled in place after it has been linked into its parent.
 type Context interface {
 	GetParentContext() Context
 	SetParentContext(Context)
@@ -20,20 +24,70 @@ type Context interface {
 
 type BaseContext struct {
 	parent Context
+	pos    Position
 }
 
 func (b BaseContext) GetParentContext() Context {
 	return b.parent
 }
 
-func (b BaseContext) SetParentContext(pContext Context) {
+// GetPosition returns where the context starts in the input, inline contexts share
+// the position of the paragraph or list item they are in.
+func (b BaseContext) GetPosition() Position {
+	return b.pos
+}
+
+func (b *BaseContext) SetParentContext(pContext Context) {
 	b.parent = pContext
 }
 
+func (b *BaseContext) setPosition(pos Position) {
+	b.pos = pos
+}
+
+// Position is a place in the input, lines count from 1. The zero value means unknown,
+// like for contexts built by hand.
+type Position struct {
+	Line int
+}
+
+// Span is a range of bytes of the input, End excluded.
+type Span struct {
+	Start, End int
+}
+
 type ParseUnit struct {
 	BaseContext
-	Title    string
+	Title string
+	// Sections are the blocks of the page in the order of the input, like the contexts of every
+	// InnerContexts below them, and no context is in the tree twice. Validate checks that the
+	// trees changed in code keep it so.
 	Sections []BlockContext
+
+	// Diagnostics lists the problems found in the input, the parser recovers from all of them.
+	Diagnostics []Diagnostic
+
+	// source is the content the unit was parsed from, for the lint rules working on lines.
+	// It is empty for units built by hand.
+	source string
+	// frozen is set by Freeze, toc is the table of contents computed then.
+	frozen bool
+	toc    []TOCEntry
+	// effects are the delimiters of the registered effects of its parser, see ParseOptions.Effects.
+	effects map[TextEffect]string
+	// pageID is the ParseOptions.PageID it was parsed with.
+	pageID string
+}
+
+// unitOf returns the unit c is in, nil for a context outside of any.
+func unitOf(c Context) *ParseUnit {
+	for c != nil {
+		if unit, ok := c.(*ParseUnit); ok {
+			return unit
+		}
+		c = c.GetParentContext()
+	}
+	return nil
 }
 
 type BlockContext interface {
@@ -43,30 +97,110 @@ type BlockContext interface {
 
 type BaseBlockContext struct {
 	BaseContext
+	// span is where the block is in the input the unit was parsed from, see BlocksWithSource.
+	span Span
 }
 
 func (b BaseBlockContext) block() {}
 
-// SectionHeader can have bold or other text effect in it, nor links.
+func (b BaseBlockContext) sourceSpan() Span {
+	return b.span
+}
+
+func (b *BaseBlockContext) setSpan(span Span) {
+	b.span = span
+}
+
+// SectionHeader can have bold or other text effect in it, but no links nor media.
 // SectionHeader should be the beginning of a line,  no whitespace before it.
 type SectionHeaderContext struct {
 	BaseBlockContext
 	HeaderLevel int
-	HeaderText  string
+	// HeaderText is the text of the heading as written, with its formatting markers. Use
+	// HeaderTextPlain for the text without them.
+	HeaderText string
+	// InnerContexts is the text of the heading parsed like the text of a paragraph, links,
+	// media and bare URLs are kept as text. It is nil for headings built by hand.
+	InnerContexts []InlineContext
+
+	// tags are the protected tags of HeaderText as the line pass found them.
+	tags      []tagRegion
+	tagsFound bool
 }
 
+// ListContext is a list, InnerContexts are its items, every one a *ListItemContext. The lines
+// of a list are indented by two spaces or more, * for an unordered item and - for an ordered one:
+//
+//   - item
+//   - item of a sub list
+//   - item
 type ListContext struct {
 	BaseBlockContext
+	// Level is the Depth of its items, 1 for a list indented by two spaces.
 	Level         int
 	Ordered       bool
 	InnerContexts []BlockContext
 }
 
+// ListItemContext is an item of a list, InnerContexts are the blocks that belong to it: the
+// paragraph of its text first, then its sub lists and the quotes indented like it.
+type ListItemContext struct {
+	BaseBlockContext
+	// RawIndent is the width of the indentation of the item, in spaces, a tab counting for
+	// ParseOptions.TabWidth spaces. Depth is half of it, rounded down like DokuWiki does: two
+	// and three spaces are depth 1, four and five depth 2. In a quote, the spaces after the >
+	// give the depth rounded up, "> * item" is depth 1 like "  * item".
+	RawIndent     int
+	Depth         int
+	InnerContexts []BlockContext
+}
+
+// QuoteContext is a quote, lines starting with >, or >> and more for the quotes in it:
+//
+//	> quoted text
+//	>> a quote in the quote
+//	> * a list in the quote
+//
+// InnerContexts are the paragraphs of its lines, the consecutive lines of a level are one
+// paragraph, the quotes one level deeper and its lists. A quote indented like the items of a
+// list, "  > text" after "  * item", is in the item it belongs to, after its text.
+type QuoteContext struct {
+	BaseBlockContext
+	// Level is 1 for the outermost quote, written >, 2 for a quote in it and so on.
+	Level         int
+	InnerContexts []BlockContext
+}
+
+// DefinitionListContext is a list of terms and their definitions, written with the syntax of
+// the definition list plugin when ParseOptions.DefinitionLists is set, on lines indented by
+// two spaces or more:
+//
+//	; term : definition
+//	; term
+//	: definition
+//	: another definition
+type DefinitionListContext struct {
+	BaseBlockContext
+	Entries []DefinitionEntry
+}
+
+// DefinitionEntry is a term with its definitions, the paragraphs are in the DefinitionListContext.
+type DefinitionEntry struct {
+	// Term is nil for the definitions at the start of a list, before any term.
+	Term        *ParaContext
+	Definitions []*ParaContext
+}
+
 // ParaContext is a fake block context that is created to contain inline blocks.
 type ParaContext struct {
 	BaseBlockContext
 	rawText       string
 	InnerContexts []InlineContext
+
+	// tags are the protected tags of rawText as the line pass found them, when tagsFound is
+	// set. The paragraphs made otherwise, like decoded ones, are scanned for them.
+	tags      []tagRegion
+	tagsFound bool
 }
 
 // Inline Contexts
@@ -81,36 +215,161 @@ type BaseInlineContext struct {
 
 func (b BaseInlineContext) inline() {}
 
+// NoWikiContext is text that is not formatted, written in a nowiki tag or between %%.
 type NoWikiContext struct {
 	BaseInlineContext
 	Text string
+	// EffectType are the effects around text between %%, like bold in **a %%**%% b**. A nowiki
+	// tag ends the effects, so it has none.
+	EffectType uint32
 }
 
+// HTMLContext holds the content of an html tag, written <html> inline or <HTML> for block
+// level elements.
 type HTMLContext struct {
 	BaseInlineContext
 	Text string
+	// IsBlock is true for the content of <HTML>, which DokuWiki does not put in a paragraph.
+	IsBlock bool
 }
 
+// CodeFileContext holds the content of a code or file tag, the content is not formatted.
+// Text is the content byte for byte, without the new line right after the start tag.
 type CodeFileContext struct {
 	BaseInlineContext
-	Text string
+	// IsFile is true for a file tag, false for a code tag.
+	IsFile bool
+	// Language is used for syntax highlighting, it can be empty.
+	Language string
+	// FileName is only meaningful for file tags.
+	FileName string
+	// Attributes are the key="value" pairs in brackets at the end of the start tag, like
+	// <code php [enable_line_numbers="true", highlight_lines_extra="2,7"]>, nil without them.
+	// The HTMLRenderer knows enable_line_numbers, start_line_numbers_at and
+	// highlight_lines_extra, the others are only kept.
+	Attributes map[string]string
+	Text       string
+
+	// newlineKept is set when the Text was parsed with ParseOptions.KeepCodeNewline, the new
+	// line after the start tag is then in it.
+	newlineKept bool
 }
 
-//Hyperlink text should not have effects.
+// LinkKind tells what a link points to.
+type LinkKind int
+
+const (
+	// LinkInternal is a page of the wiki, like [[ns:page#section]].
+	LinkInternal LinkKind = iota
+	// LinkExternal is a URL, written as a link or autolinked.
+	LinkExternal
+	// LinkInterwiki is a shortcut to another wiki, like [[wp>DokuWiki]].
+	LinkInterwiki
+	// LinkEmail is an email address, like [[user@example.com]].
+	LinkEmail
+	// LinkWindowsShare is a windows share, like [[\\server\share]].
+	LinkWindowsShare
+)
+
+var linkKindNames = []string{"internal", "external", "interwiki", "email", "windowsshare"}
+
+func (k LinkKind) String() string {
+	if k >= 0 && int(k) < len(linkKindNames) {
+		return linkKindNames[k]
+	}
+	return "LinkKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// HyperLinkContext is a link, Text is its label as written, or its target when it has none.
 type HyperLinkContext struct {
 	BaseInlineContext
-	HyperLink  string
-	Text       string
+	// HyperLink is the target as written, use SetTarget to change it with the fields parsed from it.
+	HyperLink string
+	// Scheme is the scheme of an external link, "mailto" for an email, "file" for a windows
+	// share and the shortcut of an interwiki link, like "wp" for wp>DokuWiki. It is empty for
+	// an internal link and for an external link whose URL is not valid.
+	Scheme string
+	// PageID is the page of an internal link as written, like "ns:page" for ns:page?do=edit#intro,
+	// or what an interwiki link points to, and Namespace the namespace of the page, like "ns".
+	PageID    string
+	Namespace string
+	// Anchor is the part of the target after the #, Query the part after the ? and before
+	// the #, both without their mark.
+	Anchor string
+	Query  string
+	Text   string
+	// InnerContexts is the label parsed like the text of a paragraph, with its effects and nowiki,
+	// but no links. It is nil when the link has no label or an image label.
+	InnerContexts []InlineContext
+	Kind          LinkKind
+	// IsInternal is the same as Kind == LinkInternal.
 	IsInternal bool
+	// IsAutoLink is true for a bare URL in the text that was turned into a link.
+	IsAutoLink bool
+	// Image is set when the label of the link is an image, like [[page|{{logo.png}}]],
+	// Text then still holds the raw label.
+	Image *MediaContext
+	// EffectType are the effects around the link, like bold in **see [[page]]**. They style the
+	// whole label, its InnerContexts only have the effects written in the label.
+	EffectType uint32
 }
 
 type MediaContext struct {
 	BaseInlineContext
+	// Width and Height are the requested size in pixels, 0 when not given. ?200 only gives
+	// the width and ?x100 only the height.
 	Width        int64
 	Height       int64
 	Align        int
 	Title        string
 	MediaResouce string
+	// IsExternal is true when MediaResouce is an http, https or ftp URL, not a media ID.
+	IsExternal bool
+	// ResolvedID is the media ID of MediaResouce resolved against the namespace of the page like
+	// ResolvePageID does, "wiki:logo.png" for {{..:logo.png}} on wiki:ns:page. It is only set when
+	// the parser was given ParseOptions.PageID, and never for external media.
+	ResolvedID string
+	// Params are the parameters after the ? other than the size, like nolink or a key=value,
+	// a keyword has an empty value. Params is nil when there is none.
+	Params map[string]string
+	// EffectType are the effects around the media, like italic in //see {{logo.png}}//.
+	EffectType uint32
+
+	// invalidSizes are the parameters that were neither a size nor a keyword, parsePara
+	// reports them.
+	invalidSizes []string
+}
+
+// MacroContext is a control macro like ~~NOTOC~~, it changes how the page is handled and is not rendered.
+type MacroContext struct {
+	BaseInlineContext
+	// Name is the name of the macro without the tildes, like "NOTOC".
+	Name string
+	// Args is what follows the colon of the macros taking one, like "2-4" for ~~TOC:2-4~~, which
+	// sets the levels of the table of contents of the page.
+	Args string
+}
+
+// AnchorContext is an explicit anchor, written {{anchor:name}} like with the anchor plugin when
+// ParseOptions.Anchors is set. Links point to it with its ID like to the section of a heading,
+// it stays in place when headings are reworded.
+type AnchorContext struct {
+	BaseInlineContext
+	// Name is the name of the anchor as written.
+	Name string
+}
+
+// ID returns the id of the anchor, its name cleaned like the text of a heading is for its id. An
+// anchor whose name is used before in its unit gets a number appended in the output, see
+// ParseUnit.Anchors.
+func (c *AnchorContext) ID() string {
+	return sectionID(c.Name, make(map[string]bool))
+}
+
+// LineBreakContext is a new line of a paragraph kept as a line break, with
+// ParseOptions.PreserveLineBreaks. The effects around it go on after it.
+type LineBreakContext struct {
+	BaseInlineContext
 }
 
 type TextEffectContext struct {
@@ -0,0 +1,232 @@
+package dokuwiki
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConvertOptions configures ConvertTree, the zero value is usable.
+type ConvertOptions struct {
+	// PagesRoot is the directory of the pages in the source, "." when empty.
+	PagesRoot string
+	// MediaRoot is the directory of the media files in the source. When set, the internal media
+	// used by the pages is copied below MediaDir in the destination, with DokuWiki's layout.
+	MediaRoot string
+	// MediaDir is where media is copied to, relative to the destination, "_media" when empty,
+	// the directory the renderers link media to with their default options.
+	MediaDir string
+	// Extension is the one of the written files, ".html" when empty.
+	Extension string
+	// FailFast stops the conversion at the first page or media file that fails, otherwise it
+	// is recorded in the report and the conversion goes on.
+	FailFast bool
+	// Progress, when set, is called after every page with the number of pages done, the total
+	// and the ID of the page done.
+	Progress func(done, total int, pageID string)
+}
+
+// ConvertReport tells what ConvertTree did.
+type ConvertReport struct {
+	// Pages and Media count the pages written and the media files copied.
+	Pages int
+	Media int
+	// Errors lists the files that could not be converted or copied, in the order they failed.
+	Errors []ConvertError
+	// Diagnostics are those of the parsed pages, keyed by page ID, pages without any are left out.
+	Diagnostics map[string][]Diagnostic
+}
+
+// ConvertError is a file ConvertTree failed on.
+type ConvertError struct {
+	// PageID is the ID of the failed page, or of the page using the failed media file.
+	PageID string
+	// MediaID is the ID of the media file that failed, empty for a page.
+	MediaID string
+	Err     error
+}
+
+func (e ConvertError) Error() string {
+	if e.MediaID != "" {
+		return fmt.Sprintf("media %s used by %s: %v", e.MediaID, e.PageID, e.Err)
+	}
+	return fmt.Sprintf("page %s: %v", e.PageID, e.Err)
+}
+
+func (e ConvertError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertTree renders every page of src with renderer to a file in dstDir, keeping the
+// directory of its namespace: ns/page.txt is written to dstDir/ns/page.html. Pages are
+// converted in the order of their IDs.
+//
+// The links and media of the pages written by the renderers of the package stay relative to the
+// destination: a page n namespaces deep is rendered with n "../" before their BaseURL, unless it
+// is absolute.
+//
+// The returned error is set when the conversion stopped before the end: ctx is done, the pages
+// could not be listed or, with FailFast, a file failed. The report tells what was done until then.
+func ConvertTree(ctx context.Context, src fs.FS, dstDir string, renderer Renderer, opts ConvertOptions) (ConvertReport, error) {
+	if opts.PagesRoot == "" {
+		opts.PagesRoot = "."
+	}
+	if opts.MediaDir == "" {
+		opts.MediaDir = "_media"
+	}
+	if opts.Extension == "" {
+		opts.Extension = ".html"
+	}
+	report := ConvertReport{Diagnostics: make(map[string][]Diagnostic)}
+
+	files := make(map[string]string)
+	err := fs.WalkDir(src, opts.PagesRoot, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && path.Ext(name) == ".txt" {
+			relative := strings.TrimPrefix(strings.TrimPrefix(name, opts.PagesRoot), "/")
+			if opts.PagesRoot == "." {
+				relative = name
+			}
+			files[PathToID(relative)] = name
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	copied := make(map[string]bool)
+	// fail records a failed file, the error is returned when the conversion has to stop.
+	fail := func(e ConvertError) error {
+		report.Errors = append(report.Errors, e)
+		if opts.FailFast {
+			return e
+		}
+		return nil
+	}
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		unit, err := ParseFS(src, files[id])
+		if err == nil {
+			if len(unit.Diagnostics) > 0 {
+				report.Diagnostics[id] = unit.Diagnostics
+			}
+			err = convertPage(unit, rebase(renderer, strings.Count(id, ":")), filepath.Join(dstDir, filepath.FromSlash(strings.TrimSuffix(IDToPath(id), ".txt")))+opts.Extension)
+		}
+		if err != nil {
+			if err := fail(ConvertError{PageID: id, Err: err}); err != nil {
+				return report, err
+			}
+		} else {
+			report.Pages++
+		}
+
+		if opts.MediaRoot != "" && unit != nil {
+			for _, ref := range unit.MediaRefs() {
+				if ref.IsExternal {
+					continue
+				}
+				mediaID := ResolvePageID(id, ref.Raw)
+				if copied[mediaID] {
+					continue
+				}
+				copied[mediaID] = true
+				mediaPath := strings.Replace(mediaID, ":", "/", -1)
+				err := copyFSFile(src, path.Join(opts.MediaRoot, mediaPath), filepath.Join(dstDir, opts.MediaDir, filepath.FromSlash(mediaPath)))
+				if err != nil {
+					if err := fail(ConvertError{PageID: id, MediaID: mediaID, Err: err}); err != nil {
+						return report, err
+					}
+				} else {
+					report.Media++
+				}
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(ids), id)
+		}
+	}
+	return report, nil
+}
+
+// rebase returns renderer with "../" put depth times before its BaseURL, for a page depth
+// directories below the destination. Renderers other than those of the package writing URLs,
+// and absolute base URLs, are kept.
+func rebase(renderer Renderer, depth int) Renderer {
+	base := func(opts RendererOptions) RendererOptions {
+		if u, err := url.Parse(opts.BaseURL); err == nil && !u.IsAbs() && !strings.HasPrefix(opts.BaseURL, "/") {
+			opts.BaseURL = strings.Repeat("../", depth) + opts.BaseURL
+		}
+		return opts
+	}
+	if depth == 0 {
+		return renderer
+	}
+	switch r := renderer.(type) {
+	case *HTMLRenderer:
+		rebased := *r
+		rebased.Options = base(r.Options)
+		return &rebased
+	case *MarkdownRenderer:
+		rebased := *r
+		rebased.Options = base(r.Options)
+		return &rebased
+	case *LaTeXRenderer:
+		rebased := *r
+		rebased.Options = base(r.Options)
+		return &rebased
+	}
+	return renderer
+}
+
+func convertPage(unit *ParseUnit, renderer Renderer, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := renderer.Render(f, unit); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func copyFSFile(src fs.FS, name, filename string) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
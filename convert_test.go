@@ -0,0 +1,98 @@
+package dokuwiki
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestConvertTree(t *testing.T) {
+	src := fstest.MapFS{
+		"pages/start.txt":        {Data: []byte("====== Start ======\n{{logo.png}} {{missing.png}}\n")},
+		"pages/wiki/syntax.txt":  {Data: []byte("**Syntax** {{:logo.png}}\n<code go>\nunclosed\n")},
+		"pages/wiki/notes.md":    {Data: []byte("not a page")},
+		"media/logo.png":         {Data: []byte("png")},
+		"media/wiki/unused.jpeg": {Data: []byte("jpeg")},
+	}
+	dst := t.TempDir()
+	progress := make([]string, 0)
+	report, err := ConvertTree(context.Background(), src, dst, NewMarkdownRenderer(RendererOptions{}), ConvertOptions{
+		PagesRoot: "pages",
+		MediaRoot: "media",
+		Extension: ".md",
+		Progress: func(done, total int, pageID string) {
+			progress = append(progress, pageID)
+			if total != 2 || done != len(progress) {
+				t.Errorf("progress %d/%d for %s", done, total, pageID)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"start", "wiki:syntax"}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress for %v, want %v", progress, want)
+	}
+	if report.Pages != 2 || report.Media != 1 {
+		t.Errorf("converted %d pages and %d media, want 2 and 1", report.Pages, report.Media)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].MediaID != "missing.png" || !errors.Is(report.Errors[0], fs.ErrNotExist) {
+		t.Errorf("errors %v, want missing.png not found", report.Errors)
+	}
+	if len(report.Diagnostics) != 1 || len(report.Diagnostics["wiki:syntax"]) == 0 {
+		t.Errorf("diagnostics %v, want those of wiki:syntax", report.Diagnostics)
+	}
+	for _, name := range []string{"start.md", "wiki/syntax.md", "_media/logo.png"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+
+	report, err = ConvertTree(context.Background(), src, t.TempDir(), NewMarkdownRenderer(RendererOptions{}), ConvertOptions{
+		PagesRoot: "pages",
+		MediaRoot: "media",
+		FailFast:  true,
+	})
+	if err == nil || report.Pages != 1 {
+		t.Errorf("FailFast converted %d pages and returned %v, want 1 page and an error", report.Pages, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConvertTree(ctx, src, t.TempDir(), NewMarkdownRenderer(RendererOptions{}), ConvertOptions{}); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+// TestConvertTreeRelative checks that the links and media of a page two namespaces deep go up
+// to the destination.
+func TestConvertTreeRelative(t *testing.T) {
+	src := fstest.MapFS{
+		"start.txt":          {Data: []byte("[[wiki:deep:page]]\n")},
+		"wiki/deep/page.txt": {Data: []byte("[[start]] {{logo.png}}\n")},
+	}
+	dst := t.TempDir()
+	if _, err := ConvertTree(context.Background(), src, dst, NewHTMLRenderer(RendererOptions{}), ConvertOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][]string{
+		"start.html":          {`href="wiki:deep:page"`},
+		"wiki/deep/page.html": {`href="../../start"`, `src="../../_media/logo.png"`},
+	} {
+		out, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(out), w) {
+				t.Errorf("%s has no %s:\n%s", name, w, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefinitionLists(t *testing.T) {
+	content := "Intro text\n" +
+		"  ; **Go** : a language\n" +
+		"  ; DokuWiki\n" +
+		"  : a wiki\n" +
+		"  : see [[doku>start]]\n" +
+		"  * a list item\n" +
+		"  : after the list\n" +
+		"\n" +
+		"  ; new list\n"
+	unit := ParseWithOptions([]byte(content), "dl", ParseOptions{DefinitionLists: true})
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "dl"
+  Para
+    Text "Intro text"
+  DefinitionList
+    Term
+      Text effect=bold "Go"
+    Definition
+      Text "a language"
+    Term
+      Text "DokuWiki"
+    Definition
+      Text "a wiki"
+    Definition
+      Text "see "
+      Link interwiki target="doku>start" "doku>start"
+  List level=1 unordered
+    ListItem depth=1 indent=2
+      Para
+        Text "a list item"
+  DefinitionList
+    Definition
+      Text "after the list"
+  DefinitionList
+    Term
+      Text "new list"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	if list := unit.Sections[1].(*DefinitionListContext); list.GetPosition().Line != 2 || list.Entries[1].Definitions[1].GetPosition().Line != 5 {
+		t.Errorf("the list is at line %d", list.GetPosition().Line)
+	}
+
+	// off by default, the lines are a paragraph.
+	Walk(Parse([]byte(content), "dl"), func(c Context) bool {
+		if _, ok := c.(*DefinitionListContext); ok {
+			t.Errorf("definition list parsed without the option")
+		}
+		return true
+	})
+
+	var html bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&html, unit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<dl>\n<dt><strong>Go</strong></dt>\n<dd>a language</dd>\n<dt>DokuWiki</dt>\n<dd>a wiki</dd>\n") {
+		t.Errorf("html:\n%s", html.String())
+	}
+
+	var wiki bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit); err != nil {
+		t.Fatal(err)
+	}
+	var first, second bytes.Buffer
+	Dump(&first, unit)
+	Dump(&second, ParseWithOptions(wiki.Bytes(), "dl", ParseOptions{DefinitionLists: true}))
+	if first.String() != second.String() {
+		t.Errorf("round trip changed the tree, serialized:\n%s\ngot\n%s", wiki.String(), second.String())
+	}
+}
@@ -0,0 +1,158 @@
+package dokuwiki
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Diagnostic reports something in the input the parser had to guess about, like an unclosed code tag.
+// It is an error whose errors.Is matches the sentinel of its kind, like ErrUnclosedTag.
+type Diagnostic struct {
+	Severity Severity
+	// Code names the kind of problem, it is one of the Code constants and does not change
+	// between versions, unlike Message.
+	Code string
+	// Message describes the problem, it starts with its line when it is known, like "line 3: ...".
+	Message string
+	// Position is the line of the problem, and Span the bytes of that line in the input
+	// without its new line. Both are zero when unknown.
+	Position Position
+	Span     Span
+}
+
+// The codes of the diagnostics.
+const (
+	CodeUnclosedCodeTag     = "unclosed-code-tag"
+	CodeUnclosedFileTag     = "unclosed-file-tag"
+	CodeUnclosedHTMLTag     = "unclosed-html-tag"
+	CodeUnclosedNoWikiTag   = "unclosed-nowiki-tag"
+	CodeUnclosedBlock       = "unclosed-block"
+	CodeStrayEndTag         = "stray-end-tag"
+	CodeUnclosedFormatting  = "unclosed-formatting"
+	CodeInvalidURL          = "invalid-url"
+	CodeUnsupportedMarkdown = "unsupported-markdown"
+	CodeUnclosedCodeFence   = "unclosed-code-fence"
+	CodeUnmatchedBrackets   = "unmatched-brackets"
+	CodeInvalidMediaSize    = "invalid-media-size"
+)
+
+// The sentinels the diagnostics match with errors.Is, by kind.
+var (
+	// ErrUnclosedTag is a code, file, html or nowiki tag, or a code fence, that takes the rest of the input.
+	ErrUnclosedTag = errors.New("unclosed tag")
+	// ErrUnclosedBlock is a block plugin that takes the rest of the input.
+	ErrUnclosedBlock = errors.New("unclosed block")
+	// ErrStrayEndTag is an end tag closing no tag, it is kept as text.
+	ErrStrayEndTag = errors.New("stray end tag")
+	// ErrUnclosedFormatting is a formatting marker like ** closed by the end of its paragraph.
+	ErrUnclosedFormatting = errors.New("unclosed formatting")
+	// ErrInvalidURL is an external link whose URL can not be parsed.
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrUnmatchedBrackets is a [[ or {{ never closed in its paragraph, or a ]] or }} closing
+	// nothing, they are kept as text.
+	ErrUnmatchedBrackets = errors.New("unmatched brackets")
+	// ErrInvalidMediaSize is a size of a media that is not a number, or only zeros, no size
+	// is requested then.
+	ErrInvalidMediaSize = errors.New("invalid media size")
+	// ErrUnsupported is a construct of the input that has no equivalent and was approximated.
+	ErrUnsupported = errors.New("unsupported syntax")
+)
+
+// diagnosticKinds gives the severity and the sentinel of every code.
+var diagnosticKinds = map[string]struct {
+	severity Severity
+	err      error
+}{
+	CodeUnclosedCodeTag:     {SeverityError, ErrUnclosedTag},
+	CodeUnclosedFileTag:     {SeverityError, ErrUnclosedTag},
+	CodeUnclosedHTMLTag:     {SeverityError, ErrUnclosedTag},
+	CodeUnclosedNoWikiTag:   {SeverityError, ErrUnclosedTag},
+	CodeUnclosedBlock:       {SeverityError, ErrUnclosedBlock},
+	CodeStrayEndTag:         {SeverityWarning, ErrStrayEndTag},
+	CodeUnclosedFormatting:  {SeverityWarning, ErrUnclosedFormatting},
+	CodeInvalidURL:          {SeverityWarning, ErrInvalidURL},
+	CodeUnsupportedMarkdown: {SeverityInfo, ErrUnsupported},
+	CodeUnclosedCodeFence:   {SeverityError, ErrUnclosedTag},
+	CodeUnmatchedBrackets:   {SeverityInfo, ErrUnmatchedBrackets},
+	CodeInvalidMediaSize:    {SeverityWarning, ErrInvalidMediaSize},
+}
+
+// newDiagnostic makes the diagnostic of code at line, 0 when unknown, the message is formatted
+// and prefixed with the line. Every diagnostic is made here, so codes and severities match.
+func newDiagnostic(code string, line int, format string, args ...interface{}) Diagnostic {
+	message := fmt.Sprintf(format, args...)
+	if line > 0 {
+		message = fmt.Sprintf("line %d: %s", line, message)
+	}
+	return Diagnostic{
+		Severity: diagnosticKinds[code].severity,
+		Code:     code,
+		Message:  message,
+		Position: Position{Line: line},
+	}
+}
+
+// AddDiagnostic adds a diagnostic of code at pos to the unit, with a message formatted like
+// fmt.Sprintf. Codes other than the Code constants are warnings. It fails with ErrFrozen when
+// the unit is frozen.
+func (unit *ParseUnit) AddDiagnostic(code string, pos Position, format string, args ...interface{}) error {
+	if unit.frozen {
+		return ErrFrozen
+	}
+	diagnostic := newDiagnostic(code, pos.Line, format, args...)
+	if _, ok := diagnosticKinds[code]; !ok {
+		diagnostic.Severity = SeverityWarning
+	}
+	diagnostic.Span = newLineIndex(unit.source).span(pos.Line)
+	unit.Diagnostics = append(unit.Diagnostics, diagnostic)
+	return nil
+}
+
+func (d Diagnostic) Error() string {
+	return d.Message
+}
+
+// Unwrap returns the sentinel of the kind of d, nil for an unknown code.
+func (d Diagnostic) Unwrap() error {
+	return diagnosticKinds[d.Code].err
+}
+
+// setDiagnosticSpans sets the span of the diagnostics of unit from their line.
+func setDiagnosticSpans(unit *ParseUnit) {
+	if len(unit.Diagnostics) == 0 {
+		return
+	}
+	lines := newLineIndex(unit.source)
+	for i := range unit.Diagnostics {
+		unit.Diagnostics[i].Span = lines.span(unit.Diagnostics[i].Position.Line)
+	}
+}
+
+// lineIndex holds where the lines of a source start, it is made in one pass so that finding
+// the span of a line does not scan the source again.
+type lineIndex struct {
+	length int
+	starts []int
+}
+
+func newLineIndex(source string) lineIndex {
+	starts := []int{0}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{length: len(source), starts: starts}
+}
+
+// span returns the span of line, without its new line, zero when there is no such line.
+func (l lineIndex) span(line int) Span {
+	if line < 1 || line > len(l.starts) {
+		return Span{}
+	}
+	end := l.length
+	if line < len(l.starts) {
+		end = l.starts[line] - 1
+	}
+	return Span{l.starts[line-1], end}
+}
@@ -0,0 +1,90 @@
+package dokuwiki
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticKinds(t *testing.T) {
+	content := "a **b\n\nsee </code> [[http://exa mple.com|bad]]\n\n<code go>\nx\n"
+	unit := Parse([]byte(content), "t")
+	want := []struct {
+		code     string
+		severity Severity
+		line     int
+		span     Span
+		err      error
+	}{
+		// the diagnostics of the lines come before the ones of the inline elements.
+		{CodeStrayEndTag, SeverityWarning, 3, Span{7, 46}, ErrStrayEndTag},
+		{CodeUnclosedCodeTag, SeverityError, 5, Span{48, 57}, ErrUnclosedTag},
+		{CodeUnclosedFormatting, SeverityWarning, 1, Span{0, 5}, ErrUnclosedFormatting},
+		{CodeInvalidURL, SeverityWarning, 3, Span{7, 46}, ErrInvalidURL},
+	}
+	if len(unit.Diagnostics) != len(want) {
+		t.Fatalf("got diagnostics %v", unit.Diagnostics)
+	}
+	for i, d := range unit.Diagnostics {
+		w := want[i]
+		if d.Code != w.code || d.Severity != w.severity || d.Position.Line != w.line || d.Span != w.span {
+			t.Errorf("diagnostic %d: got %+v", i, d)
+		}
+		if !errors.Is(d, w.err) {
+			t.Errorf("diagnostic %d is not %v", i, w.err)
+		}
+	}
+
+	// only the errors, like a CI would fail on.
+	errorCount := 0
+	for _, d := range unit.Diagnostics {
+		if d.Severity >= SeverityError {
+			errorCount++
+		}
+	}
+	if errorCount != 1 {
+		t.Errorf("got %d errors", errorCount)
+	}
+
+	if err := unit.AddDiagnostic("my-rule", Position{Line: 3}, "about %s", "this"); err != nil {
+		t.Fatal(err)
+	}
+	d := unit.Diagnostics[len(unit.Diagnostics)-1]
+	if d.Code != "my-rule" || d.Severity != SeverityWarning || d.Message != "line 3: about this" || d.Span != (Span{7, 46}) || d.Unwrap() != nil {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestUnmatchedBrackets(t *testing.T) {
+	tests := []struct {
+		content string
+		text    string
+		want    []string
+	}{
+		{"end [[\n", `Text "end [["`, []string{"line 1: unmatched [[ at offset 4 of the paragraph"}},
+		{"x {{\n", `Text "x {{"`, []string{"line 1: unmatched {{ at offset 2 of the paragraph"}},
+		{"a ]] b }}\n", `Text "a ]] b }}"`, []string{"line 1: unmatched ]] at offset 2 of the paragraph", "line 1: unmatched }} at offset 7 of the paragraph"}},
+		{"**bold [[ text** after\n", `Text effect=bold "bold [[ text"`, []string{"line 1: unmatched [[ at offset 7 of the paragraph"}},
+		{"first\n\n  * see [[ here\n", `Text "see [[ here"`, []string{"line 3: unmatched [[ at offset 4 of the paragraph"}},
+		// the scanning goes on after the brackets.
+		{"[[[[a]]\n", `Link internal target="[[a" "[[a"`, nil},
+		{"[[ [[a]]\n", `Link internal target="[[a" " [[a"`, nil},
+		{"a [[b]]]] c\n", `Text "]] c"`, []string{"line 1: unmatched ]] at offset 7 of the paragraph"}},
+		// headings have no links, their brackets are only text.
+		{"== a [[ ==\n", `Text "a [["`, nil},
+		{"%%[[%% {{a}}\n", `NoWiki "[["`, nil},
+	}
+	for _, test := range tests {
+		unit := Parse([]byte(test.content), "t")
+		messages := make([]string, 0)
+		for _, d := range unit.Diagnostics {
+			if d.Code != CodeUnmatchedBrackets || d.Severity != SeverityInfo || !errors.Is(d, ErrUnmatchedBrackets) {
+				t.Errorf("%q: got %+v", test.content, d)
+			}
+			messages = append(messages, d.Message)
+		}
+		if dump := dumpString(unit); !strings.Contains(dump, test.text) || strings.Join(messages, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: got %v\n%s", test.content, messages, dump)
+		}
+	}
+}
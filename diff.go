@@ -0,0 +1,224 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind tells how a block or inline context changed between two revisions.
+type ChangeKind int
+
+const (
+	ChangeInserted ChangeKind = iota
+	ChangeDeleted
+	ChangeModified
+)
+
+var changeKindNames = []string{"inserted", "deleted", "modified"}
+
+func (k ChangeKind) String() string {
+	if k >= 0 && int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// BlockChange is a heading, paragraph or list item that differs between two revisions.
+// List items are compared one by one, their paragraph is the block.
+type BlockChange struct {
+	Kind ChangeKind
+	// Old is nil for an inserted block and New for a deleted one.
+	Old, New BlockContext
+	// OldPosition and NewPosition are where the block is in each revision, the zero
+	// Position on the side it is missing from.
+	OldPosition, NewPosition Position
+	// Inlines lists what changed inside a modified paragraph or list item.
+	Inlines []InlineChange
+}
+
+// InlineChange is an inline context that differs between two revisions of a paragraph.
+type InlineChange struct {
+	Kind     ChangeKind
+	Old, New InlineContext
+}
+
+// DiffUnits compares two revisions of a page block by block, in document order. Blocks are
+// aligned on their kind and text with whitespace collapsed, so rewrapping a paragraph is not a
+// change. Blocks left between two aligned ones are paired as modified when they are of the same
+// kind, a heading is never paired with a paragraph. Moves show as a deletion and an insertion.
+func DiffUnits(old, new *ParseUnit) []BlockChange {
+	oldBlocks, newBlocks := diffBlocks(old), diffBlocks(new)
+	changes := make([]BlockChange, 0)
+	alignDiff(len(oldBlocks), len(newBlocks), func(i, j int) bool {
+		return oldBlocks[i].key == newBlocks[j].key
+	}, func(oldGap, newGap []int) {
+		// pair the blocks of the same kind in order, the others are deleted or inserted.
+		for len(oldGap) > 0 || len(newGap) > 0 {
+			switch {
+			case len(oldGap) > 0 && len(newGap) > 0 && oldBlocks[oldGap[0]].kind == newBlocks[newGap[0]].kind:
+				o, n := oldBlocks[oldGap[0]], newBlocks[newGap[0]]
+				change := BlockChange{Kind: ChangeModified, Old: o.block, New: n.block, OldPosition: o.pos, NewPosition: n.pos}
+				if oldPara, ok := o.block.(*ParaContext); ok {
+					change.Inlines = diffInlines(oldPara.InnerContexts, n.block.(*ParaContext).InnerContexts)
+				}
+				changes = append(changes, change)
+				oldGap, newGap = oldGap[1:], newGap[1:]
+			case len(oldGap) > 0 && (len(newGap) == 0 || !hasKind(newBlocks, newGap, oldBlocks[oldGap[0]].kind)):
+				o := oldBlocks[oldGap[0]]
+				changes = append(changes, BlockChange{Kind: ChangeDeleted, Old: o.block, OldPosition: o.pos})
+				oldGap = oldGap[1:]
+			default:
+				n := newBlocks[newGap[0]]
+				changes = append(changes, BlockChange{Kind: ChangeInserted, New: n.block, NewPosition: n.pos})
+				newGap = newGap[1:]
+			}
+		}
+	})
+	return changes
+}
+
+// diffBlock is a block to compare, kind is "heading", "paragraph", "item" or "quote".
+type diffBlock struct {
+	block BlockContext
+	pos   Position
+	kind  string
+	key   string
+}
+
+func hasKind(blocks []diffBlock, indices []int, kind string) bool {
+	for _, i := range indices {
+		if blocks[i].kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// diffBlocks lists the blocks of unit to compare, the items of lists are listed one by one.
+func diffBlocks(unit *ParseUnit) []diffBlock {
+	blocks := make([]diffBlock, 0)
+	Walk(unit, func(c Context) bool {
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			blocks = append(blocks, diffBlock{c, c.GetPosition(), "heading", fmt.Sprintf("heading %d %s", c.HeaderLevel, collapseSpace(c.HeaderText))})
+		case *ParaContext:
+			kind := "paragraph"
+			if item, ok := c.GetParentContext().(*ListItemContext); ok {
+				ordered := false
+				if list, ok := item.GetParentContext().(*ListContext); ok {
+					ordered = list.Ordered
+				}
+				kind = fmt.Sprintf("item %d %t", item.Depth, ordered)
+			} else if quote, ok := c.GetParentContext().(*QuoteContext); ok {
+				kind = fmt.Sprintf("quote %d", quote.Level)
+			}
+			keys := make([]string, 0, len(c.InnerContexts))
+			for _, inline := range c.InnerContexts {
+				keys = append(keys, inlineKey(inline))
+			}
+			blocks = append(blocks, diffBlock{c, c.GetPosition(), kind, kind + "\n" + strings.Join(keys, "\n")})
+			return false
+		}
+		return true
+	})
+	return blocks
+}
+
+// diffInlines compares the inline contexts of two revisions of a paragraph.
+func diffInlines(old, new []InlineContext) []InlineChange {
+	changes := make([]InlineChange, 0)
+	alignDiff(len(old), len(new), func(i, j int) bool {
+		return inlineKey(old[i]) == inlineKey(new[j])
+	}, func(oldGap, newGap []int) {
+		for len(oldGap) > 0 && len(newGap) > 0 {
+			changes = append(changes, InlineChange{Kind: ChangeModified, Old: old[oldGap[0]], New: new[newGap[0]]})
+			oldGap, newGap = oldGap[1:], newGap[1:]
+		}
+		for _, i := range oldGap {
+			changes = append(changes, InlineChange{Kind: ChangeDeleted, Old: old[i]})
+		}
+		for _, j := range newGap {
+			changes = append(changes, InlineChange{Kind: ChangeInserted, New: new[j]})
+		}
+	})
+	return changes
+}
+
+// inlineKey describes an inline context for comparing it, with whitespace collapsed.
+func inlineKey(inline InlineContext) string {
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		return fmt.Sprintf("text %d %s", c.EffectType, collapseSpace(c.Text))
+	case *HyperLinkContext:
+		key := fmt.Sprintf("link %d %s %s", c.EffectType, c.HyperLink, collapseSpace(c.Text))
+		if c.Image != nil {
+			key += " " + inlineKey(c.Image)
+		}
+		return key
+	case *MediaContext:
+		return fmt.Sprintf("media %d %s %d %d %d %s", c.EffectType, c.MediaResouce, c.Align, c.Width, c.Height, c.Title)
+	case *CodeFileContext:
+		// whitespace matters in code.
+		return fmt.Sprintf("code %t %s %s %s", c.IsFile, c.Language, c.FileName, c.Text)
+	case *HTMLContext:
+		return fmt.Sprintf("html %t %s", c.IsBlock, c.Text)
+	case *NoWikiContext:
+		return fmt.Sprintf("nowiki %d %s", c.EffectType, c.Text)
+	case *MacroContext:
+		return "macro " + c.Name + " " + c.Args
+	case *AnchorContext:
+		return "anchor " + c.Name
+	case *LineBreakContext:
+		return "line break"
+	}
+	return fmt.Sprintf("%T", inline)
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// alignDiff finds the longest common subsequence of two sequences of n and m elements, the
+// ith of the first matching the jth of the second when equal returns true. gap is called in
+// order for every run of elements between two matches, with the indices left in each sequence,
+// the runs at both ends included. Runs where both sides are empty are skipped.
+func alignDiff(n, m int, equal func(i, j int) bool, gap func(oldGap, newGap []int)) {
+	// lengths[i][j] is the length of the common subsequence of the elements from i and j on.
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(i, j) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	oldGap, newGap := make([]int, 0), make([]int, 0)
+	flush := func() {
+		if len(oldGap) > 0 || len(newGap) > 0 {
+			gap(oldGap, newGap)
+		}
+		oldGap, newGap = make([]int, 0), make([]int, 0)
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal(i, j):
+			flush()
+			i++
+			j++
+		case j == m || (i < n && lengths[i+1][j] >= lengths[i][j+1]):
+			oldGap = append(oldGap, i)
+			i++
+		default:
+			newGap = append(newGap, j)
+			j++
+		}
+	}
+	flush()
+}
@@ -0,0 +1,65 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiffUnits(t *testing.T) {
+	old := Parse([]byte(`====== Guide ======
+This paragraph is
+wrapped on two lines.
+
+Install with **make**.
+
+  * one
+  * two
+
+===== Old =====
+gone
+`), "old")
+	new := Parse([]byte(`====== Guide ======
+This paragraph is wrapped on two lines.
+
+Install with **make install**.
+
+  * one
+  * two
+  * three
+
+===== New =====
+added
+`), "new")
+
+	got := make([]string, 0)
+	for _, change := range DiffUnits(old, new) {
+		got = append(got, fmt.Sprintf("%s %T %d %d", change.Kind, change.Old, change.OldPosition.Line, change.NewPosition.Line))
+		for _, inline := range change.Inlines {
+			got = append(got, fmt.Sprintf("  %s %q", inline.Kind, inline.New.(*TextEffectContext).Text))
+		}
+	}
+	want := []string{
+		"modified *dokuwiki.ParaContext 5 4",
+		`  modified "make install"`,
+		"inserted <nil> 0 8",
+		"modified *dokuwiki.SectionHeaderContext 10 10",
+		"modified *dokuwiki.ParaContext 11 11",
+		`  modified "added"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestDiffUnitsKinds(t *testing.T) {
+	old := Parse([]byte("== A ==\n"), "old")
+	new := Parse([]byte("text\n"), "new")
+	changes := DiffUnits(old, new)
+	if len(changes) != 2 || changes[0].Kind != ChangeDeleted || changes[1].Kind != ChangeInserted {
+		t.Errorf("a heading and a paragraph were paired: %+v", changes)
+	}
+	if changes := DiffUnits(old, old); len(changes) != 0 {
+		t.Errorf("got changes between identical units: %+v", changes)
+	}
+}
@@ -0,0 +1,575 @@
+package dokuwiki
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DokuWikiRenderer writes a unit back out as DokuWiki markup, parsing the output gives
+// the same tree again. It is the way to save a unit that was changed in code.
+type DokuWikiRenderer struct {
+	Options RendererOptions
+
+	nodeRenderers map[string]func(w io.Writer, node ExtensionContext, r *DokuWikiRenderer) error
+}
+
+// dokuWikiUnportable are the constructs of DokuWiki markup that are deprecated, see
+// AnalyzePortability. Everything else is written back as it was read.
+var dokuWikiUnportable = unportable{
+	html: "deprecated, DokuWiki 2023-04-04 and later no longer allow embedded html",
+	php:  "deprecated, DokuWiki 2023-04-04 and later no longer run embedded php",
+}
+
+func NewDokuWikiRenderer(opts RendererOptions) *DokuWikiRenderer {
+	return &DokuWikiRenderer{Options: opts}
+}
+
+// RegisterNodeRenderer makes r write the extension contexts of the given kind with fn instead
+// of their WikiSource, registering a kind again replaces its function.
+func (r *DokuWikiRenderer) RegisterNodeRenderer(kind string, fn func(w io.Writer, node ExtensionContext, r *DokuWikiRenderer) error) {
+	if r.nodeRenderers == nil {
+		r.nodeRenderers = make(map[string]func(io.Writer, ExtensionContext, *DokuWikiRenderer) error)
+	}
+	r.nodeRenderers[kind] = fn
+}
+
+// renderExtension writes an extension context with its node renderer, or its source. Without
+// either, UnknownExtension decides.
+func (r *DokuWikiRenderer) renderExtension(rw *renderWriter, node ExtensionContext) {
+	if fn := r.nodeRenderers[node.Kind()]; fn != nil {
+		rw.renderNode(func(w io.Writer) error { return fn(w, node, r) })
+	} else if source, ok := node.(SourceContext); ok {
+		rw.write(source.WikiSource())
+	} else {
+		r.Options.renderUnknownExtension(rw, node, rw.write)
+	}
+}
+
+func (r *DokuWikiRenderer) Render(w io.Writer, unit *ParseUnit) error {
+	if err := r.Options.validate(unit); err != nil {
+		return err
+	}
+	rw := &renderWriter{w: w, effects: unit.effects}
+	for i, block := range unit.Sections {
+		// an empty line keeps paragraphs and lists apart.
+		if i > 0 {
+			rw.write("\n")
+		}
+		switch b := block.(type) {
+		case *SectionHeaderContext:
+			marker := strings.Repeat("=", b.HeaderLevel)
+			rw.write(marker + " " + b.HeaderText + " " + marker + "\n")
+		case *ParaContext:
+			r.renderInlines(rw, b.InnerContexts, true)
+			rw.write("\n")
+		case *ListContext:
+			r.renderList(rw, b, "")
+		case *QuoteContext:
+			r.renderQuote(rw, b, "")
+		case *DefinitionListContext:
+			r.renderDefinitionList(rw, b)
+		case ExtensionContext:
+			r.renderExtension(rw, b)
+			rw.write("\n")
+		}
+	}
+	return rw.err
+}
+
+// renderList writes the items of a list, marks are the > of the quote the list is in, empty
+// for a list outside of quotes. An item is indented by two spaces for every level, in a quote
+// by one space less after the >, a level 1 item is written "> * item".
+func (r *DokuWikiRenderer) renderList(rw *renderWriter, list *ListContext, marks string) {
+	bullet := "* "
+	if list.Ordered {
+		bullet = "- "
+	}
+	indent := strings.Repeat("  ", list.Level)
+	if marks != "" {
+		indent = marks + strings.Repeat(" ", max(2*list.Level-1, 1))
+	}
+	for _, inner := range list.InnerContexts {
+		item, ok := inner.(*ListItemContext)
+		if !ok {
+			continue
+		}
+		for _, block := range item.InnerContexts {
+			switch c := block.(type) {
+			case *ParaContext:
+				rw.write(indent + bullet)
+				r.renderInlines(rw, c.InnerContexts, false)
+				rw.write("\n")
+			case *ListContext:
+				r.renderList(rw, c, marks)
+			case *QuoteContext:
+				// a quote in a list is indented like its items, in a quote it can only be written
+				// as a deeper quote.
+				if marks == "" {
+					r.renderQuote(rw, c, strings.Repeat("  ", list.Level))
+				} else {
+					r.renderQuote(rw, c, marks)
+				}
+			}
+		}
+	}
+}
+
+// quoteListStart is the start of a line of a quote that would be read as a list item.
+var quoteListStart = regexp.MustCompile(`^ *([*-]) `)
+
+// renderQuote writes the lines of a quote after indent, the paragraphs of its levels on a line
+// each. A quote in a list is indented like the items of the list.
+func (r *DokuWikiRenderer) renderQuote(rw *renderWriter, quote *QuoteContext, indent string) {
+	marks := indent + strings.Repeat(">", quote.Level)
+	for _, inner := range quote.InnerContexts {
+		switch c := inner.(type) {
+		case *ParaContext:
+			var b strings.Builder
+			w := &renderWriter{w: &b, effects: rw.effects}
+			r.renderInlines(w, c.InnerContexts, true)
+			if w.err != nil && rw.err == nil {
+				rw.err = w.err
+			}
+			// every line of the paragraph, there is more than one with line breaks, is a line of the quote.
+			for _, text := range strings.Split(b.String(), "\n") {
+				if groups := quoteListStart.FindStringSubmatchIndex(text); groups != nil {
+					text = text[:groups[2]] + "%%" + text[groups[2]:groups[3]] + "%%" + text[groups[3]:]
+				}
+				if text == "" {
+					rw.write(marks + "\n")
+				} else {
+					rw.write(marks + " " + text + "\n")
+				}
+			}
+		case *QuoteContext:
+			r.renderQuote(rw, c, indent)
+		case *ListContext:
+			r.renderList(rw, c, marks)
+		}
+	}
+}
+
+func (r *DokuWikiRenderer) renderDefinitionList(rw *renderWriter, list *DefinitionListContext) {
+	for _, entry := range list.Entries {
+		if entry.Term != nil {
+			rw.write("  ; ")
+			r.renderInlines(rw, entry.Term.InnerContexts, false)
+			rw.write("\n")
+		}
+		for _, definition := range entry.Definitions {
+			rw.write("  : ")
+			r.renderInlines(rw, definition.InnerContexts, false)
+			rw.write("\n")
+		}
+	}
+}
+
+// mediaParamsSource returns the parameters of c as written after its ID, the size first and the
+// other parameters sorted, empty when there is none.
+func mediaParamsSource(c *MediaContext) string {
+	params := make([]string, 0, len(c.Params)+1)
+	if c.Width > 0 || c.Height > 0 {
+		size := ""
+		if c.Width > 0 {
+			size = strconv.FormatInt(c.Width, 10)
+		}
+		if c.Height > 0 {
+			size += "x" + strconv.FormatInt(c.Height, 10)
+		}
+		params = append(params, size)
+	}
+	keys := make([]string, 0, len(c.Params))
+	for key := range c.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if c.Params[key] == "" {
+			params = append(params, key)
+		} else {
+			params = append(params, key+"="+c.Params[key])
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(params, "&")
+}
+
+// codeAttributesSource writes the attributes of a code tag back, sorted by key.
+func codeAttributesSource(attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+`="`+attributes[key]+`"`)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+var dokuwikiEffectMarkers = map[uint32]string{
+	TextEffectBold:      "**",
+	TextEffectItalic:    "//",
+	TextEffectUnderline: "__",
+	TextEffectMonoSpace: "``",
+}
+
+// inlineSource is an inline as renderInlines writes it, the text of a TextEffectContext is only
+// escaped once what follows it is known.
+type inlineSource struct {
+	isText bool
+	effect uint32
+	text   string
+	// source is what is written for the other inlines.
+	source string
+	// lineBreak is set for a LineBreakContext, the line after it starts like a paragraph.
+	lineBreak bool
+}
+
+// renderInlines writes inlines with their text escaped, see escapeText. Text inlines of the same
+// effect next to each other are written as one. For a paragraph, para is true: the new lines of
+// its text are kept and the start of its line is escaped too when it would be read as a heading,
+// a list or a quote.
+func (r *DokuWikiRenderer) renderInlines(rw *renderWriter, inlines []InlineContext, para bool) {
+	sources := make([]inlineSource, 0, len(inlines))
+	for _, inline := range inlines {
+		if text, ok := inline.(*TextEffectContext); ok {
+			if last := len(sources) - 1; last >= 0 && sources[last].isText && sources[last].effect == text.EffectType {
+				sources[last].text += text.Text
+			} else {
+				sources = append(sources, inlineSource{isText: true, effect: text.EffectType, text: text.Text})
+			}
+			continue
+		}
+		if _, ok := inline.(*LineBreakContext); ok {
+			// a line break is a new line, read back as one with ParseOptions.PreserveLineBreaks.
+			// A list item or a definition is one line, the break is a space there.
+			if para {
+				sources = append(sources, inlineSource{source: "\n", lineBreak: true})
+			} else {
+				sources = append(sources, inlineSource{source: " "})
+			}
+			continue
+		}
+		var source strings.Builder
+		inner := &renderWriter{w: &source, effects: rw.effects}
+		r.renderInline(inner, inline)
+		if inner.err != nil && rw.err == nil {
+			rw.err = inner.err
+		}
+		sources = append(sources, inlineSource{source: source.String()})
+	}
+
+	line := joinInlineSources(sources, para, false, rw.effects)
+	if para && readAsBlock(line) {
+		line = joinInlineSources(sources, para, true, rw.effects)
+	}
+	rw.write(line)
+}
+
+// joinInlineSources writes sources one after the other, lines keeps the new lines of the text
+// and lineStart escapes the start of the first source. The start of a source after a line break
+// is escaped too. The registered effects are written with their delimiter in delimiters.
+func joinInlineSources(sources []inlineSource, lines, lineStart bool, delimiters map[TextEffect]string) string {
+	var b strings.Builder
+	for i, source := range sources {
+		if !source.isText {
+			b.WriteString(source.source)
+			continue
+		}
+		open, close := effectMarkerPair(source.effect, delimiters)
+		after := close
+		if after == "" && i+1 < len(sources) {
+			if next := sources[i+1]; next.isText {
+				after, _ = effectMarkerPair(next.effect, delimiters)
+			} else {
+				after = next.source
+			}
+		}
+		before := open
+		if before == "" && i > 0 {
+			if previous := sources[i-1]; previous.isText {
+				_, before = effectMarkerPair(previous.effect, delimiters)
+			} else {
+				before = previous.source
+			}
+		}
+		afterBreak := i > 0 && sources[i-1].lineBreak
+		b.WriteString(open + escapeText(source.text, before, after, open, close, lines, (lineStart && i == 0) || afterBreak) + close)
+	}
+	return b.String()
+}
+
+// readAsBlock tells whether the first line of a paragraph would be read back as a heading, a
+// list item, a definition or a quote.
+func readAsBlock(line string) bool {
+	if i := strings.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	level, _ := parseSectionHeader([]byte(line))
+	return level > 0 || lineBlockStart.MatchString(line)
+}
+
+var (
+	// lineBlockStart is the start of a list item, a definition or a quote, the items and the
+	// quotes indented by two spaces or more or a tab, escapeText escapes its mark, or the first >.
+	lineBlockStart = regexp.MustCompile(`^(?:(?: {2,}|[ \t]*\t[ \t]*)([*-]) |(?:  )+([;:]) |(?: {2,}|[ \t]*\t[ \t]*)?(>))`)
+	// textTag is the name after the < of a tag the parser takes.
+	textTag = regexp.MustCompile(`^(?i)/?(?:code|file|html|nowiki)\b`)
+)
+
+// escapeText returns text written so that the parser reads it back as text: the spans that would
+// be read as markup are written between %%, only them. before and after are what precedes and
+// follows the text, which can make markup with its start and its end, and open and close are
+// the markers of the effects of the text.
+// The new lines of the text are kept when lines is set, and lineStart tells to escape a heading
+// or a list mark at the start of the text. It escapes
+//
+//   - the formatting markers, the run of / after a : aside, and [[, {{, %% and ~~
+//   - a last character making one of those with what follows it, and a : before a //
+//   - a first character making a run of 3 with a marker before it, like the * of ***
+//   - the < of a code, file, html or nowiki tag
+//   - the :// of a URL and the dot after www, so no link is made of them
+//   - a heading, a list mark or the > of a quote at the start of a line after a new line
+//
+// A span with %% or ending with % can not be written between %%, it is written in a nowiki tag
+// instead, which ends the effects: they are closed before it and opened again after it, the
+// span loses them. Without lines, a new line is written as a space, as the parser reads it. The
+// delimiters of registered effects are not escaped.
+func escapeText(text, before, after, open, close string, lines, lineStart bool) string {
+	if !lines {
+		text = strings.ReplaceAll(text, "\n", " ")
+	}
+	// an empty line would end the paragraph, the new lines around one are written as spaces.
+	raw := []byte(text)
+	for i, ch := range raw {
+		if ch == '\n' {
+			start := strings.LastIndexByte(text[:i], '\n') + 1
+			end := strings.IndexByte(text[i+1:], '\n')
+			if strings.TrimSpace(text[start:i]) == "" || (end != -1 && strings.TrimSpace(text[i+1:i+1+end]) == "") {
+				raw[i] = ' '
+			}
+		}
+	}
+	text = string(raw)
+	escaped := make([]bool, len(text))
+	mark := func(start, end int) {
+		for i := start; i < end && i < len(text); i++ {
+			escaped[i] = true
+		}
+	}
+	following := text + after
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		var next byte
+		if i+1 < len(following) {
+			next = following[i+1]
+		}
+		switch {
+		case strings.IndexByte("*_`[{%~", ch) != -1 && next == ch:
+			mark(i, i+2)
+		case ch == '/' && next == '/' && i > 0 && text[i-1] == ':':
+			// the run of / after a : is text, like in file:////server.
+			i += markerRun([]byte(following), i) - 1
+		case ch == '/' && next == '/':
+			mark(i, i+2)
+		case ch == ':' && i == len(text)-1 && strings.HasPrefix(after, "//"):
+			mark(i, i+1)
+		case ch == '<' && textTag.MatchString(text[i+1:]):
+			mark(i, i+1)
+		case i == 0 && strings.IndexByte("*_`/", ch) != -1 && strings.HasSuffix(before, string([]byte{ch, ch})):
+			mark(i, i+1)
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		if (i == 0 && lineStart) || (i > 0 && text[i-1] == '\n') {
+			if n := len(text[i:]) - len(strings.TrimLeft(text[i:], "=")); n > 0 {
+				mark(i, i+n)
+			} else if groups := lineBlockStart.FindStringSubmatchIndex(text[i:]); groups != nil {
+				for groups[2] == -1 {
+					groups = groups[2:]
+				}
+				mark(i+groups[2], i+groups[3])
+			}
+		}
+	}
+	markURLs(text, escaped)
+
+	var b strings.Builder
+	for start := 0; start < len(text); {
+		end := start + 1
+		for end < len(text) && escaped[end] == escaped[start] {
+			end++
+		}
+		if !escaped[start] {
+			b.WriteString(text[start:end])
+		} else {
+			writeEscaped(&b, text[start:end], open, close)
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// markURLs marks in escaped what the parser would link in text, the text is cut where it is
+// escaped, as the parser does, until nothing is left to link.
+func markURLs(text string, escaped []bool) {
+	schemes, www := autolinkRegexp(DefaultAutolinkSchemes, false), autolinkRegexp([]string{}, true)
+	for changed := true; changed; {
+		changed = false
+		for start := 0; start < len(text); {
+			end := start
+			for end < len(text) && !escaped[end] {
+				end++
+			}
+			part := text[start:end]
+			for _, match := range schemes.FindAllStringIndex(part, -1) {
+				i := start + match[0] + strings.Index(part[match[0]:match[1]], "://")
+				escaped[i], escaped[i+1], escaped[i+2] = true, true, true
+				changed = true
+			}
+			for _, match := range www.FindAllStringIndex(part, -1) {
+				escaped[start+match[0]+3] = true
+				changed = true
+			}
+			start = end + 1
+		}
+	}
+}
+
+// writeEscaped writes a span escapeText escapes, its tag starts each in their own %%.
+func writeEscaped(b *strings.Builder, span, open, close string) {
+	for len(span) > 0 {
+		end := 1
+		if span[0] != '<' {
+			if end = strings.IndexByte(span, '<'); end == -1 {
+				end = len(span)
+			}
+		}
+		chunk := span[:end]
+		if strings.Contains(chunk, "%%") || strings.HasSuffix(chunk, "%") {
+			b.WriteString(close + "<nowiki>" + chunk + "</nowiki>" + open)
+		} else {
+			b.WriteString("%%" + chunk + "%%")
+		}
+		span = span[end:]
+	}
+}
+
+// effectMarkerPair returns the markers opening and closing the effects, registered effects are
+// written with their delimiter in delimiters, inside the built in ones.
+func effectMarkerPair(effectType uint32, delimiters map[TextEffect]string) (open, close string) {
+	for _, effect := range effectOrder {
+		if effectType&effect != 0 {
+			open += dokuwikiEffectMarkers[effect]
+			close = dokuwikiEffectMarkers[effect] + close
+		}
+	}
+	for bit := TextEffect(1 << 4); bit != 0; bit <<= 1 {
+		if TextEffect(effectType)&bit != 0 {
+			open += delimiters[bit]
+			close = delimiters[bit] + close
+		}
+	}
+	return open, close
+}
+
+// writeEffectMarkers writes what inner writes between the markers of the effects.
+func writeEffectMarkers(rw *renderWriter, effectType uint32, inner func()) {
+	open, close := effectMarkerPair(effectType, rw.effects)
+	rw.write(open)
+	inner()
+	rw.write(close)
+}
+
+func (r *DokuWikiRenderer) renderLink(rw *renderWriter, c *HyperLinkContext) {
+	switch {
+	case c.IsAutoLink:
+		rw.write(c.Text)
+	case c.Image != nil:
+		rw.write("[[" + c.HyperLink + "|")
+		r.renderInline(rw, c.Image)
+		rw.write("]]")
+	case c.Text == c.HyperLink:
+		rw.write("[[" + c.HyperLink + "]]")
+	default:
+		rw.write("[[" + c.HyperLink + "|" + c.Text + "]]")
+	}
+}
+
+func (r *DokuWikiRenderer) renderMedia(rw *renderWriter, c *MediaContext) {
+	rw.write("{{")
+	if c.Align == AlignLeft {
+		rw.write(" ")
+	}
+	rw.write(c.MediaResouce + mediaParamsSource(c))
+	if c.Align == AlignRight {
+		rw.write(" ")
+	}
+	if c.Title != "" {
+		rw.write("|" + c.Title)
+	}
+	rw.write("}}")
+}
+
+func (r *DokuWikiRenderer) renderInline(rw *renderWriter, inline InlineContext) {
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		writeEffectMarkers(rw, c.EffectType, func() { rw.write(c.Text) })
+	case *HyperLinkContext:
+		writeEffectMarkers(rw, c.EffectType, func() { r.renderLink(rw, c) })
+	case *MediaContext:
+		writeEffectMarkers(rw, c.EffectType, func() { r.renderMedia(rw, c) })
+	case *CodeFileContext:
+		tag := "code"
+		if c.IsFile {
+			tag = "file"
+		}
+		rw.write("<" + tag)
+		if c.Language != "" {
+			rw.write(" " + c.Language)
+		}
+		if c.IsFile && c.FileName != "" {
+			rw.write(" " + c.FileName)
+		}
+		if len(c.Attributes) > 0 {
+			rw.write(" [" + codeAttributesSource(c.Attributes) + "]")
+		}
+		rw.write(">")
+		// the parser drops a new line after the start tag, one is written for the content
+		// starting with a new line and for the blocks of several lines, unless the text kept it.
+		if !c.newlineKept && strings.Contains(c.Text, "\n") {
+			rw.write("\n")
+		}
+		rw.write(c.Text + "</" + tag + ">")
+	case *HTMLContext:
+		if c.IsBlock {
+			rw.write("<HTML>" + c.Text + "</HTML>")
+		} else {
+			rw.write("<html>" + c.Text + "</html>")
+		}
+	case *NoWikiContext:
+		// the effects go on over %%, not over a nowiki tag.
+		if c.EffectType != 0 && !strings.Contains(c.Text, "%%") && !strings.HasSuffix(c.Text, "%") {
+			writeEffectMarkers(rw, c.EffectType, func() { rw.write("%%" + c.Text + "%%") })
+		} else {
+			rw.write("<nowiki>" + c.Text + "</nowiki>")
+		}
+	case *MacroContext:
+		if c.Args != "" {
+			rw.write("~~" + c.Name + ":" + c.Args + "~~")
+		} else {
+			rw.write("~~" + c.Name + "~~")
+		}
+	case *AnchorContext:
+		rw.write("{{anchor:" + c.Name + "}}")
+	case ExtensionContext:
+		r.renderExtension(rw, c)
+	}
+}
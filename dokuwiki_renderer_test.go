@@ -0,0 +1,219 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDokuWikiRendererRoundTrip(t *testing.T) {
+	content := `====== Title ======
+Some **bold //both//** and __under__ ` + "``mono``" + ` text, see [[wiki:syntax#links|the syntax]],
+[[start]] or https://example.com/page and [[start|{{logo.png?20x10|Logo}}]].
+
+{{ left.png}} {{right.png?30 |Right}} {{center.png}}
+
+  * one
+    - nested
+    - ordered
+  * two
+
+<code go>
+fmt.Println("**not bold**")
+</code>
+
+<file text notes.txt>
+plain
+</file> <nowiki>[[not a link]]</nowiki> <html><b>x</b></html>
+`
+	first := Parse([]byte(content), "t")
+	var serialized bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&serialized, first); err != nil {
+		t.Fatal(err)
+	}
+	second := Parse(serialized.Bytes(), "t")
+
+	var want, got bytes.Buffer
+	Dump(&want, first)
+	Dump(&got, second)
+	if got.String() != want.String() {
+		t.Errorf("round trip changed the tree, serialized:\n%s\ngot\n%s\nwant\n%s", serialized.String(), got.String(), want.String())
+	}
+}
+
+// escapeTokens are the pieces of the text of the units built by TestDokuWikiRendererEscaping,
+// the ones with % are only used in text without effects.
+var escapeTokens = []string{
+	"a", "b", " ", " ", "*", "**", "/", "//", ":", "_", "__", "`", "``", "[", "[[", "]]", "{", "{{", "}}",
+	"|", "~", "~~", "~~NOTOC~~", "<code go>", "</code>", "<nowiki>", "</nowiki>", "<html>", "</HTML>", "<file a b>",
+	"http://example.com", "www.example.org", "=", "==", "  * ", "  - ", "  ; ", ">", "  > ", "\n", "%", "%%",
+}
+
+func randomText(rng *rand.Rand, effect uint32) string {
+	var b strings.Builder
+	for n := 1 + rng.Intn(6); n > 0; n-- {
+		token := escapeTokens[rng.Intn(len(escapeTokens))]
+		if effect != 0 && strings.Contains(token, "%") {
+			continue
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+func randomInlines(rng *rand.Rand, parent Context) []InlineContext {
+	inlines := make([]InlineContext, 0)
+	for n := 1 + rng.Intn(5); n > 0; n-- {
+		var inline InlineContext
+		switch rng.Intn(8) {
+		case 0:
+			inline = &MacroContext{Name: "NOTOC"}
+		case 1:
+			link, _ := ParseInline("[[page|label]]")
+			inline = link[0]
+		default:
+			effect := uint32(0)
+			if rng.Intn(2) == 0 {
+				effect = uint32(rng.Intn(16))
+			}
+			inline = &TextEffectContext{EffectType: effect, Text: randomText(rng, effect)}
+		}
+		inline.SetParentContext(parent)
+		inlines = append(inlines, inline)
+	}
+	// a paragraph of spaces only is no paragraph.
+	return append(inlines, &TextEffectContext{BaseInlineContext: BaseInlineContext{BaseContext{parent: parent}}, Text: "z"})
+}
+
+// escapeStructure describes the blocks of unit with their inlines, the text and the nowiki with
+// the same effects next to each other joined, the new lines made spaces, and the spaces at the
+// end of a block and at the start of a list item left out, as the parser reads them.
+func escapeStructure(unit *ParseUnit) []string {
+	structure := make([]string, 0)
+	add := func(kind string, inlines []InlineContext) {
+		line := kind
+		effect, text := uint32(0), ""
+		flush := func() {
+			if kind == "item" && line == kind {
+				text = strings.TrimLeft(text, " ")
+			}
+			if text != "" {
+				line += fmt.Sprintf(" [%d %q]", effect, text)
+			}
+			text = ""
+		}
+		for _, inline := range inlines {
+			switch c := inline.(type) {
+			case *TextEffectContext, *NoWikiContext:
+				e, t := uint32(0), ""
+				if te, ok := c.(*TextEffectContext); ok {
+					e, t = te.EffectType, te.Text
+				} else {
+					e, t = c.(*NoWikiContext).EffectType, c.(*NoWikiContext).Text
+				}
+				if t == "" {
+					continue
+				}
+				if e != effect {
+					flush()
+					effect = e
+				}
+				text += strings.ReplaceAll(t, "\n", " ")
+			case *HyperLinkContext:
+				flush()
+				line += " link " + c.HyperLink
+			case *MacroContext:
+				flush()
+				line += " macro " + c.Name
+			default:
+				flush()
+				line += fmt.Sprintf(" %T", c)
+			}
+		}
+		flush()
+		structure = append(structure, strings.TrimRight(line, " "))
+	}
+	Walk(unit, func(c Context) bool {
+		switch c := c.(type) {
+		case *ParaContext:
+			kind := "para"
+			if _, ok := c.GetParentContext().(*ListItemContext); ok {
+				kind = "item"
+			}
+			add(kind, c.InnerContexts)
+			return false
+		case *ListContext:
+			structure = append(structure, fmt.Sprintf("list %d", c.Level))
+		}
+		return true
+	})
+	return structure
+}
+
+func TestDokuWikiRendererEscaping(t *testing.T) {
+	for seed := int64(0); seed < 2000; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		unit := &ParseUnit{}
+		for n := 1 + rng.Intn(3); n > 0; n-- {
+			var block BlockContext
+			if rng.Intn(3) == 0 {
+				list := &ListContext{Level: 1}
+				item := &ListItemContext{RawIndent: 2, Depth: 1}
+				para := &ParaContext{}
+				para.InnerContexts = randomInlines(rng, para)
+				para.SetParentContext(item)
+				item.InnerContexts = []BlockContext{para}
+				item.SetParentContext(list)
+				list.InnerContexts = []BlockContext{item}
+				block = list
+			} else {
+				para := &ParaContext{}
+				para.InnerContexts = randomInlines(rng, para)
+				block = para
+			}
+			block.SetParentContext(unit)
+			unit.Sections = append(unit.Sections, block)
+		}
+
+		// the parser normalizes, a space between two bold texts is bold.
+		Normalize(unit)
+		var serialized bytes.Buffer
+		if err := NewDokuWikiRenderer(RendererOptions{}).Render(&serialized, unit); err != nil {
+			t.Fatal(err)
+		}
+		want, got := escapeStructure(unit), escapeStructure(Parse(serialized.Bytes(), "t"))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("seed %d, serialized:\n%s\ngot\n%s\nwant\n%s", seed, serialized.String(), strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestDokuWikiRendererEscapesMinimally(t *testing.T) {
+	for text, want := range map[string]string{
+		"plain text, 2*3 and a/b":     "plain text, 2*3 and a/b\n",
+		"a **b** c":                   "a %%**%%b%%**%% c\n",
+		"see [[page]] or {{img.png}}": "see %%[[%%page]] or %%{{%%img.png}}\n",
+		"http://example.com/a//b":     "http%%://%%example.com/a%%//%%b\n",
+		"go to www.example.org":       "go to www%%.%%example.org\n",
+		"<code go> and <b>":           "%%<%%code go> and <b>\n",
+		"== not a heading ==":         "%%==%% not a heading ==\n",
+		"  * not an item":             "  %%*%% not an item\n",
+		"   * not an item":            "   %%*%% not an item\n",
+		"\t- not an item":             "\t%%-%% not an item\n",
+		"> not a quote":               "%%>%% not a quote\n",
+		"100%% sure":                  "100<nowiki>%%</nowiki> sure\n",
+	} {
+		para := &ParaContext{}
+		para.InnerContexts = []InlineContext{&TextEffectContext{Text: text}}
+		var out bytes.Buffer
+		if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, &ParseUnit{Sections: []BlockContext{para}}); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Errorf("%q: got %q, want %q", text, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package dokuwikitest runs golden file tests of the parser and its renderers: every input page
+// in a directory, name.txt, is parsed and its tree and rendering compared to the expected ones
+// next to it, name.dump in the format of dokuwiki.Dump and name.html. Forks with more syntax,
+// plugins and renderers can test against the same corpus as the parser.
+//
+// Running the tests with DOKUWIKITEST_UPDATE=1 in the environment writes the golden files from
+// the current output instead.
+package dokuwikitest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// UpdateEnv is the environment variable that, when not empty, makes Run and Compare write the
+// golden files from the current output. It is not a flag, which would clash with the flags of
+// the test binaries using the package.
+const UpdateEnv = "DOKUWIKITEST_UPDATE"
+
+// updating tells whether UpdateEnv is set.
+func updating() bool {
+	return os.Getenv(UpdateEnv) != ""
+}
+
+// Config tells Run how to parse and render the fixtures, the zero value uses dokuwiki.New and
+// an HTMLRenderer without options.
+type Config struct {
+	Parser   *dokuwiki.Parser
+	Renderer dokuwiki.Renderer
+	// HTMLExtension is the extension of the html files, .html when empty. Another one, like
+	// .compact.html, runs the same fixtures with a renderer of other options.
+	HTMLExtension string
+	// Update writes the golden files like UpdateEnv does.
+	Update bool
+}
+
+// Fixture is an input page of a directory with the paths of its golden files, which may not exist.
+type Fixture struct {
+	// Name is the name of the input file without .txt, it names the subtest.
+	Name  string
+	Input []byte
+	// DumpFile and HTMLFile are the paths of the expected tree and html.
+	DumpFile string
+	HTMLFile string
+}
+
+// Load returns the fixtures of dir, sorted by name, the input files are the ones ending in .txt.
+// The line endings of the inputs are normalized to \n.
+func Load(dir string) ([]Fixture, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	fixtures := make([]Fixture, 0, len(names))
+	for _, name := range names {
+		input, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		base := strings.TrimSuffix(name, ".txt")
+		fixtures = append(fixtures, Fixture{
+			Name:     filepath.Base(base),
+			Input:    normalize(input),
+			DumpFile: base + ".dump",
+			HTMLFile: base + ".html",
+		})
+	}
+	return fixtures, nil
+}
+
+// Run runs every fixture of dir as a subtest. The tree of the input is compared to the dump
+// file and its rendering to the html file. A fixture needs at least one of them, when updating the
+// existing ones are written and the dump file is made for the fixtures without either.
+func Run(t *testing.T, dir string, cfg Config) {
+	t.Helper()
+	fixtures, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures in %s", dir)
+	}
+	parser := cfg.Parser
+	if parser == nil {
+		parser = dokuwiki.New()
+	}
+	renderer := cfg.Renderer
+	if renderer == nil {
+		renderer = dokuwiki.NewHTMLRenderer(dokuwiki.RendererOptions{})
+	}
+	for _, fixture := range fixtures {
+		if cfg.HTMLExtension != "" {
+			fixture.HTMLFile = strings.TrimSuffix(fixture.HTMLFile, ".html") + cfg.HTMLExtension
+		}
+		t.Run(fixture.Name, func(t *testing.T) {
+			unit := parser.Parse(fixture.Input, fixture.Name)
+			hasDump, hasHTML := exists(fixture.DumpFile), exists(fixture.HTMLFile)
+			if !hasDump && !hasHTML && !(cfg.Update || updating()) {
+				t.Fatalf("no %s nor %s, run with %s=1 to write them", fixture.DumpFile, fixture.HTMLFile, UpdateEnv)
+			}
+			if hasDump || !hasHTML {
+				var out bytes.Buffer
+				if err := dokuwiki.Dump(&out, unit); err != nil {
+					t.Fatal(err)
+				}
+				compare(t, fixture.DumpFile, out.Bytes(), cfg.Update)
+			}
+			if hasHTML {
+				var out bytes.Buffer
+				if err := renderer.Render(&out, unit); err != nil {
+					t.Fatal(err)
+				}
+				compare(t, fixture.HTMLFile, out.Bytes(), cfg.Update)
+			}
+		})
+	}
+}
+
+// Compare compares got to the content of the golden file, both with their line endings
+// normalized, and reports a diff of them. With UpdateEnv set it writes got to the file instead.
+func Compare(t testing.TB, goldenFile string, got []byte) {
+	t.Helper()
+	compare(t, goldenFile, got, false)
+}
+
+func compare(t testing.TB, goldenFile string, got []byte, forceUpdate bool) {
+	t.Helper()
+	got = normalize(got)
+	if forceUpdate || updating() {
+		if err := os.WriteFile(goldenFile, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := Diff(string(normalize(want)), string(got)); diff != "" {
+		t.Errorf("%s differs, - expected + got:\n%s", goldenFile, diff)
+	}
+}
+
+// normalize turns the \r\n and \r line endings into \n.
+func normalize(b []byte) []byte {
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// diffContext is the number of unchanged lines shown around the changed ones.
+const diffContext = 3
+
+// Diff returns the lines of want and got that differ, the ones of want prefixed with "-" and
+// the ones of got with "+", with a few unchanged lines around them, prefixed with a space.
+// Hunks start with the lines they cover, like "@@ want 3,5 got 3,6 @@". It is empty when they
+// are the same.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+		// ai and bi are the numbers of the line in want and got, from 1.
+		ai, bi int
+	}
+	lines := make([]line, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i + 1, j + 1})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, line{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// a hunk goes on while the changes are less than two contexts apart.
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		first, last := max(start-diffContext, 0), min(end+diffContext, len(lines)-1)
+		wantCount, gotCount := 0, 0
+		for _, l := range lines[first : last+1] {
+			if l.op != '+' {
+				wantCount++
+			}
+			if l.op != '-' {
+				gotCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ want %d,%d got %d,%d @@\n", lines[first].ai, wantCount, lines[first].bi, gotCount)
+		for _, l := range lines[first : last+1] {
+			out.WriteString(string(l.op) + l.text + "\n")
+		}
+		start = last + 1
+	}
+	return out.String()
+}
@@ -0,0 +1,67 @@
+package dokuwikitest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	if diff := Diff("a\nb\n", "a\nb\n"); diff != "" {
+		t.Errorf("got diff %q for the same text", diff)
+	}
+	want := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	got := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	// the changes are more than two contexts apart, they are in two hunks.
+	expected := `@@ want 2,7 got 2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+@@ want 10,4 got 10,5 @@
+ 10
+ 11
+ 12
++13
+ 
+`
+	if diff := Diff(want, got); diff != expected {
+		t.Errorf("got diff:\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("bold.txt", "some **bold**\r\n")
+	write("heading.txt", "== Title ==\n")
+	write("heading.html", "")
+
+	Run(t, dir, Config{Update: true})
+	dump, err := os.ReadFile(filepath.Join(dir, "bold.dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dump) != "ParseUnit \"bold\"\n  Para\n    Text \"some \"\n    Text effect=bold \"bold\"\n" {
+		t.Errorf("got dump:\n%s", dump)
+	}
+	if exists(filepath.Join(dir, "heading.dump")) {
+		t.Error("a dump was written for a fixture with only html")
+	}
+	html, err := os.ReadFile(filepath.Join(dir, "heading.html"))
+	if err != nil || len(html) == 0 {
+		t.Errorf("got html %q, %v", html, err)
+	}
+
+	// the golden files written match, whatever the line endings of the input.
+	write("bold.txt", "some **bold**\n")
+	Run(t, dir, Config{})
+}
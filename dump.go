@@ -0,0 +1,119 @@
+package dokuwiki
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Dump writes c and everything below it as an indented tree, one context per line.
+// The format is meant for debugging and tests, it is stable and diff friendly:
+//
+//	ParseUnit "title"
+//	  SectionHeader level=6 "Heading"
+//	    Text "Heading"
+//	  List level=2 unordered
+//	    Para
+//	      Text effect=bold "item"
+func Dump(w io.Writer, c Context) error {
+	rw := &renderWriter{w: w, effects: unitEffects(c)}
+	dump(rw, c, 0)
+	return rw.err
+}
+
+func dump(rw *renderWriter, c Context, depth int) {
+	rw.write(strings.Repeat("  ", depth))
+	switch c := c.(type) {
+	case *ParseUnit:
+		rw.printf("ParseUnit %q\n", c.Title)
+	case *SectionHeaderContext:
+		rw.printf("SectionHeader level=%d %q\n", c.HeaderLevel, c.HeaderText)
+	case *ListContext:
+		kind := "unordered"
+		if c.Ordered {
+			kind = "ordered"
+		}
+		rw.printf("List level=%d %s\n", c.Level, kind)
+	case *ListItemContext:
+		rw.printf("ListItem depth=%d indent=%d\n", c.Depth, c.RawIndent)
+	case *QuoteContext:
+		rw.printf("Quote level=%d\n", c.Level)
+	case *DefinitionListContext:
+		rw.write("DefinitionList\n")
+		// the paragraphs are said to be terms or definitions.
+		for _, entry := range c.Entries {
+			if entry.Term != nil {
+				rw.write(strings.Repeat("  ", depth+1) + "Term\n")
+				for _, child := range children(entry.Term) {
+					dump(rw, child, depth+2)
+				}
+			}
+			for _, definition := range entry.Definitions {
+				rw.write(strings.Repeat("  ", depth+1) + "Definition\n")
+				for _, child := range children(definition) {
+					dump(rw, child, depth+2)
+				}
+			}
+		}
+		return
+	case *ParaContext:
+		rw.write("Para\n")
+	case *TextEffectContext:
+		if c.EffectType != 0 {
+			rw.printf("Text effect=%s %q\n", TextEffect(c.EffectType).format(rw.effects), c.Text)
+		} else {
+			rw.printf("Text %q\n", c.Text)
+		}
+	case *HyperLinkContext:
+		rw.printf("Link %s%s target=%q %q\n", c.Kind, dumpEffect(c.EffectType, rw.effects), c.HyperLink, c.Text)
+	case *MediaContext:
+		resolved := ""
+		if c.ResolvedID != "" {
+			resolved = " resolved=" + strconv.Quote(c.ResolvedID)
+		}
+		rw.printf("Media%s %q%s align=%d width=%d height=%d title=%q\n", dumpEffect(c.EffectType, rw.effects), c.MediaResouce, resolved, c.Align, c.Width, c.Height, c.Title)
+	case *CodeFileContext:
+		attributes := ""
+		if len(c.Attributes) > 0 {
+			attributes = " [" + codeAttributesSource(c.Attributes) + "]"
+		}
+		if c.IsFile {
+			rw.printf("File lang=%q name=%q%s %q\n", c.Language, c.FileName, attributes, c.Text)
+		} else {
+			rw.printf("Code lang=%q%s %q\n", c.Language, attributes, c.Text)
+		}
+	case *HTMLContext:
+		if c.IsBlock {
+			rw.printf("HTML block %q\n", c.Text)
+		} else {
+			rw.printf("HTML %q\n", c.Text)
+		}
+	case *MacroContext:
+		if c.Args != "" {
+			rw.printf("Macro %q %q\n", c.Name, c.Args)
+		} else {
+			rw.printf("Macro %q\n", c.Name)
+		}
+	case *LineBreakContext:
+		rw.write("LineBreak\n")
+	case *AnchorContext:
+		rw.printf("Anchor %q id=%q\n", c.Name, c.ID())
+	case *NoWikiContext:
+		rw.printf("NoWiki%s %q\n", dumpEffect(c.EffectType, rw.effects), c.Text)
+	case ExtensionContext:
+		rw.printf("Extension %s %T\n", c.Kind(), c)
+	default:
+		rw.printf("%T\n", c)
+	}
+	for _, child := range children(c) {
+		dump(rw, child, depth+1)
+	}
+}
+
+// dumpEffect returns the effects around a link, media or nowiki, empty without any.
+func dumpEffect(effectType uint32, delimiters map[TextEffect]string) string {
+	if effectType == 0 {
+		return ""
+	}
+	return " effect=" + TextEffect(effectType).format(delimiters)
+}
@@ -0,0 +1,121 @@
+package dokuwiki
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// TextEffect is a set of text effects, like the EffectType of a TextEffectContext.
+type TextEffect uint32
+
+// builtinEffects are the four effects the parser always knows.
+const builtinEffects = TextEffectBold | TextEffectItalic | TextEffectUnderline | TextEffectMonoSpace
+
+// String spells out the effects like "bold+italic", the bits of the registered effects are
+// written in hex, Dump names them by their delimiter.
+func (e TextEffect) String() string {
+	return e.format(nil)
+}
+
+// format is String with the registered effects named by their delimiters.
+func (e TextEffect) format(delimiters map[TextEffect]string) string {
+	if e == 0 {
+		return "none"
+	}
+	names := make([]string, 0)
+	for i, name := range []string{"bold", "italic", "underline", "monospace"} {
+		if uint32(e)&effectOrder[i] != 0 {
+			names = append(names, name)
+		}
+	}
+	rest := e &^ builtinEffects
+	for rest != 0 {
+		bit := TextEffect(1) << bits.TrailingZeros32(uint32(rest))
+		rest &^= bit
+		if delimiter := delimiters[bit]; delimiter != "" {
+			names = append(names, delimiter)
+		} else {
+			names = append(names, fmt.Sprintf("%#x", uint32(bit)))
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+type customEffect struct {
+	delimiter string
+	bit       TextEffect
+}
+
+// RegisterEffect adds a text effect to p, like ??highlight??: text between two delim is given
+// the effect bit, which must be a single bit above the four built in effects. Effects toggle
+// and nest like the built in ones do. Where several delimiters match, the longest one is taken,
+// a built in one of the same length included, and links and media always come first.
+//
+// Registering a delimiter or a bit again replaces its effect. The effects are kept in
+// ParseOptions.Effects and in the units parsed with them, where the delimiter names the bit
+// for Dump and is written back by DokuWikiRenderer.
+func (p *Parser) RegisterEffect(delim string, bit TextEffect) error {
+	switch {
+	case delim == "":
+		return errors.New("empty effect delimiter")
+	case strings.Contains(delim, "\n"):
+		return fmt.Errorf("invalid effect delimiter %q", delim)
+	case strings.HasPrefix(delim, "[[") || strings.HasPrefix(delim, "{{"):
+		return fmt.Errorf("effect delimiter %q would shadow links or media", delim)
+	case delim == "**" || delim == "//" || delim == "__" || delim == "``":
+		return fmt.Errorf("effect delimiter %q is built in", delim)
+	case bits.OnesCount32(uint32(bit)) != 1 || bit&builtinEffects != 0:
+		return fmt.Errorf("effect %#x is not a single bit above the built in effects", uint32(bit))
+	}
+
+	effects := make(map[TextEffect]string, len(p.Options.Effects)+1)
+	for b, d := range p.Options.Effects {
+		if d != delim {
+			effects[b] = d
+		}
+	}
+	effects[bit] = delim
+	// the map is replaced, not changed, the copies of the options keep theirs.
+	p.Options.Effects = effects
+	return nil
+}
+
+// sortEffects returns the effects of delimiters, longest delimiter first.
+func sortEffects(delimiters map[TextEffect]string) []customEffect {
+	if len(delimiters) == 0 {
+		return nil
+	}
+	effects := make([]customEffect, 0, len(delimiters))
+	for bit, delimiter := range delimiters {
+		effects = append(effects, customEffect{delimiter: delimiter, bit: bit})
+	}
+	sort.Slice(effects, func(i, j int) bool {
+		if len(effects[i].delimiter) != len(effects[j].delimiter) {
+			return len(effects[i].delimiter) > len(effects[j].delimiter)
+		}
+		return effects[i].delimiter < effects[j].delimiter
+	})
+	return effects
+}
+
+// unitEffects returns the delimiters of the registered effects of the unit c is in, nil
+// outside of any.
+func unitEffects(c Context) map[TextEffect]string {
+	if unit := unitOf(c); unit != nil {
+		return unit.effects
+	}
+	return nil
+}
+
+// matchEffect returns the registered effect whose delimiter starts text, if any, the longest one.
+func matchEffect(effects []customEffect, text []byte) (customEffect, bool) {
+	for _, effect := range effects {
+		if len(text) >= len(effect.delimiter) && string(text[:len(effect.delimiter)]) == effect.delimiter {
+			return effect, true
+		}
+	}
+	return customEffect{}, false
+}
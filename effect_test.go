@@ -0,0 +1,89 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegisterEffect(t *testing.T) {
+	const highlight, mark, strong = TextEffect(1 << 4), TextEffect(1 << 5), TextEffect(1 << 6)
+	parser := NewParser(ParseOptions{})
+	for delim, bit := range map[string]TextEffect{"??": highlight, "%": mark, "%%": strong} {
+		if err := parser.RegisterEffect(delim, bit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, invalid := range []struct {
+		delim string
+		bit   TextEffect
+	}{{"", 1 << 7}, {"**", 1 << 7}, {"[[x", 1 << 7}, {"{{", 1 << 7}, {"!!", TextEffectBold}, {"!!", 3 << 7}} {
+		if err := parser.RegisterEffect(invalid.delim, invalid.bit); err == nil {
+			t.Errorf("RegisterEffect(%q, %#x) gave no error", invalid.delim, uint32(invalid.bit))
+		}
+	}
+
+	content := "a ??high **both?? bold** %one% %%two%% [[??page??]] {{%x%.png}}\n"
+	unit := parser.Parse([]byte(content), "effects")
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "effects"
+  Para
+    Text "a "
+    Text effect=?? "high "
+    Text effect=bold+?? "both"
+    Text effect=bold " bold"
+    Text " "
+    Text effect=% "one"
+    Text " "
+    Text effect=%% "two"
+    Text " "
+    Link internal target="??page??" "??page??"
+    Text " "
+    Media "%x%.png" align=1 width=0 height=0 title=""
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+
+	var out bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a ??high ??**??both??**** bold** %one% %%two%% [[??page??]] {{%x%.png}}\n"; out.String() != want {
+		t.Errorf("serialized %q, want %q", out.String(), want)
+	}
+
+	// the effects belong to the parser.
+	if got := Parse([]byte("??a??"), "plain").Sections[0].(*ParaContext).InnerContexts[0].(*TextEffectContext); got.EffectType != 0 {
+		t.Errorf("Parse used a registered effect: %v", TextEffect(got.EffectType))
+	}
+	if got := (TextEffectBold | highlight | 1<<20).String(); got != "bold+0x10+0x100000" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// another parser can give the same bit another delimiter.
+	other := NewParser(ParseOptions{})
+	if err := other.RegisterEffect("!!", highlight); err != nil {
+		t.Fatal(err)
+	}
+	otherUnit := other.Parse([]byte("!!a!! ??b??\n"), "other")
+	if errs := otherUnit.Validate(); len(errs) != 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+	out.Reset()
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, otherUnit); err != nil {
+		t.Fatal(err)
+	}
+	if want := "!!a!! ??b??\n"; out.String() != want {
+		t.Errorf("serialized %q, want %q", out.String(), want)
+	}
+	out.Reset()
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a ??high ??**??both??**** bold**"; !bytes.HasPrefix(out.Bytes(), []byte(want)) {
+		t.Errorf("serialized %q after another parser registered, want %q first", out.String(), want)
+	}
+}
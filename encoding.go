@@ -0,0 +1,586 @@
+package dokuwiki
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const encodingMagic = "dokuwiki-parser unit"
+
+// ErrEncodingVersion is returned by DecodeUnit and DecodeUnitJSON for a unit encoded with another
+// ASTVersion, so they fail instead of giving wrong trees.
+var ErrEncodingVersion = errors.New("unit encoded with another version of the tree")
+
+// EncodeUnit writes unit in the gob format, smaller and faster to load than JSON, for units
+// parsed in one program and loaded in another with DecodeUnit. The parents are not written,
+// DecodeUnit sets them again.
+//
+// Extension contexts are written with gob as they are, they must implement gob.GobEncoder
+// and gob.GobDecoder, BaseBlockContext and BaseInlineContext have no exported fields, and
+// their types must be registered with gob.Register.
+func EncodeUnit(w io.Writer, unit *ParseUnit) error {
+	encoded, err := encodeUnit(unit)
+	if err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(encodingHeader{Magic: encodingMagic, Version: ASTVersion}); err != nil {
+		return err
+	}
+	return enc.Encode(encoded)
+}
+
+// DecodeUnit reads a unit written by EncodeUnit. It fails with ErrEncodingVersion when the
+// unit was written with another ASTVersion.
+func DecodeUnit(r io.Reader) (*ParseUnit, error) {
+	dec := gob.NewDecoder(r)
+	var header encodingHeader
+	if err := dec.Decode(&header); err != nil || header.Magic != encodingMagic {
+		return nil, errors.New("not a unit written by EncodeUnit")
+	}
+	if header.Version != ASTVersion {
+		return nil, versionError(header.Version)
+	}
+	var encoded gobUnit
+	if err := dec.Decode(&encoded); err != nil {
+		return nil, err
+	}
+	return encoded.decode()
+}
+
+// EncodeUnitJSON writes unit as JSON, for the programs in other languages, an object with the
+// ASTVersion and the Unit. The contexts are written like EncodeUnit writes them, a block or an
+// inline is an object with a member named after its kind, like Para or Link, and the lines and
+// spans of the input. It fails for a unit with extension contexts, which JSON can not read back.
+func EncodeUnitJSON(w io.Writer, unit *ParseUnit) error {
+	var extension Context
+	Walk(unit, func(c Context) bool {
+		if _, ok := c.(ExtensionContext); ok && extension == nil {
+			extension = c
+		}
+		return extension == nil
+	})
+	if extension != nil {
+		return fmt.Errorf("extension context %T can not be written as JSON", extension)
+	}
+	encoded, err := encodeUnit(unit)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonUnit{ASTVersion: ASTVersion, Unit: encoded})
+}
+
+// DecodeUnitJSON reads a unit written by EncodeUnitJSON, it fails with ErrEncodingVersion when
+// the unit was written with another ASTVersion.
+func DecodeUnitJSON(r io.Reader) (*ParseUnit, error) {
+	var encoded jsonUnit
+	if err := json.NewDecoder(r).Decode(&encoded); err != nil {
+		return nil, err
+	}
+	if encoded.ASTVersion != ASTVersion {
+		return nil, versionError(encoded.ASTVersion)
+	}
+	return encoded.Unit.decode()
+}
+
+func versionError(version int) error {
+	return fmt.Errorf("%w: version %d, this is version %d", ErrEncodingVersion, version, ASTVersion)
+}
+
+type encodingHeader struct {
+	Magic   string
+	Version int
+}
+
+type jsonUnit struct {
+	ASTVersion int
+	Unit       gobUnit
+}
+
+func encodeUnit(unit *ParseUnit) (gobUnit, error) {
+	encoded := gobUnit{Title: unit.Title, Diagnostics: unit.Diagnostics, Source: unit.source, Effects: unit.effects, PageID: unit.pageID}
+	for _, block := range unit.Sections {
+		b, err := encodeBlock(block)
+		if err != nil {
+			return encoded, err
+		}
+		encoded.Sections = append(encoded.Sections, b)
+	}
+	return encoded, nil
+}
+
+func (encoded gobUnit) decode() (*ParseUnit, error) {
+	unit := &ParseUnit{Title: encoded.Title, Diagnostics: encoded.Diagnostics, source: encoded.Source, effects: encoded.Effects, pageID: encoded.PageID}
+	for _, b := range encoded.Sections {
+		block, err := b.decode(unit)
+		if err != nil {
+			return nil, err
+		}
+		unit.Sections = append(unit.Sections, block)
+	}
+	return unit, nil
+}
+
+// The gob types mirror the contexts, which gob can not write because of their parents. A block
+// or an inline is one of the kinds, the pointer of its kind is set, JSON leaves out the others.
+type gobUnit struct {
+	Title       string
+	Sections    []gobBlock
+	Diagnostics []Diagnostic
+	Source      string
+	Effects     map[TextEffect]string `json:",omitempty"`
+	PageID      string                `json:",omitempty"`
+}
+
+type gobBlock struct {
+	Line           int
+	Span           Span
+	Heading        *gobHeading        `json:",omitempty"`
+	Para           *gobPara           `json:",omitempty"`
+	List           *gobList           `json:",omitempty"`
+	ListItem       *gobListItem       `json:",omitempty"`
+	Quote          *gobQuote          `json:",omitempty"`
+	DefinitionList *gobDefinitionList `json:",omitempty"`
+	Extension      Context            `json:",omitempty"`
+}
+
+type gobHeading struct {
+	Level int
+	Text  string
+	// Parsed is false for the headings built by hand, their InnerContexts are nil.
+	Parsed  bool
+	Inlines []gobInline
+}
+
+type gobPara struct {
+	// RawText is the text the paragraph was parsed from, for the lint rules, with its protected
+	// tags when the parser found them.
+	RawText   string
+	Tags      []gobTag `json:",omitempty"`
+	TagsFound bool     `json:",omitempty"`
+	Inlines   []gobInline
+}
+
+type gobTag struct {
+	Kind                                 int
+	Start, ContentStart, ContentEnd, End int
+	Attributes                           string `json:",omitempty"`
+}
+
+type gobList struct {
+	Level   int
+	Ordered bool
+	Blocks  []gobBlock
+}
+
+type gobListItem struct {
+	RawIndent int
+	Depth     int
+	Blocks    []gobBlock
+}
+
+type gobQuote struct {
+	Level  int
+	Blocks []gobBlock
+}
+
+type gobDefinitionList struct {
+	Entries []gobDefinitionEntry
+}
+
+type gobDefinitionEntry struct {
+	Term        *gobBlock
+	Definitions []gobBlock
+}
+
+type gobInline struct {
+	Line      int
+	Text      *gobText  `json:",omitempty"`
+	NoWiki    *gobText  `json:",omitempty"`
+	HTML      *gobHTML  `json:",omitempty"`
+	Code      *gobCode  `json:",omitempty"`
+	Link      *gobLink  `json:",omitempty"`
+	Media     *gobMedia `json:",omitempty"`
+	Macro     *gobMacro `json:",omitempty"`
+	Anchor    *gobText  `json:",omitempty"`
+	LineBreak bool      `json:",omitempty"`
+	Extension Context   `json:",omitempty"`
+}
+
+type gobText struct {
+	Text       string
+	EffectType uint32
+}
+
+type gobMacro struct {
+	Name string
+	Args string `json:",omitempty"`
+}
+
+type gobHTML struct {
+	Text    string
+	IsBlock bool
+}
+
+type gobCode struct {
+	IsFile     bool
+	Language   string
+	FileName   string
+	Attributes map[string]string
+	Text       string
+	// NewlineKept is CodeFileContext.newlineKept.
+	NewlineKept bool `json:",omitempty"`
+}
+
+type gobLink struct {
+	HyperLink, Scheme, PageID, Namespace, Anchor, Query, Text string
+
+	Kind       LinkKind
+	IsInternal bool
+	IsAutoLink bool
+	EffectType uint32
+	// Labelled is false for the links without InnerContexts.
+	Labelled bool
+	Inlines  []gobInline
+	Image    *gobInline
+}
+
+type gobMedia struct {
+	Width, Height int64
+	Align         int
+	Title         string
+	MediaResouce  string
+	ResolvedID    string
+	IsExternal    bool
+	Params        map[string]string
+	EffectType    uint32
+}
+
+func encodeBlock(block BlockContext) (gobBlock, error) {
+	var b gobBlock
+	if positioned, ok := block.(interface{ GetPosition() Position }); ok {
+		b.Line = positioned.GetPosition().Line
+	}
+	if spanned, ok := block.(interface{ sourceSpan() Span }); ok {
+		b.Span = spanned.sourceSpan()
+	}
+	switch c := block.(type) {
+	case *SectionHeaderContext:
+		inlines, err := encodeInlines(c.InnerContexts)
+		if err != nil {
+			return b, err
+		}
+		b.Heading = &gobHeading{Level: c.HeaderLevel, Text: c.HeaderText, Parsed: c.InnerContexts != nil, Inlines: inlines}
+	case *ParaContext:
+		inlines, err := encodeInlines(c.InnerContexts)
+		if err != nil {
+			return b, err
+		}
+		b.Para = &gobPara{RawText: c.rawText, TagsFound: c.tagsFound, Inlines: inlines}
+		for _, tag := range c.tags {
+			b.Para.Tags = append(b.Para.Tags, gobTag{int(tag.kind), tag.start, tag.contentStart, tag.contentEnd, tag.end, tag.attributes})
+		}
+	case *ListContext:
+		b.List = &gobList{Level: c.Level, Ordered: c.Ordered}
+		for _, inner := range c.InnerContexts {
+			innerBlock, err := encodeBlock(inner)
+			if err != nil {
+				return b, err
+			}
+			b.List.Blocks = append(b.List.Blocks, innerBlock)
+		}
+	case *ListItemContext:
+		b.ListItem = &gobListItem{RawIndent: c.RawIndent, Depth: c.Depth}
+		for _, inner := range c.InnerContexts {
+			innerBlock, err := encodeBlock(inner)
+			if err != nil {
+				return b, err
+			}
+			b.ListItem.Blocks = append(b.ListItem.Blocks, innerBlock)
+		}
+	case *QuoteContext:
+		b.Quote = &gobQuote{Level: c.Level}
+		for _, inner := range c.InnerContexts {
+			innerBlock, err := encodeBlock(inner)
+			if err != nil {
+				return b, err
+			}
+			b.Quote.Blocks = append(b.Quote.Blocks, innerBlock)
+		}
+	case *DefinitionListContext:
+		b.DefinitionList = &gobDefinitionList{}
+		for _, entry := range c.Entries {
+			var e gobDefinitionEntry
+			if entry.Term != nil {
+				term, err := encodeBlock(entry.Term)
+				if err != nil {
+					return b, err
+				}
+				e.Term = &term
+			}
+			for _, definition := range entry.Definitions {
+				d, err := encodeBlock(definition)
+				if err != nil {
+					return b, err
+				}
+				e.Definitions = append(e.Definitions, d)
+			}
+			b.DefinitionList.Entries = append(b.DefinitionList.Entries, e)
+		}
+	case ExtensionContext:
+		b.Extension = c
+	default:
+		return b, fmt.Errorf("can not encode block %T", block)
+	}
+	return b, nil
+}
+
+func encodeInlines(inlines []InlineContext) ([]gobInline, error) {
+	encoded := make([]gobInline, 0, len(inlines))
+	for _, inline := range inlines {
+		i, err := encodeInline(inline)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, i)
+	}
+	return encoded, nil
+}
+
+func encodeInline(inline InlineContext) (gobInline, error) {
+	var i gobInline
+	if positioned, ok := inline.(interface{ GetPosition() Position }); ok {
+		i.Line = positioned.GetPosition().Line
+	}
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		i.Text = &gobText{Text: c.Text, EffectType: c.EffectType}
+	case *NoWikiContext:
+		i.NoWiki = &gobText{Text: c.Text, EffectType: c.EffectType}
+	case *HTMLContext:
+		i.HTML = &gobHTML{Text: c.Text, IsBlock: c.IsBlock}
+	case *CodeFileContext:
+		i.Code = &gobCode{IsFile: c.IsFile, Language: c.Language, FileName: c.FileName, Attributes: c.Attributes, Text: c.Text, NewlineKept: c.newlineKept}
+	case *HyperLinkContext:
+		inlines, err := encodeInlines(c.InnerContexts)
+		if err != nil {
+			return i, err
+		}
+		i.Link = &gobLink{
+			HyperLink: c.HyperLink, Scheme: c.Scheme, PageID: c.PageID, Namespace: c.Namespace,
+			Anchor: c.Anchor, Query: c.Query, Text: c.Text,
+			Kind: c.Kind, IsInternal: c.IsInternal, IsAutoLink: c.IsAutoLink, EffectType: c.EffectType,
+			Labelled: c.InnerContexts != nil, Inlines: inlines,
+		}
+		if c.Image != nil {
+			image, err := encodeInline(c.Image)
+			if err != nil {
+				return i, err
+			}
+			i.Link.Image = &image
+		}
+	case *MediaContext:
+		i.Media = &gobMedia{
+			Width: c.Width, Height: c.Height, Align: c.Align, Title: c.Title, MediaResouce: c.MediaResouce, ResolvedID: c.ResolvedID,
+			IsExternal: c.IsExternal, Params: c.Params, EffectType: c.EffectType,
+		}
+	case *MacroContext:
+		i.Macro = &gobMacro{Name: c.Name, Args: c.Args}
+	case *AnchorContext:
+		i.Anchor = &gobText{Text: c.Name}
+	case *LineBreakContext:
+		i.LineBreak = true
+	case ExtensionContext:
+		i.Extension = c
+	default:
+		return i, fmt.Errorf("can not encode inline %T", inline)
+	}
+	return i, nil
+}
+
+func (b gobBlock) decode(parent Context) (BlockContext, error) {
+	base := BaseBlockContext{BaseContext: BaseContext{parent: parent, pos: Position{Line: b.Line}}, span: b.Span}
+	switch {
+	case b.Heading != nil:
+		c := &SectionHeaderContext{BaseBlockContext: base, HeaderLevel: b.Heading.Level, HeaderText: b.Heading.Text}
+		if b.Heading.Parsed {
+			inlines, err := decodeInlines(b.Heading.Inlines, c)
+			if err != nil {
+				return nil, err
+			}
+			c.InnerContexts = inlines
+		}
+		return c, nil
+	case b.Para != nil:
+		return b.decodePara(parent)
+	case b.List != nil:
+		c := &ListContext{BaseBlockContext: base, Level: b.List.Level, Ordered: b.List.Ordered}
+		for _, inner := range b.List.Blocks {
+			block, err := inner.decode(c)
+			if err != nil {
+				return nil, err
+			}
+			c.InnerContexts = append(c.InnerContexts, block)
+		}
+		return c, nil
+	case b.ListItem != nil:
+		c := &ListItemContext{BaseBlockContext: base, RawIndent: b.ListItem.RawIndent, Depth: b.ListItem.Depth}
+		for _, inner := range b.ListItem.Blocks {
+			block, err := inner.decode(c)
+			if err != nil {
+				return nil, err
+			}
+			c.InnerContexts = append(c.InnerContexts, block)
+		}
+		return c, nil
+	case b.Quote != nil:
+		c := &QuoteContext{BaseBlockContext: base, Level: b.Quote.Level}
+		for _, inner := range b.Quote.Blocks {
+			block, err := inner.decode(c)
+			if err != nil {
+				return nil, err
+			}
+			c.InnerContexts = append(c.InnerContexts, block)
+		}
+		return c, nil
+	case b.DefinitionList != nil:
+		c := &DefinitionListContext{BaseBlockContext: base}
+		for _, e := range b.DefinitionList.Entries {
+			var entry DefinitionEntry
+			if e.Term != nil {
+				term, err := e.Term.decodePara(c)
+				if err != nil {
+					return nil, err
+				}
+				entry.Term = term
+			}
+			for _, d := range e.Definitions {
+				definition, err := d.decodePara(c)
+				if err != nil {
+					return nil, err
+				}
+				entry.Definitions = append(entry.Definitions, definition)
+			}
+			c.Entries = append(c.Entries, entry)
+		}
+		return c, nil
+	case b.Extension != nil:
+		block, ok := b.Extension.(BlockContext)
+		if !ok {
+			return nil, fmt.Errorf("extension %T is not a block", b.Extension)
+		}
+		block.SetParentContext(parent)
+		if positioned, ok := block.(interface{ setPosition(Position) }); ok {
+			positioned.setPosition(Position{Line: b.Line})
+		}
+		if spanned, ok := block.(interface{ setSpan(Span) }); ok {
+			spanned.setSpan(b.Span)
+		}
+		return block, nil
+	}
+	return nil, errors.New("encoded block of no kind")
+}
+
+// decodePara decodes a paragraph, the ones of definition lists can only be paragraphs.
+func (b gobBlock) decodePara(parent Context) (*ParaContext, error) {
+	if b.Para == nil {
+		return nil, errors.New("encoded definition is not a paragraph")
+	}
+	c := &ParaContext{
+		BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: parent, pos: Position{Line: b.Line}}, span: b.Span},
+		rawText:          b.Para.RawText,
+		tagsFound:        b.Para.TagsFound,
+	}
+	for _, tag := range b.Para.Tags {
+		c.tags = append(c.tags, tagRegion{tagKind(tag.Kind), tag.Start, tag.ContentStart, tag.ContentEnd, tag.End, tag.Attributes})
+	}
+	inlines, err := decodeInlines(b.Para.Inlines, c)
+	if err != nil {
+		return nil, err
+	}
+	c.InnerContexts = inlines
+	return c, nil
+}
+
+func decodeInlines(encoded []gobInline, parent Context) ([]InlineContext, error) {
+	inlines := make([]InlineContext, 0, len(encoded))
+	for _, i := range encoded {
+		inline, err := i.decode(parent)
+		if err != nil {
+			return nil, err
+		}
+		inlines = append(inlines, inline)
+	}
+	return inlines, nil
+}
+
+func (i gobInline) decode(parent Context) (InlineContext, error) {
+	base := BaseInlineContext{BaseContext: BaseContext{parent: parent, pos: Position{Line: i.Line}}}
+	switch {
+	case i.Text != nil:
+		return &TextEffectContext{BaseInlineContext: base, Text: i.Text.Text, EffectType: i.Text.EffectType}, nil
+	case i.NoWiki != nil:
+		return &NoWikiContext{BaseInlineContext: base, Text: i.NoWiki.Text, EffectType: i.NoWiki.EffectType}, nil
+	case i.HTML != nil:
+		return &HTMLContext{BaseInlineContext: base, Text: i.HTML.Text, IsBlock: i.HTML.IsBlock}, nil
+	case i.Code != nil:
+		return &CodeFileContext{
+			BaseInlineContext: base, IsFile: i.Code.IsFile, Language: i.Code.Language,
+			FileName: i.Code.FileName, Attributes: i.Code.Attributes, Text: i.Code.Text, newlineKept: i.Code.NewlineKept,
+		}, nil
+	case i.Link != nil:
+		l := i.Link
+		c := &HyperLinkContext{
+			BaseInlineContext: base, HyperLink: l.HyperLink, Scheme: l.Scheme, PageID: l.PageID,
+			Namespace: l.Namespace, Anchor: l.Anchor, Query: l.Query, Text: l.Text,
+			Kind: l.Kind, IsInternal: l.IsInternal, IsAutoLink: l.IsAutoLink, EffectType: l.EffectType,
+		}
+		if l.Labelled {
+			inlines, err := decodeInlines(l.Inlines, c)
+			if err != nil {
+				return nil, err
+			}
+			c.InnerContexts = inlines
+		}
+		if l.Image != nil {
+			image, err := l.Image.decode(c)
+			if err != nil {
+				return nil, err
+			}
+			media, ok := image.(*MediaContext)
+			if !ok {
+				return nil, errors.New("encoded link image is not media")
+			}
+			c.Image = media
+		}
+		return c, nil
+	case i.Media != nil:
+		m := i.Media
+		return &MediaContext{
+			BaseInlineContext: base, Width: m.Width, Height: m.Height, Align: m.Align, Title: m.Title,
+			MediaResouce: m.MediaResouce, ResolvedID: m.ResolvedID, IsExternal: m.IsExternal, Params: m.Params, EffectType: m.EffectType,
+		}, nil
+	case i.Macro != nil:
+		return &MacroContext{BaseInlineContext: base, Name: i.Macro.Name, Args: i.Macro.Args}, nil
+	case i.Anchor != nil:
+		return &AnchorContext{BaseInlineContext: base, Name: i.Anchor.Text}, nil
+	case i.LineBreak:
+		return &LineBreakContext{BaseInlineContext: base}, nil
+	case i.Extension != nil:
+		inline, ok := i.Extension.(InlineContext)
+		if !ok {
+			return nil, fmt.Errorf("extension %T is not an inline", i.Extension)
+		}
+		inline.SetParentContext(parent)
+		if positioned, ok := inline.(interface{ setPosition(Position) }); ok {
+			positioned.setPosition(Position{Line: i.Line})
+		}
+		return inline, nil
+	}
+	return nil, errors.New("encoded inline of no kind")
+}
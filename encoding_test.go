@@ -0,0 +1,162 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeUnit(t *testing.T) {
+	inputs := []string{
+		"====== **Bold** heading ======\n\nSome //text// with [[ns:page#a|a **label**]], [[page|{{logo.png}}]], {{ pic.jpg?20x30&nolink|pic}} and http://example.com.\n\n  * one\n    * two\n  - three\n\n  ; term : definition\n  : more\n\n<code go [enable_line_numbers=\"true\"]>\nx\n</code>\n\n<html>x</html> <nowiki>**a**</nowiki> ~~NOTOC~~ **open\n",
+	}
+	for _, dir := range []string{"testdata/golden", "testdata/conformance"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs = append(inputs, string(content))
+		}
+	}
+
+	for _, input := range inputs {
+		unit := ParseWithOptions([]byte(input), "t", ParseOptions{DefinitionLists: true})
+		unit.Sections = append(unit.Sections, &SectionHeaderContext{BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: unit}}, HeaderLevel: 2, HeaderText: "by hand"})
+		var out bytes.Buffer
+		if err := EncodeUnit(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeUnit(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dumpString(decoded), dumpString(unit); got != want {
+			t.Errorf("got unit:\n%s\nwant:\n%s", got, want)
+		}
+		if errs := decoded.Validate(); errs != nil {
+			t.Errorf("the decoded unit is not valid: %v", errs)
+		}
+		var before, after []Context
+		Walk(unit, func(c Context) bool { before = append(before, c); return true })
+		Walk(decoded, func(c Context) bool { after = append(after, c); return true })
+		for i := range before {
+			b, a := reflect.ValueOf(before[i]).Elem(), reflect.ValueOf(after[i]).Elem()
+			// the contexts are the same but for their parent, which is the decoded one.
+			if b.Type() != a.Type() {
+				t.Errorf("context %d: got %T, want %T", i, after[i], before[i])
+				continue
+			}
+			bc, ac := reflect.New(b.Type()).Elem(), reflect.New(a.Type()).Elem()
+			bc.Set(b)
+			ac.Set(a)
+			bc.Addr().Interface().(Context).SetParentContext(nil)
+			ac.Addr().Interface().(Context).SetParentContext(nil)
+			if _, ok := before[i].(*ParseUnit); ok {
+				continue
+			}
+			if !reflect.DeepEqual(normalizeEmpty(bc), normalizeEmpty(ac)) {
+				t.Errorf("context %d: got %+v, want %+v", i, after[i], before[i])
+			}
+		}
+		if !reflect.DeepEqual(decoded.Diagnostics, unit.Diagnostics) || decoded.source != unit.source || decoded.Title != unit.Title {
+			t.Errorf("got diagnostics %v, want %v", decoded.Diagnostics, unit.Diagnostics)
+		}
+		if !reflect.DeepEqual(Lint(decoded, DefaultRules()...), Lint(unit, DefaultRules()...)) {
+			t.Error("the decoded unit lints differently")
+		}
+	}
+}
+
+// normalizeEmpty returns the fields of a context that are not contexts, gob does not tell nil
+// from empty slices and maps apart, and the children are compared on their own.
+func normalizeEmpty(v reflect.Value) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+		switch value.Kind() {
+		case reflect.Slice, reflect.Map:
+			if value.Len() == 0 {
+				continue
+			}
+			if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Interface {
+				fields[f.Name] = value.Len()
+				continue
+			}
+		case reflect.Ptr:
+			fields[f.Name] = value.IsNil()
+			continue
+		}
+		fields[f.Name] = value.Interface()
+	}
+	return fields
+}
+
+func TestDecodeUnitVersion(t *testing.T) {
+	var out bytes.Buffer
+	enc := gob.NewEncoder(&out)
+	enc.Encode(encodingHeader{Magic: encodingMagic, Version: ASTVersion + 1})
+	enc.Encode(gobUnit{Title: "t"})
+	if _, err := DecodeUnit(&out); !errors.Is(err, ErrEncodingVersion) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := DecodeUnit(bytes.NewReader([]byte("not gob"))); err == nil {
+		t.Error("no error for garbage")
+	}
+}
+
+func TestEncodeUnitJSON(t *testing.T) {
+	files, err := filepath.Glob("testdata/golden/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := ParseWithOptions(content, file, ParseOptions{DefinitionLists: true})
+		var out bytes.Buffer
+		if err := EncodeUnitJSON(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out.Bytes(), []byte(`"ASTVersion": `+strconv.Itoa(ASTVersion))) {
+			t.Errorf("%s: no version in %.100s", file, out.String())
+		}
+		decoded, err := DecodeUnitJSON(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dumpString(decoded), dumpString(unit); got != want {
+			t.Errorf("%s: got unit:\n%s\nwant:\n%s", file, got, want)
+		}
+		if errs := decoded.Validate(); errs != nil {
+			t.Errorf("%s: the decoded unit is not valid: %v", file, errs)
+		}
+	}
+
+	if _, err := DecodeUnitJSON(strings.NewReader(`{"ASTVersion": 1, "Unit": {"Title": "t"}}`)); !errors.Is(err, ErrEncodingVersion) {
+		t.Errorf("got %v", err)
+	}
+	unit := Parse([]byte("a"), "t")
+	para := unit.Sections[0].(*ParaContext)
+	para.InnerContexts = append(para.InnerContexts, &colorContext{Color: "red", Text: "b"})
+	if err := EncodeUnitJSON(io.Discard, unit); err == nil {
+		t.Error("no error for an extension context")
+	}
+}
@@ -0,0 +1,193 @@
+package dokuwiki
+
+import "bytes"
+
+// EventHandler receives the events of ParseEvents. It is any value implementing some of the
+// handler interfaces below, events without a method are not reported.
+type EventHandler interface{}
+
+// HeadingHandler is told about every heading, level is the HeaderLevel of the
+// SectionHeaderContext and pos the bytes of the heading line.
+type HeadingHandler interface {
+	OnHeading(level int, text string, pos Span) error
+}
+
+// ParagraphHandler is told where paragraphs start and end, the inline events of a
+// paragraph come in between.
+type ParagraphHandler interface {
+	OnParagraphStart() error
+	OnParagraphEnd() error
+}
+
+// ListHandler is told where lists start and end, nested lists included, level is the Level of
+// the ListContext. The text of every item is a paragraph.
+type ListHandler interface {
+	OnListStart(ordered bool, level int) error
+	OnListEnd() error
+}
+
+// ListItemHandler is told where the items of lists start and end, their paragraph, sub lists
+// and quotes come in between.
+type ListItemHandler interface {
+	OnListItemStart(depth int) error
+	OnListItemEnd() error
+}
+
+// QuoteHandler is told where quotes start and end, the quotes in them and the quotes in lists
+// included. The lines of a level are a paragraph.
+type QuoteHandler interface {
+	OnQuoteStart(level int) error
+	OnQuoteEnd() error
+}
+
+// TextHandler is told about the text, with its effects. The text of nowiki tags and the
+// labels of links are text too, and a line break is the text "\n".
+type TextHandler interface {
+	OnText(text string, effect TextEffect) error
+}
+
+// LinkHandler is told about links, the label comes as text in between, or as media for an
+// image label.
+type LinkHandler interface {
+	OnLinkStart(kind LinkKind, target string) error
+	OnLinkEnd() error
+}
+
+// MediaHandler is told about media, the images of link labels included.
+type MediaHandler interface {
+	OnMedia(src, title string) error
+}
+
+// CodeBlockHandler is told about code and file tags, lang is empty when the tag has no language.
+type CodeBlockHandler interface {
+	OnCodeBlock(lang, text string) error
+}
+
+// ParseEvents parses content and reports what it finds to h in document order, for tools
+// that only need a few kinds of elements. It runs on ParseStream, so the events always
+// match the tree Parse builds, and no more than one top level block is kept at a time.
+//
+// An error returned by a handler method stops the parse and is returned as is.
+func ParseEvents(content []byte, h EventHandler) error {
+	e := &eventEmitter{h: h, lines: newLineIndex(string(content))}
+	return ParseStream(bytes.NewReader(content), func(block BlockContext) error {
+		e.block(block)
+		return e.err
+	})
+}
+
+// eventEmitter walks the blocks of ParseStream, the first handler error stops it.
+type eventEmitter struct {
+	h EventHandler
+	// lines give the span of headings.
+	lines lineIndex
+	err   error
+	// linkEffect are the effects around the link whose label is emitted.
+	linkEffect TextEffect
+}
+
+func (e *eventEmitter) emit(fn func() error) {
+	if e.err == nil {
+		e.err = fn()
+	}
+}
+
+func (e *eventEmitter) block(block BlockContext) {
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		if h, ok := e.h.(HeadingHandler); ok {
+			e.emit(func() error {
+				return h.OnHeading(b.HeaderLevel, b.HeaderTextPlain(), e.lines.span(b.GetPosition().Line))
+			})
+		}
+	case *ParaContext:
+		h, ok := e.h.(ParagraphHandler)
+		if ok {
+			e.emit(h.OnParagraphStart)
+		}
+		for _, inline := range b.InnerContexts {
+			e.inline(inline)
+		}
+		if ok {
+			e.emit(h.OnParagraphEnd)
+		}
+	case *ListContext:
+		h, ok := e.h.(ListHandler)
+		if ok {
+			e.emit(func() error { return h.OnListStart(b.Ordered, b.Level) })
+		}
+		for _, inner := range b.InnerContexts {
+			e.block(inner)
+		}
+		if ok {
+			e.emit(h.OnListEnd)
+		}
+	case *ListItemContext:
+		h, ok := e.h.(ListItemHandler)
+		if ok {
+			e.emit(func() error { return h.OnListItemStart(b.Depth) })
+		}
+		for _, inner := range b.InnerContexts {
+			e.block(inner)
+		}
+		if ok {
+			e.emit(h.OnListItemEnd)
+		}
+	case *QuoteContext:
+		h, ok := e.h.(QuoteHandler)
+		if ok {
+			e.emit(func() error { return h.OnQuoteStart(b.Level) })
+		}
+		for _, inner := range b.InnerContexts {
+			e.block(inner)
+		}
+		if ok {
+			e.emit(h.OnQuoteEnd)
+		}
+	}
+}
+
+func (e *eventEmitter) inline(inline InlineContext) {
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		e.text(c.Text, TextEffect(c.EffectType))
+	case *NoWikiContext:
+		e.text(c.Text, TextEffect(c.EffectType))
+	case *LineBreakContext:
+		e.text("\n", 0)
+	case *HyperLinkContext:
+		h, ok := e.h.(LinkHandler)
+		if ok {
+			e.emit(func() error { return h.OnLinkStart(c.Kind, c.HyperLink) })
+		}
+		e.linkEffect = TextEffect(c.EffectType)
+		if c.Image != nil {
+			e.inline(c.Image)
+		} else if c.InnerContexts != nil {
+			for _, inner := range c.InnerContexts {
+				e.inline(inner)
+			}
+		} else {
+			e.text(c.Text, 0)
+		}
+		e.linkEffect = 0
+		if ok {
+			e.emit(h.OnLinkEnd)
+		}
+	case *MediaContext:
+		if h, ok := e.h.(MediaHandler); ok {
+			e.emit(func() error { return h.OnMedia(c.MediaResouce, c.Title) })
+		}
+	case *CodeFileContext:
+		if h, ok := e.h.(CodeBlockHandler); ok {
+			e.emit(func() error { return h.OnCodeBlock(c.Language, c.Text) })
+		}
+	}
+}
+
+func (e *eventEmitter) text(text string, effect TextEffect) {
+	if h, ok := e.h.(TextHandler); ok && text != "" {
+		effect |= e.linkEffect
+		e.emit(func() error { return h.OnText(text, effect) })
+	}
+}
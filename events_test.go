@@ -0,0 +1,130 @@
+package dokuwiki
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// eventRecorder records every event as a line of text.
+type eventRecorder struct {
+	events []string
+}
+
+func (r *eventRecorder) add(format string, args ...interface{}) error {
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (r *eventRecorder) OnHeading(level int, text string, pos Span) error {
+	return r.add("heading %d %q %v", level, text, pos)
+}
+func (r *eventRecorder) OnParagraphStart() error { return r.add("para") }
+func (r *eventRecorder) OnParagraphEnd() error   { return r.add("/para") }
+func (r *eventRecorder) OnListStart(ordered bool, level int) error {
+	return r.add("list %v %d", ordered, level)
+}
+func (r *eventRecorder) OnListEnd() error                { return r.add("/list") }
+func (r *eventRecorder) OnListItemStart(depth int) error { return r.add("item %d", depth) }
+func (r *eventRecorder) OnListItemEnd() error            { return r.add("/item") }
+func (r *eventRecorder) OnText(text string, effect TextEffect) error {
+	return r.add("text %v %q", effect, text)
+}
+func (r *eventRecorder) OnLinkStart(kind LinkKind, target string) error {
+	return r.add("link %v %q", kind, target)
+}
+func (r *eventRecorder) OnLinkEnd() error                    { return r.add("/link") }
+func (r *eventRecorder) OnMedia(src, title string) error     { return r.add("media %q %q", src, title) }
+func (r *eventRecorder) OnCodeBlock(lang, text string) error { return r.add("code %q %q", lang, text) }
+
+type headingCollector []string
+
+func (c *headingCollector) OnHeading(level int, text string, pos Span) error {
+	*c = append(*c, text)
+	return nil
+}
+
+func TestParseEvents(t *testing.T) {
+	content := "====== Title ======\n" +
+		"Some **bold** [[page|label]] and [[http://example.com|{{logo.png|Logo}}]].\n" +
+		"\n" +
+		"  * item\n" +
+		"    - sub\n" +
+		"<code go>x</code>\n" +
+		"== Last =="
+	var r eventRecorder
+	if err := ParseEvents([]byte(content), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`heading 6 "Title" {0 19}`,
+		`para`,
+		`text none "Some "`,
+		`text bold "bold"`,
+		`text none " "`,
+		`link internal "page"`,
+		`text none "label"`,
+		`/link`,
+		`text none " and "`,
+		`link external "http://example.com"`,
+		`media "logo.png" "Logo"`,
+		`/link`,
+		`text none "."`,
+		`/para`,
+		`list false 1`,
+		`item 1`,
+		`para`,
+		`text none "item"`,
+		`/para`,
+		`list true 2`,
+		`item 2`,
+		`para`,
+		`text none "sub"`,
+		`/para`,
+		`/item`,
+		`/list`,
+		`/item`,
+		`/list`,
+		`para`,
+		`code "go" "x"`,
+		`/para`,
+		`heading 2 "Last" {133 143}`,
+	}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(r.events, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the headings match the ones of Parse.
+	var headings headingCollector
+	if err := ParseEvents([]byte(streamDoc), &headings); err != nil {
+		t.Fatal(err)
+	}
+	outline := make([]string, 0)
+	for _, block := range Parse([]byte(streamDoc), "").Sections {
+		if header, ok := block.(*SectionHeaderContext); ok {
+			outline = append(outline, header.HeaderText)
+		}
+	}
+	if !reflect.DeepEqual([]string(headings), outline) {
+		t.Errorf("got headings %q, want %q", headings, outline)
+	}
+}
+
+type failingHandler struct{ calls int }
+
+func (h *failingHandler) OnText(text string, effect TextEffect) error {
+	h.calls++
+	return errors.New("stop")
+}
+
+func TestParseEventsHandlerError(t *testing.T) {
+	var h failingHandler
+	if err := ParseEvents([]byte("one\n\ntwo\n"), &h); err == nil || err.Error() != "stop" {
+		t.Errorf("got error %v", err)
+	}
+	if h.calls != 1 {
+		t.Errorf("handler called %d times after its error", h.calls)
+	}
+}
@@ -0,0 +1,73 @@
+package dokuwiki_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+func ExampleParse() {
+	unit := dokuwiki.Parse([]byte("====== Title ======\nSome **bold** text.\n"), "start")
+	for _, block := range unit.Sections {
+		switch block := block.(type) {
+		case *dokuwiki.SectionHeaderContext:
+			fmt.Println("heading:", block.HeaderTextPlain())
+		case *dokuwiki.ParaContext:
+			for _, inline := range block.InnerContexts {
+				if text, ok := inline.(*dokuwiki.TextEffectContext); ok {
+					fmt.Printf("text %q bold=%v\n", text.Text, text.EffectType&dokuwiki.TextEffectBold != 0)
+				}
+			}
+		}
+	}
+	// Output:
+	// heading: Title
+	// text "Some " bold=false
+	// text "bold" bold=true
+	// text " text." bold=false
+}
+
+func ExampleRender_html() {
+	unit := dokuwiki.Parse([]byte("Hello **world**, see [[start]]."), "start")
+	if err := dokuwiki.Render(unit, os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// <p>
+	// Hello <strong>world</strong>, see <a href="start" class="wikilink1" title="start">start</a>.
+	// </p>
+}
+
+func ExampleWalk() {
+	unit := dokuwiki.MustParseFile("testdata/example.txt")
+	dokuwiki.Walk(unit, func(c dokuwiki.Context) bool {
+		if link, ok := c.(*dokuwiki.HyperLinkContext); ok {
+			fmt.Println(link.HyperLink)
+		}
+		return true
+	})
+	// Output:
+	// wp>Compiler
+	// compiler:parsing
+}
+
+func ExampleParseUnit_TOC() {
+	unit := dokuwiki.MustParseFile("testdata/example.txt")
+	for _, entry := range unit.TOC() {
+		fmt.Printf("%s%s #%s\n", strings.Repeat("  ", 6-entry.HeaderLevel), entry.Text, entry.Anchor)
+	}
+	// Output:
+	// How to write a compiler #how_to_write_a_compiler
+	//   Lexing #lexing
+	//   Parsing #parsing
+	//     Error recovery #error_recovery
+}
+
+func ExampleMustParse() {
+	unit := dokuwiki.MustParse(dokuwiki.New().ParseReader(strings.NewReader("  * a\n  * b\n"), "list"))
+	fmt.Println(unit.Title, len(unit.Sections))
+	// Output:
+	// list 1
+}
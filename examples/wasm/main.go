@@ -0,0 +1,18 @@
+//go:build js && wasm
+
+// Command wasm is the WebAssembly module of index.html, a live preview of a DokuWiki page. Build
+// it next to the page and copy the loader of the Go installation:
+//
+//	GOOS=js GOARCH=wasm go build -o preview.wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// then serve the directory over http, browsers do not load WebAssembly from files.
+package main
+
+import "github.com/321cyb/dokuwiki-parser/wasm"
+
+func main() {
+	wasm.Register()
+	// the function stays callable as long as the program runs.
+	select {}
+}
@@ -0,0 +1,88 @@
+package dokuwiki
+
+import "strings"
+
+// TextField selects a part of a unit for PlainText.
+type TextField int
+
+const (
+	// FieldTitle is the title of the unit.
+	FieldTitle TextField = 1 << iota
+	// FieldHeadings is the text of the section headers.
+	FieldHeadings
+	// FieldBody is everything else: paragraphs, list items, link labels and media titles.
+	FieldBody
+)
+
+// TextExtractOptions configures PlainText, the zero value extracts the readable text of all fields.
+type TextExtractOptions struct {
+	// Fields selects the parts to extract, all of them when zero.
+	Fields TextField
+	// HeadingWeight repeats every heading this many times, for indexers that score words by
+	// frequency. Zero means once.
+	HeadingWeight int
+	// IncludeCode, IncludeNoWiki and IncludeHTML add the content of code and file tags,
+	// nowiki tags and html tags, which are left out by default.
+	IncludeCode   bool
+	IncludeNoWiki bool
+	IncludeHTML   bool
+}
+
+// PlainText returns the human readable text of the unit for search indexing: formatting is
+// dropped, links contribute their label but not their target and whitespace is collapsed to
+// single spaces. Unlike TextRenderer it keeps no layout at all.
+func (unit *ParseUnit) PlainText(opts TextExtractOptions) string {
+	if opts.Fields == 0 {
+		opts.Fields = FieldTitle | FieldHeadings | FieldBody
+	}
+	// inline text is written as is so words split by formatting stay whole, blocks are separated by a space.
+	var text strings.Builder
+	add := func(s string) {
+		text.WriteString(s)
+	}
+	addBlock := func(s string) {
+		text.WriteString(" " + s + " ")
+	}
+
+	if opts.Fields&FieldTitle != 0 {
+		addBlock(unit.Title)
+	}
+	Walk(unit, func(c Context) bool {
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			if opts.Fields&FieldHeadings != 0 {
+				for i := 0; i < max(opts.HeadingWeight, 1); i++ {
+					addBlock(c.HeaderTextPlain())
+				}
+			}
+			return false
+		case *ParaContext:
+			addBlock("")
+			return opts.Fields&FieldBody != 0
+		case *TextEffectContext:
+			add(c.Text)
+		case *LineBreakContext:
+			add(" ")
+		case *HyperLinkContext:
+			if c.Image == nil && c.InnerContexts == nil && !c.IsAutoLink && c.Text != c.HyperLink {
+				add(c.Text)
+			}
+		case *MediaContext:
+			add(c.Title)
+		case *CodeFileContext:
+			if opts.IncludeCode {
+				addBlock(c.Text)
+			}
+		case *NoWikiContext:
+			if opts.IncludeNoWiki {
+				add(c.Text)
+			}
+		case *HTMLContext:
+			if opts.IncludeHTML {
+				add(c.Text)
+			}
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(text.String()), " ")
+}
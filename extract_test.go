@@ -0,0 +1,27 @@
+package dokuwiki
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	content := `====== Install   Guide ======
+Run **the   installer**, see [[wiki:setup|the setup page]] or https://example.com.
+
+  * {{logo.png|The logo}} and [[wiki:other]]
+<code go>fmt.Println()</code> <nowiki>**raw**</nowiki> <html><b>x</b></html>
+`
+	unit := Parse([]byte(content), "install")
+	tests := []struct {
+		opts TextExtractOptions
+		want string
+	}{
+		{TextExtractOptions{}, "install Install Guide Run the installer, see the setup page or . The logo and"},
+		{TextExtractOptions{Fields: FieldHeadings, HeadingWeight: 2}, "Install Guide Install Guide"},
+		{TextExtractOptions{Fields: FieldBody, IncludeCode: true, IncludeNoWiki: true, IncludeHTML: true},
+			"Run the installer, see the setup page or . The logo and fmt.Println() **raw** <b>x</b>"},
+	}
+	for _, test := range tests {
+		if got := unit.PlainText(test.opts); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package dokuwiki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ToolInfo describes the tool that made the findings, for WriteFindingsSARIF.
+type ToolInfo struct {
+	Name string
+	// Version and InformationURI are left out of the output when empty.
+	Version        string
+	InformationURI string
+}
+
+// BrokenLinkFindings returns the broken links given by CheckLinks as findings, of the rule
+// broken-link for a missing page and missing-anchor for a missing section. pages are the
+// pages the links were checked in, the File of a finding is the path of its page given by
+// IDToPath.
+func BrokenLinkFindings(pages map[string]*ParseUnit, broken []BrokenLink) []Finding {
+	findings := make([]Finding, 0, len(broken))
+	lines := make(pageLines)
+	for _, link := range broken {
+		finding := Finding{Rule: "broken-link", Severity: SeverityError, Message: fmt.Sprintf("link to missing page %s", link.PageID)}
+		if link.MissingAnchor {
+			finding = Finding{Rule: "missing-anchor", Severity: SeverityWarning, Message: fmt.Sprintf("link to missing section #%s of %s", link.Anchor, link.PageID)}
+		}
+		findings = append(findings, lines.finding(pages, link.From, link.Position, finding))
+	}
+	return findings
+}
+
+// MissingMediaFindings returns the missing media given by CheckMedia as findings of the rule
+// missing-media, like BrokenLinkFindings does.
+func MissingMediaFindings(pages map[string]*ParseUnit, missing []MissingMedia) []Finding {
+	findings := make([]Finding, 0, len(missing))
+	lines := make(pageLines)
+	for _, media := range missing {
+		message := fmt.Sprintf("missing media %s", media.MediaID)
+		switch {
+		case media.MediaID == "" && media.Status == 0:
+			message = fmt.Sprintf("media %s can not be fetched", media.ID)
+		case media.MediaID == "":
+			message = fmt.Sprintf("media %s answered with status %d", media.ID, media.Status)
+		}
+		finding := Finding{Rule: "missing-media", Severity: SeverityError, Message: message}
+		findings = append(findings, lines.finding(pages, media.From, media.Position, finding))
+	}
+	return findings
+}
+
+// pageLines are the line indices of the pages findings are located in, made once per page.
+type pageLines map[string]lineIndex
+
+// finding returns finding at pos of the page from, with its file, span and column.
+func (p pageLines) finding(pages map[string]*ParseUnit, from string, pos Position, finding Finding) Finding {
+	finding.File, finding.Position = IDToPath(from), pos
+	if unit := pages[from]; unit != nil {
+		lines, ok := p[from]
+		if !ok {
+			lines = newLineIndex(unit.source)
+			p[from] = lines
+		}
+		finding.locate(lines)
+	}
+	return finding
+}
+
+// locate sets the span of the finding to its line, and its column to 1, when it has a line but
+// the rule gave neither.
+func (f *Finding) locate(lines lineIndex) {
+	if f.Position.Line > 0 && f.Span == (Span{}) && f.Column == 0 {
+		f.Span, f.Column = lines.span(f.Position.Line), 1
+	}
+}
+
+// sortedFindings returns a copy of findings sorted by file, then by offset in the file, so the
+// output does not change between runs.
+func sortedFindings(findings []Finding) []Finding {
+	sorted := append([]Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Span.Start != b.Span.Start:
+			return a.Span.Start < b.Span.Start
+		case a.Position.Line != b.Position.Line:
+			return a.Position.Line < b.Position.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		case a.Rule != b.Rule:
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+	return sorted
+}
+
+// jsonFinding is a finding as written by WriteFindingsJSON.
+type jsonFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// WriteFindingsJSON writes findings to w as a JSON array, sorted by file then offset. Every
+// finding is an object with its file, line, column, offset and length in bytes, rule,
+// severity and message, the numbers are 0 when unknown.
+func WriteFindingsJSON(w io.Writer, findings []Finding) error {
+	out := make([]jsonFinding, 0, len(findings))
+	for _, f := range sortedFindings(findings) {
+		out = append(out, jsonFinding{
+			File: f.File, Line: f.Position.Line, Column: f.Column, Offset: f.Span.Start, Length: f.Span.End - f.Span.Start,
+			Rule: f.Rule, Severity: f.Severity.String(), Message: f.Message,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// sarifLevels are the SARIF levels of the severities.
+var sarifLevels = map[Severity]string{SeverityInfo: "note", SeverityWarning: "warning", SeverityError: "error"}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteFindingsSARIF writes findings to w as a SARIF 2.1.0 log with one run of tool, sorted
+// by file then offset like WriteFindingsJSON. The rules of the run are the ones of the
+// findings sorted by ID, the info severity is the note level. A finding without a file has
+// no location, one without a line no region.
+func WriteFindingsSARIF(w io.Writer, findings []Finding, tool ToolInfo) error {
+	sorted := sortedFindings(findings)
+	ruleIndex := make(map[string]int)
+	var ids []string
+	for _, f := range sorted {
+		if _, ok := ruleIndex[f.Rule]; !ok {
+			ruleIndex[f.Rule] = 0
+			ids = append(ids, f.Rule)
+		}
+	}
+	sort.Strings(ids)
+	rules := make([]sarifRule, 0, len(ids))
+	for i, id := range ids {
+		ruleIndex[id] = i
+		rules = append(rules, sarifRule{ID: id})
+	}
+
+	results := make([]sarifResult, 0, len(sorted))
+	for _, f := range sorted {
+		result := sarifResult{RuleID: f.Rule, RuleIndex: ruleIndex[f.Rule], Level: sarifLevels[f.Severity], Message: sarifMessage{f.Message}}
+		if result.Level == "" {
+			result.Level = "warning"
+		}
+		if f.File != "" {
+			location := sarifLocation{sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{f.File}}}
+			if f.Position.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: f.Position.Line, StartColumn: f.Column}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{sarifDriver{Name: tool.Name, Version: tool.Version, InformationURI: tool.InformationURI, Rules: rules}},
+			Results: results,
+		}},
+	})
+}
@@ -0,0 +1,122 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteFindingsJSON(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:start": Parse([]byte("====== Start ======\n[[nowhere]]\n\n[[#intro]]\n"), "start"),
+		"about":      Parse([]byte("[[gone]]\n"), "about"),
+	}
+	findings := BrokenLinkFindings(pages, CheckLinks(pages, nil))
+	// the order of the input does not matter.
+	findings[0], findings[2] = findings[2], findings[0]
+
+	var out bytes.Buffer
+	if err := WriteFindingsJSON(&out, findings); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "file": "about.txt",
+    "line": 1,
+    "column": 1,
+    "offset": 0,
+    "length": 8,
+    "rule": "broken-link",
+    "severity": "error",
+    "message": "link to missing page gone"
+  },
+  {
+    "file": "wiki/start.txt",
+    "line": 2,
+    "column": 1,
+    "offset": 20,
+    "length": 11,
+    "rule": "broken-link",
+    "severity": "error",
+    "message": "link to missing page wiki:nowhere"
+  },
+  {
+    "file": "wiki/start.txt",
+    "line": 4,
+    "column": 1,
+    "offset": 33,
+    "length": 10,
+    "rule": "missing-anchor",
+    "severity": "warning",
+    "message": "link to missing section #intro of wiki:start"
+  }
+]
+`
+	if out.String() != want {
+		t.Errorf("got %s", out.String())
+	}
+
+	out.Reset()
+	if err := WriteFindingsJSON(&out, nil); err != nil || out.String() != "[]\n" {
+		t.Errorf("no findings: got %q, %v", out.String(), err)
+	}
+}
+
+func TestWriteFindingsSARIF(t *testing.T) {
+	findings := []Finding{
+		{Rule: "fixme", Severity: SeverityInfo, Message: "FIXME", Position: Position{Line: 3}, File: "a.txt", Column: 5},
+		{Rule: "broken-link", Severity: SeverityError, Message: "gone", Position: Position{Line: 1}, File: "a.txt", Column: 1},
+		{Rule: "fixme", Severity: SeverityWarning, Message: "nowhere"},
+	}
+	var out bytes.Buffer
+	if err := WriteFindingsSARIF(&out, findings, ToolInfo{Name: "lint", Version: "1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name, Version string
+					Rules         []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				RuleIndex int
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name != "lint" || log.Runs[0].Tool.Driver.Version != "1.0" {
+		t.Fatalf("got %s", out.String())
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "broken-link" || run.Tool.Driver.Rules[1].ID != "fixme" {
+		t.Errorf("got rules %+v", run.Tool.Driver.Rules)
+	}
+	var got []string
+	for _, result := range run.Results {
+		location := ""
+		if len(result.Locations) == 1 {
+			l := result.Locations[0].PhysicalLocation
+			location = l.ArtifactLocation.URI + ":" + string(rune('0'+l.Region.StartLine)) + ":" + string(rune('0'+l.Region.StartColumn))
+		}
+		got = append(got, result.Level+" "+run.Tool.Driver.Rules[result.RuleIndex].ID+" "+result.Message.Text+" "+location)
+	}
+	want := []string{"warning fixme nowhere ", "error broken-link gone a.txt:1:1", "note fixme FIXME a.txt:3:5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package dokuwiki
+
+import "errors"
+
+// ErrFrozen is returned when changing a unit after Freeze.
+var ErrFrozen = errors.New("unit is frozen")
+
+// Freeze makes the unit read only, so that it can be rendered and read from many goroutines at
+// once, like one page rendered to several formats. It computes what is kept for reading, the
+// table of contents, and is called once, before the unit is shared.
+//
+// On a frozen unit ReplaceSection, AddDiagnostic, RewriteLinks and Normalize fail with ErrFrozen,
+// Merge copies the blocks instead of moving them, and an Incremental gives a new unit.
+// The fields of the contexts are still exported, changing them is not caught.
+func (unit *ParseUnit) Freeze() {
+	if unit.frozen {
+		return
+	}
+	unit.toc = unit.TOC()
+	unit.frozen = true
+}
+
+// Frozen is true once Freeze was called.
+func (unit *ParseUnit) Frozen() bool {
+	return unit.frozen
+}
@@ -0,0 +1,109 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	content := "====== Intro ======\n\nSome **bold** [[#usage|usage]] and {{logo.png}}.\n\n===== Usage =====\n\n  * one\n  * two\n"
+	unit := Parse([]byte(content), "t")
+	unit.Freeze()
+	if !unit.Frozen() {
+		t.Fatal("the unit is not frozen")
+	}
+
+	toc := unit.TOC()
+	toc[0].Text = "changed"
+	if unit.TOC()[0].Text != "Intro" {
+		t.Error("the table of contents of a frozen unit was changed through TOC")
+	}
+
+	replacement := Parse([]byte("===== Usage =====\n\nnew\n"), "t")
+	if err := unit.ReplaceSection("usage", replacement); !errors.Is(err, ErrFrozen) {
+		t.Errorf("ReplaceSection on a frozen unit: got %v", err)
+	}
+	if err := unit.AddDiagnostic("custom", Position{Line: 1}, "x"); !errors.Is(err, ErrFrozen) {
+		t.Errorf("AddDiagnostic on a frozen unit: got %v", err)
+	}
+	if _, err := RewriteLinks(map[string]*ParseUnit{"t": unit}, map[string]string{"t": "u"}); !errors.Is(err, ErrFrozen) {
+		t.Errorf("RewriteLinks of a frozen unit: got %v", err)
+	}
+
+	// a frozen unit is copied by Merge and stays usable.
+	before := dumpString(unit)
+	merged := Merge([]*ParseUnit{unit, Parse([]byte("===== Intro =====\n"), "u")}, MergeOptions{})
+	if dumpString(unit) != before {
+		t.Errorf("Merge changed a frozen unit:\n%s", dumpString(unit))
+	}
+	if len(merged.Sections) != len(unit.Sections)+1 || merged.Sections[0] == unit.Sections[0] {
+		t.Errorf("got merged unit:\n%s", dumpString(merged))
+	}
+
+	inc := NewIncremental("t", ParseOptions{})
+	first, _ := inc.Update([]byte(content))
+	first.Freeze()
+	second, _ := inc.Update([]byte("new\n\n" + content))
+	if second == first || dumpString(first) != before {
+		t.Error("Update changed a frozen unit")
+	}
+}
+
+func dumpString(c Context) string {
+	var out strings.Builder
+	Dump(&out, c)
+	return out.String()
+}
+
+// TestFreezeConcurrentRender renders one frozen unit from many goroutines, run it with -race.
+func TestFreezeConcurrentRender(t *testing.T) {
+	content := strings.Repeat("===== Part =====\n\nSome **bold //text//** with [[page|a label]], {{logo.png?20}} and http://example.com.\n\n  * one\n    * two\n\n<code go>\nx := 1\n</code>\n\n", 20)
+	unit := Parse([]byte(content), "t")
+	unit.Freeze()
+
+	renderers := []Renderer{
+		NewHTMLRenderer(RendererOptions{}),
+		NewDokuWikiRenderer(RendererOptions{}),
+		NewMarkdownRenderer(RendererOptions{}),
+		NewLaTeXRenderer(RendererOptions{}),
+		NewTextRenderer(RendererOptions{}),
+	}
+	want := make([]string, len(renderers))
+	for i, r := range renderers {
+		var out bytes.Buffer
+		if err := r.Render(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		want[i] = out.String()
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				i := (g + n) % len(renderers)
+				var out bytes.Buffer
+				if err := renderers[i].Render(&out, unit); err != nil || out.String() != want[i] {
+					t.Errorf("renderer %d: got a different output, error %v", i, err)
+				}
+				unit.TOC()
+				unit.Outline()
+				unit.Links()
+				unit.PlainText(TextExtractOptions{})
+				unit.Stats()
+				unit.Validate()
+				if _, err := unit.Section("part1"); err != nil {
+					t.Error(err)
+				}
+				Dump(io.Discard, unit)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,46 @@
+package dokuwiki
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ParseFS parses the file name of fsys, the title of the unit is the base name of the file,
+// like with ParseFile.
+func ParseFS(fsys fs.FS, name string) (*ParseUnit, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content, path.Base(name)), nil
+}
+
+// ParseAllFS parses every .txt file below root in fsys, the result maps DokuWiki page IDs,
+// derived from the path relative to root (ns/page.txt is ns:page), to the parsed units.
+// Other files are skipped. The first error stops the walk and is returned.
+func ParseAllFS(fsys fs.FS, root string) (map[string]*ParseUnit, error) {
+	pages := make(map[string]*ParseUnit)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".txt" {
+			return nil
+		}
+		unit, err := ParseFS(fsys, name)
+		if err != nil {
+			return err
+		}
+		relative := strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+		if root == "." {
+			relative = name
+		}
+		pages[PathToID(relative)] = unit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
@@ -0,0 +1,48 @@
+package dokuwiki
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+var testWiki = fstest.MapFS{
+	"pages/start.txt":          {Data: []byte("====== Welcome ======\n")},
+	"pages/wiki/syntax.txt":    {Data: []byte("====== Syntax ======\n")},
+	"pages/wiki/ns/deep.txt":   {Data: []byte("deep\n")},
+	"pages/wiki/notes.txt.bak": {Data: []byte("backup\n")},
+	"media/logo.png":           {Data: []byte("PNG")},
+}
+
+func TestParseFS(t *testing.T) {
+	unit, err := ParseFS(testWiki, "pages/wiki/syntax.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Title != "syntax.txt" || unit.Sections[0].(*SectionHeaderContext).HeaderText != "Syntax" {
+		t.Errorf("unexpected unit %+v", unit)
+	}
+	if _, err := ParseFS(testWiki, "pages/missing.txt"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestParseAllFS(t *testing.T) {
+	for _, root := range []string{"pages", "."} {
+		pages, err := ParseAllFS(testWiki, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"start", "wiki:syntax", "wiki:ns:deep"}
+		if root == "." {
+			want = []string{"pages:start", "pages:wiki:syntax", "pages:wiki:ns:deep"}
+		}
+		if len(pages) != len(want) {
+			t.Errorf("%s: got %d pages, want %d", root, len(pages), len(want))
+		}
+		for _, id := range want {
+			if pages[id] == nil {
+				t.Errorf("%s: page %s is missing", root, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// GenerateGoFile renders pages, which are keyed by page ID, to html with the default options
+// and writes a Go file of package pkg declaring them as
+//
+//	var Pages = map[string]template.HTML{"wiki:syntax": "<h1 ...", ...}
+//
+// so documentation can be compiled into a binary without parsing it at run time. The file is
+// gofmt-clean and the same pages always give the same file, it is meant to be regenerated by
+// a program run from a //go:generate directive:
+//
+//	//go:generate go run ./gendocs
+//
+// with gendocs calling ParseAllFS and GenerateGoFile.
+func GenerateGoFile(pages map[string]*ParseUnit, pkg string, w io.Writer) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by dokuwiki.GenerateGoFile. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport \"html/template\"\n\n", pkg)
+	src.WriteString("// Pages maps page IDs to their html.\nvar Pages = map[string]template.HTML{\n")
+	renderer := NewHTMLRenderer(RendererOptions{})
+	for _, id := range ids {
+		var page bytes.Buffer
+		if err := renderer.Render(&page, pages[id]); err != nil {
+			return err
+		}
+		fmt.Fprintf(&src, "%s: %s,\n", strconv.Quote(id), strconv.Quote(page.String()))
+	}
+	src.WriteString("}\n")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
@@ -0,0 +1,60 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+func TestGenerateGoFile(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:syntax": Parse([]byte("====== Syntax ======\nUse ``code`` with `backticks` and \"quotes\".\n"), "syntax"),
+		"start":       Parse([]byte("Hello **world**\n"), "start"),
+	}
+	var first, second bytes.Buffer
+	if err := GenerateGoFile(pages, "docs", &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateGoFile(pages, "docs", &second); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Error("the generated file changed between runs")
+	}
+	if formatted, err := format.Source(first.Bytes()); err != nil || !bytes.Equal(formatted, first.Bytes()) {
+		t.Errorf("the generated file is not gofmt-clean: %v\n%s", err, first.String())
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "pages.go", first.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name.Name != "docs" {
+		t.Errorf("package %s, want docs", file.Name.Name)
+	}
+	got := make(map[string]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if kv, ok := n.(*ast.KeyValueExpr); ok {
+			key, _ := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
+			got[key], _ = strconv.Unquote(kv.Value.(*ast.BasicLit).Value)
+		}
+		return true
+	})
+	for id, unit := range pages {
+		var want bytes.Buffer
+		if err := Render(unit, &want); err != nil {
+			t.Fatal(err)
+		}
+		if got[id] != want.String() {
+			t.Errorf("page %s is\n%q\nwant\n%q", id, got[id], want.String())
+		}
+	}
+
+	if err := GenerateGoFile(pages, "my-docs", &first); err == nil {
+		t.Error("no error for an invalid package name")
+	}
+}
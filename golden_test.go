@@ -0,0 +1,25 @@
+package dokuwiki_test
+
+import (
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+	"github.com/321cyb/dokuwiki-parser/dokuwikitest"
+)
+
+// TestGolden runs the fixtures of testdata/golden, write them again with
+// DOKUWIKITEST_UPDATE=1 go test -run TestGolden.
+func TestGolden(t *testing.T) {
+	dokuwikitest.Run(t, "testdata/golden", dokuwikitest.Config{
+		Parser: dokuwiki.New(dokuwiki.WithDefinitionLists()),
+	})
+}
+
+// TestGoldenCompact runs the same fixtures with compact html, which is in the .compact.html files.
+func TestGoldenCompact(t *testing.T) {
+	dokuwikitest.Run(t, "testdata/golden", dokuwikitest.Config{
+		Parser:        dokuwiki.New(dokuwiki.WithDefinitionLists()),
+		Renderer:      dokuwiki.NewHTMLRenderer(dokuwiki.RendererOptions{Compact: true}),
+		HTMLExtension: ".compact.html",
+	})
+}
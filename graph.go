@@ -0,0 +1,139 @@
+package dokuwiki
+
+import (
+	"sort"
+	"strings"
+)
+
+// GraphOptions configures AnalyzeGraphWithOptions, the zero value is usable.
+type GraphOptions struct {
+	// EntryPoints are page IDs that are never reported as orphans, like a landing page
+	// linked from outside of the wiki.
+	EntryPoints []string
+	// StartPage is the page standing for a namespace, "start" when empty. Start pages are
+	// reached from their namespace, so they are not reported as orphans.
+	StartPage string
+	// NoImplicitStartLinks reports start pages without inbound links as orphans too.
+	NoImplicitStartLinks bool
+}
+
+// GraphReport describes how the pages of a wiki link to each other, all page IDs are sorted.
+type GraphReport struct {
+	// Orphans are the pages no other page links to.
+	Orphans []string
+	// DeadEnds are the pages that link to no other page.
+	DeadEnds []string
+	// Clusters are the groups of two or more pages where every page can be reached from
+	// every other one by following links, sorted by their first page.
+	Clusters [][]string
+}
+
+// AnalyzeGraph reports orphan pages, dead ends and clusters of pages, which are keyed by page ID.
+// Only internal links between pages of pages count, links are resolved like BuildBacklinks does.
+func AnalyzeGraph(pages map[string]*ParseUnit) GraphReport {
+	return AnalyzeGraphWithOptions(pages, GraphOptions{})
+}
+
+func AnalyzeGraphWithOptions(pages map[string]*ParseUnit, opts GraphOptions) GraphReport {
+	if opts.StartPage == "" {
+		opts.StartPage = defaultStartPage
+	}
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	exists := func(id string) bool {
+		_, ok := pages[id]
+		return ok
+	}
+
+	// edges maps a page to the sorted pages it links to, without itself.
+	edges := make(map[string][]string, len(ids))
+	linked := make(map[string]bool)
+	for _, from := range ids {
+		targets := make(map[string]bool)
+		for _, link := range pages[from].Links() {
+			if link.Kind != LinkInternal {
+				continue
+			}
+			if to, _ := resolveID(from, link.Target, exists); to != from && exists(to) {
+				targets[to] = true
+				linked[to] = true
+			}
+		}
+		for to := range targets {
+			edges[from] = append(edges[from], to)
+		}
+		sort.Strings(edges[from])
+	}
+
+	entryPoints := make(map[string]bool)
+	for _, id := range opts.EntryPoints {
+		entryPoints[id] = true
+	}
+	report := GraphReport{Orphans: make([]string, 0), DeadEnds: make([]string, 0)}
+	for _, id := range ids {
+		isStart := id == opts.StartPage || strings.HasSuffix(id, ":"+opts.StartPage)
+		if !linked[id] && !entryPoints[id] && (opts.NoImplicitStartLinks || !isStart) {
+			report.Orphans = append(report.Orphans, id)
+		}
+		if len(edges[id]) == 0 {
+			report.DeadEnds = append(report.DeadEnds, id)
+		}
+	}
+	report.Clusters = stronglyConnected(ids, edges)
+	return report
+}
+
+// stronglyConnected returns the strongly connected components with more than one node,
+// using Tarjan's algorithm.
+func stronglyConnected(nodes []string, edges map[string][]string) [][]string {
+	index := make(map[string]int, len(nodes))
+	lowlink := make(map[string]int, len(nodes))
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+	components := make([][]string, 0)
+
+	var visit func(node string)
+	visit = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+		for _, next := range edges[node] {
+			if _, visited := index[next]; !visited {
+				visit(next)
+				lowlink[node] = min(lowlink[node], lowlink[next])
+			} else if onStack[next] {
+				lowlink[node] = min(lowlink[node], index[next])
+			}
+		}
+		if lowlink[node] != index[node] {
+			return
+		}
+		component := make([]string, 0)
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+	for _, node := range nodes {
+		if _, visited := index[node]; !visited {
+			visit(node)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
@@ -0,0 +1,34 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeGraph(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"start":      Parse([]byte("[[wiki:a]] [[https://example.com]]\n"), "start"),
+		"wiki:start": Parse([]byte("nothing here, [[wiki:start]] is me\n"), "start"),
+		"wiki:a":     Parse([]byte("[[b]]\n"), "a"),
+		"wiki:b":     Parse([]byte("[[c]] [[missing]]\n"), "b"),
+		"wiki:c":     Parse([]byte("[[a]]\n"), "c"),
+		"wiki:lost":  Parse([]byte("[[a]] [[d]]\n"), "lost"),
+		"wiki:d":     Parse([]byte("[[lost]]\n"), "d"),
+		"landing":    Parse([]byte("\n"), "landing"),
+	}
+
+	report := AnalyzeGraph(pages)
+	want := GraphReport{
+		Orphans:  []string{"landing"},
+		DeadEnds: []string{"landing", "wiki:start"},
+		Clusters: [][]string{{"wiki:a", "wiki:b", "wiki:c"}, {"wiki:d", "wiki:lost"}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v\nwant %+v", report, want)
+	}
+
+	report = AnalyzeGraphWithOptions(pages, GraphOptions{EntryPoints: []string{"landing"}, NoImplicitStartLinks: true})
+	if want := []string{"start", "wiki:start"}; !reflect.DeepEqual(report.Orphans, want) {
+		t.Errorf("got orphans %q, want %q", report.Orphans, want)
+	}
+}
@@ -0,0 +1,268 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// HandlerOptions configures the handler returned by NewHandler, the zero value is usable.
+type HandlerOptions struct {
+	// BasePath is the URL path the handler is mounted at, like "/wiki", links in the
+	// rendered pages are built below it. The handler itself expects request paths
+	// relative to it, so use http.StripPrefix when mounting it elsewhere than "/".
+	BasePath string
+	// Media is where the media files of the pages are served from, usually DokuWiki's
+	// data/media directory. Media is not served when it is nil.
+	Media fs.FS
+	// StartPage is the page shown for a namespace, "start" when empty.
+	StartPage string
+	// Renderer is used for the page content, its link and media resolvers are replaced
+	// by the handler's own.
+	Renderer RendererOptions
+	// Stylesheet is put in a <style> element of every page, DefaultStylesheet when empty.
+	Stylesheet string
+}
+
+// DefaultStylesheet is a small theme for the pages served by NewHandler.
+const DefaultStylesheet = `body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #333; }
+a.wikilink1 { color: #090; } a.urlextern { color: #436976; }
+pre { background: #f7f9fa; border: 1px dashed #8cacbb; padding: .5em; overflow: auto; }
+img.medialeft { float: left; } img.mediaright { float: right; } img.mediacenter { display: block; margin: 0 auto; }
+.notfound li { margin: .3em 0; }`
+
+// mediaPrefix is the path below BasePath media files are served at.
+const mediaPrefix = "/_media/"
+
+type wikiHandler struct {
+	pages fs.FS
+	opts  HandlerOptions
+}
+
+// NewHandler serves a directory of DokuWiki pages as html, like the data/pages directory of
+// a DokuWiki installation. A request for /ns/page renders ns/page.txt, a request for a
+// namespace, /ns/, renders its start page. Responses carry an ETag, from the modification
+// time and size of the page when fsys knows them and from its content otherwise.
+//
+// Missing pages get a 404 page listing the page IDs closest to the requested one.
+func NewHandler(fsys fs.FS, opts HandlerOptions) http.Handler {
+	opts.BasePath = strings.TrimRight(opts.BasePath, "/")
+	if opts.StartPage == "" {
+		opts.StartPage = "start"
+	}
+	if opts.Stylesheet == "" {
+		opts.Stylesheet = DefaultStylesheet
+	}
+
+	h := &wikiHandler{pages: fsys, opts: opts}
+	h.opts.Renderer.LinkResolver = LinkResolverFunc(h.pageHref)
+	h.opts.Renderer.MediaResolver = handlerMedia{h.opts.BasePath}
+	return h
+}
+
+// handlerMedia builds the URLs of the media served by the handler, which has no detail pages:
+// images link to their file.
+type handlerMedia struct {
+	basePath string
+}
+
+func (m handlerMedia) MediaHref(mediaID string) string {
+	return m.basePath + mediaPrefix + strings.Replace(cleanID(mediaID), ":", "/", -1)
+}
+
+func (m handlerMedia) DetailHref(mediaID string) string {
+	return m.MediaHref(mediaID)
+}
+
+func (h *wikiHandler) pageHref(pageID string) string {
+	if pageID == "" {
+		return ""
+	}
+	return h.opts.BasePath + "/" + strings.Replace(cleanID(pageID), ":", "/", -1)
+}
+
+func (h *wikiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, mediaPrefix) {
+		h.serveMedia(w, r, strings.TrimPrefix(r.URL.Path, mediaPrefix))
+		return
+	}
+
+	name := strings.Trim(strings.Replace(r.URL.Path, ":", "/", -1), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") || strings.HasSuffix(r.URL.Path, ":") {
+		name = path.Join(name, h.opts.StartPage)
+	}
+	name = strings.Replace(cleanID(strings.Replace(name, "/", ":", -1)), ":", "/", -1)
+	h.servePage(w, r, name)
+}
+
+func (h *wikiHandler) servePage(w http.ResponseWriter, r *http.Request, name string) {
+	filename := name + ".txt"
+	if !fs.ValidPath(filename) {
+		h.notFound(w, name)
+		return
+	}
+	content, err := fs.ReadFile(h.pages, filename)
+	if err != nil {
+		h.notFound(w, name)
+		return
+	}
+
+	etag := ""
+	if info, err := fs.Stat(h.pages, filename); err == nil && !info.ModTime().IsZero() {
+		etag = fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	} else {
+		etag = fmt.Sprintf(`"%x"`, sha1.Sum(content))
+	}
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	pageID := strings.Replace(name, "/", ":", -1)
+	unit := ParseWithOptions(content, pageID, ParseOptions{PageID: pageID})
+	title := pageID
+	if outline := unit.Outline(); len(outline) > 0 {
+		title = outline[0].Text
+	}
+
+	// links are relative to the namespace of the page.
+	opts := h.opts.Renderer
+	opts.LinkResolver = LinkResolverFunc(func(target string) string {
+		return h.pageHref(ResolvePageID(pageID, target))
+	})
+	var body bytes.Buffer
+	if err := NewHTMLRenderer(opts).Render(&body, unit); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	h.writePage(w, title, body.String())
+}
+
+func (h *wikiHandler) serveMedia(w http.ResponseWriter, r *http.Request, name string) {
+	if h.opts.Media == nil || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := h.opts.Media.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if seeker, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, info.ModTime(), seeker)
+		return
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(content))
+}
+
+func (h *wikiHandler) notFound(w http.ResponseWriter, name string) {
+	pageID := strings.Replace(name, "/", ":", -1)
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "\n<h1>This page does not exist yet</h1>\n<p>\nThere is no page <code>%s</code>.\n</p>\n", html.EscapeString(pageID))
+	if similar := h.similarPages(pageID, 5); len(similar) > 0 {
+		body.WriteString("<p>\nDid you mean one of these?\n</p>\n<ul class=\"notfound\">\n")
+		for _, id := range similar {
+			fmt.Fprintf(&body, "<li><a href=\"%s\" class=\"wikilink1\">%s</a></li>\n", html.EscapeString(h.pageHref(id)), html.EscapeString(id))
+		}
+		body.WriteString("</ul>\n")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	h.writePage(w, "Page not found", body.String())
+}
+
+// similarPages returns at most limit existing page IDs sorted by their edit distance to pageID,
+// IDs too different to be a typo are left out.
+func (h *wikiHandler) similarPages(pageID string, limit int) []string {
+	type candidate struct {
+		id       string
+		distance int
+	}
+	candidates := make([]candidate, 0)
+	fs.WalkDir(h.pages, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".txt") {
+			return nil
+		}
+		id := PathToID(p)
+		distance := editDistance(id, pageID)
+		// compare the last part alone too, so a page in another namespace is found.
+		if i := strings.LastIndexByte(id, ':'); i != -1 {
+			if d := editDistance(id[i+1:], pageID[strings.LastIndexByte(pageID, ':')+1:]); d < distance {
+				distance = d
+			}
+		}
+		if distance <= len([]rune(pageID))/2+1 {
+			candidates = append(candidates, candidate{id, distance})
+		}
+		return nil
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].id < candidates[j].id
+	})
+	ids := make([]string, 0, limit)
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		ids = append(ids, candidates[i].id)
+	}
+	return ids
+}
+
+func (h *wikiHandler) writePage(w io.Writer, title, body string) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\" />\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n<div class=\"dokuwiki\">\n<div class=\"page\">%s</div>\n</div>\n</body>\n</html>\n",
+		html.EscapeString(title), h.opts.Stylesheet, body)
+}
+
+// editDistance is the Levenshtein distance between a and b, counted in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
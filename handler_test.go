@@ -0,0 +1,99 @@
+package dokuwiki
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newTestHandler() http.Handler {
+	pages := fstest.MapFS{
+		"start.txt":         {Data: []byte("====== Home ======\nSee [[wiki:syntax#links|the syntax]] and {{wiki:logo.png}}.\n"), ModTime: time.Unix(1000, 0)},
+		"wiki/syntax.txt":   {Data: []byte("====== Syntax ======\n")},
+		"wiki/start.txt":    {Data: []byte("namespace start, see [[syntax]] and [[..:start|home]]\n")},
+		"wiki/dokuwiki.txt": {Data: []byte("about\n")},
+		"wiki/media.txt":    {Data: []byte("{{logo.png}} and {{..:wiki:logo.png}}\n")},
+	}
+	media := fstest.MapFS{
+		"wiki/logo.png": {Data: []byte("PNG")},
+	}
+	return NewHandler(pages, HandlerOptions{BasePath: "/docs/", Media: media})
+}
+
+func serve(h http.Handler, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlerServesPages(t *testing.T) {
+	h := newTestHandler()
+
+	rec := serve(h, "GET", "/")
+	body := rec.Body.String()
+	if rec.Code != 200 || !strings.Contains(body, "<title>Home</title>") {
+		t.Fatalf("got %d:\n%s", rec.Code, body)
+	}
+	if !strings.Contains(body, `href="/docs/wiki/syntax#links"`) || !strings.Contains(body, `src="/docs/_media/wiki/logo.png"`) {
+		t.Errorf("links are not resolved to handler URLs:\n%s", body)
+	}
+
+	rec = serve(h, "GET", "/wiki/")
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "namespace start") {
+		t.Errorf("namespace start page: got %d", rec.Code)
+	} else if !strings.Contains(body, `href="/docs/wiki/syntax"`) || !strings.Contains(body, `href="/docs/start"`) {
+		t.Errorf("relative links are not resolved against the page:\n%s", body)
+	}
+	if rec := serve(h, "GET", "/wiki:syntax"); rec.Code != 200 {
+		t.Errorf("colon separated ID: got %d", rec.Code)
+	}
+	if rec := serve(h, "POST", "/"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got %d", rec.Code)
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	h := newTestHandler()
+	for _, target := range []string{"/", "/wiki/syntax"} {
+		etag := serve(h, "GET", target).Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: no ETag", target)
+		}
+		if rec := serve(h, "GET", target, "If-None-Match", etag); rec.Code != http.StatusNotModified {
+			t.Errorf("%s: got %d with a matching ETag", target, rec.Code)
+		}
+	}
+}
+
+func TestHandlerMedia(t *testing.T) {
+	h := newTestHandler()
+	if rec := serve(h, "GET", "/_media/wiki/logo.png"); rec.Code != 200 || rec.Body.String() != "PNG" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, "GET", "/_media/wiki"); rec.Code != 404 {
+		t.Errorf("directory: got %d", rec.Code)
+	}
+	// media IDs are relative to the namespace of the page.
+	rec := serve(h, "GET", "/wiki/media")
+	if body := rec.Body.String(); strings.Count(body, `src="/docs/_media/wiki/logo.png"`) != 2 {
+		t.Errorf("got %s", body)
+	}
+}
+
+func TestHandlerNotFound(t *testing.T) {
+	rec := serve(newTestHandler(), "GET", "/wiki/sytnax")
+	body := rec.Body.String()
+	if rec.Code != 404 || !strings.Contains(body, `<a href="/docs/wiki/syntax" class="wikilink1">wiki:syntax</a>`) {
+		t.Errorf("got %d:\n%s", rec.Code, body)
+	}
+	if strings.Contains(body, "wiki:dokuwiki") {
+		t.Errorf("unrelated page listed:\n%s", body)
+	}
+}
@@ -0,0 +1,179 @@
+package dokuwiki
+
+import (
+	"strings"
+	"time"
+)
+
+// Hooks are told about the parses of a Parser, for telemetry, any of them can be nil. They are
+// called synchronously by the parse methods of the parser, Parse, ParseContext, ParseReader and
+// ParseFile, in the goroutine calling them, once the inline pass is over. They are on the hot
+// path of every parse: they get what the parser already has, nothing is allocated for them, and
+// they should return quickly, handing anything slow to another goroutine.
+type Hooks struct {
+	// OnBlockParsed is called for every top level block of the unit in order, with the bytes of
+	// the input it was parsed from, the span of BlocksWithSource.
+	OnBlockParsed func(block BlockContext, span Span)
+	// OnDiagnostic is called for every diagnostic of the unit in order.
+	OnDiagnostic func(diagnostic Diagnostic)
+	// OnFinish is called last, with the summary of the parse.
+	OnFinish func(summary Summary)
+}
+
+// Summary describes a parse, for Hooks.OnFinish.
+type Summary struct {
+	// Bytes is the size of the input and Lines its number of lines, a last line without a new
+	// line included.
+	Bytes int
+	Lines int
+	// Blocks counts the blocks by kind and Inlines the inline contexts, the ones in lists,
+	// quotes and link labels included.
+	Blocks  BlockCounts
+	Inlines InlineCounts
+	// Diagnostics is the number of diagnostics of the unit.
+	Diagnostics int
+	// LinePass is the wall time of cutting the input into blocks and building the tree of the
+	// blocks, InlinePass the one of parsing the inline elements of their paragraphs and headings.
+	// A slow line pass points to a page with long protected tags, a slow inline pass to long
+	// paragraphs with many markers.
+	LinePass   time.Duration
+	InlinePass time.Duration
+}
+
+// BlockCounts are the numbers of blocks of a unit by kind.
+type BlockCounts struct {
+	Headings int
+	// Paragraphs are the paragraphs that are not the text of list items, the terms and
+	// definitions of definition lists included.
+	Paragraphs      int
+	Lists           int
+	ListItems       int
+	Quotes          int
+	DefinitionLists int
+	// Extensions are the blocks of block plugins.
+	Extensions int
+}
+
+// InlineCounts are the numbers of inline contexts of a unit by kind.
+type InlineCounts struct {
+	Text   int
+	NoWiki int
+	HTML   int
+	Code   int
+	Links  int
+	Media  int
+	Macros int
+	// Anchors are the explicit anchors, with ParseOptions.Anchors, and LineBreaks the line
+	// breaks, with ParseOptions.PreserveLineBreaks.
+	Anchors    int
+	LineBreaks int
+	// Extensions are the inline contexts of inline plugins and of ParseOptions.UnknownTag.
+	Extensions int
+}
+
+// WithHooks sets the hooks of the parser.
+func WithHooks(hooks Hooks) Option {
+	return func(p *Parser) { p.Hooks = hooks }
+}
+
+// report calls the hooks for unit, summary has the timings of the parse.
+func (h Hooks) report(unit *ParseUnit, summary Summary) {
+	if h.OnBlockParsed != nil {
+		for _, block := range unit.Sections {
+			var span Span
+			if spanned, ok := block.(interface{ sourceSpan() Span }); ok {
+				span = sourceSpan(unit.source, spanned.sourceSpan())
+			}
+			h.OnBlockParsed(block, span)
+		}
+	}
+	if h.OnDiagnostic != nil {
+		for _, diagnostic := range unit.Diagnostics {
+			h.OnDiagnostic(diagnostic)
+		}
+	}
+	if h.OnFinish != nil {
+		summary.Bytes = len(unit.source)
+		summary.Lines = strings.Count(unit.source, "\n")
+		if unit.source != "" && !strings.HasSuffix(unit.source, "\n") {
+			summary.Lines++
+		}
+		summary.Diagnostics = len(unit.Diagnostics)
+		for _, block := range unit.Sections {
+			summary.countBlock(block)
+		}
+		h.OnFinish(summary)
+	}
+}
+
+// countBlock adds block and everything below it to the counts, without Walk, which allocates.
+func (s *Summary) countBlock(block BlockContext) {
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		s.Blocks.Headings++
+		s.countInlines(b.InnerContexts)
+	case *ParaContext:
+		if _, ok := b.GetParentContext().(*ListItemContext); !ok {
+			s.Blocks.Paragraphs++
+		}
+		s.countInlines(b.InnerContexts)
+	case *ListContext:
+		s.Blocks.Lists++
+		for _, inner := range b.InnerContexts {
+			s.countBlock(inner)
+		}
+	case *ListItemContext:
+		s.Blocks.ListItems++
+		for _, inner := range b.InnerContexts {
+			s.countBlock(inner)
+		}
+	case *QuoteContext:
+		s.Blocks.Quotes++
+		for _, inner := range b.InnerContexts {
+			s.countBlock(inner)
+		}
+	case *DefinitionListContext:
+		s.Blocks.DefinitionLists++
+		for _, entry := range b.Entries {
+			if entry.Term != nil {
+				s.countBlock(entry.Term)
+			}
+			for _, definition := range entry.Definitions {
+				s.countBlock(definition)
+			}
+		}
+	case ExtensionContext:
+		s.Blocks.Extensions++
+	}
+}
+
+func (s *Summary) countInlines(inlines []InlineContext) {
+	for _, inline := range inlines {
+		switch c := inline.(type) {
+		case *TextEffectContext:
+			s.Inlines.Text++
+		case *NoWikiContext:
+			s.Inlines.NoWiki++
+		case *HTMLContext:
+			s.Inlines.HTML++
+		case *CodeFileContext:
+			s.Inlines.Code++
+		case *HyperLinkContext:
+			s.Inlines.Links++
+			if c.Image != nil {
+				s.Inlines.Media++
+			}
+			s.countInlines(c.InnerContexts)
+		case *MediaContext:
+			s.Inlines.Media++
+		case *MacroContext:
+			s.Inlines.Macros++
+		case *AnchorContext:
+			s.Inlines.Anchors++
+		case *LineBreakContext:
+			s.Inlines.LineBreaks++
+		case ExtensionContext:
+			s.Inlines.Extensions++
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	content := "===== Title =====\nSome **bold** text with [[page|a label]] and {{logo.png}}.\n\n  * one\n    * two\n> quoted\n\nan **unclosed marker\n"
+	var events, sources []string
+	var summary Summary
+	p := New(WithHooks(Hooks{
+		OnBlockParsed: func(block BlockContext, span Span) {
+			events = append(events, "block")
+			sources = append(sources, content[span.Start:span.End])
+		},
+		OnDiagnostic: func(diagnostic Diagnostic) {
+			events = append(events, "diagnostic "+diagnostic.Code)
+		},
+		OnFinish: func(s Summary) {
+			events = append(events, "finish")
+			summary = s
+		},
+	}))
+	p.Parse([]byte(content), "t")
+
+	if want := []string{"block", "block", "block", "block", "block", "diagnostic unclosed-formatting", "finish"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %q, want %q", events, want)
+	}
+	if want := []string{"===== Title =====", "Some **bold** text with [[page|a label]] and {{logo.png}}.", "  * one\n    * two", "> quoted", "an **unclosed marker"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("got blocks %q, want %q", sources, want)
+	}
+	if summary.Bytes != len(content) || summary.Lines != 8 || summary.Diagnostics != 1 {
+		t.Errorf("got %d bytes, %d lines and %d diagnostics, want %d, 8 and 1", summary.Bytes, summary.Lines, summary.Diagnostics, len(content))
+	}
+	if want := (BlockCounts{Headings: 1, Paragraphs: 3, Lists: 2, ListItems: 2, Quotes: 1}); summary.Blocks != want {
+		t.Errorf("got blocks %+v, want %+v", summary.Blocks, want)
+	}
+	// the heading, 3 before the link, its label and 2 after it, the items, the quote and 2 in the last paragraph.
+	if want := (InlineCounts{Text: 12, Links: 1, Media: 1}); summary.Inlines != want {
+		t.Errorf("got inlines %+v, want %+v", summary.Inlines, want)
+	}
+	if summary.LinePass <= 0 || summary.InlinePass <= 0 {
+		t.Errorf("got passes of %v and %v, want both timed", summary.LinePass, summary.InlinePass)
+	}
+}
+
+// TestHooksAllocations checks that hooks doing nothing cost the parse no allocation. One more is
+// allowed, under the race detector the pools of the regexps do not always keep their values and
+// the counts move by one.
+func TestHooksAllocations(t *testing.T) {
+	content := []byte("===== Title =====\nSome **bold** text with [[page|a label]].\n\n  * one\n    * two\n\nan **unclosed marker\n")
+	allocs := func(p *Parser) float64 {
+		pool := NewParserPool(p)
+		parser := pool.Get()
+		return testing.AllocsPerRun(100, func() { parser.Parse(content, "t") })
+	}
+	without := allocs(New())
+	with := allocs(New(WithHooks(Hooks{
+		OnBlockParsed: func(BlockContext, Span) {},
+		OnDiagnostic:  func(Diagnostic) {},
+		OnFinish:      func(Summary) {},
+	})))
+	if with > without+1 {
+		t.Errorf("got %v allocations with hooks, %v without", with, without)
+	}
+}
@@ -1 +1,509 @@
 package dokuwiki
+
+import (
+	"html"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HTMLRenderer renders a unit to XHTML close to what DokuWiki produces.
+type HTMLRenderer struct {
+	Options RendererOptions
+
+	nodeRenderers map[string]func(w io.Writer, node ExtensionContext, r *HTMLRenderer) error
+}
+
+// htmlUnportable are the constructs HTMLRenderer can not write, see AnalyzePortability.
+var htmlUnportable = unportable{
+	php:       "php is not run, it is written as text",
+	plugin:    "it is written as its source unless a node renderer is registered for it",
+	interWiki: "the link goes to the target as written",
+}
+
+func NewHTMLRenderer(opts RendererOptions) *HTMLRenderer {
+	return &HTMLRenderer{Options: opts}
+}
+
+// Render renders unit as html with the default options.
+func Render(unit *ParseUnit, writer io.Writer) error {
+	return NewHTMLRenderer(RendererOptions{}).Render(writer, unit)
+}
+
+// RenderInlineHTML renders inline contexts, like the ones of ParseInline, as html without a
+// paragraph around them.
+func RenderInlineHTML(inlines []InlineContext, w io.Writer, opts RendererOptions) error {
+	return NewHTMLRenderer(opts).RenderInlines(w, inlines)
+}
+
+// RenderInlines renders inline contexts without a paragraph around them.
+func (r *HTMLRenderer) RenderInlines(w io.Writer, inlines []InlineContext) error {
+	rw := r.newRenderWriter(w)
+	r.renderInlines(rw, inlines)
+	return finishRender(rw)
+}
+
+// newRenderWriter returns the writer to render to w with, which leaves out white space when
+// Options.Compact is set. finishRender must be called once done.
+func (r *HTMLRenderer) newRenderWriter(w io.Writer) *renderWriter {
+	if r.Options.Compact {
+		return &renderWriter{w: newCompactWriter(w)}
+	}
+	return &renderWriter{w: w}
+}
+
+// finishRender writes what the writer of rw holds back and returns the first error.
+func finishRender(rw *renderWriter) error {
+	if compact, ok := rw.w.(*compactWriter); ok && rw.err == nil {
+		rw.err = compact.flush()
+	}
+	return rw.err
+}
+
+// RegisterNodeRenderer makes r render the extension contexts of the given kind with fn,
+// registering a kind again replaces its function.
+func (r *HTMLRenderer) RegisterNodeRenderer(kind string, fn func(w io.Writer, node ExtensionContext, r *HTMLRenderer) error) {
+	if r.nodeRenderers == nil {
+		r.nodeRenderers = make(map[string]func(io.Writer, ExtensionContext, *HTMLRenderer) error)
+	}
+	r.nodeRenderers[kind] = fn
+}
+
+func (r *HTMLRenderer) renderExtension(rw *renderWriter, node ExtensionContext) {
+	if fn := r.nodeRenderers[node.Kind()]; fn != nil {
+		rw.renderNode(func(w io.Writer) error { return fn(w, node, r) })
+		return
+	}
+	r.Options.renderUnknownExtension(rw, node, func(source string) { rw.write(html.EscapeString(source)) })
+}
+
+func (r *HTMLRenderer) Render(w io.Writer, unit *ParseUnit) error {
+	if err := r.Options.validate(unit); err != nil {
+		return err
+	}
+	rw := r.newRenderWriter(w)
+	rw.anchors = unit.explicitIDs()
+	if r.Options.TOC && !unit.hasMacro("NOTOC") {
+		r.renderTOC(rw, unit)
+	}
+	headings := headingLabels{ids: unit.headingIDs(), numbers: r.Options.sectionNumbers(unit)}
+	for _, block := range unit.Sections {
+		r.renderBlock(rw, block, headings)
+	}
+	return finishRender(rw)
+}
+
+// renderTOC writes the table of contents box of DokuWiki, with the entries nested by level. A
+// level skipped opens a list in an item of class clear, like DokuWiki does.
+func (r *HTMLRenderer) renderTOC(rw *renderWriter, unit *ParseUnit) {
+	entries := unit.TOCWithOptions(r.Options)
+	if len(entries) == 0 {
+		return
+	}
+	top, _ := unit.tocLevels(r.Options)
+	rw.write("<!-- TOC START -->\n<div id=\"dw__toc\" class=\"dw__toc\">\n<h3 class=\"toggle\">Table of Contents</h3>\n<div>\n\n")
+	// depth is the number of lists open, every one but the first is in an item left open.
+	depth := 0
+	for _, entry := range entries {
+		level := 7 - entry.HeaderLevel - top + 1
+		if depth >= level {
+			rw.write("</li>\n")
+			for ; depth > level; depth-- {
+				rw.write("</ul>\n</li>\n")
+			}
+		}
+		for depth < level {
+			if depth > 0 {
+				rw.write("\n")
+			}
+			rw.write("<ul class=\"toc\">\n")
+			if depth++; depth < level {
+				rw.write("<li class=\"clear\">")
+			}
+		}
+		rw.printf("<li class=\"level%d\"><div class=\"li\"><a href=\"#%s\">", level, entry.Anchor)
+		if entry.Number != "" {
+			rw.write(entry.Number + " ")
+		}
+		rw.printf("%s</a></div>", html.EscapeString(entry.Text))
+	}
+	rw.write("</li>\n")
+	for ; depth > 1; depth-- {
+		rw.write("</ul>\n</li>\n")
+	}
+	rw.write("</ul>\n</div>\n</div>\n<!-- TOC END -->\n")
+}
+
+func (r *HTMLRenderer) renderBlock(rw *renderWriter, block BlockContext, headings headingLabels) {
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		depth := r.Options.headingDepth(b.HeaderLevel)
+		rw.printf("\n<h%d id=\"%s\">", depth, headings.ids[b])
+		if number := headings.numbers[b]; number != "" {
+			rw.write(number + " ")
+		}
+		if b.InnerContexts != nil {
+			r.renderLabel(rw, b.InnerContexts)
+		} else {
+			rw.write(html.EscapeString(b.HeaderText))
+		}
+		rw.printf("</h%d>\n", depth)
+	case *ParaContext:
+		r.renderPara(rw, b)
+	case *ListContext:
+		r.renderList(rw, b)
+	case *QuoteContext:
+		rw.write("\n")
+		r.renderQuote(rw, b)
+	case *DefinitionListContext:
+		r.renderDefinitionList(rw, b)
+	case ExtensionContext:
+		r.renderExtension(rw, b)
+	}
+}
+
+// renderPara wraps the inline contexts in <p>, code blocks and <HTML> can not be put in a paragraph
+// so they close the current one.
+func (r *HTMLRenderer) renderPara(rw *renderWriter, para *ParaContext) {
+	inParagraph := false
+	for _, inline := range para.InnerContexts {
+		if isHTMLBlock(inline) {
+			if inParagraph {
+				rw.write("\n</p>\n")
+				inParagraph = false
+			}
+			r.renderInline(rw, inline)
+			continue
+		}
+		if !inParagraph {
+			if text, ok := inline.(*TextEffectContext); ok && strings.TrimSpace(text.Text) == "" {
+				continue
+			}
+			if _, ok := inline.(*MacroContext); ok {
+				continue
+			}
+			rw.write("\n<p>\n")
+			inParagraph = true
+		}
+		r.renderInline(rw, inline)
+	}
+	if inParagraph {
+		rw.write("\n</p>\n")
+	}
+}
+
+// isHTMLBlock tells whether an inline context is rendered as a block, code blocks and the
+// content of <HTML>, which close the paragraph around them.
+func isHTMLBlock(inline InlineContext) bool {
+	switch c := inline.(type) {
+	case *CodeFileContext:
+		return true
+	case *HTMLContext:
+		return c.IsBlock
+	}
+	return false
+}
+
+func (r *HTMLRenderer) renderList(rw *renderWriter, list *ListContext) {
+	tag := "ul"
+	if list.Ordered {
+		tag = "ol"
+	}
+	rw.printf("<%s>\n", tag)
+
+	for _, inner := range list.InnerContexts {
+		item, ok := inner.(*ListItemContext)
+		if !ok {
+			continue
+		}
+		class := "level" + strconv.Itoa(item.Depth)
+		for _, block := range item.InnerContexts {
+			if _, hasSubList := block.(*ListContext); hasSubList {
+				class += " node"
+				break
+			}
+		}
+		rw.printf("<li class=\"%s\">", class)
+		for _, block := range item.InnerContexts {
+			switch c := block.(type) {
+			case *ParaContext:
+				rw.write("<div class=\"li\">")
+				r.renderInlines(rw, c.InnerContexts)
+				rw.write("</div>")
+			case *ListContext:
+				rw.write("\n")
+				r.renderList(rw, c)
+			case *QuoteContext:
+				rw.write("\n")
+				r.renderQuote(rw, c)
+			}
+		}
+		rw.write("</li>\n")
+	}
+	rw.printf("</%s>\n", tag)
+}
+
+// renderQuote writes a quote like DokuWiki, the text of every level in a <div class="no">, the
+// quotes and the lists in it between.
+func (r *HTMLRenderer) renderQuote(rw *renderWriter, quote *QuoteContext) {
+	rw.write("<blockquote>")
+	// lineStart is false after the text of a level, the blocks after it start on a new line.
+	lineStart := false
+	for _, inner := range quote.InnerContexts {
+		switch c := inner.(type) {
+		case *ParaContext:
+			rw.write("<div class=\"no\">\n")
+			r.renderInlines(rw, c.InnerContexts)
+			rw.write("</div>")
+			lineStart = false
+			continue
+		}
+		if !lineStart {
+			rw.write("\n")
+		}
+		lineStart = true
+		switch c := inner.(type) {
+		case *QuoteContext:
+			r.renderQuote(rw, c)
+		case *ListContext:
+			r.renderList(rw, c)
+		}
+	}
+	rw.write("</blockquote>\n")
+}
+
+func (r *HTMLRenderer) renderDefinitionList(rw *renderWriter, list *DefinitionListContext) {
+	rw.write("<dl>\n")
+	for _, entry := range list.Entries {
+		if entry.Term != nil {
+			rw.write("<dt>")
+			r.renderInlines(rw, entry.Term.InnerContexts)
+			rw.write("</dt>\n")
+		}
+		for _, definition := range entry.Definitions {
+			rw.write("<dd>")
+			r.renderInlines(rw, definition.InnerContexts)
+			rw.write("</dd>\n")
+		}
+	}
+	rw.write("</dl>\n")
+}
+
+// codeHTML returns the escaped text of a code block. With the enable_line_numbers or
+// highlight_lines_extra attributes every line is a list item, numbered from
+// start_line_numbers_at, the extra highlighted lines, counted from 1, get the class hl.
+func codeHTML(c *CodeFileContext) string {
+	numbered := c.Attributes["enable_line_numbers"] == "true"
+	highlighted := make(map[int]bool)
+	for _, field := range strings.Split(c.Attributes["highlight_lines_extra"], ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			highlighted[n] = true
+		}
+	}
+	if !numbered && len(highlighted) == 0 {
+		return html.EscapeString(c.Text)
+	}
+
+	var b strings.Builder
+	list := "<ol>"
+	if start, err := strconv.Atoi(c.Attributes["start_line_numbers_at"]); err == nil && start != 1 {
+		list = "<ol start=\"" + strconv.Itoa(start) + "\">"
+	}
+	if !numbered {
+		list = "<ol class=\"nonumbers\">"
+	}
+	b.WriteString(list)
+	// the new line before the end tag does not make a line.
+	text := strings.TrimSuffix(c.Text, "\n")
+	for i, line := range strings.Split(text, "\n") {
+		if highlighted[i+1] {
+			b.WriteString(`<li class="hl">`)
+		} else {
+			b.WriteString("<li>")
+		}
+		b.WriteString(html.EscapeString(line) + "</li>")
+	}
+	b.WriteString("</ol>")
+	return b.String()
+}
+
+func (r *HTMLRenderer) renderInlines(rw *renderWriter, inlines []InlineContext) {
+	for _, inline := range inlines {
+		r.renderInline(rw, inline)
+	}
+}
+
+var htmlEffectTags = map[uint32][2]string{
+	TextEffectBold:      {"<strong>", "</strong>"},
+	TextEffectItalic:    {"<em>", "</em>"},
+	TextEffectUnderline: {"<em class=\"u\">", "</em>"},
+	TextEffectMonoSpace: {"<code>", "</code>"},
+}
+
+// htmlLinkClasses are the classes DokuWiki gives the links that are neither internal nor external.
+var htmlLinkClasses = map[LinkKind]string{
+	LinkInterwiki:    "interwiki",
+	LinkEmail:        "mail",
+	LinkWindowsShare: "windows",
+}
+
+// renderText writes text with its acronyms marked up.
+func (r *HTMLRenderer) renderText(rw *renderWriter, text string) {
+	written := 0
+	r.Options.Acronyms.find(text, func(start, end int) {
+		acronym := text[start:end]
+		rw.printf("%s<abbr title=\"%s\">%s</abbr>", html.EscapeString(text[written:start]), html.EscapeString(r.Options.Acronyms[acronym]), html.EscapeString(acronym))
+		written = end
+	})
+	rw.write(html.EscapeString(text[written:]))
+}
+
+// renderEffect writes what inner writes inside the tags of the effects.
+func renderEffect(rw *renderWriter, effectType uint32, inner func()) {
+	for _, effect := range effectOrder {
+		if effectType&effect != 0 {
+			rw.write(htmlEffectTags[effect][0])
+		}
+	}
+	inner()
+	for i := len(effectOrder) - 1; i >= 0; i-- {
+		if effectType&effectOrder[i] != 0 {
+			rw.write(htmlEffectTags[effectOrder[i]][1])
+		}
+	}
+}
+
+// renderLabel writes the label of a link or the text of a heading, its text has no acronyms marked
+// up like DokuWiki does.
+func (r *HTMLRenderer) renderLabel(rw *renderWriter, inlines []InlineContext) {
+	for _, inline := range inlines {
+		if text, ok := inline.(*TextEffectContext); ok {
+			renderEffect(rw, text.EffectType, func() { rw.write(html.EscapeString(text.Text)) })
+		} else {
+			r.renderInline(rw, inline)
+		}
+	}
+}
+
+func (r *HTMLRenderer) renderLink(rw *renderWriter, c *HyperLinkContext) {
+	href := html.EscapeString(r.Options.linkURL(c))
+	switch c.Kind {
+	case LinkInternal:
+		rw.printf("<a href=\"%s\" class=\"wikilink1\" title=\"%s\">", href, html.EscapeString(c.HyperLink))
+	case LinkExternal:
+		if href == "" {
+			rw.printf("<a class=\"urlextern\" title=\"%s\" rel=\"ugc nofollow\">", html.EscapeString(c.HyperLink))
+			break
+		}
+		rw.printf("<a href=\"%s\" class=\"urlextern\" title=\"%s\" rel=\"ugc nofollow\">", href, href)
+	default:
+		rw.printf("<a href=\"%s\" class=\"%s\" title=\"%s\">", href, htmlLinkClasses[c.Kind], html.EscapeString(c.HyperLink))
+	}
+	if c.Image != nil {
+		// the image of a label is not linked to its detail page, it is in the link.
+		renderEffect(rw, c.Image.EffectType, func() { r.renderImage(rw, c.Image) })
+	} else if c.InnerContexts != nil {
+		r.renderLabel(rw, c.InnerContexts)
+	} else {
+		rw.write(html.EscapeString(c.Text))
+	}
+	rw.write("</a>")
+}
+
+// renderMedia writes media in the link its parameters ask for, like DokuWiki: an image links to
+// its detail page, or with ?direct to the file, ?nolink leaves it alone and ?linkonly writes a
+// link to the file instead of the image. External media has no detail page, it links to itself.
+func (r *HTMLRenderer) renderMedia(rw *renderWriter, c *MediaContext) {
+	linking := c.Linking()
+	switch linking {
+	case MediaNoLink:
+		r.renderImage(rw, c)
+		return
+	case MediaLinkOnly:
+		text := c.Title
+		if text == "" {
+			text = path.Base(strings.ReplaceAll(c.MediaResouce, ":", "/"))
+		}
+		extension := strings.TrimPrefix(path.Ext(c.MediaResouce), ".")
+		rw.printf("<a href=\"%s\" class=\"media mediafile mf_%s\" title=\"%s\">%s</a>",
+			html.EscapeString(r.Options.mediaURL(c)), html.EscapeString(extension), html.EscapeString(c.MediaResouce), html.EscapeString(text))
+		return
+	}
+	href := r.Options.mediaDetailURL(c)
+	if linking == MediaDirect {
+		href = r.Options.mediaURL(c)
+	}
+	rw.printf("<a href=\"%s\" class=\"media\" title=\"%s\">", html.EscapeString(href), html.EscapeString(c.MediaResouce))
+	r.renderImage(rw, c)
+	rw.write("</a>")
+}
+
+// renderImage writes the img tag of media.
+func (r *HTMLRenderer) renderImage(rw *renderWriter, c *MediaContext) {
+	class := "media"
+	switch c.Align {
+	case AlignLeft:
+		class = "medialeft"
+	case AlignCenter:
+		class = "mediacenter"
+	case AlignRight:
+		class = "mediaright"
+	}
+	rw.printf("<img src=\"%s\" class=\"%s\"", html.EscapeString(r.Options.mediaURL(c)), class)
+	if c.Title != "" {
+		rw.printf(" title=\"%s\" alt=\"%s\"", html.EscapeString(c.Title), html.EscapeString(c.Title))
+	} else {
+		rw.write(" alt=\"\"")
+	}
+	if c.Width > 0 {
+		rw.printf(" width=\"%d\"", c.Width)
+	}
+	if c.Height > 0 {
+		rw.printf(" height=\"%d\"", c.Height)
+	}
+	rw.write(" />")
+}
+
+func (r *HTMLRenderer) renderInline(rw *renderWriter, inline InlineContext) {
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		renderEffect(rw, c.EffectType, func() { r.renderText(rw, c.Text) })
+	case *HyperLinkContext:
+		renderEffect(rw, c.EffectType, func() { r.renderLink(rw, c) })
+	case *MediaContext:
+		renderEffect(rw, c.EffectType, func() { r.renderMedia(rw, c) })
+	case *CodeFileContext:
+		class := "code"
+		if c.IsFile {
+			class += " file"
+		}
+		if c.Language != "" {
+			class += " " + c.Language
+		}
+		pre := "<pre class=\"" + html.EscapeString(class) + "\">" + codeHTML(c) + "</pre>"
+		// like DokuWiki, a file without a name is only its pre.
+		if c.IsFile && c.FileName != "" {
+			rw.printf("<dl class=\"file\">\n<dt>%s</dt>\n<dd>%s</dd>\n</dl>\n", html.EscapeString(c.FileName), pre)
+		} else {
+			rw.write(pre + "\n")
+		}
+	case *HTMLContext:
+		switch {
+		case r.Options.Sanitize == SanitizeNone:
+			rw.write(c.Text)
+		case r.Options.Sanitize == SanitizeEscape && c.IsBlock:
+			rw.printf("<pre class=\"code html4strict\">%s</pre>\n", html.EscapeString(c.Text))
+		case r.Options.Sanitize == SanitizeEscape:
+			rw.printf("<code class=\"code html4strict\">%s</code>", html.EscapeString(c.Text))
+		}
+	case *NoWikiContext:
+		renderEffect(rw, c.EffectType, func() { rw.write(html.EscapeString(c.Text)) })
+	case *AnchorContext:
+		rw.printf("<span id=\"%s\"></span>", html.EscapeString(rw.anchorID(c)))
+	case *LineBreakContext:
+		rw.write("<br/>\n")
+	case ExtensionContext:
+		r.renderExtension(rw, c)
+	}
+}
@@ -0,0 +1,180 @@
+package dokuwiki
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Incremental parses successive versions of a page, like the source in an editor, reusing the
+// blocks of the previous version whose lines did not change. The result is always the same as
+// the one of ParseWithOptions.
+//
+// The content is cut into chunks at the empty lines that are not in a code, file, html or nowiki
+// tag. No block goes over such a line, so every chunk can be parsed on its own, and a chunk whose
+// text was already in the previous version gets its blocks back, with their lines updated.
+type Incremental struct {
+	title   string
+	options ParseOptions
+	unit    *ParseUnit
+	// chunks are the chunks of the previous version, keyed by their text.
+	chunks map[string][]incrementalChunk
+}
+
+type incrementalChunk struct {
+	// firstLine is the line the chunk starts on, offset where it starts in the content.
+	firstLine int
+	offset    int
+	blocks    []BlockContext
+	// diagnostics are the ones of the inline content of the chunk, with the lines of the chunk.
+	diagnostics []Diagnostic
+}
+
+func NewIncremental(title string, options ParseOptions) *Incremental {
+	return &Incremental{
+		title:   title,
+		options: options,
+		unit:    &ParseUnit{Title: title, effects: options.Effects, pageID: options.PageID},
+		chunks:  make(map[string][]incrementalChunk),
+	}
+}
+
+// Update parses a new version of the page. It returns the unit, which is the same for every call
+// and changed in place, and the indices in its Sections of the blocks that were parsed again.
+// Once the unit is frozen, a new one is parsed from scratch and returned from then on.
+func (inc *Incremental) Update(source []byte) (*ParseUnit, []int) {
+	if inc.unit.frozen {
+		// the blocks of the frozen unit can not be reused, their lines would be moved.
+		inc.unit = &ParseUnit{Title: inc.title, effects: inc.options.Effects, pageID: inc.options.PageID}
+		inc.chunks = make(map[string][]incrementalChunk)
+	}
+	// the blocks are only classified to find the chunks, parsing their inline content is the slow part.
+	states := parserStates{parseunit: &ParseUnit{}, options: inc.options}
+	blocks := generateLines(&states, source)
+	lines := strings.Split(string(source), "\n")
+	// offsets are where the lines start in the content.
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1]) + 1
+	}
+
+	sections := make([]BlockContext, 0, len(inc.unit.Sections))
+	changed := make([]int, 0)
+	inlineDiagnostics := make([]Diagnostic, 0)
+	chunks := make(map[string][]incrementalChunk)
+	addChunk := func(firstLine, lastLine int) {
+		text := strings.Join(lines[firstLine-1:lastLine], "\n")
+		chunk := incrementalChunk{firstLine: firstLine, offset: offsets[firstLine-1]}
+		if previous := inc.chunks[text]; len(previous) > 0 {
+			chunk.blocks, chunk.diagnostics = previous[0].blocks, previous[0].diagnostics
+			inc.chunks[text] = previous[1:]
+			shiftLines(chunk.blocks, firstLine-previous[0].firstLine)
+			shiftSpans(chunk.blocks, chunk.offset-previous[0].offset)
+		} else {
+			// the diagnostics of the lines are those of the whole version, only the inline ones are kept.
+			chunkStates := parserStates{parseunit: &ParseUnit{Title: inc.title}, options: inc.options, effects: sortEffects(inc.options.Effects)}
+			chunkBlocks := generateLines(&chunkStates, []byte(text))
+			lineDiagnostics := len(chunkStates.parseunit.Diagnostics)
+			processContent(&chunkStates, chunkBlocks)
+			chunk.blocks = chunkStates.parseunit.Sections
+			chunk.diagnostics = chunkStates.parseunit.Diagnostics[lineDiagnostics:]
+			shiftLines(chunk.blocks, firstLine-1)
+			shiftSpans(chunk.blocks, chunk.offset)
+			for i := range chunk.blocks {
+				changed = append(changed, len(sections)+i)
+			}
+		}
+		for _, block := range chunk.blocks {
+			block.SetParentContext(inc.unit)
+		}
+		sections = append(sections, chunk.blocks...)
+		for _, diagnostic := range chunk.diagnostics {
+			inlineDiagnostics = append(inlineDiagnostics, shiftDiagnostic(diagnostic, firstLine-1))
+		}
+		chunks[text] = append(chunks[text], chunk)
+	}
+
+	firstLine, lastLine := 0, 0
+	for _, block := range blocks {
+		if firstLine > 0 && block.line > lastLine+1 {
+			addChunk(firstLine, lastLine)
+			firstLine = 0
+		}
+		if firstLine == 0 {
+			firstLine = block.line
+		}
+		lastLine = max(lastLine, block.endLine)
+	}
+	if firstLine > 0 {
+		addChunk(firstLine, min(lastLine, len(lines)))
+	}
+
+	inc.chunks = chunks
+	inc.unit.Sections = sections
+	inc.unit.Diagnostics = append(states.parseunit.Diagnostics, inlineDiagnostics...)
+	inc.unit.source = string(source)
+	setDiagnosticSpans(inc.unit)
+	return inc.unit, changed
+}
+
+// shiftDiagnostic moves a diagnostic and the line its message starts with, like "line 3: ...",
+// by delta lines. Its span is set again from the new source.
+func shiftDiagnostic(diagnostic Diagnostic, delta int) Diagnostic {
+	line := diagnostic.Position.Line
+	if line == 0 {
+		return diagnostic
+	}
+	diagnostic.Position.Line += delta
+	prefix := "line " + strconv.Itoa(line) + ": "
+	if strings.HasPrefix(diagnostic.Message, prefix) {
+		diagnostic.Message = "line " + strconv.Itoa(line+delta) + ": " + diagnostic.Message[len(prefix):]
+	}
+	return diagnostic
+}
+
+// shiftLines moves the positions of blocks and everything below them by delta lines.
+func shiftLines(blocks []BlockContext, delta int) {
+	if delta == 0 {
+		return
+	}
+	// a context could be in the tree twice, it must be moved once.
+	moved := make(map[Context]bool)
+	for _, block := range blocks {
+		Walk(block, func(c Context) bool {
+			if moved[c] {
+				return false
+			}
+			moved[c] = true
+			if c, ok := c.(interface {
+				GetPosition() Position
+				setPosition(Position)
+			}); ok && c.GetPosition().Line > 0 {
+				c.setPosition(Position{Line: c.GetPosition().Line + delta})
+			}
+			return true
+		})
+	}
+}
+
+// shiftSpans moves the spans of blocks and of the blocks below them by delta bytes.
+func shiftSpans(blocks []BlockContext, delta int) {
+	if delta == 0 {
+		return
+	}
+	moved := make(map[Context]bool)
+	for _, block := range blocks {
+		Walk(block, func(c Context) bool {
+			if moved[c] {
+				return false
+			}
+			moved[c] = true
+			if c, ok := c.(interface {
+				sourceSpan() Span
+				setSpan(Span)
+			}); ok && c.sourceSpan() != (Span{}) {
+				span := c.sourceSpan()
+				c.setSpan(Span{span.Start + delta, span.End + delta})
+			}
+			return true
+		})
+	}
+}
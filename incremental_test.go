@@ -0,0 +1,85 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dumpWithLines is Dump with the line of every context, to compare positions too.
+func dumpWithLines(t *testing.T, unit *ParseUnit) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Dump(&buf, unit); err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, 0)
+	Walk(unit, func(c Context) bool {
+		if c, ok := c.(interface{ GetPosition() Position }); ok {
+			lines = append(lines, fmt.Sprint(c.GetPosition().Line))
+		}
+		return true
+	})
+	return buf.String() + strings.Join(lines, " ") + fmt.Sprintf("\n%v", unit.Diagnostics)
+}
+
+func TestIncremental(t *testing.T) {
+	inc := NewIncremental("doc", ParseOptions{})
+	unit, changed := inc.Update([]byte("== A ==\ntext\n\nmore text\n"))
+	if !reflect.DeepEqual(changed, []int{0, 1, 2}) {
+		t.Errorf("first version: got changed %v", changed)
+	}
+	second := unit.Sections[2]
+
+	unit, changed = inc.Update([]byte("== A ==\nedited text\n\nmore text\n"))
+	if !reflect.DeepEqual(changed, []int{0, 1}) || unit.Sections[2] != second {
+		t.Errorf("edit: got changed %v", changed)
+	}
+	unit, changed = inc.Update([]byte("new\n\n== A ==\nedited text\n\nmore text\n"))
+	if !reflect.DeepEqual(changed, []int{0}) || unit.Sections[3] != second || second.(*ParaContext).GetPosition().Line != 6 {
+		t.Errorf("insert: got changed %v", changed)
+	}
+}
+
+// TestIncrementalRandomEdits checks that the result of Incremental is always the one of Parse.
+func TestIncrementalRandomEdits(t *testing.T) {
+	fragments := []string{
+		"", "", "", "== Heading ==", "===== Big =====", "some text", "**bold** and //italic", "[[link|label]] http://example.com/x",
+		"  * item", "    * sub item", "  - ordered", "<code go>", "</code>", "<file txt a.txt>", "</file>",
+		"<nowiki>", "</nowiki>", "<html>", "</html>", "{{img.png|title}}", "~~NOTOC~~", "x <code go>inline</code> y",
+	}
+	rng := rand.New(rand.NewSource(1))
+	lines := make([]string, 0)
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fragments[rng.Intn(len(fragments))])
+	}
+
+	inc := NewIncremental("doc", ParseOptions{})
+	for edit := 0; edit < 500; edit++ {
+		switch i := rng.Intn(len(lines) + 1); {
+		case rng.Intn(3) == 0 && len(lines) > 0 && i < len(lines):
+			lines = append(lines[:i], lines[i+1:]...)
+		case rng.Intn(2) == 0 && i < len(lines):
+			lines[i] = fragments[rng.Intn(len(fragments))]
+		default:
+			lines = append(lines[:i], append([]string{fragments[rng.Intn(len(fragments))]}, lines[i:]...)...)
+		}
+		source := strings.Join(lines, "\n")
+		unit, _ := inc.Update([]byte(source))
+		got, want := dumpWithLines(t, unit), dumpWithLines(t, Parse([]byte(source), "doc"))
+		if got != want {
+			t.Fatalf("edit %d of %q:\ngot\n%s\nwant\n%s", edit, source, got, want)
+		}
+		Walk(unit, func(c Context) bool {
+			for _, child := range children(c) {
+				if child.GetParentContext() != c {
+					t.Fatalf("edit %d: %T has a wrong parent", edit, child)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package dokuwiki
+
+import (
+	"io"
+	"strings"
+)
+
+// LaTeXRenderer renders a unit to a LaTeX fragment meant to be \input into a document
+// that loads the hyperref, graphicx and ulem packages.
+type LaTeXRenderer struct {
+	Options RendererOptions
+}
+
+// latexUnportable are the constructs LaTeXRenderer can not write, see AnalyzePortability.
+var latexUnportable = unportable{
+	html:         "it is written as escaped text",
+	php:          "php is not run, it is written as text",
+	windowsShare: "it is written as a file: link, which PDF viewers seldom open",
+	plugin:       "it is written as its source unless a node renderer is registered for it",
+	interWiki:    "the link goes to the target as written",
+}
+
+func NewLaTeXRenderer(opts RendererOptions) *LaTeXRenderer {
+	return &LaTeXRenderer{Options: opts}
+}
+
+var latexSectionCommands = []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+
+func (r *LaTeXRenderer) Render(w io.Writer, unit *ParseUnit) error {
+	if err := r.Options.validate(unit); err != nil {
+		return err
+	}
+	rw := &renderWriter{w: w, anchors: unit.explicitIDs()}
+	anchors := unit.headingIDs()
+	for _, block := range unit.Sections {
+		switch b := block.(type) {
+		case *SectionHeaderContext:
+			command := latexSectionCommands[r.Options.headingDepth(b.HeaderLevel)-1]
+			rw.printf("\\%s{", command)
+			if b.InnerContexts != nil {
+				r.renderInlines(rw, b.InnerContexts)
+			} else {
+				rw.write(latexEscaper.Replace(b.HeaderText))
+			}
+			rw.printf("}\\label{%s}\n\n", anchors[b])
+		case *ParaContext:
+			r.renderInlines(rw, b.InnerContexts)
+			rw.write("\n\n")
+		case *ListContext:
+			r.renderList(rw, b)
+			rw.write("\n")
+		case *QuoteContext:
+			r.renderQuote(rw, b)
+			rw.write("\n")
+		case *DefinitionListContext:
+			rw.write("\\begin{description}\n")
+			for _, entry := range b.Entries {
+				for i, definition := range entry.Definitions {
+					rw.write("\\item")
+					if i == 0 && entry.Term != nil {
+						rw.write("[")
+						r.renderInlines(rw, entry.Term.InnerContexts)
+						rw.write("]")
+					}
+					rw.write(" ")
+					r.renderInlines(rw, definition.InnerContexts)
+					rw.write("\n")
+				}
+				if len(entry.Definitions) == 0 && entry.Term != nil {
+					rw.write("\\item[")
+					r.renderInlines(rw, entry.Term.InnerContexts)
+					rw.write("]\n")
+				}
+			}
+			rw.write("\\end{description}\n\n")
+		}
+	}
+	return rw.err
+}
+
+func (r *LaTeXRenderer) renderList(rw *renderWriter, list *ListContext) {
+	env := "itemize"
+	if list.Ordered {
+		env = "enumerate"
+	}
+	rw.printf("\\begin{%s}\n", env)
+	for _, inner := range list.InnerContexts {
+		item, ok := inner.(*ListItemContext)
+		if !ok {
+			continue
+		}
+		rw.write("\\item ")
+		for i, block := range item.InnerContexts {
+			switch c := block.(type) {
+			case *ParaContext:
+				if i > 0 {
+					rw.write("\n")
+				}
+				r.renderInlines(rw, c.InnerContexts)
+				rw.write("\n")
+			case *ListContext:
+				if i == 0 {
+					rw.write("\n")
+				}
+				r.renderList(rw, c)
+			case *QuoteContext:
+				if i == 0 {
+					rw.write("\n")
+				}
+				r.renderQuote(rw, c)
+			}
+		}
+	}
+	rw.printf("\\end{%s}\n", env)
+}
+
+// renderQuote writes a quote in a quote environment, the deeper quotes in their own.
+func (r *LaTeXRenderer) renderQuote(rw *renderWriter, quote *QuoteContext) {
+	rw.write("\\begin{quote}\n")
+	for _, inner := range quote.InnerContexts {
+		switch c := inner.(type) {
+		case *ParaContext:
+			r.renderInlines(rw, c.InnerContexts)
+			rw.write("\n")
+		case *QuoteContext:
+			r.renderQuote(rw, c)
+		case *ListContext:
+			r.renderList(rw, c)
+		}
+	}
+	rw.write("\\end{quote}\n")
+}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "$", `\$`, "&", `\&`, "#", `\#`,
+	"%", `\%`, "_", `\_`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
+
+// latexURLEscaper escapes the characters hyperref does not accept verbatim in \href.
+var latexURLEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`, "%", `\%`, "{", `\{`, "}", `\}`)
+
+var latexEffectCommands = map[uint32]string{
+	TextEffectBold:      `\textbf{`,
+	TextEffectItalic:    `\emph{`,
+	TextEffectUnderline: `\uline{`,
+	TextEffectMonoSpace: `\texttt{`,
+}
+
+func (r *LaTeXRenderer) renderInlines(rw *renderWriter, inlines []InlineContext) {
+	for i, inline := range inlines {
+		if i > 0 && isCodeFile(inline) {
+			rw.write("\n")
+		}
+		switch c := inline.(type) {
+		case *TextEffectContext:
+			latexEffect(rw, c.EffectType, func() { rw.write(latexEscaper.Replace(c.Text)) })
+		case *HyperLinkContext:
+			latexEffect(rw, c.EffectType, func() { r.renderLink(rw, c) })
+		case *MediaContext:
+			latexEffect(rw, c.EffectType, func() { r.renderMedia(rw, c) })
+		case *CodeFileContext:
+			rw.printf("\\begin{verbatim}\n%s\n\\end{verbatim}\n", strings.Trim(c.Text, "\n"))
+		case *HTMLContext:
+			if r.Options.Sanitize != SanitizeStrip {
+				rw.write(latexEscaper.Replace(c.Text))
+			}
+		case *NoWikiContext:
+			latexEffect(rw, c.EffectType, func() { rw.write(latexEscaper.Replace(c.Text)) })
+		case *AnchorContext:
+			rw.printf("\\label{%s}", rw.anchorID(c))
+		case *LineBreakContext:
+			rw.write("\\\\\n")
+		}
+	}
+}
+
+// latexEffect writes what inner writes in the commands of the effects.
+func latexEffect(rw *renderWriter, effectType uint32, inner func()) {
+	opened := 0
+	for _, effect := range effectOrder {
+		if effectType&effect != 0 {
+			rw.write(latexEffectCommands[effect])
+			opened++
+		}
+	}
+	inner()
+	rw.write(strings.Repeat("}", opened))
+}
+
+func (r *LaTeXRenderer) renderLink(rw *renderWriter, c *HyperLinkContext) {
+	rw.printf("\\href{%s}{", latexURLEscaper.Replace(r.Options.linkURL(c)))
+	if c.Image != nil {
+		r.renderMedia(rw, c.Image)
+	} else if c.InnerContexts != nil {
+		r.renderInlines(rw, c.InnerContexts)
+	} else {
+		rw.write(latexEscaper.Replace(c.Text))
+	}
+	rw.write("}")
+}
+
+func (r *LaTeXRenderer) renderMedia(rw *renderWriter, media *MediaContext) {
+	if media.IsExternal {
+		rw.printf("\\url{%s}", latexURLEscaper.Replace(r.Options.mediaURL(media)))
+	} else {
+		rw.printf("\\includegraphics{%s}", strings.Replace(media.MediaResouce, ":", "/", -1))
+	}
+}
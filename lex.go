@@ -0,0 +1,346 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// TokenKind tells what part of the syntax a token is.
+type TokenKind int
+
+const (
+	// TokenText is plain text, the white space around blocks included.
+	TokenText TokenKind = iota
+	// TokenNewline is the new line ending a line outside of protected tags, or right after the
+	// start tag of code.
+	TokenNewline
+	// TokenHeaderMarker is one of the runs of = around a heading.
+	TokenHeaderMarker
+	// TokenHeaderText is the text between the markers of a heading, with its spaces.
+	TokenHeaderText
+	// TokenListBullet is the indentation, the * or - and the space starting a list item.
+	TokenListBullet
+	// TokenEffectDelimiter is one of **, //, __ and ``.
+	TokenEffectDelimiter
+	// TokenLinkOpen and TokenLinkClose are the [[ and ]] of a link.
+	TokenLinkOpen
+	TokenLinkClose
+	// TokenLinkTarget is what the link points to, TokenLinkLabel the text after the separator.
+	TokenLinkTarget
+	TokenLinkLabel
+	// TokenSeparator is the | between the target and the label of a link or the title of a media.
+	TokenSeparator
+	// TokenMediaOpen and TokenMediaClose are the {{ and }} of a media.
+	TokenMediaOpen
+	TokenMediaClose
+	// TokenMediaTarget is the media with its alignment spaces and size, TokenMediaTitle its title.
+	TokenMediaTarget
+	TokenMediaTitle
+	// TokenCodeFence is a start or end tag of code and file, TokenCodeContent what is between.
+	TokenCodeFence
+	TokenCodeContent
+	// TokenTag is a start or end tag of html and nowiki or a %%, TokenRawContent what is between.
+	TokenTag
+	TokenRawContent
+	// TokenMacro is a control macro like ~~NOTOC~~.
+	TokenMacro
+	// TokenAutoLink is a bare URL that is linked.
+	TokenAutoLink
+)
+
+var tokenKindNames = []string{
+	"text", "newline", "header marker", "header text", "list bullet", "effect delimiter",
+	"link open", "link close", "link target", "link label", "separator",
+	"media open", "media close", "media target", "media title",
+	"code fence", "code content", "tag", "raw content", "macro", "autolink",
+}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Token is a piece of the input, Position is the line Span starts on.
+type Token struct {
+	Kind     TokenKind
+	Span     Span
+	Position Position
+}
+
+// lexProtectedTags are the tags whose content is not parsed, with their end tag. They are
+// matched like the parser does, without case and with spaces allowed before the >.
+var lexProtectedTags = []struct {
+	start, end *regexp.Regexp
+	tag        TokenKind
+	contents   TokenKind
+}{
+	{regexp.MustCompile(`^(?i)<code(?: [a-zA-Z0-9+_#./-]+)?(?: \[[^\]]*\])?\s*>`), regexp.MustCompile(`(?i)</code\s*>`), TokenCodeFence, TokenCodeContent},
+	{regexp.MustCompile(`^(?i)<file(?: [a-zA-Z0-9+_#./-]+(?: [^>]+)?)?\s*>`), regexp.MustCompile(`(?i)</file\s*>`), TokenCodeFence, TokenCodeContent},
+	{regexp.MustCompile(`^(?i)<html\s*>`), regexp.MustCompile(`(?i)</html\s*>`), TokenTag, TokenRawContent},
+	{regexp.MustCompile(`^(?i)<nowiki\s*>`), regexp.MustCompile(`(?i)</nowiki\s*>`), TokenTag, TokenRawContent},
+}
+
+// Lex splits content into tokens for syntax highlighting. The tokens follow each other
+// without gaps or overlaps and cover all of content, an empty content gives no token.
+//
+// Lex follows the rules of Parse, without options or registered syntax, so the tokens may
+// disagree with a tree parsed with them.
+func Lex(content []byte) []Token {
+	l := &lexer{content: content, lineStarts: []int{0}, validURL: autolinkRegexp(nil, true)}
+	for i, b := range content {
+		if b == '\n' {
+			l.lineStarts = append(l.lineStarts, i+1)
+		}
+	}
+	for l.pos < len(content) {
+		l.block()
+	}
+	return l.tokens
+}
+
+type lexer struct {
+	content    []byte
+	lineStarts []int
+	validURL   *regexp.Regexp
+	pos        int
+	tokens     []Token
+}
+
+// emit adds the token from l.pos to end and moves l.pos to end, an empty token is dropped.
+func (l *lexer) emit(kind TokenKind, end int) {
+	if end <= l.pos {
+		return
+	}
+	line := sort.Search(len(l.lineStarts), func(i int) bool { return l.lineStarts[i] > l.pos })
+	l.tokens = append(l.tokens, Token{Kind: kind, Span: Span{l.pos, end}, Position: Position{Line: line}})
+	l.pos = end
+}
+
+// lineEnd returns the offset of the new line ending the line at pos, or the end of content.
+func (l *lexer) lineEnd(pos int) int {
+	if i := bytes.IndexByte(l.content[pos:], '\n'); i != -1 {
+		return pos + i
+	}
+	return len(l.content)
+}
+
+// newline emits the new line at l.pos, if any.
+func (l *lexer) newline() {
+	if l.pos < len(l.content) && l.content[l.pos] == '\n' {
+		l.emit(TokenNewline, l.pos+1)
+	}
+}
+
+// startsBlock tells whether the line at pos is empty or starts a heading or a list item,
+// which ends the paragraph before it.
+func (l *lexer) startsBlock(pos int) bool {
+	line := l.content[pos:l.lineEnd(pos)]
+	if len(bytes.TrimSpace(line)) == 0 {
+		return true
+	}
+	if _, depth, _, _ := parseListItem(line, 0); depth > 0 {
+		return true
+	}
+	level, _ := parseSectionHeader(line)
+	return level > 0
+}
+
+// paraEnd returns the end of the paragraph going on at l.pos, as far as links and media can go.
+func (l *lexer) paraEnd() int {
+	end := l.lineEnd(l.pos)
+	for end+1 < len(l.content) && !l.startsBlock(end+1) {
+		end = l.lineEnd(end + 1)
+	}
+	return end
+}
+
+// block lexes the block starting at l.pos, the beginning of a line.
+func (l *lexer) block() {
+	start, end := l.pos, l.lineEnd(l.pos)
+	line := l.content[start:end]
+	if len(bytes.TrimSpace(line)) == 0 {
+		l.emit(TokenText, end)
+		l.newline()
+		return
+	}
+	if level, _ := parseSectionHeader(line); level > 0 {
+		trimmed := start + len(bytes.TrimRight(line, "\t "))
+		l.emit(TokenHeaderMarker, start+level)
+		l.emit(TokenHeaderText, trimmed-level)
+		l.emit(TokenHeaderMarker, trimmed)
+		l.emit(TokenText, end)
+		l.newline()
+		return
+	}
+	if _, depth, _, _ := parseListItem(line, 0); depth > 0 {
+		// a list item is a single line, unless a protected tag goes on.
+		l.emit(TokenListBullet, start+len(line)-len(bytes.TrimLeft(line, " \t"))+2)
+		l.inline(true)
+		l.newline()
+		return
+	}
+	for {
+		l.inline(false)
+		if l.pos+1 >= len(l.content) || l.startsBlock(l.pos+1) {
+			l.newline()
+			return
+		}
+		l.newline()
+	}
+}
+
+// inline lexes the inline syntax up to the end of the line, protected tags go on until their
+// end tag. Links and media may go on until the end of the paragraph, or of the line in a list item.
+func (l *lexer) inline(listItem bool) {
+	text := l.pos
+	limit := -1
+	// closing returns the offset of end after the open starting at l.pos, -1 when there is none.
+	// An end in the open does not count, so %% can be both.
+	closing := func(open, end string) int {
+		if !bytes.HasPrefix(l.content[l.pos:], []byte(open)) {
+			return -1
+		}
+		if limit < l.pos {
+			if listItem {
+				limit = l.lineEnd(l.pos)
+			} else {
+				limit = l.paraEnd()
+			}
+		}
+		i := bytes.Index(l.content[l.pos+len(open):limit], []byte(end))
+		if i == -1 {
+			return -1
+		}
+		return i + len(open)
+	}
+	for l.pos < len(l.content) && l.content[l.pos] != '\n' {
+		rest := l.content[l.pos:]
+		var syntax func()
+		if tag, n := protectedTag(rest); n > 0 {
+			syntax = func() { l.protected(tag, n) }
+		} else if rest[0] == '/' && isBuiltinEffect(rest, 0) && l.pos > 0 && l.content[l.pos-1] == ':' {
+			// the // in a URL like http://example.com is not italic, nor the rest of the run.
+			l.pos += markerRun(rest, 0)
+			continue
+		} else if isBuiltinEffect(rest, 0) {
+			syntax = func() { l.emit(TokenEffectDelimiter, l.pos+2) }
+		} else if macro := validMacro.Find(rest); macro != nil {
+			syntax = func() { l.emit(TokenMacro, l.pos+len(macro)) }
+		} else if close := closing("%%", "%%"); close != -1 {
+			syntax = func() {
+				l.emit(TokenTag, l.pos+2)
+				l.emit(TokenRawContent, l.pos+close-2)
+				l.emit(TokenTag, l.pos+2)
+			}
+		} else if close := closing("[[", "]]"); close != -1 {
+			syntax = func() { l.link(l.pos + close) }
+		} else if close := closing("{{", "}}"); close != -1 {
+			syntax = func() { l.media(l.pos + close) }
+		} else {
+			l.pos++
+			continue
+		}
+		end := l.pos
+		l.pos = text
+		l.text(end)
+		syntax()
+		text = l.pos
+	}
+	end := l.pos
+	l.pos = text
+	l.text(end)
+}
+
+// text emits the text from l.pos to end with its autolinks.
+func (l *lexer) text(end int) {
+	if l.pos < end {
+		from := l.pos
+		for _, link := range findAutolinks(string(l.content[from:end]), l.validURL) {
+			l.emit(TokenText, from+link[0])
+			l.emit(TokenAutoLink, from+link[1])
+		}
+	}
+	l.emit(TokenText, end)
+}
+
+// link emits the link at l.pos whose ]] is at close.
+func (l *lexer) link(close int) {
+	l.emit(TokenLinkOpen, l.pos+2)
+	if i := l.separator(close); i != -1 {
+		l.emit(TokenLinkTarget, l.pos+i)
+		l.emit(TokenSeparator, l.pos+1)
+		l.emit(TokenLinkLabel, close)
+	}
+	l.emit(TokenLinkTarget, close)
+	l.emit(TokenLinkClose, close+2)
+}
+
+// separator returns the offset from l.pos of the | between the target and the label of the link
+// ending at close, -1 when there is none. Like for Parse, the | in a nowiki tag or between %% is not one.
+func (l *lexer) separator(close int) int {
+	for i := l.pos; i < close; i++ {
+		rest := l.content[i:close]
+		if rest[0] == '|' {
+			return i - l.pos
+		}
+		if nowiki := lexProtectedTags[3]; nowiki.start.Match(rest) {
+			end := nowiki.end.FindIndex(rest)
+			if end == nil {
+				return -1
+			}
+			i += end[1] - 1
+		} else if bytes.HasPrefix(rest, []byte("%%")) {
+			if n := unformattedLength(rest); n > 0 {
+				i += n - 1
+			}
+		}
+	}
+	return -1
+}
+
+// media emits the media at l.pos whose }} is at close.
+func (l *lexer) media(close int) {
+	l.emit(TokenMediaOpen, l.pos+2)
+	if i := bytes.IndexByte(l.content[l.pos:close], '|'); i != -1 {
+		l.emit(TokenMediaTarget, l.pos+i)
+		l.emit(TokenSeparator, l.pos+1)
+		l.emit(TokenMediaTitle, close)
+	}
+	l.emit(TokenMediaTarget, close)
+	l.emit(TokenMediaClose, close+2)
+}
+
+// protectedTag returns the protected tag starting rest and the length of its start tag, 0
+// when there is none.
+func protectedTag(rest []byte) (int, int) {
+	for i, tag := range lexProtectedTags {
+		if n := len(tag.start.Find(rest)); n > 0 {
+			return i, n
+		}
+	}
+	return 0, 0
+}
+
+// protected emits the start tag of length n at l.pos, the content and the end tag, an unclosed
+// tag takes the rest of the content.
+func (l *lexer) protected(i, n int) {
+	tag := lexProtectedTags[i]
+	l.emit(tag.tag, l.pos+n)
+	// the new line after the start tag of code is not content.
+	if tag.tag == TokenCodeFence {
+		if rest := l.content[l.pos:]; bytes.HasPrefix(rest, []byte("\r\n")) || bytes.HasPrefix(rest, []byte("\n")) {
+			l.emit(TokenNewline, l.pos+len(rest)-len(trimCodeNewline(string(rest))))
+		}
+	}
+	end := tag.end.FindIndex(l.content[l.pos:])
+	if end == nil {
+		l.emit(tag.contents, len(l.content))
+		return
+	}
+	l.emit(tag.contents, l.pos+end[0])
+	l.emit(tag.tag, l.pos+end[1]-end[0])
+}
@@ -0,0 +1,123 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var lexDocs = []string{
+	"",
+	"\n\n",
+	streamDoc,
+	"====== Title ======  \n" +
+		"Some **bold** and //italic// at http://example.com/a.\n" +
+		"\n" +
+		"[[ns:page|a\nlabel]] and {{ logo.png?20x10|The logo}} ~~NOTOC~~\n" +
+		"\n" +
+		"  * item with <code go>a\n" +
+		"\n" +
+		"b</code> after\n" +
+		"    - [[http://example.com]] ``mono``\n" +
+		"<file txt a.txt>\nx\n</file><nowiki>**raw**</nowiki> <html><b>\n" +
+		"</html> [[unclosed and {{ too\n" +
+		"== End ==",
+	"see www.example.org,\ntext <code unclosed>\n\n== not a heading ==",
+	"<Code java >a</CODE> and <NoWiki>**b**</NOWIKI>\n",
+	"<code>\n</nowiki>\n</code> <file>a</file> <file php>**b**</file>\n",
+	"<file c f.c>a</code><nowiki>b</file> <nowiki><code c></nowiki>\n",
+	"[[a %%|%% b|c]] %%**x**%% [[a <nowiki>|</nowiki>|b|c]] %% [[d|e]]\n",
+	"a ____ b **a*** ______ C:////Windows/System32 and //C:/Users//\n",
+}
+
+// lexed returns the text of the tokens of a kind in content.
+func lexed(content string, kind TokenKind) []string {
+	texts := make([]string, 0)
+	for _, token := range Lex([]byte(content)) {
+		if token.Kind == kind {
+			texts = append(texts, content[token.Span.Start:token.Span.End])
+		}
+	}
+	return texts
+}
+
+func TestLexCoversInput(t *testing.T) {
+	for _, doc := range lexDocs {
+		end := 0
+		for _, token := range Lex([]byte(doc)) {
+			if token.Span.Start != end || token.Span.End <= token.Span.Start {
+				t.Errorf("%q: token %v %v follows offset %d", doc, token.Kind, token.Span, end)
+			}
+			if line := strings.Count(doc[:token.Span.Start], "\n") + 1; token.Position.Line != line {
+				t.Errorf("%q: token %v %v is at line %d, want %d", doc, token.Kind, token.Span, token.Position.Line, line)
+			}
+			end = token.Span.End
+		}
+		if end != len(doc) {
+			t.Errorf("%q: tokens end at %d of %d", doc, end, len(doc))
+		}
+	}
+}
+
+// TestLexMatchesParse cross checks the tokens with the tree built by Parse.
+func TestLexMatchesParse(t *testing.T) {
+	for _, doc := range lexDocs {
+		unit := Parse([]byte(doc), "")
+		headings, targets, autolinks, code, macros := []string{}, []string{}, []string{}, []string{}, []string{}
+		Walk(unit, func(c Context) bool {
+			switch c := c.(type) {
+			case *SectionHeaderContext:
+				headings = append(headings, c.HeaderText)
+			case *HyperLinkContext:
+				if c.IsAutoLink {
+					autolinks = append(autolinks, c.Text)
+				} else {
+					targets = append(targets, c.HyperLink)
+				}
+			case *CodeFileContext:
+				code = append(code, c.Text)
+			case *MacroContext:
+				macros = append(macros, "~~"+c.Name+"~~")
+			}
+			return true
+		})
+		lexedHeadings := lexed(doc, TokenHeaderText)
+		for i := range lexedHeadings {
+			lexedHeadings[i] = strings.TrimSpace(lexedHeadings[i])
+		}
+		lexedTargets := lexed(doc, TokenLinkTarget)
+		for i := range lexedTargets {
+			lexedTargets[i] = strings.TrimSpace(lexedTargets[i])
+		}
+		for _, check := range []struct {
+			name      string
+			got, want []string
+		}{
+			{"headings", lexedHeadings, headings},
+			{"link targets", lexedTargets, targets},
+			{"autolinks", lexed(doc, TokenAutoLink), autolinks},
+			{"code", lexed(doc, TokenCodeContent), code},
+			{"macros", lexed(doc, TokenMacro), macros},
+		} {
+			if !reflect.DeepEqual(check.got, check.want) {
+				t.Errorf("%q: lexed %s %q, parsed %q", doc, check.name, check.got, check.want)
+			}
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	content := "== A ==\n  * **b** [[c|d]]"
+	tokens := make([]string, 0)
+	for _, token := range Lex([]byte(content)) {
+		tokens = append(tokens, token.Kind.String()+" "+content[token.Span.Start:token.Span.End])
+	}
+	want := []string{
+		"header marker ==", "header text  A ", "header marker ==", "newline \n",
+		"list bullet   * ", "effect delimiter **", "text b", "effect delimiter **", "text  ",
+		"link open [[", "link target c", "separator |", "link label d", "link close ]]",
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %q, want %q", tokens, want)
+	}
+}
@@ -0,0 +1,79 @@
+package linkcheck
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Cache keeps the checks of URLs for a time, so that stable links are not checked on every run.
+// It is used by several goroutines at once.
+type Cache interface {
+	// Get returns the check of url, false when it is not kept or has expired.
+	Get(url string) (Check, bool)
+	// Set keeps the check of url for ttl.
+	Set(url string, check Check, ttl time.Duration)
+}
+
+// MemoryCache is a Cache in memory, Save writes it out to be read again by LoadCache in the next
+// run. The zero value is not usable, use NewMemoryCache.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// now is time.Now, set by the tests.
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	Check   Check     `json:"check"`
+	Expires time.Time `json:"expires"`
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]cacheEntry), now: time.Now}
+}
+
+// LoadCache reads a cache written by MemoryCache.Save.
+func LoadCache(r io.Reader) (*MemoryCache, error) {
+	c := NewMemoryCache()
+	if err := json.NewDecoder(r).Decode(&c.entries); err != nil {
+		return nil, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	return c, nil
+}
+
+func (c *MemoryCache) Get(url string) (Check, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[url]
+	if !ok || !c.now().Before(entry.Expires) {
+		return Check{}, false
+	}
+	return entry.Check, true
+}
+
+func (c *MemoryCache) Set(url string, check Check, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = cacheEntry{Check: check, Expires: c.now().Add(ttl)}
+}
+
+// Save writes the checks that have not expired to w as JSON, keyed by URL.
+func (c *MemoryCache) Save(w io.Writer) error {
+	c.mu.Lock()
+	entries := make(map[string]cacheEntry, len(c.entries))
+	now := c.now()
+	for url, entry := range c.entries {
+		if now.Before(entry.Expires) {
+			entries[url] = entry
+		}
+	}
+	c.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
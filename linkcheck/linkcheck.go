@@ -0,0 +1,260 @@
+// Package linkcheck checks the external links of pages over HTTP, apart from the parser so that
+// it does not depend on the network. The links are checked with HEAD requests, with a ranged GET
+// when a server does not take HEAD, and the answers can be kept in a Cache between runs.
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// ExtCheckOptions configures CheckExternalLinks, the zero value checks with http.DefaultClient
+// and no cache.
+type ExtCheckOptions struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil. Its redirects are not
+	// followed by it but by the checker, which counts them.
+	HTTPClient *http.Client
+	// Concurrency is the number of URLs checked at the same time, 4 when zero.
+	Concurrency int
+	// Timeout limits every request, 10 seconds when zero.
+	Timeout time.Duration
+	// MinInterval is the least time between the start of two requests to the same host,
+	// requests are not limited when it is zero.
+	MinInterval time.Duration
+	// MaxRedirects is the number of redirects followed before giving up, 10 when zero.
+	MaxRedirects int
+	// Cache, when set, gives the checks of URLs checked before, and keeps the new ones for TTL,
+	// 24 hours when zero. Only the checks answered with a status below 500 other than 429 are
+	// kept, the others may be transient.
+	Cache Cache
+	TTL   time.Duration
+}
+
+// Check is the outcome of checking one URL.
+type Check struct {
+	// Status is the status of the last answer, 0 when no answer came.
+	Status int `json:"status"`
+	// FinalURL is the URL the redirects led to, the URL checked when there were none.
+	FinalURL  string `json:"finalURL"`
+	Redirects int    `json:"redirects"`
+	// Latency is the time the requests took, without the waits of MinInterval.
+	Latency time.Duration `json:"latency"`
+	// Error tells why the check failed when no answer came or the redirects did not end, it
+	// is empty otherwise.
+	Error string `json:"error,omitempty"`
+	// CheckedAt is when the check was made.
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Broken tells whether the URL could not be reached or answered with a status of 400 or more.
+func (c Check) Broken() bool {
+	return c.Error != "" || c.Status >= 400
+}
+
+// ExtResult is the check of the URL of one link.
+type ExtResult struct {
+	Link dokuwiki.LinkRef
+	Check
+	// Cached is true when the check came from the cache.
+	Cached bool
+}
+
+// fallbackStatuses are the answers to a HEAD request that are checked again with a GET, the
+// servers that do not take HEAD answer so.
+var fallbackStatuses = map[int]bool{http.StatusForbidden: true, http.StatusMethodNotAllowed: true, http.StatusNotImplemented: true}
+
+// CheckExternalLinks checks the external links with an http or https URL of links, like the
+// ones of ParseUnit.Links, and returns a result for each of them in the order of links, the
+// other links are left out. Every URL is requested once however many links have it. A HEAD
+// request answered with 403, 405 or 501 is made again as a GET of the first byte.
+//
+// When ctx is done, the URLs not checked by then have its error in their result.
+func CheckExternalLinks(ctx context.Context, links []dokuwiki.LinkRef, opts ExtCheckOptions) []ExtResult {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = 10
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	// the checker follows the redirects itself.
+	client := *opts.HTTPClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	c := &checker{opts: opts, client: &client, hosts: make(map[string]time.Time)}
+
+	results := make([]ExtResult, 0)
+	// urls lists the indices in results of the links to every URL, in the order of their first link.
+	urls := make(map[string][]int)
+	order := make([]string, 0)
+	for _, link := range links {
+		if link.Kind != dokuwiki.LinkExternal {
+			continue
+		}
+		if u, err := url.Parse(link.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if _, ok := urls[link.Target]; !ok {
+			order = append(order, link.Target)
+		}
+		urls[link.Target] = append(urls[link.Target], len(results))
+		results = append(results, ExtResult{Link: link})
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency && i < len(order); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every URL is checked by one worker, which writes to the results of its links only.
+			for target := range jobs {
+				check, cached := c.cached(ctx, target)
+				for _, i := range urls[target] {
+					results[i].Check, results[i].Cached = check, cached
+				}
+			}
+		}()
+	}
+	for _, target := range order {
+		jobs <- target
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+type checker struct {
+	opts   ExtCheckOptions
+	client *http.Client
+
+	mu sync.Mutex
+	// hosts is when the next request to every host may start.
+	hosts map[string]time.Time
+}
+
+// cached returns the check of target from the cache, or checks it and keeps it in the cache.
+func (c *checker) cached(ctx context.Context, target string) (Check, bool) {
+	if c.opts.Cache != nil {
+		if check, ok := c.opts.Cache.Get(target); ok {
+			return check, true
+		}
+	}
+	check := c.check(ctx, target)
+	if c.opts.Cache != nil && check.Error == "" && check.Status < 500 && check.Status != http.StatusTooManyRequests {
+		c.opts.Cache.Set(target, check, c.opts.TTL)
+	}
+	return check, false
+}
+
+// check requests target and the URLs it redirects to.
+func (c *checker) check(ctx context.Context, target string) Check {
+	check := Check{FinalURL: target, CheckedAt: time.Now()}
+	for {
+		u, err := url.Parse(check.FinalURL)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		resp, err := c.request(ctx, u, http.MethodHead, &check)
+		if err == nil && fallbackStatuses[resp.StatusCode] {
+			resp, err = c.request(ctx, u, http.MethodGet, &check)
+		}
+		if err != nil {
+			check.Status, check.Error = 0, err.Error()
+			return check
+		}
+		check.Status = resp.StatusCode
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			return check
+		}
+		next, err := u.Parse(location)
+		if err != nil {
+			check.Error = fmt.Sprintf("redirect to an invalid location %q", location)
+			return check
+		}
+		if check.Redirects == c.opts.MaxRedirects {
+			check.Error = fmt.Sprintf("stopped after %d redirects", check.Redirects)
+			return check
+		}
+		check.Redirects++
+		check.FinalURL = next.String()
+	}
+}
+
+// request makes one request to u after the wait of its host, its time is added to the latency
+// of check. The body is closed.
+func (c *checker) request(ctx context.Context, u *url.URL, method string, check *Check) (*http.Response, error) {
+	if err := c.wait(ctx, u.Host); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	check.Latency += time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	// a server ignoring the range sends all of the body, only a little of it is read so the
+	// connection can be reused.
+	io.CopyN(io.Discard, resp.Body, 4096)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// wait blocks until MinInterval has passed since the start of the previous request to host, or
+// ctx is done.
+func (c *checker) wait(ctx context.Context, host string) error {
+	if c.opts.MinInterval <= 0 {
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	next := c.hosts[host]
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	// reserve the slot before sleeping so concurrent requests queue up behind each other.
+	c.hosts[host] = next.Add(c.opts.MinInterval)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
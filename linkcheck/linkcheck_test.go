@@ -0,0 +1,159 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// testServer answers the paths of the tests and counts the requests by method and path, the
+// counts are read once the checks are done.
+func testServer(t *testing.T) (*httptest.Server, map[string]int) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ok":
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("GET without range: %q", r.Header.Get("Range"))
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server, requests
+}
+
+// pageLinks returns the links of a page linking to the paths on server.
+func pageLinks(server *httptest.Server, paths ...string) []dokuwiki.LinkRef {
+	var b strings.Builder
+	b.WriteString("[[start]] [[wp>DokuWiki]] [[mailto:a@example.com]]\n")
+	for _, path := range paths {
+		b.WriteString("[[" + server.URL + path + "]]\n")
+	}
+	return dokuwiki.Parse([]byte(b.String()), "page").Links()
+}
+
+func TestCheckExternalLinks(t *testing.T) {
+	server, requests := testServer(t)
+	defer server.Close()
+
+	links := pageLinks(server, "/ok", "/nohead", "/moved", "/missing", "/loop", "/ok")
+	results := CheckExternalLinks(context.Background(), links, ExtCheckOptions{MaxRedirects: 3})
+	want := []struct {
+		path      string
+		status    int
+		finalPath string
+		redirects int
+		broken    bool
+	}{
+		{"/ok", 200, "/ok", 0, false},
+		{"/nohead", 206, "/nohead", 0, false},
+		{"/moved", 200, "/ok", 1, false},
+		{"/missing", 404, "/missing", 0, true},
+		{"/loop", 302, "/loop", 3, true},
+		{"/ok", 200, "/ok", 0, false},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Link.Target != server.URL+w.path || r.Status != w.status || r.FinalURL != server.URL+w.finalPath ||
+			r.Redirects != w.redirects || r.Broken() != w.broken || r.Cached {
+			t.Errorf("%d: got %+v, want %+v", i, r, w)
+		}
+		if r.Latency <= 0 || r.CheckedAt.IsZero() {
+			t.Errorf("%d: no latency or time in %+v", i, r)
+		}
+	}
+	if results[4].Error != "stopped after 3 redirects" {
+		t.Errorf("got error %q", results[4].Error)
+	}
+	// every URL once, a GET only after a HEAD that is not taken.
+	for request, n := range map[string]int{"HEAD /ok": 2, "HEAD /nohead": 1, "GET /nohead": 1, "HEAD /moved": 1, "HEAD /loop": 4, "GET /ok": 0} {
+		if requests[request] != n {
+			t.Errorf("%s requested %d times, want %d", request, requests[request], n)
+		}
+	}
+}
+
+func TestCheckExternalLinksCache(t *testing.T) {
+	server, requests := testServer(t)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewMemoryCache()
+	cache.now = func() time.Time { return now }
+	links := pageLinks(server, "/ok", "/missing", "/busy")
+	opts := ExtCheckOptions{Cache: cache, TTL: time.Hour}
+	CheckExternalLinks(context.Background(), links, opts)
+
+	// the cache is saved and loaded for the next run.
+	var saved bytes.Buffer
+	if err := cache.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCache(&saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.now = cache.now
+	opts.Cache = loaded
+	results := CheckExternalLinks(context.Background(), links, opts)
+	if !results[0].Cached || results[0].Status != 200 || !results[1].Cached || results[1].Status != 404 {
+		t.Errorf("got %+v", results)
+	}
+	// a 503 may be transient, it is checked again.
+	if results[2].Cached || requests["HEAD /busy"] != 2 || requests["HEAD /ok"] != 1 {
+		t.Errorf("got %+v after %v", results[2], requests)
+	}
+
+	now = now.Add(time.Hour)
+	if results := CheckExternalLinks(context.Background(), links, opts); results[0].Cached || requests["HEAD /ok"] != 2 {
+		t.Errorf("expired check used: %+v", results[0])
+	}
+}
+
+func TestCheckExternalLinksRateLimit(t *testing.T) {
+	server, _ := testServer(t)
+	defer server.Close()
+
+	links := pageLinks(server, "/ok", "/missing", "/nohead")
+	start := time.Now()
+	// 4 requests to the same host, the GET of /nohead included.
+	CheckExternalLinks(context.Background(), links, ExtCheckOptions{Concurrency: 3, MinInterval: 50 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("took %v, want 150ms at least", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range CheckExternalLinks(ctx, links, ExtCheckOptions{}) {
+		if r.Error != context.Canceled.Error() || !r.Broken() {
+			t.Errorf("got %+v after cancel", r)
+		}
+	}
+}
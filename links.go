@@ -0,0 +1,120 @@
+package dokuwiki
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	validEmail     = regexp.MustCompile(`^(mailto:)?[\w.%+-]+@[\w-]+(\.[\w-]+)+$`)
+	validInterwiki = regexp.MustCompile(`^[a-zA-Z0-9.]+>`)
+)
+
+// classifyLink tells what the target of a [[link]] points to.
+func classifyLink(target string) LinkKind {
+	switch {
+	case strings.HasPrefix(target, `\\`):
+		return LinkWindowsShare
+	case strings.Contains(target, "://"):
+		return LinkExternal
+	case validEmail.MatchString(target):
+		return LinkEmail
+	case validInterwiki.MatchString(target):
+		return LinkInterwiki
+	}
+	return LinkInternal
+}
+
+// SetTarget sets the target of the link and the fields parsed from it for its Kind, which is
+// kept. The error is the one of net/url for an external link whose URL is not valid.
+func (c *HyperLinkContext) SetTarget(target string) error {
+	c.HyperLink = target
+	c.Scheme, c.PageID, c.Namespace, c.Anchor, c.Query = "", "", "", "", ""
+	switch c.Kind {
+	case LinkExternal:
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		c.Scheme, c.Anchor, c.Query = u.Scheme, u.Fragment, u.RawQuery
+		return nil
+	case LinkEmail:
+		c.Scheme = "mailto"
+		return nil
+	case LinkWindowsShare:
+		c.Scheme = "file"
+		return nil
+	case LinkInterwiki:
+		if i := strings.IndexByte(target, '>'); i != -1 {
+			c.Scheme, target = target[:i], target[i+1:]
+		}
+	}
+	if i := strings.IndexByte(target, '#'); i != -1 {
+		target, c.Anchor = target[:i], target[i+1:]
+	}
+	if c.Kind == LinkInterwiki {
+		c.PageID = target
+		return nil
+	}
+	if i := strings.IndexByte(target, '?'); i != -1 {
+		target, c.Query = target[:i], target[i+1:]
+	}
+	c.PageID = target
+	if i := strings.LastIndexByte(target, ':'); i != -1 {
+		c.Namespace = target[:i]
+	}
+	return nil
+}
+
+// LinkRef describes one link of a unit.
+type LinkRef struct {
+	// Target is the link target as written, like "ns:page#section" or "https://example.com".
+	Target string
+	Kind   LinkKind
+	// Anchor is the part of the target after the #, without it.
+	Anchor string
+	// Text is what the link displays.
+	Text string
+	// IsAutoLink is true for a bare URL in the text.
+	IsAutoLink bool
+	Position   Position
+	// Link is the context the reference was made from.
+	Link *HyperLinkContext
+}
+
+// Links returns every link of the unit in document order, wherever it is in the tree,
+// including autolinked URLs.
+func (unit *ParseUnit) Links() []LinkRef {
+	refs := make([]LinkRef, 0)
+	Walk(unit, func(c Context) bool {
+		link, ok := c.(*HyperLinkContext)
+		if !ok {
+			return true
+		}
+		refs = append(refs, LinkRef{
+			Target:     link.HyperLink,
+			Kind:       link.Kind,
+			Anchor:     link.Anchor,
+			Text:       link.Text,
+			IsAutoLink: link.IsAutoLink,
+			Position:   link.GetPosition(),
+			Link:       link,
+		})
+		return true
+	})
+	return refs
+}
+
+// UniqueLinks is like Links but keeps only the first link to every target.
+func (unit *ParseUnit) UniqueLinks() []LinkRef {
+	seen := make(map[string]bool)
+	refs := make([]LinkRef, 0)
+	for _, ref := range unit.Links() {
+		if !seen[ref.Target] {
+			seen[ref.Target] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
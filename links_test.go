@@ -0,0 +1,289 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	content := `====== Links ======
+See [[wiki:syntax#links|the syntax]] and
+https://example.com/a for more.
+
+  * [[wp>DokuWiki]] in a list
+    * [[user@example.com]] and [[\\server\share]]
+  * again [[wiki:syntax]]
+
+[[https://example.com/a|same URL]]
+`
+	unit := Parse([]byte(content), "links")
+	want := []LinkRef{
+		{Target: "wiki:syntax#links", Kind: LinkInternal, Anchor: "links", Text: "the syntax", Position: Position{Line: 2}},
+		{Target: "https://example.com/a", Kind: LinkExternal, Text: "https://example.com/a", IsAutoLink: true, Position: Position{Line: 2}},
+		{Target: "wp>DokuWiki", Kind: LinkInterwiki, Text: "wp>DokuWiki", Position: Position{Line: 5}},
+		{Target: "user@example.com", Kind: LinkEmail, Text: "user@example.com", Position: Position{Line: 6}},
+		{Target: `\\server\share`, Kind: LinkWindowsShare, Text: `\\server\share`, Position: Position{Line: 6}},
+		{Target: "wiki:syntax", Kind: LinkInternal, Text: "wiki:syntax", Position: Position{Line: 7}},
+		{Target: "https://example.com/a", Kind: LinkExternal, Text: "same URL", Position: Position{Line: 9}},
+	}
+	links := unit.Links()
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i, link := range links {
+		if link.Link == nil || link.Link.HyperLink != link.Target {
+			t.Errorf("link %d does not point to its context", i)
+		}
+		link.Link = nil
+		if link != want[i] {
+			t.Errorf("link %d is %+v, want %+v", i, link, want[i])
+		}
+	}
+
+	unique := unit.UniqueLinks()
+	if len(unique) != 6 || unique[5].Target != "wiki:syntax" {
+		t.Errorf("unexpected unique links %+v", unique)
+	}
+}
+
+func TestRenderLinkKinds(t *testing.T) {
+	unit := Parse([]byte(`[[user@example.com]] [[\\server\share]] [[wp>DokuWiki]]`), "t")
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "\n<p>\n" +
+		`<a href="mailto:user@example.com" class="mail" title="user@example.com">user@example.com</a> ` +
+		`<a href="file://server/share" class="windows" title="\\server\share">\\server\share</a> ` +
+		`<a href="wp&gt;DokuWiki" class="interwiki" title="wp&gt;DokuWiki">wp&gt;DokuWiki</a>` +
+		"\n</p>\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestClassifyLink(t *testing.T) {
+	options := ParseOptions{
+		ClassifyLink: func(target string) (LinkKind, string, bool) {
+			if strings.HasPrefix(target, "jira:") {
+				return LinkExternal, "https://jira.example.com/browse/" + strings.TrimPrefix(target, "jira:"), true
+			}
+			if strings.HasPrefix(target, "gerrit:") {
+				return LinkExternal, "", true
+			}
+			return 0, "", false
+		},
+	}
+	unit := ParseWithOptions([]byte("[[jira:ABC-123]] [[jira:ABC-7|the bug]] [[gerrit:12345]] [[ns:page]] [[wp>Go]]"), "t", options)
+	want := []LinkRef{
+		{Target: "https://jira.example.com/browse/ABC-123", Kind: LinkExternal, Text: "jira:ABC-123", Position: Position{Line: 1}},
+		{Target: "https://jira.example.com/browse/ABC-7", Kind: LinkExternal, Text: "the bug", Position: Position{Line: 1}},
+		{Target: "gerrit:12345", Kind: LinkExternal, Text: "gerrit:12345", Position: Position{Line: 1}},
+		{Target: "ns:page", Kind: LinkInternal, Text: "ns:page", Position: Position{Line: 1}},
+		{Target: "wp>Go", Kind: LinkInterwiki, Text: "wp>Go", Position: Position{Line: 1}},
+	}
+	links := unit.Links()
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i, link := range links {
+		link.Link = nil
+		if link != want[i] {
+			t.Errorf("link %d is %+v, want %+v", i, link, want[i])
+		}
+	}
+}
+
+func TestLinkLabels(t *testing.T) {
+	content := "[[page|**bold** label]] [[page|a %%|%% b]] [[a|b|c]] [[a <nowiki>|</nowiki> b|c]] [[page|<nowiki>**x**</nowiki> http://example.com]]"
+	unit := Parse([]byte(content), "t")
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "t"
+  Para
+    Link internal target="page" "**bold** label"
+      Text effect=bold "bold"
+      Text " label"
+    Text " "
+    Link internal target="page" "a %%|%% b"
+      Text "a "
+      NoWiki "|"
+      Text " b"
+    Text " "
+    Link internal target="a" "b|c"
+      Text "b|c"
+    Text " "
+    Link internal target="a <nowiki>|</nowiki> b" "c"
+      Text "c"
+    Text " "
+    Link internal target="page" "<nowiki>**x**</nowiki> http://example.com"
+      NoWiki "**x**"
+      Text " http://example.com"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	for _, inline := range unit.Sections[0].(*ParaContext).InnerContexts {
+		if link, ok := inline.(*HyperLinkContext); ok {
+			for _, label := range link.InnerContexts {
+				if label.GetParentContext() != link {
+					t.Errorf("label of %q is not in the link", link.HyperLink)
+				}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`title="page"><strong>bold</strong> label</a>`,
+		`title="page">a | b</a>`,
+		`title="a">b|c</a>`,
+		`title="page">**x** http://example.com</a>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("html does not contain %s:\n%s", want, out.String())
+		}
+	}
+
+	var wiki bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+	if wiki.String() != content+"\n" {
+		t.Errorf("serialized as %q", wiki.String())
+	}
+}
+
+func TestLinkTargets(t *testing.T) {
+	content := "[[ns:sub:page?do=edit#intro|a]] [[:start]] [[#local]] [[wp>DokuWiki#History]] " +
+		"[[https://example.com/a?q=1#top]] [[mailto:user@example.com]] [[\\\\server\\share]] " +
+		"[[http://exa mple.com|bad]] www.example.org/b?c"
+	unit := Parse([]byte(content), "t")
+	want := []HyperLinkContext{
+		{HyperLink: "ns:sub:page?do=edit#intro", PageID: "ns:sub:page", Namespace: "ns:sub", Query: "do=edit", Anchor: "intro"},
+		{HyperLink: ":start", PageID: ":start"},
+		{HyperLink: "#local", Anchor: "local"},
+		{HyperLink: "wp>DokuWiki#History", Scheme: "wp", PageID: "DokuWiki", Anchor: "History"},
+		{HyperLink: "https://example.com/a?q=1#top", Scheme: "https", Query: "q=1", Anchor: "top"},
+		{HyperLink: "mailto:user@example.com", Scheme: "mailto"},
+		{HyperLink: `\\server\share`, Scheme: "file"},
+		{HyperLink: "http://exa mple.com"},
+		{HyperLink: "http://www.example.org/b?c", Scheme: "http", Query: "c"},
+	}
+	links := unit.Links()
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d", len(links), len(want))
+	}
+	for i, ref := range links {
+		got, w := ref.Link, want[i]
+		if got.HyperLink != w.HyperLink || got.Scheme != w.Scheme || got.PageID != w.PageID || got.Namespace != w.Namespace || got.Query != w.Query || got.Anchor != w.Anchor {
+			t.Errorf("link %d: got %q scheme=%q page=%q ns=%q query=%q anchor=%q", i, got.HyperLink, got.Scheme, got.PageID, got.Namespace, got.Query, got.Anchor)
+		}
+	}
+
+	if len(unit.Diagnostics) != 1 || !strings.HasPrefix(unit.Diagnostics[0].Message, `line 1: invalid URL "http://exa mple.com": `) {
+		t.Errorf("got diagnostics %v", unit.Diagnostics)
+	}
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a class="urlextern" title="http://exa mple.com" rel="ugc nofollow">bad</a>`; !strings.Contains(out.String(), want) {
+		t.Errorf("html does not contain %s:\n%s", want, out.String())
+	}
+	var wiki bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+	if wiki.String() != content+"\n" {
+		t.Errorf("serialized as %q", wiki.String())
+	}
+
+	link := links[0].Link
+	link.SetTarget("other:page")
+	if link.PageID != "other:page" || link.Namespace != "other" || link.Query != "" || link.Anchor != "" {
+		t.Errorf("after SetTarget got page=%q ns=%q query=%q anchor=%q", link.PageID, link.Namespace, link.Query, link.Anchor)
+	}
+}
+
+func TestEffectsAroundLinks(t *testing.T) {
+	tests := []struct {
+		content string
+		dump    string
+		html    string
+	}{
+		{
+			"**see [[page|here]] please**",
+			`    Text effect=bold "see "
+    Link internal effect=bold target="page" "here"
+      Text "here"
+    Text effect=bold " please"
+`,
+			`<strong>see </strong><strong><a href="page" class="wikilink1" title="page">here</a></strong><strong> please</strong>`,
+		},
+		{
+			"//a {{logo.png}} b//",
+			`    Text effect=italic "a "
+    Media effect=italic "logo.png" align=1 width=0 height=0 title=""
+    Text effect=italic " b"
+`,
+			`<em><a href="lib/exe/detail.php?media=logo.png" class="media" title="logo.png"><img src="_media/logo.png" class="mediacenter" alt="" /></a></em>`,
+		},
+		{
+			"a __b [[page]] c__ d",
+			`    Text "a "
+    Text effect=underline "b "
+    Link internal effect=underline target="page" "page"
+    Text effect=underline " c"
+    Text " d"
+`,
+			`<em class="u"><a href="page" class="wikilink1" title="page">page</a></em>`,
+		},
+	}
+	for _, test := range tests {
+		unit := Parse([]byte(test.content), "t")
+		var dump bytes.Buffer
+		if err := Dump(&dump, unit); err != nil {
+			t.Fatal(err)
+		}
+		if want := "ParseUnit \"t\"\n  Para\n" + test.dump; dump.String() != want {
+			t.Errorf("%q parsed as\n%s\nwant\n%s", test.content, dump.String(), want)
+		}
+		if len(unit.Diagnostics) != 0 {
+			t.Errorf("%q gave diagnostics %v", test.content, unit.Diagnostics)
+		}
+
+		var out bytes.Buffer
+		if err := Render(unit, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), test.html) {
+			t.Errorf("html does not contain %s:\n%s", test.html, out.String())
+		}
+
+		var wiki, again bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+		Dump(&again, Parse(wiki.Bytes(), "t"))
+		if again.String() != dump.String() {
+			t.Errorf("%q serialized as %q, parsed back as\n%s", test.content, wiki.String(), again.String())
+		}
+	}
+}
+
+func TestMediaDetailURL(t *testing.T) {
+	unit := ParseWithOptions([]byte("{{logo.png}} {{:a b&c.png}}"), "t", ParseOptions{PageID: "wiki:start"})
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`href="lib/exe/detail.php?media=wiki%3Alogo.png&amp;id=wiki%3Astart"`,
+		`href="lib/exe/detail.php?media=a_b_c.png&amp;id=wiki%3Astart"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("html does not contain %s:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a lint finding or a diagnostic is.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity is the inverse of Severity.String.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q", name)
+}
+
+// Finding is a problem reported by a lint rule, or by a checker turned into one like
+// BrokenLinkFindings does.
+type Finding struct {
+	// Rule is the ID of the rule that reported the finding.
+	Rule     string
+	Severity Severity
+	Message  string
+	Position Position
+	// File is the path of the page of the finding, Lint leaves it empty.
+	File string
+	// Span is the bytes of the finding in the input and Column the byte of its line it starts
+	// at, from 1. Lint sets them to the whole line when the rule gave only the line.
+	Span   Span
+	Column int
+}
+
+// Rule checks a unit for one kind of problem. Implement it to add rules of your own.
+type Rule interface {
+	// ID names the rule, like "trailing-whitespace", it is used to disable the rule.
+	ID() string
+	Check(unit *ParseUnit) []Finding
+}
+
+// Lint checks unit with rules, DefaultRules when none are given. The findings are sorted by
+// position, the Rule of those left empty by a rule is set to its ID.
+func Lint(unit *ParseUnit, rules ...Rule) []Finding {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	findings := make([]Finding, 0)
+	lines := newLineIndex(unit.source)
+	for _, rule := range rules {
+		for _, finding := range rule.Check(unit) {
+			if finding.Rule == "" {
+				finding.Rule = rule.ID()
+			}
+			finding.locate(lines)
+			findings = append(findings, finding)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Position.Line < findings[j].Position.Line
+	})
+	return findings
+}
+
+// DefaultRules returns the rules shipped with the package:
+//
+//	header-markers       a heading with a different number of = on each side, warning
+//	heading-levels       a heading more than one level below the previous one, warning
+//	top-heading          a page without headings or whose first heading is not its biggest, info
+//	unclosed-formatting  a formatting marker like ** not closed in its paragraph, warning
+//	bare-url             a URL written in the text instead of as a link, info
+//	empty-link-label     a link with a | but no label, warning
+//	image-title          an image without a title, which is its alt text, warning
+//	trailing-whitespace  a line ending in spaces or tabs, info
+//	fixme                a FIXME or DELETEME marker, info
+//
+// The rules working on lines need a unit parsed from content, they find nothing in units
+// built by hand.
+func DefaultRules() []Rule {
+	return []Rule{
+		&lintRule{"header-markers", SeverityWarning, checkHeaderMarkers},
+		headingLevelsRule,
+		topHeadingRule,
+		&lintRule{"unclosed-formatting", SeverityWarning, checkUnclosedFormatting},
+		&lintRule{"bare-url", SeverityInfo, checkBareURLs},
+		&lintRule{"empty-link-label", SeverityWarning, checkEmptyLinkLabels},
+		&lintRule{"image-title", SeverityWarning, checkImageTitles},
+		&lintRule{"trailing-whitespace", SeverityInfo, checkTrailingWhitespace},
+		&lintRule{"fixme", SeverityInfo, checkFixmes},
+	}
+}
+
+// The rules on the heading structure, Report runs them too. Rules keep no state, so they are shared.
+var (
+	headingLevelsRule = &lintRule{"heading-levels", SeverityWarning, checkHeadingLevels}
+	topHeadingRule    = &lintRule{"top-heading", SeverityInfo, checkTopHeading}
+)
+
+// DisableRules returns rules without the ones whose ID is in ids.
+func DisableRules(rules []Rule, ids ...string) []Rule {
+	disabled := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		disabled[id] = true
+	}
+	enabled := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if !disabled[rule.ID()] {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled
+}
+
+// lintRule is a rule shipped with the package, check reports the problems it finds with report.
+type lintRule struct {
+	id       string
+	severity Severity
+	check    func(unit *ParseUnit, report func(pos Position, format string, args ...interface{}))
+}
+
+func (r *lintRule) ID() string {
+	return r.id
+}
+
+func (r *lintRule) Check(unit *ParseUnit) []Finding {
+	findings := make([]Finding, 0)
+	r.check(unit, func(pos Position, format string, args ...interface{}) {
+		findings = append(findings, Finding{Rule: r.id, Severity: r.severity, Message: fmt.Sprintf(format, args...), Position: pos})
+	})
+	return findings
+}
+
+// headerLine is a line that looks like a heading, with any number of = on each side.
+var headerLine = regexp.MustCompile(`^[ \t]*(={2,})[^=]+(={2,})[ \t]*$`)
+
+func checkHeaderMarkers(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	sourceLines(unit, func(line int, text string) {
+		if groups := headerLine.FindStringSubmatch(text); groups != nil && len(groups[1]) != len(groups[2]) {
+			report(Position{Line: line}, "heading has %d = before and %d after, it is not taken as a heading", len(groups[1]), len(groups[2]))
+		}
+	})
+}
+
+func checkHeadingLevels(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	previous := 0
+	for _, block := range unit.Sections {
+		header, ok := block.(*SectionHeaderContext)
+		if !ok {
+			continue
+		}
+		// a bigger HeaderLevel is a bigger heading.
+		if previous > 0 && header.HeaderLevel < previous-1 {
+			report(header.GetPosition(), "heading %q skips %d level(s)", header.HeaderText, previous-header.HeaderLevel-1)
+		}
+		previous = header.HeaderLevel
+	}
+}
+
+func checkTopHeading(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	var first, top *SectionHeaderContext
+	for _, block := range unit.Sections {
+		if header, ok := block.(*SectionHeaderContext); ok {
+			if first == nil {
+				first = header
+			}
+			if top == nil || header.HeaderLevel > top.HeaderLevel {
+				top = header
+			}
+		}
+	}
+	switch {
+	case first == nil && len(unit.Sections) > 0:
+		// the line of the first block, unknown for units built by hand.
+		pos := Position{}
+		if positioned, ok := unit.Sections[0].(interface{ GetPosition() Position }); ok {
+			pos = positioned.GetPosition()
+		}
+		report(pos, "page has no heading")
+	case first != top:
+		report(first.GetPosition(), "first heading %q is smaller than heading %q", first.HeaderText, top.HeaderText)
+	}
+}
+
+// effectMarkers are the formatting markers in the order of the effect bits.
+var effectMarkers = map[uint32]string{
+	TextEffectBold:      "**",
+	TextEffectItalic:    "//",
+	TextEffectUnderline: "__",
+	TextEffectMonoSpace: "``",
+}
+
+func checkUnclosedFormatting(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	Walk(unit, func(c Context) bool {
+		para, ok := c.(*ParaContext)
+		if !ok {
+			return true
+		}
+		if effect := unclosedEffects(para); effect != 0 {
+			for _, e := range effectOrder {
+				if effect&e != 0 {
+					report(para.GetPosition(), "%s is not closed before the end of the paragraph", effectMarkers[e])
+				}
+			}
+		}
+		return false
+	})
+}
+
+// markerEffects are the effects of the characters of the doubled markers.
+var markerEffects = map[byte]uint32{'*': TextEffectBold, '/': TextEffectItalic, '_': TextEffectUnderline, '`': TextEffectMonoSpace}
+
+// unclosedEffects follows the formatting markers of a paragraph like parsePara does and returns the
+// effects still on at its end. Tags end the effects, links and media are skipped.
+func unclosedEffects(para *ParaContext) uint32 {
+	var effect uint32
+	raw := para.rawText
+	tags := para.tagRegions(false)
+	for offset := 0; offset+1 < len(raw); offset++ {
+		for len(tags) > 0 && tags[0].start < offset {
+			tags = tags[1:]
+		}
+		nextTag := len(raw)
+		if len(tags) > 0 {
+			nextTag = tags[0].start
+		}
+		ch, next := raw[offset], raw[offset+1]
+		switch {
+		case offset == nextTag:
+			// a tag, its content is skipped, an unterminated tag takes the rest.
+			effect = 0
+			offset = tags[0].end - 1
+		case ch == '/' && next == '/' && offset > 0 && raw[offset-1] == ':':
+			offset += markerRun([]byte(raw[offset:]), 0) - 1
+		case markerEffects[ch] != 0 && next == ch:
+			before, _, after := splitMarkerRun(effect&markerEffects[ch] != 0, markerRun([]byte(raw[offset:]), 0))
+			if before != after {
+				effect ^= markerEffects[ch]
+			}
+			offset += markerRun([]byte(raw[offset:]), 0) - 1
+		case ch == '%' && next == '%' && unformattedLength([]byte(raw[offset:nextTag])) > 0:
+			offset += unformattedLength([]byte(raw[offset:nextTag])) - 1
+		case ch == '[' && next == '[' && strings.Contains(raw[offset:], "]]"):
+			offset += strings.Index(raw[offset:], "]]") + 1
+		case ch == '{' && next == '{' && strings.Contains(raw[offset:], "}}"):
+			offset += strings.Index(raw[offset:], "}}") + 1
+		}
+	}
+	return effect
+}
+
+func checkBareURLs(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	Walk(unit, func(c Context) bool {
+		if link, ok := c.(*HyperLinkContext); ok && link.IsAutoLink {
+			report(link.GetPosition(), "bare URL %s, write it as [[%s|label]]", link.HyperLink, link.HyperLink)
+		}
+		return true
+	})
+}
+
+func checkEmptyLinkLabels(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	Walk(unit, func(c Context) bool {
+		if link, ok := c.(*HyperLinkContext); ok && link.Image == nil && strings.TrimSpace(link.Text) == "" {
+			report(link.GetPosition(), "link to %s has an empty label", link.HyperLink)
+		}
+		return true
+	})
+}
+
+func checkImageTitles(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	Walk(unit, func(c Context) bool {
+		if media, ok := c.(*MediaContext); ok && media.Title == "" && imageExtensions[strings.ToLower(path.Ext(media.MediaResouce))] {
+			report(media.GetPosition(), "image %s has no title to use as alt text", media.MediaResouce)
+		}
+		return true
+	})
+}
+
+func checkTrailingWhitespace(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	for i, line := range strings.Split(unit.source, "\n") {
+		if strings.TrimRight(line, " \t") != line {
+			report(Position{Line: i + 1}, "line ends with whitespace")
+		}
+	}
+}
+
+func checkFixmes(unit *ParseUnit, report func(Position, string, ...interface{})) {
+	for _, fixme := range unit.Metadata().Fixmes {
+		report(fixme.Position, "%s marker", fixme.Text)
+	}
+}
+
+// sourceLines calls fn with the lines of the source of unit that are not in code, file,
+// html or nowiki tags.
+func sourceLines(unit *ParseUnit, fn func(line int, text string)) {
+	closing := ""
+	for i, text := range strings.Split(unit.source, "\n") {
+		if closing != "" {
+			if strings.Contains(text, closing) {
+				closing = ""
+			}
+			continue
+		}
+		for _, tag := range []string{"code", "file", "html", "HTML", "nowiki"} {
+			if j := strings.Index(text, "<"+tag); j != -1 && !strings.Contains(text[j:], "</"+tag+">") {
+				closing = "</" + tag + ">"
+			}
+		}
+		fn(i+1, text)
+	}
+}
@@ -0,0 +1,63 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	content := "====== Guide ======\n" +
+		"=== Too deep ===\n" +
+		"Some **bold text \n" +
+		"and a link to http://example.com here.\n" +
+		"\n" +
+		"==== Broken ===\n" +
+		"\n" +
+		"[[wiki:page|]] {{logo.png}} {{logo.png|Logo}} FIXME\n" +
+		"<code text>\n" +
+		"=== not a heading ==\n" +
+		"**\n" +
+		"</code>\n"
+	got := make([]string, 0)
+	for _, finding := range Lint(Parse([]byte(content), "guide")) {
+		got = append(got, fmt.Sprintf("%d %s %s: %s", finding.Position.Line, finding.Severity, finding.Rule, finding.Message))
+	}
+	want := []string{
+		`2 warning heading-levels: heading "Too deep" skips 2 level(s)`,
+		"3 warning unclosed-formatting: ** is not closed before the end of the paragraph",
+		"3 info bare-url: bare URL http://example.com, write it as [[http://example.com|label]]",
+		"3 info trailing-whitespace: line ends with whitespace",
+		"6 warning header-markers: heading has 4 = before and 3 after, it is not taken as a heading",
+		"8 warning empty-link-label: link to wiki:page has an empty label",
+		"8 warning image-title: image logo.png has no title to use as alt text",
+		"8 info fixme: FIXME marker",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+type upperRule struct{}
+
+func (upperRule) ID() string { return "no-shouting" }
+
+func (upperRule) Check(unit *ParseUnit) []Finding {
+	findings := make([]Finding, 0)
+	for _, entry := range unit.Outline() {
+		if entry.Text == strings.ToUpper(entry.Text) {
+			findings = append(findings, Finding{Severity: SeverityError, Message: "heading in capitals", Position: entry.Position})
+		}
+	}
+	return findings
+}
+
+func TestLintRules(t *testing.T) {
+	unit := Parse([]byte("== LOUD ==\nline \n"), "doc")
+	findings := Lint(unit, append(DisableRules(DefaultRules(), "trailing-whitespace"), upperRule{})...)
+	want := []Finding{{Rule: "no-shouting", Severity: SeverityError, Message: "heading in capitals", Position: Position{Line: 1}, Span: Span{0, 10}, Column: 1}}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("got %+v, want %+v", findings, want)
+	}
+}
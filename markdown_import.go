@@ -0,0 +1,403 @@
+package dokuwiki
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdFence         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+	mdATXHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdSetextLine    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdListItem      = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	mdBlockquote    = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	mdThematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdTableDivider  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdIndentedCode  = regexp.MustCompile(`^(?: {4}|\t)(.*)$`)
+)
+
+// FromMarkdown converts Markdown to a unit, which DokuWikiRenderer can write out as DokuWiki
+// markup. It handles the common part of CommonMark: ATX and setext headings, emphasis, code
+// spans, fenced and indented code, links, images, autolinks and nested lists.
+//
+// What has no equivalent in the tree is kept as text and reported in the diagnostics of the
+// unit: the content of blockquotes, tables, whose rows are kept as DokuWiki reads them, thematic
+// breaks, kept as DokuWiki's ----, and strikethrough. The error is only returned when src is not
+// valid UTF-8.
+func FromMarkdown(src []byte, title string) (*ParseUnit, error) {
+	if !utf8.Valid(src) {
+		return nil, errors.New("markdown is not valid UTF-8")
+	}
+	m := &markdownImporter{
+		unit:     &ParseUnit{Title: title, Sections: make([]BlockContext, 0)},
+		lines:    strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n"),
+		reported: make(map[string]bool),
+	}
+	m.parseBlocks()
+	return m.unit, nil
+}
+
+type markdownImporter struct {
+	unit  *ParseUnit
+	lines []string
+	// next is the index in lines of the line to read next.
+	next int
+	// reported are the unsupported constructs already in the diagnostics, each is reported once.
+	reported map[string]bool
+}
+
+func (m *markdownImporter) unsupported(line int, construct, kept string) {
+	if !m.reported[construct] {
+		m.reported[construct] = true
+		m.unit.AddDiagnostic(CodeUnsupportedMarkdown, Position{Line: line}, "markdown %s are not supported, %s", construct, kept)
+	}
+}
+
+func (m *markdownImporter) newPara(line int) *ParaContext {
+	return &ParaContext{BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: m.unit, pos: Position{Line: line}}}}
+}
+
+func (m *markdownImporter) addBlock(block BlockContext) {
+	m.unit.Sections = append(m.unit.Sections, block)
+}
+
+// startsBlock tells whether line begins a block that interrupts a paragraph.
+func startsBlock(line string) bool {
+	return mdFence.MatchString(line) || mdATXHeading.MatchString(line) || mdBlockquote.MatchString(line) ||
+		mdThematicBreak.MatchString(line) || mdListItem.MatchString(line)
+}
+
+func (m *markdownImporter) parseBlocks() {
+	for m.next < len(m.lines) {
+		line, number := m.lines[m.next], m.next+1
+		switch {
+		case strings.TrimSpace(line) == "":
+			m.next++
+		case mdFence.MatchString(line):
+			m.parseFence()
+		case mdATXHeading.MatchString(line):
+			groups := mdATXHeading.FindStringSubmatch(line)
+			m.addBlock(&SectionHeaderContext{
+				BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: m.unit, pos: Position{Line: number}}},
+				HeaderLevel:      7 - len(groups[1]),
+				HeaderText:       strings.TrimSpace(groups[2]),
+			})
+			m.next++
+		case mdThematicBreak.MatchString(line):
+			m.unsupported(number, "thematic breaks", "they are written as ----")
+			para := m.newPara(number)
+			para.InnerContexts = []InlineContext{&TextEffectContext{BaseInlineContext: inlineBase(para), Text: "----"}}
+			m.addBlock(para)
+			m.next++
+		case mdListItem.MatchString(line):
+			m.addBlock(m.parseList())
+		case mdBlockquote.MatchString(line):
+			m.unsupported(number, "blockquotes", "their content is kept as text")
+			text := make([]string, 0)
+			for ; m.next < len(m.lines) && mdBlockquote.MatchString(m.lines[m.next]); m.next++ {
+				text = append(text, mdBlockquote.FindStringSubmatch(m.lines[m.next])[1])
+			}
+			m.addParagraph(number, strings.Join(text, " "))
+		case mdIndentedCode.MatchString(line):
+			code := make([]string, 0)
+			for ; m.next < len(m.lines); m.next++ {
+				if groups := mdIndentedCode.FindStringSubmatch(m.lines[m.next]); groups != nil {
+					code = append(code, groups[1])
+				} else if strings.TrimSpace(m.lines[m.next]) == "" {
+					code = append(code, "")
+				} else {
+					break
+				}
+			}
+			for len(code) > 0 && code[len(code)-1] == "" {
+				code = code[:len(code)-1]
+			}
+			m.addCode(number, "", strings.Join(code, "\n"))
+		case strings.Contains(line, "|") && m.next+1 < len(m.lines) && mdTableDivider.MatchString(m.lines[m.next+1]) && strings.Contains(m.lines[m.next+1], "-"):
+			m.parseTable()
+		default:
+			m.parseParagraph()
+		}
+	}
+}
+
+func (m *markdownImporter) parseFence() {
+	number := m.next + 1
+	groups := mdFence.FindStringSubmatch(m.lines[m.next])
+	fence, language := groups[1], groups[2]
+	indent := len(m.lines[m.next]) - len(strings.TrimLeft(m.lines[m.next], " "))
+	code := make([]string, 0)
+	closed := false
+	for m.next++; m.next < len(m.lines); m.next++ {
+		line := m.lines[m.next]
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			closed = true
+			m.next++
+			break
+		}
+		// the indentation of the fence is removed from the content.
+		for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+	if !closed {
+		m.unit.AddDiagnostic(CodeUnclosedCodeFence, Position{Line: number}, "unclosed code fence")
+	}
+	m.addCode(number, language, strings.Join(code, "\n"))
+}
+
+func (m *markdownImporter) addCode(number int, language, code string) {
+	para := m.newPara(number)
+	para.InnerContexts = []InlineContext{&CodeFileContext{BaseInlineContext: inlineBase(para), Language: language, Text: code + "\n"}}
+	m.addBlock(para)
+}
+
+// parseTable keeps the rows of a table as text, they are table rows for DokuWiki too, only the
+// line dividing the header from the body is dropped.
+func (m *markdownImporter) parseTable() {
+	number := m.next + 1
+	m.unsupported(number, "tables", "their rows are kept as text")
+	rows := []string{m.lines[m.next]}
+	for m.next += 2; m.next < len(m.lines) && strings.Contains(m.lines[m.next], "|") && strings.TrimSpace(m.lines[m.next]) != ""; m.next++ {
+		rows = append(rows, m.lines[m.next])
+	}
+	para := m.newPara(number)
+	para.InnerContexts = []InlineContext{&TextEffectContext{BaseInlineContext: inlineBase(para), Text: strings.Join(rows, "\n")}}
+	m.addBlock(para)
+}
+
+func (m *markdownImporter) parseParagraph() {
+	number := m.next + 1
+	text := []string{strings.TrimSpace(m.lines[m.next])}
+	for m.next++; m.next < len(m.lines); m.next++ {
+		line := m.lines[m.next]
+		if groups := mdSetextLine.FindStringSubmatch(line); groups != nil {
+			level := 6
+			if groups[1][0] == '-' {
+				level = 5
+			}
+			m.addBlock(&SectionHeaderContext{
+				BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: m.unit, pos: Position{Line: number}}},
+				HeaderLevel:      level,
+				HeaderText:       strings.Join(text, " "),
+			})
+			m.next++
+			return
+		}
+		if strings.TrimSpace(line) == "" || startsBlock(line) {
+			break
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	m.addParagraph(number, strings.Join(text, " "))
+}
+
+func (m *markdownImporter) addParagraph(number int, text string) {
+	para := m.newPara(number)
+	m.parseInlines(para, text)
+	m.addBlock(para)
+}
+
+// parseList reads a list and the lists nested in it. Items are nested by their indentation,
+// an item indented more than the one before it starts a sub list.
+func (m *markdownImporter) parseList() *ListContext {
+	type openList struct {
+		indent int
+		list   *ListContext
+	}
+	var root *ListContext
+	stack := make([]openList, 0)
+	for m.next < len(m.lines) {
+		line, number := m.lines[m.next], m.next+1
+		groups := mdListItem.FindStringSubmatch(line)
+		if groups == nil {
+			if strings.TrimSpace(line) == "" && m.next+1 < len(m.lines) && mdListItem.MatchString(m.lines[m.next+1]) {
+				// an empty line between items does not end the list.
+				m.next++
+				continue
+			}
+			break
+		}
+		indent, ordered := len(groups[1]), !strings.ContainsAny(groups[2][:1], "-*+")
+		for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 || stack[len(stack)-1].indent < indent {
+			list := &ListContext{Level: len(stack) + 1, Ordered: ordered}
+			list.pos = Position{Line: number}
+			if len(stack) == 0 {
+				if root != nil {
+					// a list less indented than the first item, the first list is over.
+					break
+				}
+				root = list
+				list.parent = m.unit
+			} else {
+				// the sub list is in the last item of its list.
+				parent := lastListItem(stack[len(stack)-1].list)
+				list.parent = parent
+				parent.InnerContexts = append(parent.InnerContexts, list)
+			}
+			stack = append(stack, openList{indent, list})
+		}
+		list := stack[len(stack)-1].list
+
+		// lines indented below the item or not starting a block continue it.
+		text := []string{strings.TrimSpace(groups[3])}
+		for m.next++; m.next < len(m.lines); m.next++ {
+			next := m.lines[m.next]
+			if strings.TrimSpace(next) == "" || startsBlock(next) {
+				break
+			}
+			text = append(text, strings.TrimSpace(next))
+		}
+		// the item is indented like in DokuWiki, two spaces for every level.
+		item := &ListItemContext{RawIndent: 2 * list.Level, Depth: list.Level}
+		item.parent, item.pos = list, Position{Line: number}
+		para := &ParaContext{BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: item, pos: Position{Line: number}}}}
+		m.parseInlines(para, strings.Join(text, " "))
+		item.InnerContexts = append(item.InnerContexts, para)
+		list.InnerContexts = append(list.InnerContexts, item)
+	}
+	return root
+}
+
+// markdownEffects are the emphasis markers, the longest first.
+var markdownEffects = []struct {
+	marker string
+	effect uint32
+}{
+	{"***", TextEffectBold | TextEffectItalic},
+	{"___", TextEffectBold | TextEffectItalic},
+	{"**", TextEffectBold},
+	{"__", TextEffectBold},
+	{"*", TextEffectItalic},
+	{"_", TextEffectItalic},
+}
+
+var (
+	mdLink     = regexp.MustCompile(`^\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\(\s*<?([^\s<>()]*(?:\([^\s()]*\)[^\s<>()]*)*)>?(?:\s+"[^"]*")?\s*\)`)
+	mdImage    = regexp.MustCompile(`^!\[([^\[\]]*)\]\(\s*<?([^\s<>()]*)>?(?:\s+"([^"]*)")?\s*\)`)
+	mdAutoLink = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*|[^\s<>@]+@[^\s<>@]+\.[^\s<>@]+)>`)
+)
+
+// markdownEscapable are the characters a backslash escapes.
+const markdownEscapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// parseInlines fills para with the inline contexts of a paragraph of Markdown.
+func (m *markdownImporter) parseInlines(para *ParaContext, text string) {
+	var effect uint32
+	var pending strings.Builder
+	flush := func() {
+		if pending.Len() > 0 {
+			para.InnerContexts = append(para.InnerContexts, &TextEffectContext{BaseInlineContext: inlineBase(para), EffectType: effect, Text: pending.String()})
+			pending.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte(markdownEscapable, rest[1]) != -1:
+			pending.WriteByte(rest[1])
+			i += 2
+		case rest[0] == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			end := strings.Index(rest[run:], rest[:run])
+			if end == -1 {
+				pending.WriteString(rest[:run])
+				i += run
+				break
+			}
+			flush()
+			code := rest[run : run+end]
+			if strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			para.InnerContexts = append(para.InnerContexts, &TextEffectContext{BaseInlineContext: inlineBase(para), EffectType: effect | TextEffectMonoSpace, Text: code})
+			i += 2*run + end
+		case strings.HasPrefix(rest, "!["):
+			groups := mdImage.FindStringSubmatch(rest)
+			if groups == nil {
+				pending.WriteString("![")
+				i += 2
+				break
+			}
+			flush()
+			para.InnerContexts = append(para.InnerContexts, newMedia(para, []byte(groups[2]+"|"+groups[1])))
+			i += len(groups[0])
+		case rest[0] == '[':
+			groups := mdLink.FindStringSubmatch(rest)
+			if groups == nil {
+				pending.WriteByte('[')
+				i++
+				break
+			}
+			flush()
+			label := groups[1]
+			if image := mdImage.FindStringSubmatch(label); image != nil && len(image[0]) == len(label) {
+				label = "{{" + image[2] + "|" + image[1] + "}}"
+			} else {
+				label = markdownPlainText(label)
+			}
+			if link, _ := parseLink(para, []byte(groups[2]+"|"+label), nil, nil); link.Image == nil {
+				link.InnerContexts = []InlineContext{&TextEffectContext{BaseInlineContext: BaseInlineContext{BaseContext{parent: link, pos: link.pos}}, Text: label}}
+			}
+			i += len(groups[0])
+		case rest[0] == '<' && mdAutoLink.MatchString(rest):
+			groups := mdAutoLink.FindStringSubmatch(rest)
+			flush()
+			parseLink(para, []byte(groups[1]), nil, nil)
+			i += len(groups[0])
+		case strings.HasPrefix(rest, "~~"):
+			m.unsupported(para.pos.Line, "strikethroughs", "their text is kept")
+			i += 2
+		case rest[0] == '*' || rest[0] == '_':
+			marker, toggled := "", uint32(0)
+			for _, e := range markdownEffects {
+				if strings.HasPrefix(rest, e.marker) {
+					marker, toggled = e.marker, e.effect
+					break
+				}
+			}
+			before, _ := utf8.DecodeLastRuneInString(text[:i])
+			after, _ := utf8.DecodeRuneInString(rest[len(marker):])
+			opening := effect&toggled == 0
+			// a marker opens before text and closes after it, _ is not emphasis inside a word.
+			valid := (opening && after != utf8.RuneError && !unicode.IsSpace(after) && strings.Contains(rest[len(marker):], marker)) ||
+				(!opening && i > 0 && !unicode.IsSpace(before))
+			if marker[0] == '_' && isWordRune(before) && isWordRune(after) {
+				valid = false
+			}
+			if !valid {
+				pending.WriteString(marker)
+				i += len(marker)
+				break
+			}
+			flush()
+			effect ^= toggled
+			i += len(marker)
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			pending.WriteString(rest[:size])
+			i += size
+		}
+	}
+	flush()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// markdownPlainText is the text of Markdown with the inline markup dropped, for link labels.
+func markdownPlainText(text string) string {
+	m := &markdownImporter{unit: &ParseUnit{}, reported: make(map[string]bool)}
+	para := &ParaContext{}
+	m.parseInlines(para, text)
+	return paraText(para)
+}
@@ -0,0 +1,93 @@
+package dokuwiki
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	markdown := "# Title\n" +
+		"\n" +
+		"Some *italic*, **bold** and `code` text,\n" +
+		"a [link](https://example.com) and [[wiki]](wiki:page) snake_case_name.\n" +
+		"\n" +
+		"Setext\n" +
+		"------\n" +
+		"\n" +
+		"- one\n" +
+		"- two\n" +
+		"  1. nested\n" +
+		"\n" +
+		"```go\n" +
+		"fmt.Println()\n" +
+		"```\n" +
+		"\n" +
+		"![logo](logo.png) <https://go.dev>\n"
+	unit, err := FromMarkdown([]byte(markdown), "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "====== Title ======\n" +
+		"\n" +
+		"Some //italic//, **bold** and ``code`` text, a [[https://example.com|link]] and [[wiki:page|[wiki]]] snake_case_name.\n" +
+		"\n" +
+		"===== Setext =====\n" +
+		"\n" +
+		"  * one\n" +
+		"  * two\n" +
+		"    - nested\n" +
+		"\n" +
+		"<code go>\nfmt.Println()\n</code>\n" +
+		"\n" +
+		"{{logo.png|logo}} [[https://go.dev]]\n"
+	if got := serialize(t, unit); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if len(unit.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics %v", unit.Diagnostics)
+	}
+}
+
+func TestFromMarkdownUnsupported(t *testing.T) {
+	markdown := "> quoted\n" +
+		"> text\n" +
+		"\n" +
+		"| a | b |\n" +
+		"|---|---|\n" +
+		"| 1 | 2 |\n" +
+		"\n" +
+		"***\n" +
+		"\n" +
+		"~~gone~~\n"
+	unit, err := FromMarkdown([]byte(markdown), "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "quoted text\n" +
+		"\n" +
+		"| a | b |\n| 1 | 2 |\n" +
+		"\n" +
+		"----\n" +
+		"\n" +
+		"gone\n"
+	if got := serialize(t, unit); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	messages := make([]string, 0)
+	for _, d := range unit.Diagnostics {
+		messages = append(messages, d.Message)
+	}
+	for _, prefix := range []string{"line 1: markdown blockquotes", "line 4: markdown tables", "line 8: markdown thematic breaks", "line 10: markdown strikethroughs"} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.HasPrefix(message, prefix)
+		}
+		if !found {
+			t.Errorf("no diagnostic %q in %q", prefix, messages)
+		}
+	}
+
+	if _, err := FromMarkdown([]byte{0xff}, "bad"); err == nil {
+		t.Error("no error for invalid UTF-8")
+	}
+}
@@ -1 +1,291 @@
 package dokuwiki
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MarkdownRenderer renders a unit to CommonMark. Underline has no Markdown equivalent,
+// it is written as an inline <u> tag.
+type MarkdownRenderer struct {
+	Options RendererOptions
+
+	nodeRenderers map[string]func(w io.Writer, node ExtensionContext, r *MarkdownRenderer) error
+}
+
+// markdownUnportable are the constructs MarkdownRenderer can not write, see AnalyzePortability.
+var markdownUnportable = unportable{
+	underline:    "Markdown has no underline, it is written as an html <u> tag",
+	html:         "it is escaped as text unless sanitizing is off, and Markdown renderers may drop it",
+	php:          "php is not run, it is written as text",
+	windowsShare: "it is written as a file: link, which browsers do not open",
+	plugin:       "it is written as its source unless a node renderer is registered for it",
+	interWiki:    "the link goes to the target as written",
+}
+
+func NewMarkdownRenderer(opts RendererOptions) *MarkdownRenderer {
+	return &MarkdownRenderer{Options: opts}
+}
+
+// RegisterNodeRenderer makes r render the extension contexts of the given kind with fn,
+// registering a kind again replaces its function.
+func (r *MarkdownRenderer) RegisterNodeRenderer(kind string, fn func(w io.Writer, node ExtensionContext, r *MarkdownRenderer) error) {
+	if r.nodeRenderers == nil {
+		r.nodeRenderers = make(map[string]func(io.Writer, ExtensionContext, *MarkdownRenderer) error)
+	}
+	r.nodeRenderers[kind] = fn
+}
+
+func (r *MarkdownRenderer) renderExtension(rw *renderWriter, node ExtensionContext) {
+	if fn := r.nodeRenderers[node.Kind()]; fn != nil {
+		rw.renderNode(func(w io.Writer) error { return fn(w, node, r) })
+		return
+	}
+	r.Options.renderUnknownExtension(rw, node, func(source string) { rw.write(markdownEscaper.Replace(source)) })
+}
+
+func (r *MarkdownRenderer) Render(w io.Writer, unit *ParseUnit) error {
+	if err := r.Options.validate(unit); err != nil {
+		return err
+	}
+	rw := &renderWriter{w: w, anchors: unit.explicitIDs()}
+	numbers := r.Options.sectionNumbers(unit)
+	for i, block := range unit.Sections {
+		if i > 0 {
+			rw.write("\n")
+		}
+		if header, ok := block.(*SectionHeaderContext); ok {
+			r.renderHeading(rw, header, numbers[header])
+			continue
+		}
+		r.renderBlock(rw, block, "")
+	}
+	return rw.err
+}
+
+// renderHeading writes a heading with its number, if any.
+func (r *MarkdownRenderer) renderHeading(rw *renderWriter, header *SectionHeaderContext, number string) {
+	rw.write(strings.Repeat("#", r.Options.headingDepth(header.HeaderLevel)) + " ")
+	if number != "" {
+		rw.write(number + " ")
+	}
+	if header.InnerContexts != nil {
+		r.renderInlines(rw, header.InnerContexts, "")
+	} else {
+		rw.write(markdownEscaper.Replace(header.HeaderText))
+	}
+	rw.write("\n")
+}
+
+// renderBlock writes one block, every line after the first one is prefixed with indent,
+// which is how list items keep their continuation lines.
+func (r *MarkdownRenderer) renderBlock(rw *renderWriter, block BlockContext, indent string) {
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		r.renderHeading(rw, b, "")
+	case *ParaContext:
+		r.renderInlines(rw, b.InnerContexts, indent)
+		if n := len(b.InnerContexts); n == 0 || !isCodeFile(b.InnerContexts[n-1]) {
+			rw.write("\n")
+		}
+	case *ListContext:
+		r.renderList(rw, b, indent)
+	case *QuoteContext:
+		r.renderQuote(rw, b, indent)
+	case *DefinitionListContext:
+		// the syntax of PHP Markdown Extra and pandoc, a term on its line and its definitions after ": ".
+		for i, entry := range b.Entries {
+			if i > 0 {
+				rw.write("\n")
+			}
+			if entry.Term != nil {
+				r.renderInlines(rw, entry.Term.InnerContexts, indent)
+				rw.write("\n")
+			}
+			for _, definition := range entry.Definitions {
+				rw.write(": ")
+				r.renderInlines(rw, definition.InnerContexts, indent+"  ")
+				rw.write("\n")
+			}
+		}
+	case ExtensionContext:
+		r.renderExtension(rw, b)
+		rw.write("\n")
+	}
+}
+
+func (r *MarkdownRenderer) renderList(rw *renderWriter, list *ListContext, indent string) {
+	number := 0
+	for i, inner := range list.InnerContexts {
+		item, ok := inner.(*ListItemContext)
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			rw.write(indent)
+		}
+		bullet := "- "
+		if list.Ordered {
+			number++
+			bullet = strconv.Itoa(number) + ". "
+		}
+		rw.write(bullet)
+		itemIndent := indent + strings.Repeat(" ", len(bullet))
+		for j, block := range item.InnerContexts {
+			// the blocks after the text of the item are on their lines, indented like it.
+			if j > 0 {
+				rw.write(itemIndent)
+			}
+			switch c := block.(type) {
+			case *ParaContext:
+				r.renderInlines(rw, c.InnerContexts, itemIndent)
+				rw.write("\n")
+			case *ListContext:
+				r.renderList(rw, c, itemIndent)
+			case *QuoteContext:
+				r.renderQuote(rw, c, itemIndent)
+			}
+		}
+		if len(item.InnerContexts) == 0 {
+			rw.write("\n")
+		}
+	}
+}
+
+// renderQuote writes a quote, its lines after indent are prefixed with >. The blocks in it are
+// kept apart by a line with only the >, so that the text after a deeper quote is not read as
+// part of it.
+func (r *MarkdownRenderer) renderQuote(rw *renderWriter, quote *QuoteContext, indent string) {
+	if len(quote.InnerContexts) == 0 {
+		rw.write(">\n")
+		return
+	}
+	rw.write("> ")
+	for i, inner := range quote.InnerContexts {
+		if i > 0 {
+			rw.write(indent + ">\n" + indent + "> ")
+		}
+		r.renderBlock(rw, inner, indent+"> ")
+	}
+}
+
+func (r *MarkdownRenderer) renderInlines(rw *renderWriter, inlines []InlineContext, indent string) {
+	for i, inline := range inlines {
+		// a fence has to start on its own line.
+		if i > 0 && isCodeFile(inline) {
+			rw.write("\n" + indent)
+		}
+		r.renderInline(rw, inline, indent)
+	}
+}
+
+func isCodeFile(inline InlineContext) bool {
+	_, ok := inline.(*CodeFileContext)
+	return ok
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+)
+
+var markdownEffectMarkers = map[uint32][2]string{
+	TextEffectBold:      {"**", "**"},
+	TextEffectItalic:    {"*", "*"},
+	TextEffectUnderline: {"<u>", "</u>"},
+	TextEffectMonoSpace: {"`", "`"},
+}
+
+// markdownEffect writes what inner writes between the markers of the effects.
+func markdownEffect(rw *renderWriter, effectType uint32, inner func()) {
+	for _, effect := range effectOrder {
+		if effectType&effect != 0 {
+			rw.write(markdownEffectMarkers[effect][0])
+		}
+	}
+	inner()
+	for i := len(effectOrder) - 1; i >= 0; i-- {
+		if effectType&effectOrder[i] != 0 {
+			rw.write(markdownEffectMarkers[effectOrder[i]][1])
+		}
+	}
+}
+
+func (r *MarkdownRenderer) renderLink(rw *renderWriter, c *HyperLinkContext, indent string) {
+	if c.Image != nil {
+		rw.write("[")
+		r.renderInline(rw, c.Image, indent)
+		rw.printf("](%s)", markdownURL(r.Options.linkURL(c)))
+	} else if c.InnerContexts != nil {
+		rw.write("[")
+		for _, inner := range c.InnerContexts {
+			r.renderInline(rw, inner, indent)
+		}
+		rw.printf("](%s)", markdownURL(r.Options.linkURL(c)))
+	} else {
+		rw.printf("[%s](%s)", markdownEscaper.Replace(c.Text), markdownURL(r.Options.linkURL(c)))
+	}
+}
+
+func (r *MarkdownRenderer) renderMedia(rw *renderWriter, c *MediaContext) {
+	rw.printf("![%s](%s)", markdownEscaper.Replace(c.Title), markdownURL(r.Options.mediaURL(c)))
+}
+
+func (r *MarkdownRenderer) renderInline(rw *renderWriter, inline InlineContext, indent string) {
+	switch c := inline.(type) {
+	case *TextEffectContext:
+		text := markdownEscaper.Replace(c.Text)
+		if c.EffectType&TextEffectMonoSpace != 0 {
+			text = c.Text
+		}
+		// markers must hug the text, keep surrounding spaces outside of them.
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || c.EffectType == 0 {
+			rw.write(text)
+			return
+		}
+		rw.write(text[:strings.Index(text, trimmed)])
+		markdownEffect(rw, c.EffectType, func() { rw.write(trimmed) })
+		rw.write(text[strings.Index(text, trimmed)+len(trimmed):])
+	case *HyperLinkContext:
+		// code spans can not hold links and images.
+		markdownEffect(rw, c.EffectType&^TextEffectMonoSpace, func() { r.renderLink(rw, c, indent) })
+	case *MediaContext:
+		markdownEffect(rw, c.EffectType&^TextEffectMonoSpace, func() { r.renderMedia(rw, c) })
+	case *CodeFileContext:
+		fence := "```"
+		for strings.Contains(c.Text, fence) {
+			fence += "`"
+		}
+		rw.printf("%s%s\n", fence, c.Language)
+		for _, line := range strings.Split(strings.Trim(c.Text, "\n"), "\n") {
+			rw.write(indent + line + "\n")
+		}
+		rw.write(indent + fence + "\n")
+	case *HTMLContext:
+		switch r.Options.Sanitize {
+		case SanitizeNone:
+			rw.write(c.Text)
+		case SanitizeEscape:
+			rw.write(markdownEscaper.Replace(c.Text))
+		}
+	case *AnchorContext:
+		rw.printf("<a id=\"%s\"></a>", rw.anchorID(c))
+	case *LineBreakContext:
+		// a backslash at the end of a line is a hard line break.
+		rw.write("\\\n" + indent)
+	case *NoWikiContext:
+		text := markdownEscaper.Replace(c.Text)
+		if c.EffectType&TextEffectMonoSpace != 0 {
+			text = c.Text
+		}
+		markdownEffect(rw, c.EffectType, func() { rw.write(text) })
+	case ExtensionContext:
+		r.renderExtension(rw, c)
+	}
+}
+
+// markdownURL makes a URL safe to put in the parentheses of a link.
+func markdownURL(url string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(url)
+}
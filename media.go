@@ -0,0 +1,86 @@
+package dokuwiki
+
+import "strconv"
+
+// MediaRef describes one media file used by a unit.
+type MediaRef struct {
+	// ID is the media ID, like "ns:logo.png", resolved against the namespace of the page when
+	// the unit was parsed with ParseOptions.PageID, or the URL of external media. Raw is the
+	// media as written, like ".:logo.png".
+	ID  string
+	Raw string
+	// IsExternal is true when ID is a URL, there is no file for it in the wiki.
+	IsExternal bool
+	// Width and Height are the requested size, 0 when not given.
+	Width  int64
+	Height int64
+	// InLink is true for an image used as the label of a link.
+	InLink   bool
+	Position Position
+	// Media is the context the reference was made from.
+	Media *MediaContext
+}
+
+// MediaRefs returns every media file used in the unit in document order, wherever it
+// is in the tree, including images used as link labels.
+func (unit *ParseUnit) MediaRefs() []MediaRef {
+	refs := make([]MediaRef, 0)
+	Walk(unit, func(c Context) bool {
+		media, ok := c.(*MediaContext)
+		if !ok {
+			return true
+		}
+		_, inLink := media.GetParentContext().(*HyperLinkContext)
+		id := media.MediaResouce
+		if media.ResolvedID != "" {
+			id = media.ResolvedID
+		}
+		refs = append(refs, MediaRef{
+			ID:         id,
+			Raw:        media.MediaResouce,
+			IsExternal: media.IsExternal,
+			Width:      media.Width,
+			Height:     media.Height,
+			InLink:     inLink,
+			Position:   media.GetPosition(),
+			Media:      media,
+		})
+		return true
+	})
+	return refs
+}
+
+// MediaLinking is what a media file links to, set by the nolink, direct and linkonly parameters.
+type MediaLinking int
+
+const (
+	// MediaDetails links an image to its detail page, the default.
+	MediaDetails MediaLinking = iota
+	// MediaDirect links an image to the file itself, written ?direct.
+	MediaDirect
+	// MediaNoLink shows an image without a link, written ?nolink.
+	MediaNoLink
+	// MediaLinkOnly writes a link to the file, with the title or the file name as its text,
+	// instead of showing it, written ?linkonly.
+	MediaLinkOnly
+)
+
+var mediaLinkingNames = []string{"details", "direct", "nolink", "linkonly"}
+
+func (l MediaLinking) String() string {
+	if l >= 0 && int(l) < len(mediaLinkingNames) {
+		return mediaLinkingNames[l]
+	}
+	return "MediaLinking(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Linking returns what the media links to from its parameters. When several are given nolink
+// wins over direct, and direct over linkonly, like in DokuWiki.
+func (c *MediaContext) Linking() MediaLinking {
+	for _, linking := range []MediaLinking{MediaNoLink, MediaDirect, MediaLinkOnly} {
+		if _, ok := c.Params[linking.String()]; ok {
+			return linking
+		}
+	}
+	return MediaDetails
+}
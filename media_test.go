@@ -0,0 +1,223 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMediaRefs(t *testing.T) {
+	content := `{{wiki:logo.png?200x100|Logo}}
+
+  * {{https://example.com/a.png?50}} in a list
+  * [[start|{{ wiki:home.png|Home}}]]
+`
+	unit := Parse([]byte(content), "media")
+	want := []MediaRef{
+		{ID: "wiki:logo.png", Raw: "wiki:logo.png", Width: 200, Height: 100, Position: Position{Line: 1}},
+		{ID: "https://example.com/a.png", Raw: "https://example.com/a.png", IsExternal: true, Width: 50, Position: Position{Line: 3}},
+		{ID: "wiki:home.png", Raw: "wiki:home.png", InLink: true, Position: Position{Line: 4}},
+	}
+	refs := unit.MediaRefs()
+	if len(refs) != len(want) {
+		t.Fatalf("got %d media, want %d: %+v", len(refs), len(want), refs)
+	}
+	for i, ref := range refs {
+		if ref.Media == nil || ref.Media.MediaResouce != ref.Raw {
+			t.Errorf("media %d does not point to its context", i)
+		}
+		ref.Media = nil
+		if ref != want[i] {
+			t.Errorf("media %d is %+v, want %+v", i, ref, want[i])
+		}
+	}
+
+	// a relative reference is resolved against the namespace of the page.
+	refs = ParseWithOptions([]byte("{{.:img.png}} {{..:up.png}}"), "t", ParseOptions{PageID: "wiki:sub:page"}).MediaRefs()
+	if len(refs) != 2 || refs[0].ID != "wiki:sub:img.png" || refs[0].Raw != ".:img.png" || refs[1].ID != "wiki:up.png" || refs[1].Raw != "..:up.png" {
+		t.Errorf("got relative media %+v", refs)
+	}
+}
+
+func TestRenderImageLink(t *testing.T) {
+	unit := Parse([]byte("[[start|{{logo.png|Logo}}]]"), "t")
+	link := unit.Sections[0].(*ParaContext).InnerContexts[0].(*HyperLinkContext)
+	if link.Image == nil || link.Image.Title != "Logo" || link.Image.GetParentContext() != link {
+		t.Fatalf("unexpected image %+v", link.Image)
+	}
+
+	tests := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{NewHTMLRenderer(RendererOptions{}), "\n<p>\n<a href=\"start\" class=\"wikilink1\" title=\"start\"><img src=\"_media/logo.png\" class=\"mediacenter\" title=\"Logo\" alt=\"Logo\" /></a>\n</p>\n"},
+		{NewMarkdownRenderer(RendererOptions{}), "[![Logo](_media/logo.png)](start)\n"},
+		{NewTextRenderer(RendererOptions{}), "Logo\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := test.renderer.Render(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%T: got %q, want %q", test.renderer, out.String(), test.want)
+		}
+	}
+}
+
+func TestMediaParams(t *testing.T) {
+	tests := []struct {
+		source   string
+		id       string
+		external bool
+		width    int64
+		height   int64
+		params   map[string]string
+	}{
+		{"wiki:a.png?200x100&nolink", "wiki:a.png", false, 200, 100, map[string]string{"nolink": ""}},
+		{"wiki:a.png?direct&50", "wiki:a.png", false, 50, 0, map[string]string{"direct": ""}},
+		{"wiki:a.png?w=1&linkonly", "wiki:a.png", false, 0, 0, map[string]string{"w": "1", "linkonly": ""}},
+		{"https://example.com/img.php?id=5", "https://example.com/img.php?id=5", true, 0, 0, nil},
+		{"https://example.com/img.php?id=5&size=2", "https://example.com/img.php?id=5&size=2", true, 0, 0, nil},
+		{"https://example.com/img.php?id=5&s=2?200x50&nocache", "https://example.com/img.php?id=5&s=2", true, 200, 50, map[string]string{"nocache": ""}},
+		{"HTTP://example.com/a.png?30", "HTTP://example.com/a.png", true, 30, 0, nil},
+		{"ftp://example.com/a.png", "ftp://example.com/a.png", true, 0, 0, nil},
+	}
+	for _, test := range tests {
+		content := "{{" + test.source + "}}"
+		unit := Parse([]byte(content), "t")
+		media := unit.Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext)
+		if media.MediaResouce != test.id || media.IsExternal != test.external || media.Width != test.width || media.Height != test.height || !reflect.DeepEqual(media.Params, test.params) {
+			t.Errorf("%s: got %q external=%t %dx%d %v", content, media.MediaResouce, media.IsExternal, media.Width, media.Height, media.Params)
+		}
+
+		var wiki bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+		again := Parse(wiki.Bytes(), "t").Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext)
+		if again.MediaResouce != media.MediaResouce || again.Width != media.Width || again.Height != media.Height || !reflect.DeepEqual(again.Params, media.Params) {
+			t.Errorf("%s: serialized as %q", content, wiki.String())
+		}
+	}
+}
+
+func TestMediaResolvedID(t *testing.T) {
+	content := "{{logo.png}} {{..:shared:pic.jpg}} {{:top.png}} {{ns:Big File.png?20}} {{https://example.com/x.png}} [[p|{{.:icon.png}}]]\n"
+	unit := ParseWithOptions([]byte(content), "t", ParseOptions{PageID: "wiki:ns:page"})
+	got := make([]string, 0)
+	for _, ref := range unit.MediaRefs() {
+		got = append(got, ref.Media.ResolvedID)
+	}
+	want := []string{"wiki:ns:logo.png", "wiki:shared:pic.jpg", "top.png", "ns:big_file.png", "", "wiki:ns:icon.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{BaseURL: "/"}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{`src="/_media/wiki:ns:logo.png"`, `src="/_media/wiki:shared:pic.jpg"`, `src="https://example.com/x.png"`} {
+		if !strings.Contains(out.String(), src) {
+			t.Errorf("no %s in\n%s", src, out.String())
+		}
+	}
+
+	// without the page ID the media IDs are kept as written.
+	for _, ref := range Parse([]byte(content), "t").MediaRefs() {
+		if ref.Media.ResolvedID != "" {
+			t.Errorf("%s resolved to %s", ref.ID, ref.Media.ResolvedID)
+		}
+	}
+	// the media checker looks for the resolved ID.
+	pages := map[string]*ParseUnit{"copy": ParseWithOptions([]byte("{{logo.png}}\n"), "t", ParseOptions{PageID: "wiki:page"})}
+	if missing := CheckMedia(pages, fstest.MapFS{"media/wiki/logo.png": {}}, "media"); len(missing) != 0 {
+		t.Errorf("got missing %+v", missing)
+	}
+}
+
+type detailResolver struct{}
+
+func (detailResolver) MediaHref(mediaID string) string  { return "/media/" + mediaID }
+func (detailResolver) DetailHref(mediaID string) string { return "/detail/" + mediaID }
+
+func TestMediaLinking(t *testing.T) {
+	tests := []struct {
+		source  string
+		linking MediaLinking
+		want    string
+	}{
+		{"{{a.png}}", MediaDetails, `<a href="/detail/a.png" class="media" title="a.png"><img src="/media/a.png"`},
+		{"{{a.png?direct}}", MediaDirect, `<a href="/media/a.png" class="media" title="a.png"><img src="/media/a.png"`},
+		{"{{a.png?nolink&direct}}", MediaNoLink, "\n<p>\n<img src=\"/media/a.png\""},
+		{"{{ns:a.png?linkonly}}", MediaLinkOnly, `<a href="/media/ns:a.png" class="media mediafile mf_png" title="ns:a.png">a.png</a>`},
+		{"{{https://example.com/a.png}}", MediaDetails, `<a href="https://example.com/a.png" class="media" title="https://example.com/a.png"><img`},
+	}
+	for _, test := range tests {
+		unit := Parse([]byte(test.source), "t")
+		media := unit.Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext)
+		if media.Linking() != test.linking {
+			t.Errorf("%s: linking is %v, want %v", test.source, media.Linking(), test.linking)
+		}
+		var out bytes.Buffer
+		if err := NewHTMLRenderer(RendererOptions{MediaResolver: detailResolver{}}).Render(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("%s: got %s, want %s", test.source, out.String(), test.want)
+		}
+	}
+}
+
+func TestMediaSizes(t *testing.T) {
+	tests := []struct {
+		size    string
+		width   int64
+		height  int64
+		invalid bool
+	}{
+		{"200", 200, 0, false},
+		{"200x100", 200, 100, false},
+		{"x300", 0, 300, false},
+		{"0x300", 0, 300, false},
+		{"200x0", 200, 0, false},
+		{"0200x007", 200, 7, false},
+		{"9223372036854775807", 9223372036854775807, 0, false},
+		{"9223372036854775808", 0, 0, true},
+		{"10x99999999999999999999", 0, 0, true},
+		{"0", 0, 0, true},
+		{"0x0", 0, 0, true},
+		{"x0", 0, 0, true},
+		{"x", 0, 0, true},
+		{"200x", 0, 0, true},
+		{"abc", 0, 0, true},
+		{"-5", 0, 0, true},
+	}
+	for _, test := range tests {
+		content := "{{wiki:a.png?" + test.size + "}}\n"
+		unit := Parse([]byte(content), "t")
+		media := unit.Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext)
+		if media.Width != test.width || media.Height != test.height || media.Params != nil {
+			t.Errorf("%s: got %dx%d %v", test.size, media.Width, media.Height, media.Params)
+		}
+		invalid := len(unit.Diagnostics) == 1 && unit.Diagnostics[0].Code == CodeInvalidMediaSize
+		if invalid != test.invalid || (!invalid && len(unit.Diagnostics) != 0) {
+			t.Errorf("%s: got diagnostics %v", test.size, unit.Diagnostics)
+		}
+
+		var out bytes.Buffer
+		if err := Render(unit, &out); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(out.String(), `width="0"`) || strings.Contains(out.String(), `height="0"`) {
+			t.Errorf("%s: zero size in %s", test.size, out.String())
+		}
+		var wiki bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+		again := Parse(wiki.Bytes(), "t").Sections[0].(*ParaContext).InnerContexts[0].(*MediaContext)
+		if again.Width != media.Width || again.Height != media.Height {
+			t.Errorf("%s: serialized as %q", test.size, wiki.String())
+		}
+	}
+}
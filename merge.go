@@ -0,0 +1,84 @@
+package dokuwiki
+
+import "strings"
+
+// MergeOptions configures Merge, the zero value just concatenates the units.
+type MergeOptions struct {
+	// Title is the title of the merged unit.
+	Title string
+	// TitleHeadings puts a heading with the title of each unit before its content.
+	TitleHeadings bool
+	// DemoteHeadings moves the headings of each unit one level down, so they fit under a title
+	// heading. Headings already at the lowest level stay there.
+	DemoteHeadings bool
+}
+
+// Merge concatenates units into a new one, like for assembling release notes out of one page
+// per version. The blocks are moved, not copied, so the units should not be used afterwards,
+// the ones of frozen units are copied and those stay as they are.
+//
+// Headings get their anchors in the merged unit the way the renderer gives them, so identical
+// headings of different units get numbered ids. Links to an anchor of their own page, like
+// [[#setup]], are updated so they keep pointing to the same heading. The parser has no
+// footnotes, so there are none to renumber.
+func Merge(units []*ParseUnit, opts MergeOptions) *ParseUnit {
+	merged := &ParseUnit{Title: opts.Title, Sections: make([]BlockContext, 0)}
+	// seen holds the anchors of the merged unit so far.
+	seen := make(map[string]bool)
+	for _, unit := range units {
+		for bit, delimiter := range unit.effects {
+			if merged.effects == nil {
+				merged.effects = make(map[TextEffect]string)
+			}
+			merged.effects[bit] = delimiter
+		}
+		if opts.TitleHeadings {
+			header := &SectionHeaderContext{HeaderLevel: 6, HeaderText: unit.Title}
+			header.SetParentContext(merged)
+			merged.Sections = append(merged.Sections, header)
+			sectionID(header.HeaderTextPlain(), seen)
+		}
+
+		// anchors maps the anchors the headings had in unit to the ones they get in merged.
+		anchors := make(map[string]string)
+		unitSeen := make(map[string]bool)
+		blocks := unit.Sections
+		if unit.frozen {
+			blocks = make([]BlockContext, 0, len(unit.Sections))
+			for _, block := range unit.Sections {
+				blocks = append(blocks, cloneBlock(block, merged))
+			}
+		}
+		for _, block := range blocks {
+			if header, ok := block.(*SectionHeaderContext); ok {
+				anchors[sectionID(header.HeaderTextPlain(), unitSeen)] = sectionID(header.HeaderTextPlain(), seen)
+				if opts.DemoteHeadings && header.HeaderLevel > 1 {
+					header.HeaderLevel--
+				}
+			}
+			block.SetParentContext(merged)
+			merged.Sections = append(merged.Sections, block)
+		}
+
+		for _, block := range blocks {
+			Walk(block, func(c Context) bool {
+				link, ok := c.(*HyperLinkContext)
+				if !ok || link.Kind != LinkInternal || !strings.HasPrefix(link.HyperLink, "#") {
+					return true
+				}
+				if anchor, ok := anchors[link.HyperLink[1:]]; ok {
+					if link.Text == link.HyperLink {
+						link.Text = "#" + anchor
+					}
+					link.SetTarget("#" + anchor)
+				}
+				return true
+			})
+		}
+		merged.Diagnostics = append(merged.Diagnostics, unit.Diagnostics...)
+		if !unit.frozen {
+			unit.Sections = nil
+		}
+	}
+	return merged
+}
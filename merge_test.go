@@ -0,0 +1,61 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	v1 := Parse([]byte("===== Changes =====\nSee [[#changes]] and [[#fixes|the fixes]].\n===== Fixes =====\nnone\n"), "1.0")
+	v2 := Parse([]byte("===== Changes =====\nSee [[#changes]].\n"), "2.0")
+	merged := Merge([]*ParseUnit{v1, v2}, MergeOptions{Title: "notes", TitleHeadings: true, DemoteHeadings: true})
+
+	var dump bytes.Buffer
+	if err := Dump(&dump, merged); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "notes"
+  SectionHeader level=6 "1.0"
+  SectionHeader level=4 "Changes"
+    Text "Changes"
+  Para
+    Text "See "
+    Link internal target="#changes" "#changes"
+    Text " and "
+    Link internal target="#fixes" "the fixes"
+      Text "the fixes"
+    Text "."
+  SectionHeader level=4 "Fixes"
+    Text "Fixes"
+  Para
+    Text "none"
+  SectionHeader level=6 "2.0"
+  SectionHeader level=4 "Changes"
+    Text "Changes"
+  Para
+    Text "See "
+    Link internal target="#changes1" "#changes1"
+    Text "."
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+
+	Walk(merged, func(c Context) bool {
+		for _, child := range children(c) {
+			if child.GetParentContext() != c {
+				t.Errorf("%T has a wrong parent", child)
+			}
+		}
+		return true
+	})
+
+	var html bytes.Buffer
+	if err := Render(merged, &html); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), `<h3 id="changes1">Changes</h3>`) {
+		t.Errorf("the second heading does not have the anchor its link points to:\n%s", html.String())
+	}
+}
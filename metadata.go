@@ -0,0 +1,182 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abstractLength is the length of the abstract in characters, like DokuWiki's.
+const abstractLength = 250
+
+// PageMeta is the metadata of a page derived from its content.
+type PageMeta struct {
+	// Title is the text of the first heading, empty when there is none.
+	Title string
+	// Abstract is the beginning of the text of the page, headings left out, at most 250
+	// characters followed by "…" when the text is longer.
+	Abstract string
+	Words    int
+	Headings int
+	Links    int
+	Media    int
+	// Fixmes are the FIXME and DELETEME markers in the text.
+	Fixmes []Fixme
+	// Macros are the names of the control macros, like "NOTOC" for ~~NOTOC~~, sorted.
+	Macros []string
+}
+
+// Fixme is a FIXME or DELETEME marker.
+type Fixme struct {
+	// Text is "FIXME" or "DELETEME".
+	Text     string
+	Position Position
+}
+
+// Metadata derives the metadata of the unit, like DokuWiki keeps for every page.
+func (unit *ParseUnit) Metadata() PageMeta {
+	meta := PageMeta{Fixmes: make([]Fixme, 0), Macros: make([]string, 0)}
+	macros := make(map[string]bool)
+	Walk(unit, func(c Context) bool {
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			if meta.Headings == 0 {
+				meta.Title = c.HeaderTextPlain()
+			}
+			meta.Headings++
+		case *TextEffectContext:
+			for _, word := range strings.FieldsFunc(c.Text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+				if word == "FIXME" || word == "DELETEME" {
+					meta.Fixmes = append(meta.Fixmes, Fixme{Text: word, Position: c.GetPosition()})
+				}
+			}
+		case *HyperLinkContext:
+			meta.Links++
+		case *MediaContext:
+			meta.Media++
+		case *MacroContext:
+			if !macros[c.Name] {
+				macros[c.Name] = true
+				meta.Macros = append(meta.Macros, c.Name)
+			}
+		}
+		return true
+	})
+	sort.Strings(meta.Macros)
+
+	meta.Words = len(tokenize(unit.PlainText(TextExtractOptions{Fields: FieldBody})))
+	abstract, _ := abstractText(unit, abstractLength)
+	meta.Abstract = truncateRunes(abstract, abstractLength)
+	return meta
+}
+
+// Abstract returns the beginning of the prose of the page, for link previews and descriptions,
+// at most maxLen characters, 250 like DokuWiki when maxLen <= 0. Headings, code, html and media
+// are left out, link labels are kept and whitespace is collapsed. A page without prose gives its
+// first heading.
+//
+// A longer text ends at the last sentence ending in the second half of maxLen, or else after the
+// last whole word, followed by "…" which counts in maxLen. Only a word longer than maxLen is cut.
+func (unit *ParseUnit) Abstract(maxLen int) string {
+	if maxLen <= 0 {
+		maxLen = abstractLength
+	}
+	// a few characters more tell whether a sentence ends at maxLen.
+	abstract, heading := abstractText(unit, maxLen+1)
+	if abstract == "" {
+		abstract = strings.Join(strings.Fields(heading), " ")
+	}
+	return cutAbstract(abstract, maxLen)
+}
+
+// abstractText returns the text of the paragraphs of the unit for the abstracts, whitespace
+// collapsed, the paragraphs after the first limit characters left out, and its first heading.
+func abstractText(unit *ParseUnit, limit int) (abstract, heading string) {
+	var text strings.Builder
+	length := 0
+	Walk(unit, func(c Context) bool {
+		if length > limit {
+			return false
+		}
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			if heading == "" {
+				heading = c.HeaderTextPlain()
+			}
+			return false
+		case *ParaContext:
+			para := paraText(c)
+			text.WriteString(" " + para)
+			length += 1 + utf8.RuneCountInString(para)
+			return false
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(text.String()), " "), heading
+}
+
+// cutAbstract cuts s to maxLen characters, see Abstract.
+func cutAbstract(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	for i := maxLen - 1; i >= maxLen/2; i-- {
+		if strings.ContainsRune(".!?", runes[i]) && runes[i+1] == ' ' {
+			return string(runes[:i+1])
+		}
+	}
+	// the ellipsis takes the last character.
+	for i := maxLen - 1; i > 0; i-- {
+		if runes[i] == ' ' {
+			return strings.TrimRight(string(runes[:i]), " ,;:-–") + "…"
+		}
+	}
+	return string(runes[:maxLen-1]) + "…"
+}
+
+// paraText is the readable text of one paragraph for the abstracts, with the text of the
+// links and without media and html. A link without a label gives its target.
+func paraText(para *ParaContext) string {
+	var text bytes.Buffer
+	// skip drops the spaces before what is left out, so no space is left before the punctuation after it.
+	skip := func() bool {
+		text.Truncate(len(bytes.TrimRight(text.Bytes(), " \t\n")))
+		return false
+	}
+	Walk(para, func(c Context) bool {
+		switch c := c.(type) {
+		case *TextEffectContext:
+			text.WriteString(c.Text)
+		case *NoWikiContext:
+			text.WriteString(c.Text)
+		case *LineBreakContext:
+			text.WriteString(" ")
+		case *HyperLinkContext:
+			if c.Image != nil {
+				return skip()
+			}
+			if c.InnerContexts == nil {
+				text.WriteString(c.Text)
+			}
+		case *MediaContext, *CodeFileContext, *HTMLContext:
+			return skip()
+		}
+		return true
+	})
+	return text.String()
+}
+
+// truncateRunes cuts s to at most n characters, adding "…" when something was cut.
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return strings.TrimRight(s[:i], " ") + "…"
+		}
+		count++
+	}
+	return s
+}
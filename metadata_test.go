@@ -0,0 +1,73 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMetadata(t *testing.T) {
+	content := `~~NOTOC~~
+====== Release Notes ======
+This page is FIXME, see [[wiki:syntax]] and {{logo.png}}.
+
+===== Details =====
+  * DELETEME old item
+<code go>ignored code words</code>
+~~NOCACHE~~ ~~NOTOC~~
+`
+	meta := Parse([]byte(content), "notes").Metadata()
+	want := PageMeta{
+		Title:    "Release Notes",
+		Abstract: "This page is FIXME, see wiki:syntax and. DELETEME old item",
+		Words:    9,
+		Headings: 2,
+		Links:    1,
+		Media:    1,
+		Fixmes:   []Fixme{{"FIXME", Position{Line: 3}}, {"DELETEME", Position{Line: 6}}},
+		Macros:   []string{"NOCACHE", "NOTOC"},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("got %+v\nwant %+v", meta, want)
+	}
+}
+
+func TestMetadataAbstract(t *testing.T) {
+	// 300 two byte characters, the cut must not split one.
+	meta := Parse([]byte("====== Ü ======\n"+strings.Repeat("ü", 300)+"\n"), "t").Metadata()
+	if want := strings.Repeat("ü", 250) + "…"; meta.Abstract != want {
+		t.Errorf("got abstract %q", meta.Abstract)
+	}
+}
+
+func TestAbstract(t *testing.T) {
+	tests := []struct {
+		content string
+		maxLen  int
+		want    string
+	}{
+		{"====== Title ======\n\nShort   **text**\nwith a [[page|link //label//]] {{logo.png|logo}}.\n\n<code go>\nx\n</code>\n\n  * and a list\n", 0,
+			"Short text with a link label. and a list"},
+		// a sentence ending in the second half of maxLen ends the abstract.
+		{"First sentence here. Second sentence is longer than the rest.", 30, "First sentence here."},
+		// otherwise the last whole word, the ellipsis included in maxLen.
+		{"Some words, that go on and on without an end", 20, "Some words, that go…"},
+		{"Some words that, go on", 17, "Some words that…"},
+		{"Überall schöne Wörter überall", 16, "Überall schöne…"},
+		{strings.Repeat("ü", 30), 10, strings.Repeat("ü", 9) + "…"},
+		{"no cut needed", 13, "no cut needed"},
+		// a page without prose gives its first heading.
+		{"===== Only **a** heading =====\n\n{{image.png}}\n\n===== Another =====\n", 0, "Only a heading"},
+		{"", 0, ""},
+	}
+	for _, test := range tests {
+		got := Parse([]byte(test.content), "t").Abstract(test.maxLen)
+		if got != test.want {
+			t.Errorf("%q, %d: got %q, want %q", test.content, test.maxLen, got, test.want)
+		}
+		if test.maxLen > 0 && utf8.RuneCountInString(got) > test.maxLen {
+			t.Errorf("%q is longer than %d", got, test.maxLen)
+		}
+	}
+}
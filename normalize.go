@@ -0,0 +1,84 @@
+package dokuwiki
+
+import "strings"
+
+// invisibleOnSpaces are the effects that do not show on white space.
+const invisibleOnSpaces = TextEffectBold | TextEffectItalic
+
+// Normalize tidies the text of c and of every context below it, c is a unit or any context
+// of it like a paragraph. In the inline contexts of paragraphs, headings and link labels, it
+// drops the empty texts, gives a text of only white space between two texts with the same
+// effects those effects when its own differ from them only by bold and italic, which do not
+// show on white space, and merges the neighbouring texts with the same effects, so that
+// **a** **b** is one bold text. The text read is never changed, and normalizing twice
+// changes nothing more.
+//
+// The parser normalizes what it parses unless ParseOptions.NoNormalize is set, Normalize is
+// for the trees changed afterwards. It fails with ErrFrozen on a frozen unit.
+func Normalize(c Context) error {
+	if unit, ok := c.(*ParseUnit); ok && unit.frozen {
+		return ErrFrozen
+	}
+	Walk(c, func(c Context) bool {
+		switch c := c.(type) {
+		case *ParaContext:
+			c.InnerContexts = NormalizeInlines(c.InnerContexts)
+		case *SectionHeaderContext:
+			c.InnerContexts = NormalizeInlines(c.InnerContexts)
+		case *HyperLinkContext:
+			c.InnerContexts = NormalizeInlines(c.InnerContexts)
+		}
+		return true
+	})
+	return nil
+}
+
+// NormalizeInlines is Normalize for a list of inline contexts, it returns the list normalized,
+// which reuses the array of inlines. The first of texts merged is kept.
+func NormalizeInlines(inlines []InlineContext) []InlineContext {
+	for i, inline := range inlines {
+		text, ok := inline.(*TextEffectContext)
+		if !ok || i == 0 || i == len(inlines)-1 || strings.TrimSpace(text.Text) != "" || text.Text == "" {
+			continue
+		}
+		before, isText := inlines[i-1].(*TextEffectContext)
+		after, isAlsoText := inlines[i+1].(*TextEffectContext)
+		if isText && isAlsoText && before.EffectType == after.EffectType && (text.EffectType^before.EffectType)&^invisibleOnSpaces == 0 {
+			text.EffectType = before.EffectType
+		}
+	}
+
+	normalized := inlines[:0]
+	var last *TextEffectContext
+	// run collects the texts merged into last, it is given to last once at the end of the run
+	// so a long run is not copied again for every text.
+	var run strings.Builder
+	endRun := func() {
+		if run.Len() > 0 {
+			last.Text = run.String()
+			run.Reset()
+		}
+		last = nil
+	}
+	for _, inline := range inlines {
+		text, ok := inline.(*TextEffectContext)
+		switch {
+		case !ok:
+			endRun()
+		case text.Text == "":
+			continue
+		case last != nil && last.EffectType == text.EffectType:
+			if run.Len() == 0 {
+				run.WriteString(last.Text)
+			}
+			run.WriteString(text.Text)
+			continue
+		default:
+			endRun()
+			last = text
+		}
+		normalized = append(normalized, inline)
+	}
+	endRun()
+	return normalized
+}
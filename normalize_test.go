@@ -0,0 +1,132 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"**a****b**", `<strong>ab</strong>`},
+		{"**a** **b** c", `<strong>a b</strong> c`},
+		{"//a// //b//", `<em>a b</em>`},
+		// a space underlined shows, it is left alone.
+		{"__a__ __b__", `<em class="u">a</em> <em class="u">b</em>`},
+		{"**a** b **c**", `<strong>a</strong> b <strong>c</strong>`},
+		{"== **a** **b** ==", `<strong>a b</strong>`},
+		{"[[page|**a****b**]]", `<strong>ab</strong>`},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		Render(Parse([]byte(test.content+"\n"), "t"), &out)
+		if !bytes.Contains(out.Bytes(), []byte(test.want)) {
+			t.Errorf("%s: no %s in %s", test.content, test.want, out.String())
+		}
+
+		// with NoNormalize, Normalize gives the same tree afterwards.
+		unit := New(WithOptions(ParseOptions{NoNormalize: true})).Parse([]byte(test.content+"\n"), "t")
+		Normalize(unit)
+		var normalized bytes.Buffer
+		Render(unit, &normalized)
+		if normalized.String() != out.String() {
+			t.Errorf("%s: got %s after Normalize, want %s", test.content, normalized.String(), out.String())
+		}
+	}
+}
+
+func TestNormalizeKeepsText(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no golden files: %v", err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := New(WithOptions(ParseOptions{NoNormalize: true})).Parse(content, "t")
+		var before, once, twice bytes.Buffer
+		NewTextRenderer(RendererOptions{}).Render(&before, unit)
+		Normalize(unit)
+		NewTextRenderer(RendererOptions{}).Render(&once, unit)
+		var dumped bytes.Buffer
+		Dump(&dumped, unit)
+		Normalize(unit)
+		var again bytes.Buffer
+		Dump(&again, unit)
+		NewTextRenderer(RendererOptions{}).Render(&twice, unit)
+		if once.String() != before.String() || twice.String() != before.String() {
+			t.Errorf("%s: the text changed:\n%s\n%s", file, before.String(), once.String())
+		}
+		if again.String() != dumped.String() {
+			t.Errorf("%s: normalizing twice changed the tree:\n%s\n%s", file, dumped.String(), again.String())
+		}
+	}
+}
+
+func TestNormalizeInlines(t *testing.T) {
+	para := &ParaContext{}
+	para.InnerContexts = []InlineContext{
+		&TextEffectContext{Text: ""},
+		&TextEffectContext{Text: "a", EffectType: TextEffectBold},
+		&TextEffectContext{Text: "", EffectType: TextEffectItalic},
+		&TextEffectContext{Text: "b", EffectType: TextEffectBold},
+		&TextEffectContext{Text: " ", EffectType: TextEffectItalic},
+		&TextEffectContext{Text: "c", EffectType: TextEffectBold},
+		&MacroContext{Name: "NOTOC"},
+		&TextEffectContext{Text: "d"},
+		&TextEffectContext{Text: "e"},
+		&TextEffectContext{Text: ""},
+	}
+	Normalize(para)
+	var got []string
+	for _, inline := range para.InnerContexts {
+		if text, ok := inline.(*TextEffectContext); ok {
+			got = append(got, fmt.Sprintf("%d:%s", text.EffectType, text.Text))
+		} else {
+			got = append(got, "macro")
+		}
+	}
+	want := []string{"1:ab c", "macro", "0:de"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	frozen := Parse([]byte("text\n"), "t")
+	frozen.Freeze()
+	if err := Normalize(frozen); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Normalize of a frozen unit: got %v", err)
+	}
+}
+
+// TestNormalizeInlinesScales checks that merging a run of texts allocates in proportion to the
+// run, four times the texts may not take much more than four times the bytes.
+func TestNormalizeInlinesScales(t *testing.T) {
+	allocated := func(n int) uint64 {
+		inlines := make([]InlineContext, 0, n)
+		for i := 0; i < n; i++ {
+			inlines = append(inlines, &TextEffectContext{Text: "a ", EffectType: TextEffectBold})
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		merged := NormalizeInlines(inlines)
+		runtime.ReadMemStats(&after)
+		if len(merged) != 1 || len(merged[0].(*TextEffectContext).Text) != 2*n {
+			t.Fatalf("%d texts merged into %d", n, len(merged))
+		}
+		return after.TotalAlloc - before.TotalAlloc
+	}
+	small, large := allocated(5000), allocated(20000)
+	if large > 8*small {
+		t.Errorf("merging 5000 texts allocated %d bytes, 20000 texts %d", small, large)
+	}
+}
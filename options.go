@@ -0,0 +1,180 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseOptions tunes the parser, the zero value gives the default behaviour.
+type ParseOptions struct {
+	// Parallelism is the number of goroutines used to parse the inline elements of paragraphs,
+	// values <= 1 parse them serially in the calling goroutine.
+	Parallelism int
+
+	// AutolinkSchemes are the schemes of the bare URLs turned into links, compared without
+	// case, DefaultAutolinkSchemes when nil. NoAutolink turns autolinking off.
+	AutolinkSchemes []string
+	NoAutolink      bool
+	// NoWWWAutolink stops linking the hosts starting with www. written without a scheme, like
+	// www.example.com, which DokuWiki links to http://www.example.com.
+	NoWWWAutolink bool
+
+	// PageID is the ID of the page being parsed, like "wiki:syntax". When set, the media IDs are
+	// resolved against its namespace into MediaContext.ResolvedID.
+	PageID string
+
+	// LineJoin tells what joins the lines of a paragraph, a space by default.
+	LineJoin LineJoin
+	// PreserveLineBreaks keeps the new lines of paragraphs, list items and quotes as
+	// LineBreakContext, for the wikis where a new line in the text is a line break, instead of
+	// joining the lines with LineJoin.
+	PreserveLineBreaks bool
+
+	// DisableEmbeds makes the html and HTML tags text like any other, formatted like the text
+	// around them, so that no HTMLContext is ever made, for untrusted input. DokuWiki does so when
+	// htmlok is off. The php tags are always text, the parser does not know them.
+	DisableEmbeds bool
+
+	// NoNormalize keeps the texts of paragraphs as the inline parser made them, instead of
+	// normalizing them like Normalize does, for the tools that need every node it made.
+	NoNormalize bool
+
+	// TabWidth is the number of spaces a tab counts for in the indentation of list items, 2
+	// when 0 like DokuWiki, so that a tab is one level.
+	TabWidth int
+
+	// DefinitionLists turns on the syntax of the definition list plugin, lines like
+	// "  ; term : definition" and "  : definition" give a DefinitionListContext.
+	DefinitionLists bool
+
+	// Anchors turns on the syntax of the anchor plugin, {{anchor:name}} gives an AnchorContext
+	// instead of the media anchor:name.
+	Anchors bool
+
+	// KeepCodeNewline keeps the new line right after the start tag of code and file tags in
+	// their Text, which is dropped by default like DokuWiki does. The rest of the content is
+	// always kept as is, trailing new lines and spaces included.
+	KeepCodeNewline bool
+
+	// UnknownTag, when set, is called for the tags the parser does not know, like
+	// <color red>text</color> or <WRAP>...</WRAP>, with their name and their raw text from the
+	// start tag to the end tag, which may be on another line. A tag without an end tag is left
+	// alone. The returned context replaces the tag, Strip removes it and nil keeps it as text.
+	UnknownTag func(name, raw string) InlineContext
+	// UnknownMacro is the same for the macros other than ~~NOTOC~~, ~~NOCACHE~~ and ~~TOC:n-m~~, like
+	// ~~DISCUSSION:off~~, name is the part before the colon.
+	UnknownMacro func(name, raw string) InlineContext
+
+	// ClassifyLink, when set, is asked first what kind of link a [[link]] target is, like an
+	// external link for a custom scheme such as jira:ABC-123. It returns the kind and the target
+	// to use instead, the original one when empty, or handled false to leave the target to the
+	// built in rules. The text of a link without a label stays the original target.
+	ClassifyLink func(target string) (kind LinkKind, rewritten string, handled bool)
+
+	// Effects are the delimiters of the text effects registered with Parser.RegisterEffect, by
+	// their bit, which checks them. The units parsed with them keep them.
+	Effects map[TextEffect]string
+}
+
+// LineJoin tells how the lines of a paragraph are joined into its text.
+type LineJoin int
+
+const (
+	// LineJoinSpace puts a space between the lines, like DokuWiki does.
+	LineJoinSpace LineJoin = iota
+	// LineJoinNone puts nothing between the lines.
+	LineJoinNone
+	// LineJoinSmart puts a space unless the characters on both sides of the line break are
+	// Chinese or Japanese, written without spaces between words, like CommonMark renderers do.
+	// Korean is written with spaces, so Hangul gets a space.
+	LineJoinSmart
+)
+
+var lineJoinNames = []string{"space", "none", "smart"}
+
+func (j LineJoin) String() string {
+	if j >= 0 && int(j) < len(lineJoinNames) {
+		return lineJoinNames[j]
+	}
+	return "LineJoin(" + strconv.Itoa(int(j)) + ")"
+}
+
+// Option sets up a Parser made by New.
+type Option func(*Parser)
+
+// WithOptions replaces the options of the parser, the options given after it change them further.
+func WithOptions(opts ParseOptions) Option {
+	return func(p *Parser) { p.Options = opts }
+}
+
+// WithParallelism sets ParseOptions.Parallelism.
+func WithParallelism(n int) Option {
+	return func(p *Parser) { p.Options.Parallelism = n }
+}
+
+// WithDefinitionLists turns on ParseOptions.DefinitionLists.
+func WithDefinitionLists() Option {
+	return func(p *Parser) { p.Options.DefinitionLists = true }
+}
+
+// WithAnchors turns on ParseOptions.Anchors.
+func WithAnchors() Option {
+	return func(p *Parser) { p.Options.Anchors = true }
+}
+
+// WithDisableEmbeds turns on ParseOptions.DisableEmbeds.
+func WithDisableEmbeds() Option {
+	return func(p *Parser) { p.Options.DisableEmbeds = true }
+}
+
+// WithClassifyLink sets ParseOptions.ClassifyLink.
+func WithClassifyLink(classify func(target string) (kind LinkKind, rewritten string, handled bool)) Option {
+	return func(p *Parser) { p.Options.ClassifyLink = classify }
+}
+
+// WithInline registers an inline plugin, like Parser.RegisterInline.
+func WithInline(name string, matcher InlineMatcher) Option {
+	return func(p *Parser) { p.RegisterInline(name, matcher) }
+}
+
+// WithBlock registers a block plugin, like Parser.RegisterBlock.
+func WithBlock(name string, matcher BlockMatcher) Option {
+	return func(p *Parser) { p.RegisterBlock(name, matcher) }
+}
+
+// lineJoiner returns what joins line to the next line of its paragraph, next: a new line with
+// PreserveLineBreaks, parsePara then makes a LineBreakContext of it.
+func (opts ParseOptions) lineJoiner(line, next []byte) []byte {
+	if opts.PreserveLineBreaks {
+		return newLineJoiner
+	}
+	return opts.LineJoin.lineJoiner(line, next)
+}
+
+var newLineJoiner = []byte{'\n'}
+
+// lineJoiner returns what joins line to the next line of its paragraph, next.
+func (j LineJoin) lineJoiner(line, next []byte) []byte {
+	switch j {
+	case LineJoinNone:
+		return nil
+	case LineJoinSmart:
+		last, _ := utf8.DecodeLastRune(bytes.TrimRight(line, "\r"))
+		first, _ := utf8.DecodeRune(next)
+		if isCJK(last) && isCJK(first) {
+			return nil
+		}
+	}
+	return spaceJoiner
+}
+
+var spaceJoiner = []byte{' '}
+
+// isCJK tells whether r is a Chinese or Japanese character or punctuation.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		r >= 0x3000 && r <= 0x303f || // CJK symbols and punctuation
+		r >= 0xff00 && r <= 0xffef // full width forms
+}
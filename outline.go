@@ -0,0 +1,87 @@
+package dokuwiki
+
+import (
+	"regexp"
+	"sort"
+)
+
+// OutlineEntry is one heading of a page as listed by Outline.
+type OutlineEntry struct {
+	// HeaderLevel is the level as written in the source, 6 for ====== down to 1 for =.
+	HeaderLevel int
+	// Depth is the level of the heading in the outline, 1 for the biggest heading used.
+	Depth int
+	// Text is the heading with formatting markers like ** and // removed.
+	Text string
+	// Anchor is the id the html renderer gives to the heading.
+	Anchor   string
+	Position Position
+}
+
+// OutlineOptions configures OutlineWithOptions.
+type OutlineOptions struct {
+	// PreserveGaps keeps the distance between levels, so a page using ====== and ====
+	// gives depths 1 and 3. By default the levels used get consecutive depths, 1 and 2.
+	PreserveGaps bool
+}
+
+// Outline lists the headings of the unit in document order with their depth, for building
+// navigation. The levels used by the page are mapped to consecutive depths.
+func (unit *ParseUnit) Outline() []OutlineEntry {
+	return unit.OutlineWithOptions(OutlineOptions{})
+}
+
+func (unit *ParseUnit) OutlineWithOptions(opts OutlineOptions) []OutlineEntry {
+	headers := make([]*SectionHeaderContext, 0)
+	used := make(map[int]bool)
+	for _, block := range unit.Sections {
+		if header, ok := block.(*SectionHeaderContext); ok {
+			headers = append(headers, header)
+			used[header.HeaderLevel] = true
+		}
+	}
+
+	// levels are sorted biggest first, which is the highest HeaderLevel.
+	levels := make([]int, 0, len(used))
+	for level := range used {
+		levels = append(levels, level)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levels)))
+	depths := make(map[int]int, len(levels))
+	for i, level := range levels {
+		if opts.PreserveGaps {
+			depths[level] = levels[0] - level + 1
+		} else {
+			depths[level] = i + 1
+		}
+	}
+
+	entries := make([]OutlineEntry, 0, len(headers))
+	anchors := unit.headingIDs()
+	for _, header := range headers {
+		entries = append(entries, OutlineEntry{
+			HeaderLevel: header.HeaderLevel,
+			Depth:       depths[header.HeaderLevel],
+			Text:        header.HeaderTextPlain(),
+			Anchor:      anchors[header],
+			Position:    header.GetPosition(),
+		})
+	}
+	return entries
+}
+
+// markerPairs match text wrapped in a formatting marker, markers without a partner are kept.
+var markerPairs = []*regexp.Regexp{
+	regexp.MustCompile(`\*\*(.+?)\*\*`),
+	regexp.MustCompile(`//(.+?)//`),
+	regexp.MustCompile(`__(.+?)__`),
+	regexp.MustCompile("``(.+?)``"),
+}
+
+// stripMarkers removes the formatting markers of text, for the headings that were not parsed.
+func stripMarkers(text string) string {
+	for _, re := range markerPairs {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	return text
+}
@@ -0,0 +1,103 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutline(t *testing.T) {
+	content := "==== **Setup** ====\ntext\n=== The //new// way ===\n=== Limits ===\n==== Setup ====\n== Deep ==\n"
+	unit := Parse([]byte(content), "doc")
+
+	want := []OutlineEntry{
+		{HeaderLevel: 4, Depth: 1, Text: "Setup", Anchor: "setup", Position: Position{Line: 1}},
+		{HeaderLevel: 3, Depth: 2, Text: "The new way", Anchor: "the_new_way", Position: Position{Line: 3}},
+		{HeaderLevel: 3, Depth: 2, Text: "Limits", Anchor: "limits", Position: Position{Line: 4}},
+		{HeaderLevel: 4, Depth: 1, Text: "Setup", Anchor: "setup1", Position: Position{Line: 5}},
+		{HeaderLevel: 2, Depth: 3, Text: "Deep", Anchor: "deep", Position: Position{Line: 6}},
+	}
+	if got := unit.Outline(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	// a page skipping levels.
+	unit = Parse([]byte("====== Title ======\n==== Part ====\n== Detail ==\n==== Other ====\n"), "doc")
+	depths := func(opts OutlineOptions) []int {
+		depths := make([]int, 0)
+		for _, entry := range unit.OutlineWithOptions(opts) {
+			depths = append(depths, entry.Depth)
+		}
+		return depths
+	}
+	if got, want := depths(OutlineOptions{}), []int{1, 2, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got depths %v, want %v", got, want)
+	}
+	if got, want := depths(OutlineOptions{PreserveGaps: true}), []int{1, 3, 5, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("preserving gaps: got depths %v, want %v", got, want)
+	}
+}
+
+func TestStripMarkers(t *testing.T) {
+	tests := map[string]string{
+		"**bold** and //italic//": "bold and italic",
+		"__u__ ``mono``":          "u mono",
+		"a ** b":                  "a ** b",
+		"**//both//**":            "both",
+	}
+	for in, want := range tests {
+		if got := stripMarkers(in); got != want {
+			t.Errorf("stripMarkers(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestHeadingFormatting(t *testing.T) {
+	content := "====== **Project** Plan ======\nsee [[#project_plan]]\n\n== a [[page]] {{x.png}} http://example.com ==\n"
+	unit := Parse([]byte(content), "doc")
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "doc"
+  SectionHeader level=6 "**Project** Plan"
+    Text effect=bold "Project"
+    Text " Plan"
+  Para
+    Text "see "
+    Link internal target="#project_plan" "#project_plan"
+  SectionHeader level=2 "a [[page]] {{x.png}} http://example.com"
+    Text "a [[page]] {{x.png}} http://example.com"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+
+	header := unit.Sections[0].(*SectionHeaderContext)
+	if got := header.HeaderTextPlain(); got != "Project Plan" {
+		t.Errorf("got plain text %q", got)
+	}
+	if got := (&SectionHeaderContext{HeaderText: "**Project** Plan"}).HeaderTextPlain(); got != "Project Plan" {
+		t.Errorf("heading built by hand: got plain text %q", got)
+	}
+	if toc := unit.TOC(); toc[0].Text != "Project Plan" || toc[0].Anchor != "project_plan" {
+		t.Errorf("got toc %+v", toc)
+	}
+
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<h1 id="project_plan"><strong>Project</strong> Plan</h1>`; !strings.Contains(out.String(), want) {
+		t.Errorf("html does not contain %s:\n%s", want, out.String())
+	}
+
+	var wiki bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+	var again bytes.Buffer
+	Dump(&again, Parse(wiki.Bytes(), "doc"))
+	if again.String() != want {
+		t.Errorf("round trip gave\n%s", again.String())
+	}
+}
@@ -0,0 +1,210 @@
+package dokuwiki
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// IDOptions are DokuWiki settings that change how page IDs are cleaned, the zero value
+// matches a default DokuWiki installation.
+type IDOptions struct {
+	// Separator replaces the characters not allowed in IDs, '_' when zero, like the sepchar setting.
+	Separator rune
+	// UseSlash makes "/" a namespace separator like ":", like the useslash setting.
+	UseSlash bool
+	// Deaccent replaces accented latin letters by their base letters, "ä" becomes "ae",
+	// like the deaccent setting.
+	Deaccent bool
+	// ASCII drops every character that is still not ASCII after deaccenting.
+	ASCII bool
+}
+
+// CleanID normalizes a page or media ID the way DokuWiki's cleanID does: it is lower cased,
+// ";" becomes ":", every character that is not a letter, a digit or one of ".-_:" becomes the
+// separator, runs of separators and colons are collapsed, and separators around colons as
+// well as leading and trailing ":._-" are removed.
+func CleanID(raw string, opts IDOptions) string {
+	separator := opts.Separator
+	if separator == 0 {
+		separator = '_'
+	}
+	id := strings.ToLower(strings.TrimSpace(raw))
+	id = strings.Replace(id, ";", ":", -1)
+	if opts.UseSlash {
+		id = strings.Replace(id, "/", ":", -1)
+	}
+	if opts.Deaccent || opts.ASCII {
+		id = deaccent(id)
+	}
+
+	var b strings.Builder
+	lastWasSeparator, lastWasColon := false, false
+	for _, r := range id {
+		switch {
+		case r == ':':
+			if lastWasColon {
+				continue
+			}
+		case r == separator || !(unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '-' || r == '.' || r == '_'):
+			if opts.ASCII && r > unicode.MaxASCII && r != separator {
+				continue
+			}
+			if lastWasSeparator {
+				continue
+			}
+			r = separator
+		case opts.ASCII && r > unicode.MaxASCII:
+			continue
+		}
+		b.WriteRune(r)
+		lastWasSeparator, lastWasColon = r == separator, r == ':'
+	}
+	id = strings.Trim(b.String(), ":._-"+string(separator))
+
+	// drop the separators next to a colon, like "ns_:_page", and the parts left empty, like in
+	// "ns:...:page".
+	isSeparator := func(r rune) bool { return r == '.' || r == '_' || r == '-' || r == separator }
+	parts := strings.Split(id, ":")
+	kept := parts[:0]
+	for i, part := range parts {
+		if i > 0 {
+			part = strings.TrimLeftFunc(part, isSeparator)
+		}
+		if i < len(parts)-1 {
+			part = strings.TrimRightFunc(part, isSeparator)
+		}
+		if part != "" || len(parts) == 1 {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ":")
+}
+
+var deaccenter = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "å", "a", "ā", "a", "ą", "a", "ă", "a", "ä", "ae", "æ", "ae",
+	"ç", "c", "ć", "c", "č", "c", "ď", "d", "đ", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e", "ě", "e",
+	"ğ", "g", "ì", "i", "í", "i", "î", "i", "ï", "i", "ī", "i", "ı", "i",
+	"ł", "l", "ñ", "n", "ń", "n", "ň", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ø", "o", "ō", "o", "ő", "o", "ö", "oe", "œ", "oe",
+	"ř", "r", "ś", "s", "š", "s", "ş", "s", "ß", "ss", "ť", "t", "ţ", "t",
+	"ù", "u", "ú", "u", "û", "u", "ū", "u", "ů", "u", "ű", "u", "ü", "ue",
+	"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z", "þ", "th", "ð", "d",
+)
+
+// deaccent replaces the accented lower case latin letters of s by their base letters.
+func deaccent(s string) string {
+	return deaccenter.Replace(s)
+}
+
+// IDToPath returns the path of the file of a page, relative to DokuWiki's pages directory,
+// "wiki:syntax" is stored in "wiki/syntax.txt".
+func IDToPath(id string) string {
+	return strings.Replace(id, ":", "/", -1) + ".txt"
+}
+
+// PathToID is the inverse of IDToPath, a path without the .txt extension is accepted too.
+func PathToID(path string) string {
+	return strings.Replace(strings.TrimSuffix(strings.TrimPrefix(path, "/"), ".txt"), "/", ":", -1)
+}
+
+// cleanID is CleanID with the default options.
+func cleanID(raw string) string {
+	return CleanID(raw, IDOptions{})
+}
+
+// defaultStartPage is the page standing for a namespace, like DokuWiki's start setting.
+const defaultStartPage = "start"
+
+// ResolvePageID resolves the target of an internal link found on page current to a clean page ID,
+// the way DokuWiki does:
+//
+//   - a target starting with a colon is absolute, ":wiki:syntax"
+//   - a target without a colon, "syntax", is in the namespace of the current page
+//   - a target starting with ".:" or "." is in the namespace of the current page, every ".." part
+//     goes up one namespace, so "..:page" is in the parent namespace, a part of more dots is
+//     dropped, "." and ".." alone are the current namespace
+//   - a target starting with "~" is below the current page, "~sub" on page "ns:page" is "ns:page:sub"
+//   - any other target with a colon, "wiki:syntax", is absolute
+//   - a target ending with a colon is a namespace, it resolves to the namespace's start page
+//   - an empty target, like in [[#anchor]], is the current page
+//
+// The anchor of the target is dropped. The current page being a start page makes no
+// difference, a bare name on "ns:start" is in "ns" too.
+func ResolvePageID(current, target string) string {
+	id, _ := resolveID(current, target, nil)
+	return id
+}
+
+// leadingDots matches dots at the start of a target that are not followed by a colon, like in ".page".
+var leadingDots = regexp.MustCompile(`^((?:\.+:)*)(\.+)([^:.])`)
+
+// resolveID is ResolvePageID, the anchor, without the #, is returned separately. exists is
+// used to find the page of a namespace: ns:start, then ns:ns, then ns itself, it may be nil.
+func resolveID(currentID, target string, exists func(id string) bool) (id, anchor string) {
+	if i := strings.IndexByte(target, '#'); i != -1 {
+		target, anchor = target[:i], target[i+1:]
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return currentID, anchor
+	}
+
+	namespace := ""
+	if i := strings.LastIndexByte(currentID, ':'); i != -1 {
+		namespace = currentID[:i]
+	}
+	switch {
+	case target[0] == '~':
+		target = currentID + ":" + target[1:]
+	case target[0] == '.':
+		target = namespace + ":" + leadingDots.ReplaceAllString(target, "$1$2:$3")
+	case !strings.Contains(target, ":"):
+		target = namespace + ":" + target
+	}
+	// trailing dots are dropped, "." and ".." alone mean the current namespace.
+	target = strings.TrimRight(target, ".")
+	isNamespace := strings.HasSuffix(target, ":")
+
+	parts := make([]string, 0)
+	for _, part := range strings.Split(target, ":") {
+		switch {
+		case part == "" || part == ".":
+		case part == "..":
+			// only .. goes up one namespace, more dots are dropped by cleanID like DokuWiki does.
+			if len(parts) > 0 {
+				parts = parts[:len(parts)-1]
+			}
+		default:
+			parts = append(parts, part)
+		}
+	}
+	id = cleanID(strings.Join(parts, ":"))
+	if isNamespace || id == "" {
+		return namespacePage(id, exists), anchor
+	}
+	return id, anchor
+}
+
+func joinID(namespace, id string) string {
+	if namespace == "" {
+		return id
+	}
+	return namespace + ":" + id
+}
+
+// namespacePage picks the page standing for a namespace.
+func namespacePage(namespace string, exists func(id string) bool) string {
+	startPage := joinID(namespace, defaultStartPage)
+	if exists == nil || namespace == "" || exists(startPage) {
+		return startPage
+	}
+	if last := namespace[strings.LastIndexByte(namespace, ':')+1:]; exists(joinID(namespace, last)) {
+		return joinID(namespace, last)
+	}
+	if exists(namespace) {
+		return namespace
+	}
+	return startPage
+}
@@ -0,0 +1,156 @@
+package dokuwiki
+
+import "testing"
+
+// The cases are modelled on DokuWiki's own tests of cleanID.
+func TestCleanID(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"page", "page"},
+		{"pa_ge", "pa_ge"},
+		{"pa%ge", "pa_ge"},
+		{"pa#ge", "pa_ge"},
+		{"pàge", "pàge"},
+		{"pagĖ", "pagė"},
+		{"pa$%^*#ge", "pa_ge"},
+		{"*page*", "page"},
+		{"ښ", "ښ"},
+		{"päge", "päge"},
+		{"foo bar", "foo_bar"},
+		{"PÄGÖ", "pägö"},
+		{"Faß", "faß"},
+		{"ښ   ښ", "ښ_ښ"},
+		{"pa?ge", "pa_ge"},
+		{"page/", "page"},
+		{"page.", "page"},
+		{"page..", "page"},
+		{"pa..ge", "pa..ge"},
+		{"pa.-ge", "pa.-ge"},
+		{"pa-.ge", "pa-.ge"},
+		{"pa_-ge", "pa_-ge"},
+		{"pa-_ge", "pa-_ge"},
+		{"pa__ge", "pa_ge"},
+		{"pa--ge", "pa--ge"},
+		{"page.-_", "page"},
+		{"  Page  ", "page"},
+		{":page", "page"},
+		{"ns:page", "ns:page"},
+		{":ns:page", "ns:page"},
+		{"ns::page", "ns:page"},
+		{"ns:...:page", "ns:page"},
+		{"ns:_:page", "ns:page"},
+		{"ns:::page:", "ns:page"},
+		{"ns:.page", "ns:page"},
+		{"ns:_page", "ns:page"},
+		{"ns:-page", "ns:page"},
+		{"ns.:page", "ns:page"},
+		{"ns_:page", "ns:page"},
+		{"ns-:page", "ns:page"},
+		{"ns;page", "ns:page"},
+		{"ns/page", "ns_page"},
+		{"ns : my page", "ns:my_page"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := CleanID(test.raw, IDOptions{}); got != test.want {
+			t.Errorf("CleanID(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestCleanIDOptions(t *testing.T) {
+	tests := []struct {
+		raw  string
+		opts IDOptions
+		want string
+	}{
+		{"ns/page", IDOptions{UseSlash: true}, "ns:page"},
+		{"ns/sub/page/", IDOptions{UseSlash: true}, "ns:sub:page"},
+		{"pa ge", IDOptions{Separator: '-'}, "pa-ge"},
+		{"pa--ge", IDOptions{Separator: '-'}, "pa-ge"},
+		{"pa__ge", IDOptions{Separator: '-'}, "pa__ge"},
+		{"pa%ge", IDOptions{Separator: '-'}, "pa-ge"},
+		{"ns:-page-", IDOptions{Separator: '-'}, "ns:page"},
+		{"pàge", IDOptions{Deaccent: true}, "page"},
+		{"pagĖ", IDOptions{Deaccent: true}, "page"},
+		{"päge", IDOptions{Deaccent: true}, "paege"},
+		{"Faß", IDOptions{Deaccent: true}, "fass"},
+		{"Crème Brûlée", IDOptions{Deaccent: true}, "creme_brulee"},
+		{"ښ page", IDOptions{Deaccent: true}, "ښ_page"},
+		{"ښ page", IDOptions{ASCII: true}, "page"},
+		{"zürich ښ", IDOptions{ASCII: true}, "zuerich"},
+	}
+	for _, test := range tests {
+		if got := CleanID(test.raw, test.opts); got != test.want {
+			t.Errorf("CleanID(%q, %+v) = %q, want %q", test.raw, test.opts, got, test.want)
+		}
+	}
+}
+
+func TestIDPaths(t *testing.T) {
+	tests := []struct {
+		id, path string
+	}{
+		{"start", "start.txt"},
+		{"wiki:syntax", "wiki/syntax.txt"},
+		{"a:b:c", "a/b/c.txt"},
+	}
+	for _, test := range tests {
+		if got := IDToPath(test.id); got != test.path {
+			t.Errorf("IDToPath(%q) = %q, want %q", test.id, got, test.path)
+		}
+		if got := PathToID(test.path); got != test.id {
+			t.Errorf("PathToID(%q) = %q, want %q", test.path, got, test.id)
+		}
+	}
+	if got := PathToID("/wiki/syntax"); got != "wiki:syntax" {
+		t.Errorf("PathToID without extension = %q", got)
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	exists := func(id string) bool {
+		return id == "manual:manual" || id == "faq"
+	}
+	tests := []struct {
+		current, target, id, anchor string
+	}{
+		{"wiki:start", "syntax", "wiki:syntax", ""},
+		{"wiki:start", "Other Page#Intro", "wiki:other_page", "Intro"},
+		{"wiki:start", "wiki:syntax", "wiki:syntax", ""},
+		{"wiki:start", ":syntax", "syntax", ""},
+		{"wiki:sub:page", ".:sibling", "wiki:sub:sibling", ""},
+		{"wiki:sub:page", ".sibling", "wiki:sub:sibling", ""},
+		{"wiki:sub:page", "..:up", "wiki:up", ""},
+		{"wiki:sub:page", "..:..:top", "top", ""},
+		{"wiki:start", "#section", "wiki:start", "section"},
+		{"wiki:start", "tools:", "tools:start", ""},
+		{"wiki:start", "manual:", "manual:manual", ""},
+		{"wiki:start", ":faq:", "faq", ""},
+		{"start", "page", "page", ""},
+		{"wiki:ns:start", "page", "wiki:ns:page", ""},
+		{"wiki:ns:start", "~sub", "wiki:ns:start:sub", ""},
+		{"wiki:page", "~", "wiki:page:start", ""},
+		{"wiki:sub:page", "...:up", "wiki:sub:up", ""},
+		{"wiki:sub:page", "..:..:..:..:top", "top", ""},
+		{"wiki:sub:page", "..", "wiki:sub:start", ""},
+		{"wiki:sub:page", ".", "wiki:sub:start", ""},
+		{"wiki:sub:page", "..sibling", "wiki:sibling", ""},
+		{"wiki:sub:page", "a:..:b", "b", ""},
+		{"wiki:sub:page", ":wiki:./sub:x", "wiki:sub:x", ""},
+		{"page", ".:other", "other", ""},
+	}
+	for _, test := range tests {
+		id, anchor := resolveID(test.current, test.target, exists)
+		if id != test.id || anchor != test.anchor {
+			t.Errorf("resolveID(%q, %q) = %q, %q, want %q, %q", test.current, test.target, id, anchor, test.id, test.anchor)
+		}
+	}
+}
+
+func TestResolvePageID(t *testing.T) {
+	if got := ResolvePageID("wiki:start", "Other Page#Intro"); got != "wiki:other_page" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package dokuwiki
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// ParseFiles parses the given files concurrently with a pool of at most
+// workers goroutines, workers <= 0 means one per CPU.
+//
+// The results are in the same order as paths: units[i] and errs[i] belong to
+// paths[i], errs[i] is nil on success. Files that were not started before ctx
+// is done get ctx.Err() as their error.
+//
+// Every file is parsed with its own parser states, the only package level
+// state shared between goroutines is the set of read-only compiled regexps
+// and markers, so no locking is needed around Parse itself.
+func ParseFiles(ctx context.Context, paths []string, workers int) ([]*ParseUnit, []error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	units := make([]*ParseUnit, len(paths))
+	errs := make([]error, len(paths))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				units[i], errs[i] = defaultParser.ParseFile(paths[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(paths); j++ {
+				errs[j] = ctx.Err()
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return units, errs
+}
@@ -0,0 +1,60 @@
+package dokuwiki
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writePages(t *testing.T, n int) []string {
+	dir := t.TempDir()
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("page%d.txt", i))
+		content := fmt.Sprintf("====== Page %d ======\n\nsome text\n", i)
+		if err := ioutil.WriteFile(paths[i], []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestParseFilesKeepsOrder(t *testing.T) {
+	paths := writePages(t, 50)
+	paths = append(paths, filepath.Join(filepath.Dir(paths[0]), "missing.txt"))
+
+	units, errs := ParseFiles(context.Background(), paths, 4)
+	if len(units) != len(paths) || len(errs) != len(paths) {
+		t.Fatalf("got %d units and %d errors for %d paths", len(units), len(errs), len(paths))
+	}
+	for i := 0; i < 50; i++ {
+		if errs[i] != nil {
+			t.Fatalf("page%d: %v", i, errs[i])
+		}
+		if units[i].Title != fmt.Sprintf("page%d.txt", i) {
+			t.Errorf("units[%d].Title = %q", i, units[i].Title)
+		}
+		header := units[i].Sections[0].(*SectionHeaderContext)
+		if header.HeaderText != fmt.Sprintf("Page %d", i) {
+			t.Errorf("units[%d] header = %q", i, header.HeaderText)
+		}
+	}
+	if errs[50] == nil || units[50] != nil {
+		t.Errorf("missing file should fail, got %v, %v", units[50], errs[50])
+	}
+}
+
+func TestParseFilesCancelled(t *testing.T) {
+	paths := writePages(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	units, errs := ParseFiles(ctx, paths, 2)
+	for i := range paths {
+		if errs[i] != context.Canceled || units[i] != nil {
+			t.Errorf("paths[%d]: got %v, %v", i, units[i], errs[i])
+		}
+	}
+}
@@ -2,27 +2,20 @@ package dokuwiki
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	_ "fmt"
 	"io"
-	"io/ioutil"
+	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-)
-
-// Normally 0x00 won't appear in a UTF8 text, so we use it as a special marker.
-var (
-	startOfCodeTag   = []byte{0, 1}
-	endOfCodeTag     = []byte{0, 2}
-	startOfFileTag   = []byte{0, 3}
-	endOfFileTag     = []byte{0, 4}
-	startOfHTMLTag   = []byte{0, 5}
-	endOfHTMLTag     = []byte{0, 6}
-	startOfhtmlTag   = []byte{0, 7}
-	endOfhtmlTag     = []byte{0, 8}
-	startOfNoWikiTag = []byte{0, 9}
-	endOfNoWikiTag   = []byte{0, 10}
+	"sync"
+	"time"
+	"unicode"
 )
 
 const (
@@ -31,15 +24,36 @@ const (
 	unOrderedListType = 2
 	orderedListType   = 3
 	paraType          = 4
+	pluginType        = 5
+	termType          = 6
+	definitionType    = 7
+	quoteType         = 8
 )
 
 var (
 	validSectionHeader = regexp.MustCompile(`^(=+)([^=]+)(=+)$`)
-	validListItem      = regexp.MustCompile(`^((  )+)([*-]) ((?s).*)$`)
-	validCodeStartTag  = regexp.MustCompile(`<code [a-zA-Z]+>$`)
-	validFileStartTag  = regexp.MustCompile(`<file [a-zA-Z]+ .+>$`)
-	validMedia         = regexp.MustCompile(`^(.*?)(\?\d+(x\d+)?)?$`)
-	validURL           = regexp.MustCompile(`(https?|ftp)://[^\s/$.?#].[^\s]*`)
+	validListItem      = regexp.MustCompile(`^([ \t]+)([*-]) ((?s).*)$`)
+	validDefinition    = regexp.MustCompile(`^((  )+)([;:]) ((?s).*)$`)
+	validQuote         = regexp.MustCompile(`^([ \t]*)(>+)((?s).*)$`)
+	// validQuoteListItem is a list item in a quote, what follows the > of the line.
+	validQuoteListItem = regexp.MustCompile(`^( +)([*-]) ((?s).*)$`)
+	// The names of the protected tags are matched without case and may have spaces before the
+	// >, but not after the <. Languages are like c++, objective-c, 1c, c#, html5 or text/x-abc.
+	// The start tags of code and file are matched from the last < of the text, their
+	// attributes have none. The language and the file name are optional, like in <code>,
+	// <file> and <file php>.
+	validCodeStartTag   = regexp.MustCompile(`(?i)<code(?: [a-zA-Z0-9+_#./-]+)?(?: \[[^\]]*\])?\s*>$`)
+	validFileStartTag   = regexp.MustCompile(`(?i)<file(?: [a-zA-Z0-9+_#./-]+(?: .+)?)?\s*>$`)
+	validCodeEndTag     = regexp.MustCompile(`(?i)</code\s*>$`)
+	validFileEndTag     = regexp.MustCompile(`(?i)</file\s*>$`)
+	validHTMLStartTag   = regexp.MustCompile(`(?i)<html\s*>$`)
+	validHTMLEndTag     = regexp.MustCompile(`(?i)</html\s*>$`)
+	validNoWikiStartTag = regexp.MustCompile(`(?i)<nowiki\s*>$`)
+	validNoWikiEndTag   = regexp.MustCompile(`(?i)</nowiki\s*>$`)
+	validMediaSize      = regexp.MustCompile(`^(\d*)(?:x(\d+))?$`)
+	validExternalMedia  = regexp.MustCompile(`^(?i)(https?|ftp)://`)
+	validMacro          = regexp.MustCompile(`^~~([A-Z]+|TOC:[1-5]-[1-5])~~`)
+	validCodeAttribute  = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_-]*)\s*=\s*"([^"]*)"`)
 )
 
 type wholeBlock struct {
@@ -49,169 +63,635 @@ type wholeBlock struct {
 	//only meaningful when blocktype is 1
 	headerLevel int
 
-	//only meaningful when blocktype is 2 or 3
-	listLevel int
+	// only meaningful when blocktype is 2, 3 or 8, the depth of the list item and the width of
+	// its indentation, see ListItemContext.
+	listLevel  int
+	listIndent int
 
-	// only meaningful when blockType is 2 or 3
+	// only meaningful when blockType is 2, 3, 6, 7 or 8
 	forceNewList bool
 
+	// only meaningful when blockType is 8, the number of > and of the spaces before them. A list
+	// item in the quote has its level in listLevel, ordered tells its kind.
+	quoteLevel  int
+	quoteIndent int
+	ordered     bool
+
 	//all blockTypes need this
 	rawText []byte
+	// tags are the protected tags of rawText found by the line pass, with offsets in rawText.
+	// tagsFound is false for the blocks whose rawText is not a part of the bytes scanned.
+	tags      []tagRegion
+	tagsFound bool
+
+	// the lines the block starts and ends on, counting from 1.
+	line    int
+	endLine int
+	// span is the bytes of the lines of the block in the input, without the last new line.
+	// textSpan is the one of the text of a list item, a term or a definition, without the marks
+	// of the list, it is zero for the other blocks and when the block has several lines.
+	span     Span
+	textSpan Span
+
+	// only meaningful when blockType is 5, the plugin that claimed the lines.
+	plugin *blockPlugin
+	lines  []string
 }
 
 type parserStates struct {
 	parseunit *ParseUnit
+	options   ParseOptions
+	// parser holds the syntax plugins, it is nil for the package level functions.
+	parser *Parser
+	// unknownTags claims the lines of the unknown tags spanning several lines, when
+	// options.UnknownTag is set.
+	unknownTags *blockPlugin
+	// labelOffset is the offset in its paragraph of the link label being parsed, for the diagnostics.
+	labelOffset int
+	// noLinks keeps links and media as text, for headings.
+	noLinks bool
+	// ctx stops the parsing when it is done, nil for the parses that can not be stopped.
+	ctx context.Context
+	// lines is the number of lines read, for the ParseError of a stopped parse.
+	lines int
+	// scratch holds the buffers of the parse, the ones of its parser when it comes from a ParserPool.
+	scratch *parseScratch
+	// effects are the effects of options.Effects, longest delimiter first.
+	effects []customEffect
 }
 
-func ParseFile(filename string) *ParseUnit {
-	if bytes, err := ioutil.ReadFile(filename); err == nil {
-		return Parse(bytes, filepath.Base(filename))
-	} else {
+// cancelled tells whether the context of the parse is done, the parsing then stops where it is.
+func (states *parserStates) cancelled() bool {
+	return states.ctx != nil && states.ctx.Err() != nil
+}
+
+// Parser parses pages with its options and the syntax plugins registered on it, every Parser
+// has its own. It is set up before parsing, with New and its options or by registering plugins,
+// then its parse methods can be called from several goroutines, each call has its own state.
+type Parser struct {
+	Options ParseOptions
+	// Hooks are told about every parse, see Hooks.
+	Hooks Hooks
+
+	inlines []inlinePlugin
+	blocks  []blockPlugin
+	// scratch is set for the parsers of a ParserPool, their parses reuse it.
+	scratch *parseScratch
+}
+
+// New returns a parser set up by opts, applied in order. Without any it parses like Parse.
+func New(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewParser returns a parser with the given options, like New(WithOptions(opts)).
+func NewParser(opts ParseOptions) *Parser {
+	return New(WithOptions(opts))
+}
+
+// defaultParser is the parser of the package level functions, it has no plugins.
+var defaultParser = New()
+
+// Parse parses content like ParseWithOptions does with the options of p, with its plugins.
+func (p *Parser) Parse(content []byte, title string) *ParseUnit {
+	unit, _ := parse(context.Background(), content, title, p.Options, p)
+	return unit
+}
+
+// ParseContext parses content like Parse, but stops when ctx is done. The lines are read one by
+// one and the paragraphs parsed one by one, ctx is checked before each of them. A stopped parse
+// returns a *ParseError wrapping ctx.Err(), whose Unit is what was parsed by then.
+func (p *Parser) ParseContext(ctx context.Context, content []byte, title string) (*ParseUnit, error) {
+	return parse(ctx, content, title, p.Options, p)
+}
+
+// ParseReader parses all of r, it only fails when reading does, with a *ParseError.
+func (p *Parser) ParseReader(r io.Reader, title string) (*ParseUnit, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &ParseError{Title: title, Err: err}
+	}
+	return p.Parse(content, title), nil
+}
+
+// ParseFile parses the file, the title of the unit is the base name of the file. It only fails
+// when reading does, with a *ParseError.
+func (p *Parser) ParseFile(filename string) (*ParseUnit, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, &ParseError{Title: filepath.Base(filename), Err: err}
+	}
+	return p.Parse(content, filepath.Base(filename)), nil
+}
+
+// inlinePlugins returns the inline plugins of the parser, if any.
+func (states *parserStates) inlinePlugins() []inlinePlugin {
+	if states.parser == nil {
 		return nil
 	}
+	return states.parser.inlines
+}
+
+// startBlockPlugin returns the block plugin whose block starts with line, if any, the line
+// starting at offset in the input.
+func (states *parserStates) startBlockPlugin(line []byte, offset int) *blockPlugin {
+	if states.parser != nil {
+		for i := range states.parser.blocks {
+			if states.parser.blocks[i].matcher.Start(line) {
+				return &states.parser.blocks[i]
+			}
+		}
+	}
+	if states.unknownTags != nil {
+		// only the end tags after the line close its tag.
+		states.unknownTags.matcher.(*unknownTagMatcher).offset = offset
+		if states.unknownTags.matcher.Start(line) {
+			return states.unknownTags
+		}
+	}
+	return nil
+}
+
+// ParseFile parses the file with the default options, it returns nil when the file can not be read.
+func ParseFile(filename string) *ParseUnit {
+	unit, _ := defaultParser.ParseFile(filename)
+	return unit
+}
+
+// ParseInline parses text as the content of one paragraph, for the snippets of markup without
+// blocks, like a comment or a template string. Headings, lists and block plugins are not looked
+// for, their markup is text. The inline contexts have no parent and are all on line 1, the
+// diagnostics of the tags are on their own line of text.
+func (p *Parser) ParseInline(text string) ([]InlineContext, []Diagnostic) {
+	states := &parserStates{parseunit: &ParseUnit{source: text}, options: p.Options, parser: p, effects: sortEffects(p.Options.Effects)}
+	para := &ParaContext{}
+	para.pos = Position{Line: 1}
+
+	// the tags are found like in a document, new lines outside of them are spaces.
+	lc := newLineClassifier(states, func(block wholeBlock) {
+		para.rawText, para.tags, para.tagsFound = string(block.rawText), block.tags, block.tagsFound
+	})
+	lc.blockLine = 1
+	lines := bytes.Split([]byte(text), []byte{'\n'})
+	for i, line := range lines {
+		lc.line++
+		lc.scanTags(line)
+		if i == len(lines)-1 {
+			break
+		}
+		if lc.isProtected() {
+			lc.blockBytes = append(lc.blockBytes, '\n')
+		} else {
+			lc.blockBytes = append(lc.blockBytes, states.options.lineJoiner(line, lines[i+1])...)
+		}
+	}
+	para.rawText, para.tags, para.tagsFound = string(lc.blockBytes), lc.tags.regions, true
+	// an unclosed tag emits the paragraph again.
+	lc.finish()
+
+	states.parseunit.Diagnostics = append(states.parseunit.Diagnostics, parsePara(states, para)...)
+	setDiagnosticSpans(states.parseunit)
+	for _, inline := range para.InnerContexts {
+		inline.SetParentContext(nil)
+	}
+	return para.InnerContexts, states.parseunit.Diagnostics
+}
+
+// ParseInline parses text as the content of one paragraph with a parser set up by opts, see
+// Parser.ParseInline.
+func ParseInline(text string, opts ...Option) ([]InlineContext, []Diagnostic) {
+	return New(opts...).ParseInline(text)
+}
+
+// ParseContext parses content with the default options until ctx is done, see Parser.ParseContext.
+func ParseContext(ctx context.Context, content []byte, title string) (*ParseUnit, error) {
+	return defaultParser.ParseContext(ctx, content, title)
 }
 
 func Parse(origContent []byte, title string) *ParseUnit {
-	parseunit := &ParseUnit{Title: title}
+	return defaultParser.Parse(origContent, title)
+}
+
+func ParseWithOptions(origContent []byte, title string, options ParseOptions) *ParseUnit {
+	return NewParser(options).Parse(origContent, title)
+}
+
+// MustParse returns unit and panics when err is not nil, it wraps the parses that can fail for
+// scripts and examples, like MustParse(ParseContext(ctx, content, title)).
+func MustParse(unit *ParseUnit, err error) *ParseUnit {
+	if err != nil {
+		panic(err)
+	}
+	return unit
+}
+
+// MustParseFile is ParseFile that panics when the file can not be read.
+func MustParseFile(filename string) *ParseUnit {
+	return MustParse(defaultParser.ParseFile(filename))
+}
+
+// ParseError is returned when a parse fails, Err is the cause. The problems in the input are
+// not errors, they are in the diagnostics of the unit.
+//
+// When reading the input failed, Unit is nil. When the context of ParseContext was done before
+// the end of the input, Unit is the partial result: it has the blocks that ended in the first
+// Lines lines, and of its paragraphs and headings only the ones parsed before the context was
+// done have their inline contexts, the others have none. Lines is the number of lines of the
+// input when the parse stopped while parsing the inline elements. The diagnostics of Unit are
+// only the ones found by then.
+type ParseError struct {
+	// Title is the title of the unit being parsed.
+	Title string
+	Err   error
+	Lines int
+	Unit  *ParseUnit
+}
+
+func (e *ParseError) Error() string {
+	if e.Unit == nil {
+		return "parse " + e.Title + ": " + e.Err.Error()
+	}
+	return "parse " + e.Title + ": stopped after " + strconv.Itoa(e.Lines) + " lines: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parse(ctx context.Context, origContent []byte, title string, options ParseOptions, parser *Parser) (*ParseUnit, error) {
+	parseunit := &ParseUnit{Title: title, source: string(origContent), effects: options.Effects, pageID: options.PageID}
 	states := parserStates{
 		parseunit: parseunit,
+		options:   options,
+		parser:    parser,
+		effects:   sortEffects(options.Effects),
+	}
+	if parser != nil && parser.scratch != nil {
+		states.scratch = parser.scratch
+		states.scratch.acquire()
+		defer states.scratch.release()
+	}
+	// a context that is never done, like context.Background(), is not checked at all.
+	if ctx.Done() != nil {
+		states.ctx = ctx
 	}
 
-	blocks := generateLines(origContent)
-	processContent(&states, blocks)
+	var summary Summary
+	if !states.cancelled() {
+		// processContent, with the time of both passes.
+		start := time.Now()
+		blocks := generateLines(&states, origContent)
+		for _, block := range blocks {
+			processLine(&states, block)
+		}
+		inlineStart := time.Now()
+		walkAST(&states)
+		summary.LinePass, summary.InlinePass = inlineStart.Sub(start), time.Since(inlineStart)
+		states.scratch.keepBlocks(blocks)
+	}
+	setDiagnosticSpans(states.parseunit)
+	if parser != nil {
+		parser.Hooks.report(states.parseunit, summary)
+	}
 
-	return states.parseunit
+	if states.cancelled() {
+		return states.parseunit, &ParseError{Title: title, Err: ctx.Err(), Lines: states.lines, Unit: states.parseunit}
+	}
+	return states.parseunit, nil
 }
 
 // generatelines splits the raw content into lines, each line is a section or a list item or a normal paragraph.
 // also removing empty lines and extra new lines.
-func generateLines(origContent []byte) []wholeBlock {
-	var isInCodeTag bool
-	var isInFileTag bool
-	var isInHTMLTag bool
-	var isInhtmlTag bool
-	var isInNoWikiTag bool
-
-	blocks := make([]wholeBlock, 0)
-	blockBytes := make([]byte, 0)
-	lastBlockBytes := blockBytes
+func generateLines(states *parserStates, origContent []byte) []wholeBlock {
+	if states.scratch == nil {
+		states.scratch = &parseScratch{}
+	}
+	blocks := states.scratch.blocks[:0]
+	if states.options.UnknownTag != nil {
+		states.unknownTags = &blockPlugin{name: "unknown tag", matcher: newUnknownTagMatcher(states.options.UnknownTag, origContent)}
+	}
+	classifier := newLineClassifier(states, func(block wholeBlock) {
+		blocks = append(blocks, block)
+	})
+	classifier.blockBytes = states.scratch.blockBytes[:0]
+	states.scratch.text = states.scratch.text[:0]
+	classifier.text = &states.scratch.text
+	defer func() { states.scratch.blockBytes = classifier.blockBytes[:0] }()
 
-	// append this to make processing easier, there should be no major side effects to do this.
-	origContent = append(origContent, '\n')
-	physicalLines := bytes.Split(origContent, []byte{'\n'})
+	// append this to make processing easier, copy first so the caller's buffer is never written to,
+	// it may be shared with other goroutines.
+	origContent = append(append(states.scratch.content[:0], origContent...), '\n')
+	states.scratch.content = origContent
+	physicalLines := splitLines(states.scratch.lines[:0], origContent)
+	states.scratch.lines = physicalLines
 
 	for physicalLineIndex, physicalLine := range physicalLines {
-		for _, b := range physicalLine {
-			blockBytes = append(blockBytes, b)
-			if b == '>' {
-				if matchedLen := bytesEndsWithRegexp(blockBytes, validCodeStartTag); matchedLen > 0 {
-					if !(isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag) {
-						isInCodeTag = true
-						blockBytes = replaceBytesWithMarker(blockBytes, matchedLen, startOfCodeTag)
-					}
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', '/', 'c', 'o', 'd', 'e', '>'}) {
-					isInCodeTag = false
-					blockBytes = replaceBytesWithMarker(blockBytes, len("</code>"), endOfCodeTag)
-				} else if matchedLen := bytesEndsWithRegexp(blockBytes, validFileStartTag); matchedLen > 0 {
-					if !(isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag) {
-						isInFileTag = true
-						blockBytes = replaceBytesWithMarker(blockBytes, matchedLen, startOfFileTag)
-					}
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', '/', 'f', 'i', 'l', 'e', '>'}) {
-					isInFileTag = false
-					blockBytes = replaceBytesWithMarker(blockBytes, len("</file>"), endOfFileTag)
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', 'h', 't', 'm', 'l', '>'}) {
-					if !(isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag) {
-						isInhtmlTag = true
-						blockBytes = replaceBytesWithMarker(blockBytes, len("<html>"), startOfhtmlTag)
-					}
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', '/', 'h', 't', 'm', 'l', '>'}) {
-					isInhtmlTag = false
-					blockBytes = replaceBytesWithMarker(blockBytes, len("</html>"), endOfhtmlTag)
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', 'H', 'T', 'M', 'L', '>'}) {
-					if !(isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag) {
-						isInHTMLTag = true
-						blockBytes = replaceBytesWithMarker(blockBytes, len("<HTML>"), startOfHTMLTag)
-					}
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', '/', 'H', 'T', 'M', 'L', '>'}) {
-					isInHTMLTag = false
-					blockBytes = replaceBytesWithMarker(blockBytes, len("</HTML>"), endOfHTMLTag)
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', 'n', 'o', 'w', 'i', 'k', 'i', '>'}) {
-					if !(isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag) {
-						isInNoWikiTag = true
-						blockBytes = replaceBytesWithMarker(blockBytes, len("<nowiki>"), startOfNoWikiTag)
-					}
-				} else if bytes.HasSuffix(blockBytes, []byte{'<', '/', 'n', 'o', 'w', 'i', 'k', 'i', '>'}) {
-					isInNoWikiTag = false
-					blockBytes = replaceBytesWithMarker(blockBytes, len("</nowiki>"), endOfNoWikiTag)
-				}
-			}
+		// a stopped parse keeps the blocks that ended, the one going on is dropped.
+		if states.cancelled() {
+			return blocks
+		}
+		nextPhysicalLine := []byte("")
+		if physicalLineIndex < (len(physicalLines) - 1) {
+			nextPhysicalLine = physicalLines[physicalLineIndex+1]
 		}
+		classifier.feed(physicalLine, nextPhysicalLine)
+		// the new line appended above is not a line of the input.
+		states.lines = min(classifier.line, len(physicalLines)-1)
+	}
+	classifier.finish()
 
-		// process new line
-		if isInCodeTag || isInFileTag || isInHTMLTag || isInhtmlTag || isInNoWikiTag {
-			blockBytes = append(blockBytes, '\n')
-		} else {
-			if len(bytes.TrimSpace(blockBytes)) > 0 {
-				headerLevel, headerContent := parseSectionHeader(blockBytes)
-				if headerLevel > 0 {
-					blocks = append(blocks, wholeBlock{
-						blockType:   sectionHeaderType,
-						headerLevel: headerLevel,
-						rawText:     headerContent,
-					})
-					lastBlockBytes = blockBytes
-					blockBytes = make([]byte, 0)
-				} else {
-					listLevel, isOrdered, itemBytes := parseListItem(blockBytes)
-					if listLevel > 0 {
-						block := wholeBlock{
-							listLevel: listLevel,
-							rawText:   itemBytes,
-						}
-						block.forceNewList = len(bytes.TrimSpace(lastBlockBytes)) == 0
-						if isOrdered {
-							block.blockType = orderedListType
-						} else {
-							block.blockType = unOrderedListType
-						}
-						blocks = append(blocks, block)
-						lastBlockBytes = blockBytes
-						blockBytes = make([]byte, 0)
-					} else {
-						nextPhysicalLine := []byte("")
-						if physicalLineIndex < (len(physicalLines) - 1) {
-							nextPhysicalLine = physicalLines[physicalLineIndex+1]
-						}
-						currentBlockStopsHere := false
-						if len(bytes.TrimSpace(nextPhysicalLine)) == 0 {
-							currentBlockStopsHere = true
-						} else if l, _, _ := parseListItem(nextPhysicalLine); l > 0 {
-							currentBlockStopsHere = true
-						} else if l, _ := parseSectionHeader(nextPhysicalLine); l > 0 {
-							currentBlockStopsHere = true
-						} else {
-							// treat new line as whitespace.
-							blockBytes = append(blockBytes, ' ')
-						}
-						if currentBlockStopsHere {
-							blocks = append(blocks, wholeBlock{
-								blockType: paraType,
-								rawText:   blockBytes,
-							})
-							lastBlockBytes = blockBytes
-							blockBytes = make([]byte, 0)
-						}
-					}
+	return blocks
+}
+
+// lineClassifier is the state machine behind generateLines, it is fed one physical line at a time
+// together with the following line, which is all the look ahead it needs, so it can also work on a stream.
+type lineClassifier struct {
+	// tags follows the protected tags of the block bytes, which are kept as they are.
+	tags tagScanner
+
+	// plugin is the block plugin that claimed the lines in pluginLines, until its block ends.
+	plugin      *blockPlugin
+	pluginLines []string
+
+	// blockBytes are the bytes of the current block, their array is used again by the next one,
+	// the raw text of the blocks emitted is copied to text, or to its own slice when text is nil.
+	blockBytes []byte
+	text       *[]byte
+	// line is the number of the physical line being fed, blockLine the one the current block started on.
+	line      int
+	blockLine int
+	// offset is where the next physical line starts in the input, lineEnd where the one being fed
+	// ends and blockStart where the current block starts.
+	offset     int
+	lineEnd    int
+	blockStart int
+	// whether the line before the current block is empty, a list item after an empty line starts a new list.
+	lastLineEmpty bool
+
+	states *parserStates
+	emit   func(wholeBlock)
+}
+
+func newLineClassifier(states *parserStates, emit func(wholeBlock)) *lineClassifier {
+	return &lineClassifier{
+		blockBytes:    make([]byte, 0),
+		tags:          tagScanner{disableEmbeds: states.options.DisableEmbeds},
+		lastLineEmpty: true,
+		states:        states,
+		emit:          emit,
+	}
+}
+
+func (lc *lineClassifier) isProtected() bool {
+	return lc.tags.protected()
+}
+
+// resetBlock empties the block bytes for the next block.
+func (lc *lineClassifier) resetBlock() {
+	lc.blockBytes = lc.blockBytes[:0]
+	lc.tags = tagScanner{disableEmbeds: lc.states.options.DisableEmbeds}
+}
+
+// finish is called after the last line, a protected tag that was never closed swallowed the rest of
+// the content, it is kept as a paragraph so nothing gets lost.
+func (lc *lineClassifier) finish() {
+	if lc.plugin != nil {
+		lc.states.parseunit.Diagnostics = append(lc.states.parseunit.Diagnostics,
+			newDiagnostic(CodeUnclosedBlock, lc.blockLine, "unclosed %s block", lc.plugin.name))
+		lc.endPluginBlock()
+		return
+	}
+	if !lc.isProtected() {
+		return
+	}
+	tag, code := "", ""
+	switch lc.tags.open.kind {
+	case codeTag:
+		tag, code = "<code>", CodeUnclosedCodeTag
+	case fileTag:
+		tag, code = "<file>", CodeUnclosedFileTag
+	case htmlBlockTag:
+		tag, code = "<HTML>", CodeUnclosedHTMLTag
+	case htmlTag:
+		tag, code = "<html>", CodeUnclosedHTMLTag
+	case noWikiTag:
+		tag, code = "<nowiki>", CodeUnclosedNoWikiTag
+	}
+	lc.states.parseunit.Diagnostics = append(lc.states.parseunit.Diagnostics,
+		newDiagnostic(code, lc.blockLine, "unclosed %s tag", tag))
+	lc.endBlock(wholeBlock{
+		blockType: paraType,
+		rawText:   bytes.TrimRight(lc.blockBytes, "\n"),
+	})
+}
+
+// strayEndTag reports the end tag of length n ending the block bytes, which closes no tag. It
+// stays as text.
+func (lc *lineClassifier) strayEndTag(n int) {
+	tag := string(lc.blockBytes[len(lc.blockBytes)-n:])
+	lc.states.parseunit.Diagnostics = append(lc.states.parseunit.Diagnostics,
+		newDiagnostic(CodeStrayEndTag, lc.line, "stray %s tag", tag))
+}
+
+// endBlock emits the blocks the block bytes are, with the protected tags of their raw text.
+func (lc *lineClassifier) endBlock(blocks ...wholeBlock) {
+	regions := lc.tags.finish(len(lc.blockBytes))
+	for _, block := range blocks {
+		block.line = lc.blockLine
+		block.endLine = lc.line
+		block.span = Span{lc.blockStart, lc.lineEnd}
+		if len(block.rawText) == 0 {
+			block.tagsFound = true
+		} else if offset := offsetIn(lc.blockBytes, block.rawText); offset != -1 {
+			block.tags, block.tagsFound = tagsBetween(regions, offset, offset+len(block.rawText)), true
+		}
+		block.rawText = lc.keepText(block.rawText)
+		lc.emit(block)
+	}
+	lc.lastLineEmpty = false
+	lc.resetBlock()
+}
+
+// keepText copies the raw text of a block out of the block bytes, the next block writes over them.
+func (lc *lineClassifier) keepText(rawText []byte) []byte {
+	if len(rawText) == 0 {
+		return nil
+	}
+	if lc.text == nil {
+		return bytes.Clone(rawText)
+	}
+	// what is appended after it is never read through it.
+	start := len(*lc.text)
+	*lc.text = append(*lc.text, rawText...)
+	return (*lc.text)[start:len(*lc.text):len(*lc.text)]
+}
+
+// endPluginBlock emits the lines claimed by the current block plugin.
+func (lc *lineClassifier) endPluginBlock() {
+	lc.endBlock(wholeBlock{
+		blockType: pluginType,
+		plugin:    lc.plugin,
+		lines:     lc.pluginLines,
+	})
+	lc.plugin, lc.pluginLines = nil, nil
+}
+
+func (lc *lineClassifier) feed(physicalLine []byte, nextPhysicalLine []byte) {
+	lc.line++
+	lineStart := lc.offset
+	lc.offset += len(physicalLine) + 1
+	lc.lineEnd = lineStart + len(physicalLine)
+	if len(lc.blockBytes) == 0 && lc.plugin == nil {
+		lc.blockLine = lc.line
+		lc.blockStart = lineStart
+		// block plugins come first, a block can only start where no other one is going on.
+		if !lc.isProtected() {
+			lc.plugin = lc.states.startBlockPlugin(physicalLine, lineStart)
+		}
+	}
+	if lc.plugin != nil {
+		lc.pluginLines = append(lc.pluginLines, string(physicalLine))
+		if lc.plugin.matcher.End(physicalLine, nextPhysicalLine) {
+			lc.endPluginBlock()
+		}
+		return
+	}
+	lc.scanTags(physicalLine)
+
+	// process new line
+	if lc.isProtected() {
+		lc.blockBytes = append(lc.blockBytes, '\n')
+	} else {
+		if len(bytes.TrimSpace(lc.blockBytes)) > 0 {
+			if blocks := lc.classify(lc.blockBytes); blocks != nil {
+				if lc.blockStart == lineStart {
+					setTextSpans(blocks, physicalLine, lineStart)
 				}
+				// only the first block of a line can follow an empty line.
+				blocks[0].forceNewList = lc.lastLineEmpty
+				lc.endBlock(blocks...)
+			} else if lc.startsBlock(nextPhysicalLine, lc.offset) {
+				lc.endBlock(wholeBlock{
+					blockType: paraType,
+					rawText:   lc.blockBytes,
+				})
 			} else {
-				lastBlockBytes = blockBytes
-				blockBytes = make([]byte, 0)
+				// treat new line as whitespace, or as nothing with ParseOptions.LineJoin.
+				lc.blockBytes = append(lc.blockBytes, lc.states.options.lineJoiner(physicalLine, nextPhysicalLine)...)
 			}
+		} else {
+			lc.lastLineEmpty = true
+			lc.resetBlock()
 		}
 	}
+}
 
-	return blocks
+// scanTags appends line to the block bytes and follows its code, file, html and nowiki tags, the
+// end tags that close no tag are reported.
+func (lc *lineClassifier) scanTags(line []byte) {
+	for _, b := range line {
+		lc.blockBytes = append(lc.blockBytes, b)
+		if b == '>' {
+			if n := lc.tags.scan(lc.blockBytes); n > 0 {
+				lc.strayEndTag(n)
+			}
+		}
+	}
+}
+
+// classify returns the blocks a line is, nil for the text of a paragraph. A term line of a
+// definition list can also hold a definition, it is two blocks.
+func (lc *lineClassifier) classify(line []byte) []wholeBlock {
+	if level, content := parseSectionHeader(line); level > 0 {
+		return []wholeBlock{{blockType: sectionHeaderType, headerLevel: level, rawText: content}}
+	}
+	if indent, depth, isOrdered, content := parseListItem(line, lc.states.options.TabWidth); depth > 0 {
+		block := wholeBlock{blockType: unOrderedListType, listLevel: depth, listIndent: indent, rawText: content}
+		if isOrdered {
+			block.blockType = orderedListType
+		}
+		return []wholeBlock{block}
+	}
+	if level, indent, content := parseQuote(line, lc.states.options.TabWidth); level > 0 {
+		block := wholeBlock{blockType: quoteType, quoteLevel: level, quoteIndent: indent, rawText: bytes.TrimSpace(content)}
+		if listIndent, listLevel, isOrdered, item := parseQuoteListItem(content); listLevel > 0 {
+			block.listLevel, block.listIndent, block.ordered, block.rawText = listLevel, listIndent, isOrdered, item
+		}
+		return []wholeBlock{block}
+	}
+	if isTerm, term, definition := lc.parseDefinition(line); term != nil || definition != nil {
+		blocks := make([]wholeBlock, 0, 2)
+		if isTerm {
+			blocks = append(blocks, wholeBlock{blockType: termType, rawText: term})
+		}
+		if definition != nil {
+			blocks = append(blocks, wholeBlock{blockType: definitionType, rawText: definition})
+		}
+		return blocks
+	}
+	return nil
+}
+
+// setTextSpans sets the text spans of the list items, of the lines of definition lists and of
+// quotes in blocks, the blocks classify found in line, which starts at offset start of the input.
+func setTextSpans(blocks []wholeBlock, line []byte, start int) {
+	var from, to int
+	switch blocks[0].blockType {
+	case unOrderedListType, orderedListType:
+		groups := validListItem.FindSubmatchIndex(line)
+		if groups == nil {
+			return
+		}
+		from, to = groups[6], groups[7]
+	case termType, definitionType:
+		groups := validDefinition.FindSubmatchIndex(line)
+		if groups == nil {
+			return
+		}
+		from, to = groups[8], groups[9]
+	case quoteType:
+		groups := validQuote.FindSubmatchIndex(line)
+		if groups == nil {
+			return
+		}
+		from, to = groups[6], groups[7]
+		// the text of a list item in the quote is after its mark.
+		if item := validQuoteListItem.FindSubmatchIndex(line[from:to]); item != nil && blocks[0].listLevel > 0 {
+			from, to = from+item[6], from+item[7]
+		}
+	default:
+		return
+	}
+	if len(blocks) == 2 {
+		// a term and its definition, split like parseDefinition does.
+		if i := bytes.Index(line[from:to], []byte(" : ")); i != -1 {
+			blocks[0].textSpan = trimmedSpan(line, start, from, from+i)
+			blocks[1].textSpan = trimmedSpan(line, start, from+i+3, to)
+		}
+		return
+	}
+	blocks[0].textSpan = trimmedSpan(line, start, from, to)
+}
+
+// trimmedSpan returns the span of line[from:to] without its leading and trailing white space,
+// line starting at offset start.
+func trimmedSpan(line []byte, start, from, to int) Span {
+	text := line[from:to]
+	trimmed := bytes.TrimLeftFunc(text, unicode.IsSpace)
+	from += len(text) - len(trimmed)
+	to = from + len(bytes.TrimRightFunc(trimmed, unicode.IsSpace))
+	return Span{start + from, start + to}
+}
+
+// startsBlock tells whether line ends the paragraph before it: an empty line, a line classify
+// finds a block in or the start of a block plugin. Every kind of block known to classify ends a
+// paragraph without an empty line before it. The line starts at offset in the input.
+func (lc *lineClassifier) startsBlock(line []byte, offset int) bool {
+	return len(bytes.TrimSpace(line)) == 0 || lc.classify(line) != nil || lc.states.startBlockPlugin(line, offset) != nil
 }
 
 // return value is the length of matched part, 0 means not match.
@@ -223,8 +703,16 @@ func bytesEndsWithRegexp(bts []byte, re *regexp.Regexp) int {
 	return 0
 }
 
-func replaceBytesWithMarker(lineBytes []byte, length int, marker []byte) []byte {
-	return append(lineBytes[:len(lineBytes)-length], marker...)
+// maxTagLength bounds the bytes looked at for the tags without attributes, so a long block
+// with many > is not scanned again and again.
+const maxTagLength = 64
+
+// endsWithTag returns the length of the tag matched by re at the end of bts, 0 when there is none.
+func endsWithTag(bts []byte, re *regexp.Regexp) int {
+	if len(bts) > maxTagLength {
+		bts = bts[len(bts)-maxTagLength:]
+	}
+	return bytesEndsWithRegexp(bts, re)
 }
 
 func processContent(states *parserStates, blocks []wholeBlock) {
@@ -237,255 +725,816 @@ func processContent(states *parserStates, blocks []wholeBlock) {
 }
 
 func processLine(states *parserStates, block wholeBlock) {
+	unit := states.parseunit
 	if block.blockType == sectionHeaderType {
-		states.parseunit.Sections = append(states.parseunit.Sections, SectionHeaderContext{
-			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{*states.parseunit}},
+		unit.Sections = append(unit.Sections, &SectionHeaderContext{
+			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: unit, pos: Position{Line: block.line}}, span: block.span},
 			HeaderLevel:      block.headerLevel,
 			HeaderText:       string(block.rawText),
+			tags:             block.tags,
+			tagsFound:        block.tagsFound,
 		})
 	} else if block.blockType == orderedListType || block.blockType == unOrderedListType {
-		if len(states.parseunit.Sections) == 0 {
-			states.parseunit.Sections = append(states.parseunit.Sections, ParaContext{
-				BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{*states.parseunit}},
-				rawText:          string(block.rawText),
-			})
+		processListItem(states, block)
+	} else if block.blockType == termType || block.blockType == definitionType {
+		processDefinition(states, block)
+	} else if block.blockType == pluginType {
+		processPluginBlock(states, block)
+	} else if block.blockType == quoteType {
+		processQuote(states, block)
+	} else {
+		unit.Sections = append(unit.Sections, &ParaContext{
+			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: unit, pos: Position{Line: block.line}}, span: block.span},
+			rawText:          string(block.rawText),
+			tags:             block.tags,
+			tagsFound:        block.tagsFound,
+		})
+	}
+}
+
+// processListItem attaches a list item to the list it belongs to, creating new lists or sub lists as needed.
+func processListItem(states *parserStates, block wholeBlock) {
+	unit := states.parseunit
+	addListItem(unit, &unit.Sections, block, block.blockType == orderedListType)
+}
+
+// addListItem attaches a list item to the list it belongs to in blocks, the blocks of container,
+// the unit or a quote.
+func addListItem(container Context, blocks *[]BlockContext, block wholeBlock, ordered bool) {
+	// find the deepest open list whose level is not deeper than the item, the sub lists of a list
+	// are in its last item.
+	var parent *ListItemContext
+	var current *ListContext
+	if !block.forceNewList && len(*blocks) > 0 {
+		current, _ = (*blocks)[len(*blocks)-1].(*ListContext)
+	}
+	for current != nil && current.Level < block.listLevel {
+		item := lastListItem(current)
+		if item == nil || len(item.InnerContexts) == 0 {
+			break
+		}
+		subList, isList := item.InnerContexts[len(item.InnerContexts)-1].(*ListContext)
+		if !isList || subList.Level > block.listLevel {
+			break
+		}
+		parent, current = item, subList
+	}
+
+	newList := func(p Context) *ListContext {
+		return &ListContext{
+			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: p, pos: Position{Line: block.line}}, span: block.span},
+			Level:            block.listLevel,
+			Ordered:          ordered,
+		}
+	}
+
+	if current == nil || current.Level > block.listLevel || lastListItem(current) == nil && current.Level < block.listLevel {
+		current = newList(container)
+		*blocks = append(*blocks, current)
+	} else if current.Level < block.listLevel {
+		item := lastListItem(current)
+		subList := newList(item)
+		item.InnerContexts = append(item.InnerContexts, subList)
+		current = subList
+	} else if current.Ordered != ordered {
+		// same level but a different kind of list, start a sibling list.
+		if parent == nil {
+			current = newList(container)
+			*blocks = append(*blocks, current)
 		} else {
-			var currentBlock = states.parseunit.Sections[len(states.parseunit.Sections)-1]
-			goDeeper := true
-			createTopLevelList := false
+			current = newList(parent)
+			parent.InnerContexts = append(parent.InnerContexts, current)
+		}
+	}
 
-			if block.forceNewList {
-				goDeeper = false
-				createTopLevelList = true
+	item := &ListItemContext{
+		BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: current, pos: Position{Line: block.line}}, span: block.span},
+		RawIndent:        block.listIndent,
+		Depth:            block.listLevel,
+	}
+	item.InnerContexts = []BlockContext{&ParaContext{
+		BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: item, pos: Position{Line: block.line}}, span: block.textSpan},
+		rawText:          string(block.rawText),
+		tags:             block.tags,
+		tagsFound:        block.tagsFound,
+	}}
+	current.InnerContexts = append(current.InnerContexts, item)
+	extendListSpans(current, block.span.End)
+}
+
+// lastListItem returns the last item of list, nil when there is no list or it has no item, like
+// a list built by hand.
+func lastListItem(list *ListContext) *ListItemContext {
+	if list == nil {
+		return nil
+	}
+	if n := len(list.InnerContexts); n > 0 {
+		item, _ := list.InnerContexts[n-1].(*ListItemContext)
+		return item
+	}
+	return nil
+}
+
+// extendListSpans makes list, the items it is in and their lists end at end, with the block
+// that was added to list.
+func extendListSpans(list *ListContext, end int) {
+	for list != nil {
+		list.span.End = end
+		item, _ := list.parent.(*ListItemContext)
+		if item == nil {
+			return
+		}
+		item.span.End = end
+		list, _ = item.parent.(*ListContext)
+	}
+}
+
+// processQuote adds a line of a quote to the quote it belongs to, the last block unless an empty
+// line came before. A line indented like the items of the list of the last block is in that list,
+// after the last item of the deepest list it is indented in, its depth is half the width of its
+// indentation rounded down like the one of an item, so "   > text" is in "   * item".
+func processQuote(states *parserStates, block wholeBlock) {
+	unit := states.parseunit
+	newQuote := func(p Context, level int) *QuoteContext {
+		return &QuoteContext{
+			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: p, pos: Position{Line: block.line}}, span: block.span},
+			Level:            level,
+		}
+	}
+	var last BlockContext
+	if !block.forceNewList && len(unit.Sections) > 0 {
+		last = unit.Sections[len(unit.Sections)-1]
+	}
+
+	var quote *QuoteContext
+	list, _ := last.(*ListContext)
+	if item := lastListItem(list); item != nil && block.quoteIndent >= 2 {
+		// the deepest item the quote is indented in.
+		for len(item.InnerContexts) > 0 {
+			subList, isList := item.InnerContexts[len(item.InnerContexts)-1].(*ListContext)
+			if !isList || subList.Level > block.quoteIndent/2 || lastListItem(subList) == nil {
+				break
 			}
+			list, item = subList, lastListItem(subList)
+		}
+		if n := len(item.InnerContexts); n > 0 {
+			quote, _ = item.InnerContexts[n-1].(*QuoteContext)
+		}
+		if quote == nil {
+			quote = newQuote(item, 1)
+			item.InnerContexts = append(item.InnerContexts, quote)
+		}
+		item.span.End = block.span.End
+		extendListSpans(list, block.span.End)
+	} else if quote, _ = last.(*QuoteContext); quote == nil {
+		quote = newQuote(unit, 1)
+		unit.Sections = append(unit.Sections, quote)
+	}
+	quote.span.End = block.span.End
 
-			for goDeeper {
-				goDeeper = false
-				if listBlock, isListBlock := currentBlock.(ListContext); isListBlock {
-					if listBlock.Level < block.listLevel {
-						if len(listBlock.InnerContexts) > 0 {
-							nextLevelBlock := listBlock.InnerContexts[len(listBlock.InnerContexts)-1]
-							if _, isNextLevelBlockList := nextLevelBlock.(ListContext); isNextLevelBlockList {
-								currentBlock = nextLevelBlock
-								goDeeper = true
-							} else {
-								// create a new sub list
-								listBlock.InnerContexts = append(listBlock.InnerContexts, ListContext{
-									BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{listBlock}},
-									Level:            block.listLevel,
-									Ordered:          block.blockType == orderedListType,
-								})
-								justAddedListContext := listBlock.InnerContexts[len(listBlock.InnerContexts)-1].(ListContext)
-								justAddedListContext.InnerContexts = append(justAddedListContext.InnerContexts, ParaContext{
-									BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{listBlock.InnerContexts[len(listBlock.InnerContexts)-1]}},
-									rawText:          string(block.rawText),
-								})
-							}
-						} else {
-							// THIS SHOULD NEVER HAPPEN
-							panic("PANIC!")
-						}
-					} else if listBlock.Level == block.listLevel {
-						if listBlock.Ordered == (block.blockType == orderedListType) {
-							listBlock.InnerContexts = append(listBlock.InnerContexts, ParaContext{
-								BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{listBlock}},
-								rawText:          string(block.rawText),
-							})
-						} else {
-							createTopLevelList = true
-						}
-					} else {
-						createTopLevelList = true
-					}
-				} else {
-					createTopLevelList = true
-				}
+	// the quotes of the deeper levels are in the last block of the level above.
+	for quote.Level < block.quoteLevel {
+		var inner *QuoteContext
+		if n := len(quote.InnerContexts); n > 0 {
+			inner, _ = quote.InnerContexts[n-1].(*QuoteContext)
+		}
+		if inner == nil {
+			inner = newQuote(quote, quote.Level+1)
+			quote.InnerContexts = append(quote.InnerContexts, inner)
+		}
+		quote = inner
+		quote.span.End = block.span.End
+	}
+
+	if block.listLevel > 0 {
+		addListItem(quote, &quote.InnerContexts, block, block.ordered)
+		return
+	}
+	if n := len(quote.InnerContexts); n > 0 {
+		if para, ok := quote.InnerContexts[n-1].(*ParaContext); ok {
+			// the lines of a level are one paragraph, joined like the lines of a paragraph, a line
+			// with only the > adds nothing.
+			if para.rawText != "" && len(block.rawText) > 0 {
+				para.rawText += string(states.options.lineJoiner([]byte(para.rawText), block.rawText))
 			}
-			if createTopLevelList {
-				states.parseunit.Sections = append(states.parseunit.Sections, ListContext{
-					BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{*states.parseunit}},
-					Level:            block.listLevel,
-					Ordered:          block.blockType == orderedListType,
-				})
-				justAddedListContext := states.parseunit.Sections[len(states.parseunit.Sections)-1].(ListContext)
-				justAddedListContext.InnerContexts = append(justAddedListContext.InnerContexts, ParaContext{
-					BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{states.parseunit.Sections[len(states.parseunit.Sections)-1]}},
-					rawText:          string(block.rawText),
-				})
+			para.tags = append(para.tags, shiftTags(block.tags, len(para.rawText))...)
+			para.tagsFound = para.tagsFound && block.tagsFound
+			para.rawText += string(block.rawText)
+			switch {
+			case len(block.rawText) == 0:
+				// the span still ends at the last byte of the paragraph.
+			case para.span.End != 0 && block.textSpan.End != 0:
+				para.span.End = block.textSpan.End
+			default:
+				para.span = Span{}
 			}
+			return
+		}
+	}
+	quote.InnerContexts = append(quote.InnerContexts, &ParaContext{
+		BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: quote, pos: Position{Line: block.line}}, span: block.textSpan},
+		rawText:          string(block.rawText),
+		tags:             block.tags,
+		tagsFound:        block.tagsFound,
+	})
+}
+
+// processDefinition adds a term or a definition to the definition list it belongs to, the
+// last block unless an empty line came before.
+func processDefinition(states *parserStates, block wholeBlock) {
+	unit := states.parseunit
+	var list *DefinitionListContext
+	if !block.forceNewList && len(unit.Sections) > 0 {
+		list, _ = unit.Sections[len(unit.Sections)-1].(*DefinitionListContext)
+	}
+	if list == nil {
+		list = &DefinitionListContext{
+			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: unit, pos: Position{Line: block.line}}, span: block.span},
 		}
+		unit.Sections = append(unit.Sections, list)
+	}
+	list.span.End = block.span.End
+	para := &ParaContext{
+		BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{parent: list, pos: Position{Line: block.line}}, span: block.textSpan},
+		rawText:          string(block.rawText),
+		tags:             block.tags,
+		tagsFound:        block.tagsFound,
+	}
+	if block.blockType == termType || len(list.Entries) == 0 {
+		list.Entries = append(list.Entries, DefinitionEntry{})
+	}
+	entry := &list.Entries[len(list.Entries)-1]
+	if block.blockType == termType {
+		entry.Term = para
 	} else {
-		states.parseunit.Sections = append(states.parseunit.Sections, ParaContext{
-			BaseBlockContext: BaseBlockContext{BaseContext: BaseContext{*states.parseunit}},
-			rawText:          string(block.rawText),
-		})
+		entry.Definitions = append(entry.Definitions, para)
 	}
 }
 
 func walkAST(states *parserStates) {
+	parseInlines(states, states.parseunit.Sections)
 }
 
-//TODO: http in ordinary text,
-//TODO: add offset
-func parsePara(c *ParaContext) {
-	rawTextBytes := []byte(c.rawText)
+// parseInlines parses the inline elements of every paragraph in blocks, including the ones in lists,
+// and the text of the headings.
+// Paragraphs are independent of each other, so with parallelism > 1 they are
+// spread over a pool of goroutines, each one only ever touches its own paragraph. Their
+// diagnostics are added to the unit afterwards, in the order of the paragraphs.
+func parseInlines(states *parserStates, blocks []BlockContext) {
+	paras := make([]*ParaContext, 0)
+	// headers are the headings by their index in paras, where they have a nil paragraph.
+	headers := make(map[int]*SectionHeaderContext)
+	var collect func(blocks []BlockContext)
+	collect = func(blocks []BlockContext) {
+		for _, block := range blocks {
+			switch b := block.(type) {
+			case *SectionHeaderContext:
+				headers[len(paras)] = b
+				paras = append(paras, nil)
+			case *ParaContext:
+				paras = append(paras, b)
+			case *ListContext:
+				collect(b.InnerContexts)
+			case *ListItemContext:
+				collect(b.InnerContexts)
+			case *QuoteContext:
+				collect(b.InnerContexts)
+			case *DefinitionListContext:
+				for _, child := range children(b) {
+					paras = append(paras, child.(*ParaContext))
+				}
+			}
+		}
+	}
+	collect(blocks)
+
+	diagnostics := make([][]Diagnostic, len(paras))
+	defer func() {
+		for _, paraDiagnostics := range diagnostics {
+			states.parseunit.Diagnostics = append(states.parseunit.Diagnostics, paraDiagnostics...)
+		}
+	}()
+
+	parse := func(i int) []Diagnostic {
+		if states.cancelled() {
+			return nil
+		}
+		if header := headers[i]; header != nil {
+			return parseHeading(states, header)
+		}
+		return parsePara(states, paras[i])
+	}
+
+	workers := states.options.Parallelism
+	if workers > len(paras) {
+		workers = len(paras)
+	}
+	if workers <= 1 {
+		for i := range paras {
+			diagnostics[i] = parse(i)
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				diagnostics[i] = parse(i)
+			}
+		}()
+	}
+	for i := range paras {
+		if states.cancelled() {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// parsePara parses the inline elements of paragraph c and returns its diagnostics.
+func parsePara(states *parserStates, c *ParaContext) []Diagnostic {
+	buffers := states.scratch.getParaBuffers()
+	defer states.scratch.putParaBuffers(buffers)
+	rawTextBytes := append(buffers.text[:0], c.rawText...)
+	defer func() { buffers.text = rawTextBytes[:0] }()
+	// tags are the protected tags of the paragraph, their content is skipped at once.
+	tags := c.tagRegions(states.options.DisableEmbeds)
+	plugins := states.inlinePlugins()
+	effects := states.effects
 
 	var currentEffect uint32 = 0
-	effectBytes := make([]byte, 0)
+	effectBytes := buffers.effect[:0]
+	defer func() { buffers.effect = effectBytes[:0] }()
 	offset := 0
 
+	// opened are the markers of the effects that are on, by effect, with their offset.
+	type openedEffect struct {
+		marker string
+		offset int
+	}
+	opened := make(map[uint32]openedEffect)
+	var diagnostics []Diagnostic
+
+	// toggleEffect handles the doubled effect markers like ** and //, and the registered ones.
+	toggleEffect := func(effect uint32, length int) {
+		endCurrentEffect(c, &effectBytes, currentEffect)
+		currentEffect ^= effect
+		if currentEffect&effect != 0 {
+			opened[effect] = openedEffect{string(rawTextBytes[offset : offset+length]), offset}
+		} else {
+			delete(opened, effect)
+		}
+		offset += length
+	}
+
+	// toggleRun handles a run of the doubled markers of effect, like *** or ////, see splitMarkerRun.
+	toggleRun := func(effect uint32) {
+		n := markerRun(rawTextBytes, offset)
+		before, text, after := splitMarkerRun(currentEffect&effect != 0, n)
+		if n == 4 {
+			offset += n
+			return
+		}
+		if before {
+			toggleEffect(effect, 2)
+		}
+		effectBytes = append(effectBytes, rawTextBytes[offset:offset+text]...)
+		offset += text
+		if after {
+			toggleEffect(effect, 2)
+		}
+	}
+
+	// endEffects ends the effects that are on where a tag or the paragraph ends them,
+	// they are reported in the order they were opened.
+	endEffects := func() {
+		endCurrentEffect(c, &effectBytes, currentEffect)
+		currentEffect = 0
+		if len(opened) == 0 {
+			return
+		}
+		unclosed := make([]openedEffect, 0, len(opened))
+		for _, effect := range opened {
+			unclosed = append(unclosed, effect)
+		}
+		sort.Slice(unclosed, func(i, j int) bool { return unclosed[i].offset < unclosed[j].offset })
+		for _, effect := range unclosed {
+			diagnostics = append(diagnostics, newDiagnostic(CodeUnclosedFormatting, c.GetPosition().Line,
+				"unclosed %s at offset %d of the paragraph", effect.marker, states.labelOffset+effect.offset))
+		}
+		clear(opened)
+	}
+
 	for offset < len(rawTextBytes) {
 		ch := rawTextBytes[offset]
-		switch ch {
-		case 0x00:
-			//This is the beginning or end of a tag.
-			nextByte := rawTextBytes[offset+1]
-			if nextByte == 1 || nextByte == 3 || nextByte == 5 || nextByte == 7 || nextByte == 9 {
-				endCurrentEffect(c, &effectBytes, currentEffect)
-				currentEffect = 0
-			}
-
-			switch nextByte {
-			case 1:
-				if i := bytes.Index(rawTextBytes[offset:], endOfCodeTag); i != -1 {
-					c.InnerContexts = append(c.InnerContexts, CodeFileContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						Text:              string(rawTextBytes[offset+2 : offset+i]),
-					})
-				} else {
-					panic("no endOfCode tag found!")
-				}
-			case 3:
-				if i := bytes.Index(rawTextBytes[offset:], endOfFileTag); i != -1 {
-					c.InnerContexts = append(c.InnerContexts, CodeFileContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						Text:              string(rawTextBytes[offset+2 : offset+i]),
-					})
-				} else {
-					panic("no endOfFile tag found!")
-				}
-			case 5:
-				if i := bytes.Index(rawTextBytes[offset:], endOfHTMLTag); i != -1 {
-					c.InnerContexts = append(c.InnerContexts, HTMLContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						Text:              string(rawTextBytes[offset+2 : offset+i]),
-					})
-				} else {
-					panic("no endOfHTML tag found!")
-				}
-			case 7:
-				if i := bytes.Index(rawTextBytes[offset:], endOfhtmlTag); i != -1 {
-					c.InnerContexts = append(c.InnerContexts, HTMLContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						Text:              string(rawTextBytes[offset+2 : offset+i]),
-					})
-				} else {
-					panic("no endOfhtml tag found!")
-				}
-			case 9:
-				if i := bytes.Index(rawTextBytes[offset:], endOfNoWikiTag); i != -1 {
-					c.InnerContexts = append(c.InnerContexts, NoWikiContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						Text:              string(rawTextBytes[offset+2 : offset+i]),
-					})
+		var next byte
+		if offset+1 < len(rawTextBytes) {
+			next = rawTextBytes[offset+1]
+		}
+		// a link or media may end in a tag, the tags it went over are left behind.
+		for len(tags) > 0 && tags[0].start < offset {
+			tags = tags[1:]
+		}
+		nextTag := len(rawTextBytes)
+		if len(tags) > 0 {
+			nextTag = tags[0].start
+		}
+		if offset == nextTag {
+			// a tag ends the effects, an unterminated one takes the rest of the paragraph.
+			endEffects()
+			tag, base := tags[0], inlineBase(c)
+			text := string(rawTextBytes[tag.contentStart:tag.contentEnd])
+			switch tag.kind {
+			case codeTag, fileTag:
+				code := parseCodeFile(base, tag.kind == fileTag, tag.attributes, text)
+				if states.options.KeepCodeNewline {
+					code.newlineKept = true
 				} else {
-					panic("no endOfNoWiki tag found!")
+					code.Text = trimCodeNewline(code.Text)
 				}
+				c.InnerContexts = append(c.InnerContexts, code)
+			case htmlBlockTag, htmlTag:
+				c.InnerContexts = append(c.InnerContexts, &HTMLContext{BaseInlineContext: base, Text: text, IsBlock: tag.kind == htmlBlockTag})
+			case noWikiTag:
+				c.InnerContexts = append(c.InnerContexts, &NoWikiContext{BaseInlineContext: base, Text: text})
 			}
-		case '`':
-			if rawTextBytes[offset+1] == '`' {
-				endCurrentEffect(c, &effectBytes, currentEffect)
-				if (currentEffect & TextEffectMonoSpace) > 0 {
-					currentEffect ^= TextEffectMonoSpace
-				} else {
-					currentEffect |= TextEffectMonoSpace
-				}
-			} else {
+			offset = tag.end
+			continue
+		}
+		// plugins come first, but never see the content of protected tags.
+		if inline, n := matchInline(plugins, c, rawTextBytes[offset:]); n > 0 {
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			c.InnerContexts = append(c.InnerContexts, inline)
+			offset += n
+			continue
+		}
+		// a registered effect wins over a built in one if its delimiter is longer, links and media come first.
+		if effect, ok := matchEffect(effects, rawTextBytes[offset:]); ok && !((ch == '[' && next == '[') || (ch == '{' && next == '{')) &&
+			(len(effect.delimiter) > 2 || !isBuiltinEffect(rawTextBytes, offset)) {
+			toggleEffect(uint32(effect.bit), len(effect.delimiter))
+			continue
+		}
+		switch {
+		case ch == '\n' && states.options.PreserveLineBreaks:
+			// the new lines outside of the protected tags are the ones joining the lines.
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			c.InnerContexts = append(c.InnerContexts, &LineBreakContext{BaseInlineContext: inlineBase(c)})
+			offset++
+		case ch == '`' && next == '`':
+			toggleRun(TextEffectMonoSpace)
+		case ch == '_' && next == '_':
+			toggleRun(TextEffectUnderline)
+		case ch == '/' && next == '/' && offset > 0 && rawTextBytes[offset-1] == ':':
+			// the // in a URL like http://example.com is not italic, nor are the others of the
+			// run in file:////server or C:////dir.
+			n := markerRun(rawTextBytes, offset)
+			effectBytes = append(effectBytes, rawTextBytes[offset:offset+n]...)
+			offset += n
+		case ch == '/' && next == '/':
+			toggleRun(TextEffectItalic)
+		case ch == '*' && next == '*':
+			toggleRun(TextEffectBold)
+		case ch == '<' && states.options.UnknownTag != nil && unknownTagLength(rawTextBytes[offset:]) > 0:
+			n := unknownTagLength(rawTextBytes[offset:])
+			name := string(validUnknownTag.FindSubmatch(rawTextBytes[offset:])[1])
+			inline := states.options.UnknownTag(name, string(rawTextBytes[offset:offset+n]))
+			if inline == nil {
 				effectBytes = append(effectBytes, ch)
+				offset++
+				break
 			}
-		case '_':
-			if rawTextBytes[offset+1] == '_' {
+			if inline != Strip {
 				endCurrentEffect(c, &effectBytes, currentEffect)
-				if (currentEffect & TextEffectUnderline) > 0 {
-					currentEffect ^= TextEffectUnderline
-				} else {
-					currentEffect |= TextEffectUnderline
-				}
-			} else {
-				effectBytes = append(effectBytes, ch)
+				c.InnerContexts = append(c.InnerContexts, attachInline(inline, c))
 			}
-		case '/':
-			if rawTextBytes[offset+1] == '/' {
+			offset += n
+		case ch == '~' && next == '~' && states.options.UnknownMacro != nil && isUnknownMacro(rawTextBytes[offset:]):
+			groups := validUnknownMacro.FindSubmatch(rawTextBytes[offset:])
+			inline := states.options.UnknownMacro(string(groups[1]), string(groups[0]))
+			if inline == nil {
+				effectBytes = append(effectBytes, groups[0]...)
+			} else if inline != Strip {
 				endCurrentEffect(c, &effectBytes, currentEffect)
-				if (currentEffect & TextEffectItalic) > 0 {
-					currentEffect ^= TextEffectItalic
-				} else {
-					currentEffect |= TextEffectItalic
-				}
-			} else {
-				effectBytes = append(effectBytes, ch)
+				c.InnerContexts = append(c.InnerContexts, attachInline(inline, c))
 			}
-		case '*':
-			if rawTextBytes[offset+1] == '*' {
-				endCurrentEffect(c, &effectBytes, currentEffect)
-				if (currentEffect & TextEffectBold) > 0 {
-					currentEffect ^= TextEffectBold
-				} else {
-					currentEffect |= TextEffectBold
-				}
-			} else {
-				effectBytes = append(effectBytes, ch)
+			offset += len(groups[0])
+		case ch == '~' && next == '~' && validMacro.Match(rawTextBytes[offset:]):
+			groups := validMacro.FindSubmatch(rawTextBytes[offset:])
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			name, args, _ := strings.Cut(string(groups[1]), ":")
+			c.InnerContexts = append(c.InnerContexts, &MacroContext{BaseInlineContext: inlineBase(c), Name: name, Args: args})
+			offset += len(groups[0])
+		case ch == '%' && next == '%' && unformattedLength(rawTextBytes[offset:nextTag]) > 0:
+			// %%text%% is not formatted, like a nowiki tag, the effects go on after it.
+			n := unformattedLength(rawTextBytes[offset:nextTag])
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			c.InnerContexts = append(c.InnerContexts, &NoWikiContext{BaseInlineContext: inlineBase(c), Text: string(rawTextBytes[offset+2 : offset+n-2]), EffectType: currentEffect})
+			offset += n
+		case ch == '[' && next == '[' && !states.noLinks && bytes.Index(rawTextBytes[offset:], []byte{']', ']'}) != -1:
+			// start of a link.
+			// the effects go on over links and media.
+			i := bytes.Index(rawTextBytes[offset:], []byte{']', ']'})
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			link, label := parseLink(c, rawTextBytes[offset+2:offset+i], tagsBetween(tags, offset+2, offset+i), states.options.ClassifyLink)
+			link.EffectType = currentEffect
+			if link.Image != nil {
+				states.resolveMedia(link.Image)
 			}
-		case '[':
-			if rawTextBytes[offset+1] == '[' {
-				// start of a link.
-				if i := bytes.Index(rawTextBytes[offset:], []byte{']', ']'}); i != -1 {
-					endCurrentEffect(c, &effectBytes, currentEffect)
-					currentEffect = 0
-					parseLink(c, rawTextBytes[offset+2:offset+i])
-					offset += (i + 2)
-				}
+			if label != nil && link.Image == nil {
+				var labelDiagnostics []Diagnostic
+				labelOffset := offset + i - len(label)
+				link.InnerContexts, labelDiagnostics = parseLinkLabel(states, link, label, labelOffset, tagsBetween(tags, labelOffset, offset+i))
+				diagnostics = append(diagnostics, labelDiagnostics...)
 			}
-		case '{':
-			if rawTextBytes[offset+1] == '{' {
-				// start of a media file.
-				if i := bytes.Index(rawTextBytes[offset:], []byte{'}', '}'}); i != -1 {
-					endCurrentEffect(c, &effectBytes, currentEffect)
-					currentEffect = 0
-					parseMedia(c, rawTextBytes[offset+2:offset+i])
-					offset += (i + 2)
-				}
+			offset += (i + 2)
+		case ch == '{' && next == '{' && !states.noLinks && bytes.Index(rawTextBytes[offset:], []byte{'}', '}'}) != -1:
+			// start of a media file.
+			i := bytes.Index(rawTextBytes[offset:], []byte{'}', '}'})
+			endCurrentEffect(c, &effectBytes, currentEffect)
+			if name, ok := bytes.CutPrefix(rawTextBytes[offset+2:offset+i], []byte("anchor:")); ok && states.options.Anchors {
+				c.InnerContexts = append(c.InnerContexts, &AnchorContext{BaseInlineContext: inlineBase(c), Name: string(bytes.TrimSpace(name))})
+				offset += i + 2
+				break
 			}
+			media := newMedia(c, rawTextBytes[offset+2:offset+i])
+			media.EffectType = currentEffect
+			states.resolveMedia(media)
+			c.InnerContexts = append(c.InnerContexts, media)
+			offset += (i + 2)
+		case !states.noLinks && (ch == '[' || ch == '{' || ch == ']' || ch == '}') && next == ch:
+			// a [[ or {{ not closed in the paragraph, or a ]] or }} closing nothing, is text and the
+			// scanning goes on after it.
+			diagnostics = append(diagnostics, newDiagnostic(CodeUnmatchedBrackets, c.GetPosition().Line,
+				"unmatched %s at offset %d of the paragraph", rawTextBytes[offset:offset+2], states.labelOffset+offset))
+			effectBytes = append(effectBytes, ch, next)
+			offset += 2
 		default:
 			effectBytes = append(effectBytes, ch)
 			offset += 1
 		}
 	}
+	endEffects()
 
 	//fixup for links.
-	fixupLinks(c)
+	if validURL := autolinkRegexp(states.options.AutolinkSchemes, !states.options.NoWWWAutolink); validURL != nil && !states.options.NoAutolink {
+		fixupLinks(c, validURL)
+	}
+	if !states.options.NoNormalize {
+		c.InnerContexts = NormalizeInlines(c.InnerContexts)
+	}
+	diagnostics = append(diagnostics, invalidMediaSizes(c)...)
+	return append(diagnostics, invalidURLs(c)...)
 }
 
-func parseLink(c *ParaContext, linkBytes []byte) {
-	if i := bytes.IndexByte(linkBytes, '|'); i != -1 {
-		c.InnerContexts = append(c.InnerContexts, HyperLinkContext{
-			BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-			Text:              string(linkBytes[i+1:]),
-			HyperLink:         string(linkBytes[:i]),
-		})
-	} else {
-		// internal link
-		c.InnerContexts = append(c.InnerContexts, HyperLinkContext{
-			BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-			Text:              string(linkBytes),
-			IsInternal:        true,
-		})
+// invalidMediaSizes returns a diagnostic for every parameter of a media of c that is neither
+// a size nor a keyword, the media then has no size from it.
+func invalidMediaSizes(c *ParaContext) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, inline := range c.InnerContexts {
+		media, ok := inline.(*MediaContext)
+		if link, isLink := inline.(*HyperLinkContext); isLink {
+			media, ok = link.Image, link.Image != nil
+		}
+		if !ok {
+			continue
+		}
+		for _, size := range media.invalidSizes {
+			diagnostics = append(diagnostics, newDiagnostic(CodeInvalidMediaSize, c.GetPosition().Line,
+				"invalid size %q of media %s", size, media.MediaResouce))
+		}
+	}
+	return diagnostics
+}
+
+// invalidURLs returns a diagnostic for every external link of c whose URL net/url does not
+// take, the renderers then leave its href out.
+func invalidURLs(c *ParaContext) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, inline := range c.InnerContexts {
+		link, ok := inline.(*HyperLinkContext)
+		if !ok || link.Kind != LinkExternal {
+			continue
+		}
+		if _, err := url.Parse(link.HyperLink); err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(CodeInvalidURL, c.GetPosition().Line,
+				"invalid URL %q: %v", link.HyperLink, errors.Unwrap(err)))
+		}
+	}
+	return diagnostics
+}
+
+// unformattedLength returns the length of the %%text%% starting text, 0 when the %% is not
+// closed in it. text ends before the next protected tag.
+func unformattedLength(text []byte) int {
+	end := bytes.Index(text[2:], []byte("%%"))
+	if end == -1 {
+		return 0
+	}
+	return end + 4
+}
+
+// markerRun returns the number of times the byte at offset of text is repeated from there.
+func markerRun(text []byte, offset int) int {
+	n := 1
+	for offset+n < len(text) && text[offset+n] == text[offset] {
+		n++
+	}
+	return n
+}
+
+// splitMarkerRun tells what a run of n characters of a doubled effect marker, like the * of
+// **, is made of when the effect is on or not: the effect is toggled before the text of the
+// run, toggled after it, or both. A pair is markup and the other characters are text. The
+// first pair opens the effect and, like DokuWiki, the first pair closes it, so *** closing it
+// leaves one * of text after. A run of 4 is an empty effect, or one closed and opened again,
+// it leaves nothing and the effect as it was.
+func splitMarkerRun(on bool, n int) (before bool, text int, after bool) {
+	switch {
+	case n < 3:
+		return true, 0, false
+	case n == 4:
+		return false, 0, false
+	case on:
+		return true, n - 2, false
+	case n == 3:
+		return true, 1, false
 	}
+	return true, n - 4, true
 }
 
-func parseMedia(c *ParaContext, mediaBytes []byte) {
-	mc := MediaContext{
-		BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
+// isBuiltinEffect tells whether a built in effect marker is at offset of text.
+func isBuiltinEffect(text []byte, offset int) bool {
+	if offset+1 >= len(text) || text[offset] != text[offset+1] {
+		return false
+	}
+	switch text[offset] {
+	case '`', '_', '*':
+		return true
+	case '/':
+		return offset == 0 || text[offset-1] != ':'
+	}
+	return false
+}
+
+// parseCodeFile builds a CodeFileContext from the attributes of a code or file start tag, what
+// follows its name, and the text between the tags.
+func parseCodeFile(base BaseInlineContext, isFile bool, attributes, text string) *CodeFileContext {
+	cf := &CodeFileContext{BaseInlineContext: base, IsFile: isFile, Text: text}
+	// the attributes in brackets, like [enable_line_numbers="true"], end the tag.
+	if open := strings.IndexByte(attributes, '['); open != -1 {
+		if close := strings.IndexByte(attributes[open:], ']'); close != -1 {
+			cf.Attributes = parseCodeAttributes(attributes[open+1 : open+close])
+			attributes = attributes[:open]
+		}
+	}
+	fields := strings.Fields(attributes)
+	if len(fields) > 0 {
+		cf.Language = fields[0]
+	}
+	if isFile && len(fields) > 1 {
+		cf.FileName = strings.Join(fields[1:], " ")
+	}
+	return cf
+}
+
+// trimCodeNewline removes the new line right after the start tag of a code or file tag, which
+// DokuWiki does not take as content. Only one is removed, the rest is kept byte for byte.
+func trimCodeNewline(text string) string {
+	if strings.HasPrefix(text, "\r\n") {
+		return text[2:]
+	}
+	return strings.TrimPrefix(text, "\n")
+}
+
+// parseCodeAttributes parses the key="value" pairs of the attributes of a code tag, nil when
+// there is none.
+func parseCodeAttributes(text string) map[string]string {
+	var attributes map[string]string
+	for _, groups := range validCodeAttribute.FindAllStringSubmatch(text, -1) {
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		attributes[groups[1]] = groups[2]
+	}
+	return attributes
+}
+
+// inlineBase is the base of an inline context found in paragraph c.
+func inlineBase(c *ParaContext) BaseInlineContext {
+	return BaseInlineContext{BaseContext{parent: c, pos: c.pos}}
+}
+
+// parseLink parses the content of a [[link]] found in paragraph c, tags are the protected tags
+// in it and classify is the ClassifyLink option, both can be nil. It returns the link and its
+// label, nil when it has none.
+func parseLink(c *ParaContext, linkBytes []byte, tags []tagRegion, classify func(target string) (LinkKind, string, bool)) (*HyperLinkContext, []byte) {
+	target, text, label := linkBytes, linkBytes, []byte(nil)
+	if i := linkSeparator(linkBytes, tags); i != -1 {
+		target, text = linkBytes[:i], linkBytes[i+1:]
+		label = text
+	}
+	hyperLink := strings.TrimSpace(string(target))
+	kind, rewritten, handled := LinkInternal, "", false
+	if classify != nil {
+		kind, rewritten, handled = classify(hyperLink)
+	}
+	if !handled {
+		kind = classifyLink(hyperLink)
+	} else if rewritten != "" {
+		hyperLink = rewritten
+	}
+	link := &HyperLinkContext{
+		BaseInlineContext: inlineBase(c),
+		Text:              string(text),
+		Kind:              kind,
+		IsInternal:        kind == LinkInternal,
+	}
+	// an invalid URL is reported by parsePara, with the autolinks.
+	link.SetTarget(hyperLink)
+	if label := bytes.TrimSpace(text); len(label) > 4 && bytes.HasPrefix(label, []byte("{{")) && bytes.HasSuffix(label, []byte("}}")) {
+		link.Image = newMedia(c, label[2:len(label)-2])
+		link.Image.SetParentContext(link)
+	}
+	c.InnerContexts = append(c.InnerContexts, link)
+	return link, label
+}
+
+// linkSeparator returns the offset of the | between the target and the label of a link, -1 when
+// there is none. The | in a nowiki tag or between %% is not one, everything after the first
+// one is the label, other | included.
+func linkSeparator(linkBytes []byte, tags []tagRegion) int {
+	for i := 0; i < len(linkBytes); i++ {
+		for len(tags) > 0 && tags[0].start < i {
+			tags = tags[1:]
+		}
+		switch {
+		case len(tags) > 0 && tags[0].start == i:
+			// a protected tag, an unterminated one takes the rest.
+			i = tags[0].end - 1
+		case linkBytes[i] == '|':
+			return i
+		case bytes.HasPrefix(linkBytes[i:], []byte("%%")):
+			if end := bytes.Index(linkBytes[i+2:], []byte("%%")); end != -1 {
+				i += end + 3
+			}
+		}
+	}
+	return -1
+}
+
+// parseLinkLabel parses the label of link like the text of a paragraph, a URL in it is not
+// linked again. offset is where the label is in the paragraph, tags its protected tags. It
+// returns the inline contexts of the label and its diagnostics.
+func parseLinkLabel(states *parserStates, link *HyperLinkContext, label []byte, offset int, tags []tagRegion) ([]InlineContext, []Diagnostic) {
+	labelStates := *states
+	labelStates.options.NoAutolink = true
+	labelStates.labelOffset = offset
+	para := &ParaContext{rawText: string(label), tags: tags, tagsFound: true}
+	para.pos = link.pos
+	diagnostics := parsePara(&labelStates, para)
+	for _, inline := range para.InnerContexts {
+		inline.SetParentContext(link)
+	}
+	return para.InnerContexts, diagnostics
+}
+
+// parseHeading parses the text of header, links and media are not found in headings.
+func parseHeading(states *parserStates, header *SectionHeaderContext) []Diagnostic {
+	headingStates := *states
+	headingStates.options.NoAutolink = true
+	headingStates.noLinks = true
+	para := &ParaContext{rawText: header.HeaderText, tags: header.tags, tagsFound: header.tagsFound}
+	para.pos = header.pos
+	diagnostics := parsePara(&headingStates, para)
+	for _, inline := range para.InnerContexts {
+		inline.SetParentContext(header)
+	}
+	header.InnerContexts = para.InnerContexts
+	// the tags are only needed to parse the text.
+	header.tags, header.tagsFound = nil, false
+	if header.InnerContexts == nil {
+		header.InnerContexts = []InlineContext{}
+	}
+	return diagnostics
+}
+
+// resolveMedia sets the resolved ID of media when the page ID is known.
+func (states *parserStates) resolveMedia(media *MediaContext) {
+	if states.options.PageID != "" && !media.IsExternal {
+		media.ResolvedID = ResolvePageID(states.options.PageID, media.MediaResouce)
+	}
+}
+
+// newMedia parses the content of a {{media}} tag found in paragraph c.
+func newMedia(c *ParaContext, mediaBytes []byte) *MediaContext {
+	mc := &MediaContext{
+		BaseInlineContext: inlineBase(c),
 	}
 
 	bytesLeft := mediaBytes
@@ -494,7 +1543,9 @@ func parseMedia(c *ParaContext, mediaBytes []byte) {
 		bytesLeft = mediaBytes[:i]
 	}
 
-	if bytesLeft[0] == ' ' {
+	if len(bytesLeft) == 0 {
+		mc.Align = AlignCenter
+	} else if bytesLeft[0] == ' ' {
 		mc.Align = AlignLeft
 		bytesLeft = bytesLeft[1:]
 	} else if bytesLeft[len(bytesLeft)-1] == ' ' {
@@ -504,19 +1555,82 @@ func parseMedia(c *ParaContext, mediaBytes []byte) {
 		mc.Align = AlignCenter
 	}
 
-	groups := validMedia.FindSubmatch(bytesLeft)
-	if groups != nil && len(groups[2]) > 0 {
-		dimentions := groups[2][1 : len(groups[2])-1]
-		if i := bytes.Index(dimentions, []byte{'x'}); i != -1 {
-			mc.Width, _ = strconv.ParseInt(string(dimentions[:i]), 10, 64)
-			mc.Height, _ = strconv.ParseInt(string(dimentions[i+1:]), 10, 64)
-		} else {
-			mc.Width, _ = strconv.ParseInt(string(dimentions), 10, 64)
+	// a wiki media ID has no ? of its own, all after the first one are parameters. An external
+	// URL may have a query, only a last ? followed by sizes and keywords is for DokuWiki.
+	resource := string(bytesLeft)
+	mc.IsExternal = validExternalMedia.MatchString(resource)
+	i := strings.IndexByte(resource, '?')
+	if mc.IsExternal {
+		i = strings.LastIndexByte(resource, '?')
+	}
+	if i != -1 && (!mc.IsExternal || isMediaKeywords(resource[i+1:])) {
+		mc.parseParams(resource[i+1:])
+		resource = resource[:i]
+	}
+	mc.MediaResouce = resource
+	return mc
+}
+
+// mediaKeywords are the parameters of media that have no value.
+var mediaKeywords = map[string]bool{"nolink": true, "direct": true, "details": true, "linkonly": true, "nocache": true, "recache": true}
+
+// isMediaKeywords tells whether the parameters of an external media are only sizes and keywords,
+// which a query of the URL is not.
+func isMediaKeywords(params string) bool {
+	for _, param := range strings.Split(params, "&") {
+		if !mediaKeywords[param] && (param == "" || !validMediaSize.MatchString(param)) {
+			return false
 		}
 	}
-	mc.MediaResouce = string(groups[1])
+	return true
+}
 
-	c.InnerContexts = append(c.InnerContexts, mc)
+// parseParams sets the size and the Params of the media from the parameters after the ?,
+// separated by &. A size is WIDTH, WIDTHxHEIGHT or xHEIGHT, a parameter that is neither a
+// size, a keyword nor a key=value, like a size that is only zeros or does not fit in an
+// int64, requests no size and is kept in invalidSizes.
+func (mc *MediaContext) parseParams(params string) {
+	for _, param := range strings.Split(params, "&") {
+		if param == "" {
+			continue
+		}
+		if groups := validMediaSize.FindStringSubmatch(param); groups != nil {
+			width, height, ok := parseMediaSize(groups[1], groups[2])
+			if !ok {
+				mc.invalidSizes = append(mc.invalidSizes, param)
+				continue
+			}
+			mc.Width, mc.Height = width, height
+			continue
+		}
+		if !mediaKeywords[param] && !strings.Contains(param, "=") {
+			mc.invalidSizes = append(mc.invalidSizes, param)
+			continue
+		}
+		if mc.Params == nil {
+			mc.Params = make(map[string]string)
+		}
+		key, value, _ := strings.Cut(param, "=")
+		mc.Params[key] = value
+	}
+}
+
+// parseMediaSize parses the width and the height of a media size, either can be empty. It
+// is not ok when a number does not fit in an int64 or both are 0.
+func parseMediaSize(width, height string) (int64, int64, bool) {
+	var w, h int64
+	var err error
+	if width != "" {
+		if w, err = strconv.ParseInt(width, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if height != "" {
+		if h, err = strconv.ParseInt(height, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return w, h, w > 0 || h > 0
 }
 
 func endCurrentEffect(c *ParaContext, effectBytes *[]byte, currentEffect uint32) {
@@ -524,12 +1638,12 @@ func endCurrentEffect(c *ParaContext, effectBytes *[]byte, currentEffect uint32)
 		return
 	}
 
-	c.InnerContexts = append(c.InnerContexts, TextEffectContext{
-		BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
+	c.InnerContexts = append(c.InnerContexts, &TextEffectContext{
+		BaseInlineContext: inlineBase(c),
 		EffectType:        currentEffect,
 		Text:              string(*effectBytes),
 	})
-	*effectBytes = make([]byte, 0)
+	*effectBytes = (*effectBytes)[:0]
 }
 
 // returns the section header level, 0 means not a header.
@@ -542,64 +1656,151 @@ func parseSectionHeader(line []byte) (int, []byte) {
 	return 0, nil
 }
 
-func fixupLinks(c *ParaContext) {
-	for {
-		if scanParaOnce(c) == false {
-			return
+// fixupLinks splits the texts of the paragraph at the URLs to link, in a single pass building
+// the inline contexts once.
+func fixupLinks(c *ParaContext, validURL *regexp.Regexp) {
+	var inlines []InlineContext
+	for i, inline := range c.InnerContexts {
+		tc, ok := inline.(*TextEffectContext)
+		var links [][2]int
+		if ok {
+			links = findAutolinks(tc.Text, validURL)
+		}
+		if len(links) == 0 {
+			if inlines != nil {
+				inlines = append(inlines, inline)
+			}
+			continue
+		}
+		if inlines == nil {
+			inlines = make([]InlineContext, 0, len(c.InnerContexts)+2*len(links))
+			inlines = append(inlines, c.InnerContexts[:i]...)
+		}
+		text := func(from, to int) {
+			if from < to {
+				inlines = append(inlines, &TextEffectContext{
+					BaseInlineContext: inlineBase(c),
+					EffectType:        tc.EffectType,
+					Text:              tc.Text[from:to],
+				})
+			}
+		}
+		last := 0
+		for _, span := range links {
+			text(last, span[0])
+			link := &HyperLinkContext{
+				BaseInlineContext: inlineBase(c),
+				Text:              tc.Text[span[0]:span[1]],
+				Kind:              LinkExternal,
+				IsAutoLink:        true,
+				EffectType:        tc.EffectType,
+			}
+			link.SetTarget(autolinkTarget(tc.Text[span[0]:span[1]]))
+			inlines = append(inlines, link)
+			last = span[1]
 		}
+		text(last, len(tc.Text))
+	}
+	if inlines != nil {
+		c.InnerContexts = inlines
 	}
 }
 
-// scanparaonce returns false when there is no links found.
-func scanParaOnce(c *ParaContext) bool {
-	for i := 0; i < len(c.InnerContexts); i++ {
-		if tc, ok := c.InnerContexts[i].(TextEffectContext); ok {
-			groups := validURL.FindStringSubmatchIndex(tc.Text)
-			if groups != nil {
-				newContenxts := make([]InlineContext, 0)
-				before := []byte(tc.Text)[:groups[0]]
-				if len(bytes.TrimSpace(before)) > 0 {
-					newContenxts = append(newContenxts, TextEffectContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						EffectType:        tc.EffectType,
-						Text:              string(before),
-					})
-				}
-				newContenxts = append(newContenxts, HyperLinkContext{
-					BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-					Text:              string([]byte(tc.Text)[groups[0]:groups[1]]),
-					HyperLink:         string([]byte(tc.Text)[groups[0]:groups[1]]),
-				})
-				after := []byte(tc.Text)[groups[1]:]
-				if len(bytes.TrimSpace(after)) > 0 {
-					newContenxts = append(newContenxts, TextEffectContext{
-						BaseInlineContext: BaseInlineContext{BaseContext{parent: *c}},
-						EffectType:        tc.EffectType,
-						Text:              string(after),
-					})
-				}
-				if i < len(c.InnerContexts)-1 {
-					c.InnerContexts = append(append(c.InnerContexts[:i], newContenxts...), c.InnerContexts[i+1:]...)
-				} else {
-					c.InnerContexts = append(c.InnerContexts[:i], newContenxts...)
-				}
-				return true
+// trimURL returns the length of url without the punctuation that ends the sentence around it:
+// .,;:!? and the closing brackets that have no opening one in the URL, like in (https://example.com).
+func trimURL(url string) int {
+	end := len(url)
+	for end > 0 {
+		switch c := url[end-1]; c {
+		case '.', ',', ';', ':', '!', '?':
+			end--
+		case ')', ']', '}':
+			opening := map[byte]string{')': "(", ']': "[", '}': "{"}[c]
+			if strings.Count(url[:end], opening) >= strings.Count(url[:end], string(c)) {
+				return end
 			}
+			end--
+		default:
+			return end
 		}
 	}
-	return false
+	return end
+}
+
+// parseDefinition returns the term and the definition of a line of a definition list, both nil
+// when it is not one or definition lists are off. A term line may also hold a definition after " : ".
+func (lc *lineClassifier) parseDefinition(line []byte) (isTerm bool, term, definition []byte) {
+	if !lc.states.options.DefinitionLists {
+		return false, nil, nil
+	}
+	groups := validDefinition.FindSubmatch(line)
+	if groups == nil {
+		return false, nil, nil
+	}
+	text := bytes.TrimSpace(groups[4])
+	if string(groups[3]) == ":" {
+		return false, nil, text
+	}
+	if i := bytes.Index(text, []byte(" : ")); i != -1 {
+		return true, bytes.TrimSpace(text[:i]), bytes.TrimSpace(text[i+3:])
+	}
+	return true, text, nil
 }
 
-// returns the list item level, 0 means not a list
-func parseListItem(line []byte) (int, bool, []byte) {
-	lineString := string(line)
-	groups := validListItem.FindStringSubmatch(lineString)
-	if groups != nil && len(groups[1]) >= 2 {
-		starOrDash := groups[3]
-		return len(groups[1]), starOrDash == "-", []byte(strings.TrimSpace(groups[4]))
+// parseQuote returns the number of > starting a line of a quote, the width of the indentation
+// before them, measured like parseListItem does, and the rest of the line. The level is 0 when
+// it is not a line of a quote, or is indented by a single space.
+func parseQuote(line []byte, tabWidth int) (int, int, []byte) {
+	groups := validQuote.FindSubmatch(line)
+	if groups == nil {
+		return 0, 0, nil
+	}
+	indent := indentWidth(groups[1], tabWidth)
+	if indent == 1 {
+		return 0, 0, nil
 	}
-	return 0, false, nil
+	return len(groups[2]), indent, groups[3]
 }
 
-func Render(unit *ParseUnit, writer io.Writer) {
+// parseQuoteListItem returns the spaces before the mark of the list item a line of a quote holds
+// and its depth, content is what follows its >. One space or two before the mark is depth 1,
+// three or four depth 2 and so on, so that a list keeps its depths when it is quoted.
+func parseQuoteListItem(content []byte) (int, int, bool, []byte) {
+	groups := validQuoteListItem.FindSubmatch(content)
+	if groups == nil {
+		return 0, 0, false, nil
+	}
+	return len(groups[1]), (len(groups[1]) + 1) / 2, string(groups[2]) == "-", bytes.TrimSpace(groups[3])
+}
+
+// indentWidth returns the width of the spaces and tabs of indent, a tab counting for tabWidth
+// spaces, 2 when 0.
+func indentWidth(indent []byte, tabWidth int) int {
+	if tabWidth <= 0 {
+		tabWidth = 2
+	}
+	width := 0
+	for _, b := range indent {
+		if b == '\t' {
+			width += tabWidth
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// parseListItem returns the width of the indentation of a list item line, a tab counting for
+// tabWidth spaces, 2 when 0, its depth, half of the width rounded down, whether it is ordered
+// and its text. The depth is 0 when the line is not a list item, indented by less than two spaces.
+func parseListItem(line []byte, tabWidth int) (int, int, bool, []byte) {
+	groups := validListItem.FindSubmatch(line)
+	if groups == nil {
+		return 0, 0, false, nil
+	}
+	indent := indentWidth(groups[1], tabWidth)
+	if indent < 2 {
+		return 0, 0, false, nil
+	}
+	return indent, indent / 2, string(groups[2]) == "-", bytes.TrimSpace(groups[3])
 }
@@ -1,8 +1,18 @@
 package dokuwiki
 
 import (
-	_ "fmt"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSimpleParseSectionHeader(t *testing.T) {
@@ -16,8 +26,8 @@ func TestSimpleParseSectionHeader(t *testing.T) {
 }
 
 func TestSimpleListItem(t *testing.T) {
-	level, isOrdered, content := parseListItem([]byte("  - abc "))
-	if level != 2 {
+	indent, depth, isOrdered, content := parseListItem([]byte("  - abc "), 0)
+	if indent != 2 || depth != 1 {
 		t.Fail()
 	}
 	if !isOrdered {
@@ -28,10 +38,49 @@ func TestSimpleListItem(t *testing.T) {
 	}
 }
 
-func TestReplaceBytes(t *testing.T) {
-	ret := replaceBytesWithMarker([]byte("ab<code>"), len("<code>"), []byte{0x00, 0x01})
-	if len(ret) != 4 || ret[0] != 'a' || ret[1] != 'b' || ret[2] != 0x00 || ret[3] != 0x01 {
-		t.Fail()
+func TestScanTagRegions(t *testing.T) {
+	text := []byte("ab<code c [a=\"1\"]>x</code>y<nowiki><html></nowiki><file")
+	want := []tagRegion{
+		{kind: codeTag, start: 2, contentStart: 18, contentEnd: 19, end: 26, attributes: ` c [a="1"]`},
+		{kind: noWikiTag, start: 27, contentStart: 35, contentEnd: 41, end: 50},
+	}
+	if got := scanTagRegions(text, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// an unterminated tag takes the rest.
+	want = []tagRegion{{kind: htmlTag, start: 1, contentStart: 7, contentEnd: 9, end: 9}}
+	if got := scanTagRegions([]byte("a<html>b*"), false); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := scanTagRegions([]byte("a<html>b*"), true); got != nil {
+		t.Errorf("got %+v with DisableEmbeds", got)
+	}
+}
+
+// TestParagraphTags checks that the protected tags the line pass gives the paragraphs are the
+// ones of their raw text.
+func TestParagraphTags(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no golden files: %v", err)
+	}
+	inputs := []string{"> a <nowiki>b\n> c</nowiki> d\n", "  * [[page|<nowiki>|</nowiki>]] <code>x</code>\n"}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(content))
+	}
+	for _, input := range inputs {
+		Walk(Parse([]byte(input), "t"), func(c Context) bool {
+			if para, ok := c.(*ParaContext); ok {
+				if want := scanTagRegions([]byte(para.rawText), false); !para.tagsFound || len(para.tags)+len(want) > 0 && !reflect.DeepEqual(para.tags, want) {
+					t.Errorf("%q: got %+v, want %+v", para.rawText, para.tags, want)
+				}
+			}
+			return true
+		})
 	}
 }
 
@@ -41,6 +90,577 @@ func TestFileStartTag(t *testing.T) {
 	}
 }
 
-func TestA(t *testing.T) {
-	ParseFile("/home/turing/how_to_write_a_compiler.txt")
+func TestMustParse(t *testing.T) {
+	unit := MustParseFile("testdata/example.txt")
+	if unit.Title != "example.txt" || len(unit.Sections) == 0 {
+		t.Errorf("got %q with %d sections", unit.Title, len(unit.Sections))
+	}
+	defer func() {
+		var parseErr *ParseError
+		if err, _ := recover().(error); !errors.As(err, &parseErr) || parseErr.Title != "missing.txt" {
+			t.Errorf("got panic %v, want a *ParseError", err)
+		}
+	}()
+	MustParseFile("testdata/missing.txt")
+}
+
+func TestParseInline(t *testing.T) {
+	unit := Parse([]byte("plain **bold** and //italic **both**// [[page]] end"), "t")
+	para := unit.Sections[0].(*ParaContext)
+	want := []struct {
+		text   string
+		effect uint32
+	}{
+		{"plain ", 0},
+		{"bold", TextEffectBold},
+		{" and ", 0},
+		{"italic ", TextEffectItalic},
+		{"both", TextEffectItalic | TextEffectBold},
+		{" ", 0},
+		{"page", 0},
+		{" end", 0},
+	}
+	if len(para.InnerContexts) != len(want) {
+		t.Fatalf("got %d inline contexts, want %d", len(para.InnerContexts), len(want))
+	}
+	for i, w := range want {
+		switch c := para.InnerContexts[i].(type) {
+		case *TextEffectContext:
+			if c.Text != w.text || c.EffectType != w.effect {
+				t.Errorf("%d: got %q/%d, want %q/%d", i, c.Text, c.EffectType, w.text, w.effect)
+			}
+		case *HyperLinkContext:
+			if c.Text != w.text || !c.IsInternal {
+				t.Errorf("%d: got link %q", i, c.Text)
+			}
+		}
+		if para.InnerContexts[i].GetParentContext() != para {
+			t.Errorf("%d: wrong parent", i)
+		}
+	}
+}
+
+func TestNestedLists(t *testing.T) {
+	unit := Parse([]byte("  * a\n    * b\n    * c\n  * d\n  - e\n"), "t")
+	if len(unit.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(unit.Sections))
+	}
+	list := unit.Sections[0].(*ListContext)
+	if list.Ordered || list.Level != 1 || len(list.InnerContexts) != 2 {
+		t.Fatalf("unexpected top level list %+v", list)
+	}
+	item := list.InnerContexts[0].(*ListItemContext)
+	if len(item.InnerContexts) != 2 || item.Depth != 1 || item.RawIndent != 2 {
+		t.Fatalf("unexpected first item %+v", item)
+	}
+	sub := item.InnerContexts[1].(*ListContext)
+	if sub.Level != 2 || len(sub.InnerContexts) != 2 || sub.GetParentContext() != item {
+		t.Errorf("unexpected sub list %+v", sub)
+	}
+	if ordered := unit.Sections[1].(*ListContext); !ordered.Ordered {
+		t.Errorf("expected an ordered list after the unordered one")
+	}
+}
+
+func TestListTabWidth(t *testing.T) {
+	for _, test := range []struct {
+		tabWidth      int
+		indent, depth int
+	}{
+		{0, 2, 1},
+		{4, 4, 2},
+		{3, 3, 1},
+		{1, 0, 0},
+	} {
+		unit := ParseWithOptions([]byte("\t* item\n"), "t", ParseOptions{TabWidth: test.tabWidth})
+		list, ok := unit.Sections[0].(*ListContext)
+		if !ok {
+			if test.depth != 0 {
+				t.Errorf("tab width %d: got %T, want a list", test.tabWidth, unit.Sections[0])
+			}
+			continue
+		}
+		item := list.InnerContexts[0].(*ListItemContext)
+		if item.RawIndent != test.indent || item.Depth != test.depth || list.Level != test.depth {
+			t.Errorf("tab width %d: got indent %d depth %d, want %d and %d", test.tabWidth, item.RawIndent, item.Depth, test.indent, test.depth)
+		}
+	}
+}
+
+func TestParallelInlineParsing(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&buf, "paragraph %d with **bold**, //italic// and [[link%d|a link]] http://example.com/%d\n\n", i, i, i)
+		if i%10 == 0 {
+			fmt.Fprintf(&buf, "  * item %d with __underline__\n    * sub item ``mono``\n\n", i)
+		}
+	}
+	content := buf.Bytes()
+
+	serial := Parse(content, "serial")
+	for round := 0; round < 3; round++ {
+		parallel := ParseWithOptions(content, "serial", ParseOptions{Parallelism: 8})
+		if !reflect.DeepEqual(serial, parallel) {
+			t.Fatalf("round %d: parallel parse differs from the serial one", round)
+		}
+	}
+}
+
+func TestUnclosedEffects(t *testing.T) {
+	content := "**bold with no close\n\nplain\n\n  * item //italic\n\na **[[page]] b [[page|a **b]]\n"
+	unit := Parse([]byte(content), "t")
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "t"
+  Para
+    Text effect=bold "bold with no close"
+  Para
+    Text "plain"
+  List level=1 unordered
+    ListItem depth=1 indent=2
+      Para
+        Text "item "
+        Text effect=italic "italic"
+  Para
+    Text "a "
+    Link internal effect=bold target="page" "page"
+    Text effect=bold " b "
+    Link internal effect=bold target="page" "a **b"
+      Text "a "
+      Text effect=bold "b"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	wantMessages := []string{
+		"line 1: unclosed ** at offset 0 of the paragraph",
+		"line 5: unclosed // at offset 5 of the paragraph",
+		// the bold in the label is reported when the link ends, the one around it with the paragraph.
+		"line 7: unclosed ** at offset 24 of the paragraph",
+		"line 7: unclosed ** at offset 2 of the paragraph",
+	}
+	messages := make([]string, 0, len(unit.Diagnostics))
+	for _, diagnostic := range unit.Diagnostics {
+		messages = append(messages, diagnostic.Message)
+	}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("got diagnostics %q, want %q", messages, wantMessages)
+	}
+}
+
+func TestParagraphEnds(t *testing.T) {
+	parser := NewParser(ParseOptions{DefinitionLists: true})
+	parser.RegisterBlock("wrap", wrapMatcher{})
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"== Heading ==", `SectionHeader level=2 "Heading"`},
+		{"  * item", `List level=1 unordered`},
+		{"  - item", `List level=1 ordered`},
+		{"   * item", `List level=1 unordered`},
+		{"\t* item", `List level=1 unordered`},
+		{"  ; term", `DefinitionList`},
+		{"  : definition", `DefinitionList`},
+		{"<WRAP>x</WRAP>", `Extension wrap *dokuwiki.wrap`},
+	}
+	for _, test := range tests {
+		unit := parser.Parse([]byte("some text\n"+test.line+"\n"), "t")
+		var dump bytes.Buffer
+		if err := Dump(&dump, unit); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(dump.String(), "\n")
+		if len(lines) < 4 || strings.TrimSpace(lines[2]) != `Text "some text"` || strings.TrimSpace(lines[3]) != test.want {
+			t.Errorf("paragraph followed by %q parsed as\n%s", test.line, dump.String())
+		}
+	}
+
+	// a line of text goes on with the paragraph.
+	para := Parse([]byte("some text\nmore text\n"), "t").Sections[0].(*ParaContext)
+	if len(para.InnerContexts) != 1 || para.InnerContexts[0].(*TextEffectContext).Text != "some text more text" {
+		t.Errorf("got %#v", para.InnerContexts)
+	}
+}
+
+func TestNew(t *testing.T) {
+	parser := New(WithDefinitionLists(), WithBlock("wrap", wrapMatcher{}), WithParallelism(4))
+	if !parser.Options.DefinitionLists || parser.Options.Parallelism != 4 {
+		t.Errorf("got options %+v", parser.Options)
+	}
+	content := "<WRAP>x</WRAP>\n  ; term : definition\n"
+
+	unit, err := parser.ParseReader(strings.NewReader(content), "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	if err := Dump(&want, unit); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(want.String(), "\n"); len(lines) < 3 || lines[1] != "  Extension wrap *dokuwiki.wrap" || lines[2] != "  DefinitionList" {
+		t.Errorf("got\n%s", want.String())
+	}
+
+	filename := filepath.Join(t.TempDir(), "page.txt")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	unit, err = parser.ParseFile(filename)
+	if err != nil || unit.Title != "page.txt" || len(unit.Sections) != 2 {
+		t.Errorf("ParseFile gave %v, %v", unit, err)
+	}
+	var parseErr *ParseError
+	if _, err := parser.ParseFile(filename + ".missing"); !errors.As(err, &parseErr) || !errors.Is(err, fs.ErrNotExist) || parseErr.Title != "page.txt.missing" {
+		t.Errorf("ParseFile of a missing file gave %v", err)
+	}
+
+	// the calls on one parser do not share any state.
+	var wg sync.WaitGroup
+	dumps := make([]string, 8)
+	for i := range dumps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var dump bytes.Buffer
+			Dump(&dump, parser.Parse([]byte(content), "reader"))
+			dumps[i] = dump.String()
+		}()
+	}
+	wg.Wait()
+	for _, dump := range dumps {
+		if dump != want.String() {
+			t.Errorf("concurrent parse gave\n%s", dump)
+		}
+	}
+
+	// the package level functions keep their behaviour.
+	if len(Parse([]byte(content), "t").Sections) != 1 {
+		t.Error("Parse used the options of another parser")
+	}
+}
+
+// cancelMatcher cancels a parse at the lines starting with STOP.
+type cancelMatcher struct{ cancel context.CancelFunc }
+
+func (m cancelMatcher) Start(line []byte) bool {
+	if bytes.HasPrefix(line, []byte("STOP")) {
+		m.cancel()
+	}
+	return false
+}
+
+func (cancelMatcher) End(line, next []byte) bool { return true }
+
+func (cancelMatcher) Block(lines []string) BlockContext { return nil }
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	content := bytes.Repeat([]byte("some **text** [[link]]\n\n"), 10<<20/24)
+	start := time.Now()
+	unit, err := ParseContext(ctx, content, "big")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, context.Canceled) || parseErr.Unit != unit || parseErr.Lines != 0 || len(unit.Sections) != 0 {
+		t.Errorf("got %v, %+v", err, unit)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("a cancelled parse took %v", elapsed)
+	}
+
+	// stopped while reading the lines, the blocks that ended are kept without their inlines.
+	ctx, cancel = context.WithCancel(context.Background())
+	parser := New(WithBlock("cancel", cancelMatcher{cancel}))
+	unit, err = parser.ParseContext(ctx, []byte("a\n\n== b ==\n\nSTOP\n\nc\n"), "t")
+	if !errors.As(err, &parseErr) || parseErr.Lines != 5 || len(unit.Sections) != 3 {
+		t.Fatalf("got %v, %+v", err, unit)
+	}
+	if para := unit.Sections[0].(*ParaContext); para.InnerContexts != nil {
+		t.Errorf("got inlines %v", para.InnerContexts)
+	}
+	if err.Error() != "parse t: stopped after 5 lines: context canceled" {
+		t.Errorf("got error %q", err.Error())
+	}
+
+	// stopped while parsing the paragraphs, the ones after are left alone.
+	ctx, cancel = context.WithCancel(context.Background())
+	parser = New(WithInline("cancel", InlineMatcherFunc(func(text []byte) (InlineContext, int) {
+		if bytes.HasPrefix(text, []byte("STOP")) {
+			cancel()
+		}
+		return nil, 0
+	})))
+	unit, err = parser.ParseContext(ctx, []byte("a\n\nSTOP b\n\nc"), "t")
+	if !errors.As(err, &parseErr) || parseErr.Lines != 5 || len(unit.Sections) != 3 {
+		t.Fatalf("got %v, %+v", err, unit)
+	}
+	for i, want := range []int{1, 1, 0} {
+		if got := len(unit.Sections[i].(*ParaContext).InnerContexts); got != want {
+			t.Errorf("paragraph %d has %d inlines, want %d", i, got, want)
+		}
+	}
+
+	if _, err := ParseContext(context.Background(), []byte("a"), "t"); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestParseInlineFragment(t *testing.T) {
+	inlines, diagnostics := ParseInline("**see** [[page|the //page//]]\n== not a heading == %%**x**%% <nowiki>a\nb</nowiki> {{logo.png}}")
+	var dump bytes.Buffer
+	for _, inline := range inlines {
+		if inline.GetParentContext() != nil {
+			t.Errorf("%#v has a parent", inline)
+		}
+		if err := Dump(&dump, inline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `Text effect=bold "see"
+Text " "
+Link internal target="page" "the //page//"
+  Text "the "
+  Text effect=italic "page"
+Text " == not a heading == "
+NoWiki "**x**"
+Text " "
+NoWiki "a\nb"
+Text " "
+Media "logo.png" align=1 width=0 height=0 title=""
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("got diagnostics %v", diagnostics)
+	}
+
+	var out bytes.Buffer
+	if err := RenderInlineHTML(inlines[:3], &out, RendererOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<strong>see</strong> <a href="page" class="wikilink1" title="page">the <em>page</em></a>`; out.String() != want {
+		t.Errorf("got html %q", out.String())
+	}
+
+	_, diagnostics = ParseInline("a **b\n</code> <code go>x")
+	if len(diagnostics) != 3 || diagnostics[0].Code != CodeStrayEndTag || diagnostics[0].Position.Line != 2 ||
+		diagnostics[1].Code != CodeUnclosedCodeTag || diagnostics[2].Code != CodeUnclosedFormatting {
+		t.Errorf("got diagnostics %v", diagnostics)
+	}
+
+	// the options and plugins of the parser are used.
+	inlines, _ = ParseInline("see http://example.com", WithOptions(ParseOptions{NoAutolink: true}))
+	if len(inlines) != 1 {
+		t.Errorf("got %v", inlines)
+	}
+}
+
+func TestLineJoin(t *testing.T) {
+	tests := []struct {
+		join          LineJoin
+		content, want string
+	}{
+		{LineJoinSpace, "这是一个很长的\n句子。\n", "这是一个很长的 句子。"},
+		{LineJoinNone, "这是一个很长的\n句子。\n", "这是一个很长的句子。"},
+		{LineJoinNone, "some\ntext\n", "sometext"},
+		{LineJoinSmart, "这是一个很长的\n句子。\n", "这是一个很长的句子。"},
+		{LineJoinSmart, "日本語の文章を\nここで折り返す。\n", "日本語の文章をここで折り返す。"},
+		{LineJoinSmart, "句子在这里，\n继续。\r\n下一行\r\n", "句子在这里，继续。\r下一行\r"},
+		// English on one side of the break keeps its space.
+		{LineJoinSmart, "这句话提到了\nDokuWiki 和\n中文。\n", "这句话提到了 DokuWiki 和中文。"},
+		{LineJoinSmart, "mixed English and\n中文 text wrapped\nmid sentence\n", "mixed English and 中文 text wrapped mid sentence"},
+		{LineJoinSmart, "한국어는\n띄어쓰기\n", "한국어는 띄어쓰기"},
+	}
+	for _, test := range tests {
+		para := NewParser(ParseOptions{LineJoin: test.join}).Parse([]byte(test.content), "t").Sections[0].(*ParaContext)
+		if len(para.InnerContexts) != 1 || para.InnerContexts[0].(*TextEffectContext).Text != test.want {
+			t.Errorf("%s join of %q: got %s", test.join, test.content, dumpString(para))
+		}
+	}
+
+	// the inline elements are parsed on the joined text.
+	para := NewParser(ParseOptions{LineJoin: LineJoinSmart}).Parse([]byte("**粗体的\n文字**和[[链接|标签]]\n"), "t").Sections[0].(*ParaContext)
+	if got, want := dumpString(para), "Para\n  Text effect=bold \"粗体的文字\"\n  Text \"和\"\n  Link internal target=\"链接\" \"标签\"\n    Text \"标签\"\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	inlines, _ := ParseInline("中文\n文字", WithOptions(ParseOptions{LineJoin: LineJoinSmart}))
+	if len(inlines) != 1 || inlines[0].(*TextEffectContext).Text != "中文文字" {
+		t.Errorf("got %v", inlines)
+	}
+}
+
+func TestPreserveLineBreaks(t *testing.T) {
+	content := "first **bold\nstill bold** line\n<code go>\nkept\n</code> end\n  * item\n> quoted\n> twice\n"
+	opts := ParseOptions{PreserveLineBreaks: true}
+	unit := ParseWithOptions([]byte(content), "t", opts)
+	want := `ParseUnit "t"
+  Para
+    Text "first "
+    Text effect=bold "bold"
+    LineBreak
+    Text effect=bold "still bold"
+    Text " line"
+    LineBreak
+    Code lang="go" "kept\n"
+    Text " end"
+  List level=1 unordered
+    ListItem depth=1 indent=2
+      Para
+        Text "item"
+  Quote level=1
+    Para
+      Text "quoted"
+      LineBreak
+      Text "twice"
+`
+	if got := dumpString(unit); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	var html bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{}).Render(&html, unit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<strong>bold</strong><br/>\n<strong>still bold</strong>") {
+		t.Errorf("got html\n%s", html.String())
+	}
+
+	// the DokuWiki renderer writes the breaks as new lines, the lines are read back the same.
+	var serialized bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&serialized, unit); err != nil {
+		t.Fatal(err)
+	}
+	if got := dumpString(ParseWithOptions(serialized.Bytes(), "t", opts)); got != want {
+		t.Errorf("round trip of\n%s\ngot\n%s", serialized.String(), got)
+	}
+	if got, want := serialized.String(), "first **bold**\n**still bold** line\n<code go>\nkept\n</code> end\n\n  * item\n\n> quoted\n> twice\n"; got != want {
+		t.Errorf("got serialized\n%s\nwant\n%s", got, want)
+	}
+
+	// a line that would be read as a block after a break is escaped.
+	para := &ParaContext{}
+	para.InnerContexts = []InlineContext{&TextEffectContext{Text: "a"}, &LineBreakContext{}, &TextEffectContext{Text: "  * b"}}
+	unit = &ParseUnit{Sections: []BlockContext{para}}
+	serialized.Reset()
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&serialized, unit); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := serialized.String(), "a\n  %%*%% b\n"; got != want {
+		t.Errorf("got serialized %q, want %q", got, want)
+	}
+
+	// the lines are joined by default.
+	if got := dumpString(Parse([]byte("one\ntwo\n"), "t").Sections[0]); got != "Para\n  Text \"one two\"\n" {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+func TestDisableEmbeds(t *testing.T) {
+	content := "<html><script>alert(1)</script></html> and **<html>bold</html>**\n\n<HTML>\n<p>block</p>\n</HTML>\n"
+	unit := New(WithDisableEmbeds()).Parse([]byte(content), "t")
+	Walk(unit, func(c Context) bool {
+		if _, ok := c.(*HTMLContext); ok {
+			t.Errorf("got an HTMLContext %+v", c)
+		}
+		return true
+	})
+	if len(unit.Diagnostics) != 0 {
+		t.Errorf("got diagnostics %v", unit.Diagnostics)
+	}
+
+	var out bytes.Buffer
+	if err := Render(unit, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"&lt;html&gt;&lt;script&gt;alert(1)&lt;/script&gt;&lt;/html&gt;",
+		"<strong>&lt;html&gt;bold&lt;/html&gt;</strong>",
+		"&lt;HTML&gt; &lt;p&gt;block&lt;/p&gt; &lt;/HTML&gt;",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("no %s in\n%s", want, out.String())
+		}
+	}
+
+	// without the option the tags are embedded.
+	if _, ok := Parse([]byte(content), "t").Sections[0].(*ParaContext).InnerContexts[0].(*HTMLContext); !ok {
+		t.Errorf("the html tag is not embedded without the option")
+	}
+}
+
+func TestNulBytesAreText(t *testing.T) {
+	// the bytes 0x00 0x01 and 0x00 0x02 once stood for a code tag.
+	content := "a \x00\x01 b <code go>x</code> c \x00\x02 [[page|d\x00\x09]]\n"
+	para := Parse([]byte(content), "t").Sections[0].(*ParaContext)
+	if para.rawText != strings.TrimSuffix(content, "\n") {
+		t.Errorf("got raw text %q", para.rawText)
+	}
+	var texts []string
+	for _, inline := range para.InnerContexts {
+		switch inline := inline.(type) {
+		case *TextEffectContext:
+			texts = append(texts, inline.Text)
+		case *CodeFileContext:
+			texts = append(texts, "code "+inline.Language+" "+inline.Text)
+		case *HyperLinkContext:
+			texts = append(texts, "link "+inline.HyperLink+" "+inline.Text)
+		}
+	}
+	want := []string{"a \x00\x01 b ", "code go x", " c \x00\x02 ", "link page d\x00\x09"}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("got %q, want %q", texts, want)
+	}
+
+	header := Parse([]byte("== a <nowiki>**b**</nowiki> ==\n"), "t").Sections[0].(*SectionHeaderContext)
+	if header.HeaderText != "a <nowiki>**b**</nowiki>" {
+		t.Errorf("got header text %q", header.HeaderText)
+	}
+}
+
+func TestMarkerRuns(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"____", nil},
+		{"a ____ b", []string{"0:a  b"}},
+		{"a **** b ////", []string{"0:a  b "}},
+		// the first pair closes the effect, like DokuWiki.
+		{"**a***", []string{"1:a", "0:*"}},
+		{"//a///", []string{"2:a", "0:/"}},
+		{"***a***", []string{"1:*a", "0:*"}},
+		{"**a****b**", []string{"1:ab"}},
+		{"______", []string{"4:__"}},
+		{"C:////Windows/System32 and //C:/Users//", []string{"0:C:////Windows/System32 and ", "2:C:/Users"}},
+		{"file:////server/share", []string{"0:file:////server/share"}},
+	}
+	for _, test := range tests {
+		unit := Parse([]byte(test.content+"\n"), "t")
+		var got []string
+		if len(unit.Sections) > 0 {
+			for _, inline := range unit.Sections[0].(*ParaContext).InnerContexts {
+				text := inline.(*TextEffectContext)
+				got = append(got, fmt.Sprintf("%d:%s", text.EffectType, text.Text))
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.content, got, test.want)
+		}
+		if findings := Lint(unit, &lintRule{"unclosed-formatting", SeverityWarning, checkUnclosedFormatting}); len(findings) != 0 || len(unit.Diagnostics) != 0 {
+			t.Errorf("%s: got %v %v", test.content, findings, unit.Diagnostics)
+		}
+
+		if test.want == nil {
+			// an empty paragraph is written as nothing.
+			continue
+		}
+		var wiki bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+		if again := escapeStructure(Parse(wiki.Bytes(), "t")); !reflect.DeepEqual(again, escapeStructure(unit)) {
+			t.Errorf("%s: serialized as %q, read back as %q", test.content, wiki.String(), again)
+		}
+	}
 }
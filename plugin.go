@@ -0,0 +1,132 @@
+package dokuwiki
+
+import "strings"
+
+// ExtensionContext is implemented by the contexts built by syntax plugins. Kind names their
+// syntax, like "color", so renderers can tell them apart.
+type ExtensionContext interface {
+	Context
+	Kind() string
+}
+
+// SourceContext is implemented by the extension contexts that can be written back as DokuWiki
+// markup, DokuWikiRenderer writes them with WikiSource. The source of a block has no final new line.
+type SourceContext interface {
+	ExtensionContext
+	WikiSource() string
+}
+
+// InlineMatcher recognizes the syntax of an inline plugin.
+type InlineMatcher interface {
+	// MatchInline is called at every position of the text of a paragraph, before the built in
+	// syntax, with the text from there to the end of the paragraph. It returns the context built
+	// from the start of text and the number of bytes it takes, or 0 when its syntax does not
+	// start there. The context should embed BaseInlineContext and implement ExtensionContext,
+	// its parent and position are set by the parser. The bytes of text are used again after the
+	// call, the context keeps a copy of what it needs.
+	MatchInline(text []byte) (InlineContext, int)
+}
+
+// InlineMatcherFunc is an ordinary function used as an InlineMatcher.
+type InlineMatcherFunc func(text []byte) (InlineContext, int)
+
+func (f InlineMatcherFunc) MatchInline(text []byte) (InlineContext, int) {
+	return f(text)
+}
+
+type inlinePlugin struct {
+	name    string
+	matcher InlineMatcher
+}
+
+// RegisterInline adds an inline plugin to p, registering a name again replaces its matcher.
+// Matchers are tried in the order they were first registered, the first match wins.
+func (p *Parser) RegisterInline(name string, matcher InlineMatcher) {
+	for i := range p.inlines {
+		if p.inlines[i].name == name {
+			p.inlines[i].matcher = matcher
+			return
+		}
+	}
+	p.inlines = append(p.inlines, inlinePlugin{name: name, matcher: matcher})
+}
+
+// matchInline tries plugins at the start of text, which is in paragraph c.
+func matchInline(plugins []inlinePlugin, c *ParaContext, text []byte) (InlineContext, int) {
+	for _, plugin := range plugins {
+		inline, n := plugin.matcher.MatchInline(text)
+		if n <= 0 || inline == nil {
+			continue
+		}
+		return attachInline(inline, c), min(n, len(text))
+	}
+	return nil, 0
+}
+
+// attachInline puts inline, built outside of the parser, in paragraph c.
+func attachInline(inline InlineContext, c *ParaContext) InlineContext {
+	inline.SetParentContext(c)
+	if inline, ok := inline.(interface{ setPosition(Position) }); ok {
+		inline.setPosition(c.pos)
+	}
+	return inline
+}
+
+// BlockMatcher recognizes the syntax of a block plugin, which takes whole lines. It is tried at
+// the start of every block, before headings, lists and paragraphs, but never in the content of
+// a code, file, html or nowiki tag.
+type BlockMatcher interface {
+	// Start tells whether line is the first line of a block of the plugin.
+	Start(line []byte) bool
+	// End tells whether line, a line of the block starting with the first one, is its last one,
+	// next is the line after it, empty at the end of the input.
+	End(line, next []byte) bool
+	// Block builds the context of the block from its lines, without their new lines. The
+	// context should embed BaseBlockContext and implement ExtensionContext, its parent and
+	// position are set by the parser. A nil context makes the lines a paragraph.
+	Block(lines []string) BlockContext
+}
+
+type blockPlugin struct {
+	name    string
+	matcher BlockMatcher
+}
+
+// RegisterBlock adds a block plugin to p, registering a name again replaces its matcher.
+// Matchers are tried in the order they were first registered, the first one to start a block
+// takes it. A block that does not end before the end of the input is reported in the diagnostics.
+func (p *Parser) RegisterBlock(name string, matcher BlockMatcher) {
+	for i := range p.blocks {
+		if p.blocks[i].name == name {
+			p.blocks[i].matcher = matcher
+			return
+		}
+	}
+	p.blocks = append(p.blocks, blockPlugin{name: name, matcher: matcher})
+}
+
+func processPluginBlock(states *parserStates, block wholeBlock) {
+	unit := states.parseunit
+	context := block.plugin.matcher.Block(block.lines)
+	if context == stripBlock {
+		return
+	}
+	if context == nil {
+		context = &ParaContext{rawText: strings.Join(block.lines, " ")}
+	}
+	context.SetParentContext(unit)
+	if spanned, ok := context.(interface{ setSpan(Span) }); ok {
+		spanned.setSpan(block.span)
+	}
+	// the contexts below the block do not know where they are either.
+	Walk(context, func(c Context) bool {
+		if c, ok := c.(interface {
+			GetPosition() Position
+			setPosition(Position)
+		}); ok && c.GetPosition().Line == 0 {
+			c.setPosition(Position{Line: block.line})
+		}
+		return true
+	})
+	unit.Sections = append(unit.Sections, context)
+}
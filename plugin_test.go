@@ -0,0 +1,230 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// colorContext is the context of the color plugin, <color red>text</color>.
+type colorContext struct {
+	BaseInlineContext
+	Color string
+	Text  string
+}
+
+func (c *colorContext) Kind() string { return "color" }
+
+var colorSyntax = regexp.MustCompile(`^<color ([a-z]+|#[0-9a-fA-F]{3,6})>(.*?)</color>`)
+
+func matchColor(text []byte) (InlineContext, int) {
+	groups := colorSyntax.FindSubmatch(text)
+	if groups == nil {
+		return nil, 0
+	}
+	return &colorContext{Color: string(groups[1]), Text: string(groups[2])}, len(groups[0])
+}
+
+func TestRegisterInline(t *testing.T) {
+	parser := NewParser(ParseOptions{})
+	parser.RegisterInline("color", InlineMatcherFunc(matchColor))
+	content := []byte("Text **<color red>important</color> bold** and <color #00f>blue</color>.\n\n" +
+		"<code text><color red>code</color></code>\n")
+	unit := parser.Parse(content, "colors")
+
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "colors"
+  Para
+    Text "Text "
+    Extension color *dokuwiki.colorContext
+    Text effect=bold " bold"
+    Text " and "
+    Extension color *dokuwiki.colorContext
+    Text "."
+  Para
+    Code lang="text" "<color red>code</color>"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	para := unit.Sections[0].(*ParaContext)
+	color := para.InnerContexts[1].(*colorContext)
+	if color.Color != "red" || color.Text != "important" || color.GetParentContext() != para || color.GetPosition().Line != 1 {
+		t.Errorf("got %+v", color)
+	}
+
+	// the plugins belong to the parser, Parse does not know them.
+	for _, inline := range Parse(content, "colors").Sections[0].(*ParaContext).InnerContexts {
+		if _, ok := inline.(ExtensionContext); ok {
+			t.Error("Parse used the plugin of another parser")
+		}
+	}
+
+	// registering a name again replaces its matcher.
+	parser.RegisterInline("color", InlineMatcherFunc(func(text []byte) (InlineContext, int) { return nil, 0 }))
+	if _, ok := parser.Parse(content, "colors").Sections[0].(*ParaContext).InnerContexts[1].(ExtensionContext); ok {
+		t.Error("the replaced matcher was used")
+	}
+}
+
+// definitionList is the context of the definition list plugin, "; term : definition" lines.
+type definitionList struct {
+	BaseBlockContext
+	Terms, Definitions []string
+}
+
+func (d *definitionList) Kind() string { return "deflist" }
+
+func (d *definitionList) WikiSource() string {
+	lines := make([]string, 0, len(d.Terms))
+	for i, term := range d.Terms {
+		lines = append(lines, "; "+term+" : "+d.Definitions[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+type definitionListMatcher struct{}
+
+func (definitionListMatcher) Start(line []byte) bool { return bytes.HasPrefix(line, []byte("; ")) }
+
+func (definitionListMatcher) End(line, next []byte) bool { return !bytes.HasPrefix(next, []byte("; ")) }
+
+func (definitionListMatcher) Block(lines []string) BlockContext {
+	list := &definitionList{}
+	for _, line := range lines {
+		term, definition, _ := strings.Cut(strings.TrimPrefix(line, "; "), " : ")
+		list.Terms = append(list.Terms, term)
+		list.Definitions = append(list.Definitions, definition)
+	}
+	return list
+}
+
+// wrap is the context of the wrap plugin, lines between <WRAP class> and </WRAP>.
+type wrap struct {
+	BaseBlockContext
+	Lines []string
+}
+
+func (w *wrap) Kind() string { return "wrap" }
+
+type wrapMatcher struct{}
+
+func (wrapMatcher) Start(line []byte) bool { return bytes.HasPrefix(line, []byte("<WRAP")) }
+
+func (wrapMatcher) End(line, next []byte) bool { return bytes.Contains(line, []byte("</WRAP>")) }
+
+func (wrapMatcher) Block(lines []string) BlockContext { return &wrap{Lines: lines} }
+
+func TestRegisterBlock(t *testing.T) {
+	parser := NewParser(ParseOptions{})
+	parser.RegisterBlock("deflist", definitionListMatcher{})
+	parser.RegisterBlock("wrap", wrapMatcher{})
+	content := "A paragraph\n" +
+		"; Go : a language\n" +
+		"; Wiki : a site\n" +
+		"<WRAP center>\n" +
+		"  * not a list\n" +
+		"</WRAP>\n" +
+		"<code text>\n" +
+		"; not a term : here\n" +
+		"</code>\n" +
+		"<WRAP unclosed>\n" +
+		"text\n"
+	unit := parser.Parse([]byte(content), "plugins")
+
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "plugins"
+  Para
+    Text "A paragraph"
+  Extension deflist *dokuwiki.definitionList
+  Extension wrap *dokuwiki.wrap
+  Para
+    Code lang="text" "; not a term : here\n"
+  Extension wrap *dokuwiki.wrap
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	list := unit.Sections[1].(*definitionList)
+	if !reflect.DeepEqual(list.Terms, []string{"Go", "Wiki"}) || list.GetPosition().Line != 2 || list.GetParentContext() != unit {
+		t.Errorf("got %+v", list)
+	}
+	if lines := unit.Sections[2].(*wrap).Lines; len(lines) != 3 || unit.Sections[2].(*wrap).GetPosition().Line != 4 {
+		t.Errorf("wrap lines %q", lines)
+	}
+	if len(unit.Diagnostics) != 1 || unit.Diagnostics[0].Message != "line 10: unclosed wrap block" {
+		t.Errorf("diagnostics %v", unit.Diagnostics)
+	}
+
+	var out bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&out, &ParseUnit{Sections: []BlockContext{list}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "; Go : a language\n; Wiki : a site\n"; out.String() != want {
+		t.Errorf("serialized %q, want %q", out.String(), want)
+	}
+}
+
+func TestRegisterNodeRenderer(t *testing.T) {
+	parser := NewParser(ParseOptions{})
+	parser.RegisterInline("color", InlineMatcherFunc(matchColor))
+	parser.RegisterBlock("deflist", definitionListMatcher{})
+	unit := parser.Parse([]byte("; a<b : c\n\nSome <color red>red</color> text\n"), "nodes")
+
+	html := NewHTMLRenderer(RendererOptions{UnknownExtension: ExtensionSource})
+	html.RegisterNodeRenderer("color", func(w io.Writer, node ExtensionContext, r *HTMLRenderer) error {
+		color := node.(*colorContext)
+		_, err := fmt.Fprintf(w, "<span style=\"color:%s\">%s</span>", color.Color, color.Text)
+		return err
+	})
+	markdown := NewMarkdownRenderer(RendererOptions{})
+	markdown.RegisterNodeRenderer("color", func(w io.Writer, node ExtensionContext, r *MarkdownRenderer) error {
+		_, err := io.WriteString(w, node.(*colorContext).Text)
+		return err
+	})
+	dokuwiki := NewDokuWikiRenderer(RendererOptions{})
+	dokuwiki.RegisterNodeRenderer("color", func(w io.Writer, node ExtensionContext, r *DokuWikiRenderer) error {
+		color := node.(*colorContext)
+		_, err := fmt.Fprintf(w, "<color %s>%s</color>", color.Color, color.Text)
+		return err
+	})
+
+	tests := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{html, "; a&lt;b : c\n<p>\nSome <span style=\"color:red\">red</span> text\n</p>\n"},
+		{markdown, "\n\nSome red text\n"},
+		{dokuwiki, "; a<b : c\n\nSome <color red>red</color> text\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := test.renderer.Render(&out, unit); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%T rendered %q, want %q", test.renderer, out.String(), test.want)
+		}
+	}
+
+	strict := NewHTMLRenderer(RendererOptions{UnknownExtension: ExtensionError})
+	if err := strict.Render(io.Discard, unit); err == nil || !strings.Contains(err.Error(), `"deflist"`) {
+		t.Errorf("got %v, want an error for deflist", err)
+	}
+	failing := errors.New("failing")
+	strict.RegisterNodeRenderer("deflist", func(w io.Writer, node ExtensionContext, r *HTMLRenderer) error { return failing })
+	if err := strict.Render(io.Discard, unit); err != failing {
+		t.Errorf("got %v, want the error of the node renderer", err)
+	}
+}
@@ -0,0 +1,132 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// ParserPool hands out parsers that share the setup of one, its options and plugins, and each
+// have their own buffers, kept from one parse to the next. It cuts the allocations of services
+// parsing a page on every request.
+//
+// A parser from Get is used by one goroutine at a time and given back with Put once its parse
+// returned, the units it made stay valid. Parsing with a parser from the pool in two goroutines
+// at once, or giving it back while it parses, panics.
+type ParserPool struct {
+	parser *Parser
+	pool   sync.Pool
+}
+
+// NewParserPool returns a pool of parsers set up like p, which must not be changed afterwards.
+func NewParserPool(p *Parser) *ParserPool {
+	pool := &ParserPool{parser: p}
+	pool.pool.New = func() any {
+		c := *pool.parser
+		// registering a plugin on a parser of the pool must not change the others.
+		c.inlines = c.inlines[:len(c.inlines):len(c.inlines)]
+		c.blocks = c.blocks[:len(c.blocks):len(c.blocks)]
+		c.scratch = &parseScratch{}
+		return &c
+	}
+	return pool
+}
+
+// Get returns a parser of the pool, a new one when there is none left.
+func (pool *ParserPool) Get() *Parser {
+	return pool.pool.Get().(*Parser)
+}
+
+// Put gives back a parser returned by Get.
+func (pool *ParserPool) Put(p *Parser) {
+	if p.scratch == nil {
+		panic("dokuwiki: ParserPool.Put of a parser not from a pool")
+	}
+	if p.scratch.parsing.Load() {
+		panic("dokuwiki: ParserPool.Put of a parser that is parsing")
+	}
+	pool.pool.Put(p)
+}
+
+// Parse parses content with a parser of the pool.
+func (pool *ParserPool) Parse(content []byte, title string) *ParseUnit {
+	p := pool.Get()
+	defer pool.Put(p)
+	return p.Parse(content, title)
+}
+
+// parseScratch holds the buffers a parse needs only while it runs.
+type parseScratch struct {
+	// content is the input with a new line appended, lines are its lines.
+	content []byte
+	lines   [][]byte
+	blocks  []wholeBlock
+	// blockBytes are the bytes of the block being classified, text the raw text of the blocks.
+	blockBytes []byte
+	text       []byte
+	// paras are the paraBuffers of the paragraphs parsed, which may be in several goroutines with
+	// Parallelism, or in a link label while its paragraph holds its own.
+	parasMu sync.Mutex
+	paras   []*paraBuffers
+	parsing atomic.Bool
+}
+
+// paraBuffers are the buffers of the inline parsing of a paragraph.
+type paraBuffers struct {
+	// text is the raw text of the paragraph, effect the text of the current effect.
+	text   []byte
+	effect []byte
+}
+
+// getParaBuffers returns buffers for a paragraph, new ones without a scratch.
+func (s *parseScratch) getParaBuffers() *paraBuffers {
+	if s == nil {
+		return &paraBuffers{}
+	}
+	s.parasMu.Lock()
+	defer s.parasMu.Unlock()
+	if n := len(s.paras); n > 0 {
+		buffers := s.paras[n-1]
+		s.paras = s.paras[:n-1]
+		return buffers
+	}
+	return &paraBuffers{}
+}
+
+// putParaBuffers keeps the buffers of a paragraph parsed for the next one.
+func (s *parseScratch) putParaBuffers(buffers *paraBuffers) {
+	if s != nil {
+		s.parasMu.Lock()
+		s.paras = append(s.paras, buffers)
+		s.parasMu.Unlock()
+	}
+}
+
+// acquire marks the scratch as used by a parse, it panics when another one uses it.
+func (s *parseScratch) acquire() {
+	if !s.parsing.CompareAndSwap(false, true) {
+		panic("dokuwiki: a parser from a ParserPool used by two goroutines at once")
+	}
+}
+
+func (s *parseScratch) release() {
+	s.parsing.Store(false)
+}
+
+// keepBlocks keeps the blocks slice for the next parse, without what its blocks point to.
+func (s *parseScratch) keepBlocks(blocks []wholeBlock) {
+	clear(blocks)
+	s.blocks = blocks[:0]
+}
+
+// splitLines appends the lines of content, cut at the new lines, to lines.
+func splitLines(lines [][]byte, content []byte) [][]byte {
+	for {
+		i := bytes.IndexByte(content, '\n')
+		if i == -1 {
+			return append(lines, content)
+		}
+		lines = append(lines, content[:i:i])
+		content = content[i+1:]
+	}
+}
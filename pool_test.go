@@ -0,0 +1,106 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var poolContent = []byte(strings.Repeat("===== Part =====\n\nSome **bold //text//** with [[page|a label]] and {{logo.png?20}}.\n\n  * one\n    * two\n\n<code go>\nx := 1\n</code>\n\n", 50))
+
+func TestParserPool(t *testing.T) {
+	parser := New(WithDefinitionLists())
+	pool := NewParserPool(parser)
+	want := dumpString(parser.Parse(poolContent, "t"))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 5; n++ {
+				if got := dumpString(pool.Parse(poolContent, "t")); got != want {
+					t.Errorf("a parser of the pool parses differently:\n%s", got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the units stay valid after their parser was used again.
+	p := pool.Get()
+	first := p.Parse([]byte("first **page**\n"), "a")
+	p.Parse([]byte("second page, longer than the first one\n\n== x ==\n"), "b")
+	pool.Put(p)
+	if got := dumpString(first); !strings.Contains(got, `"first "`) || strings.Contains(got, "second") {
+		t.Errorf("the unit changed with the next parse:\n%s", got)
+	}
+
+	// the paragraphs parsed at once share the buffers of their parser.
+	parallel := NewParserPool(New(WithDefinitionLists(), WithParallelism(4)))
+	for n := 0; n < 3; n++ {
+		if got := dumpString(parallel.Parse(poolContent, "t")); got != want {
+			t.Errorf("a parser of the pool parses differently with Parallelism:\n%s", got)
+		}
+	}
+}
+
+// blockingMatcher blocks the parse at the lines starting with WAIT until release is closed.
+type blockingMatcher struct{ started, release chan struct{} }
+
+func (m blockingMatcher) Start(line []byte) bool {
+	if bytes.HasPrefix(line, []byte("WAIT")) {
+		close(m.started)
+		<-m.release
+	}
+	return false
+}
+
+func (blockingMatcher) End(line, next []byte) bool { return true }
+
+func (blockingMatcher) Block(lines []string) BlockContext { return nil }
+
+func TestParserPoolMisuse(t *testing.T) {
+	matcher := blockingMatcher{make(chan struct{}), make(chan struct{})}
+	pool := NewParserPool(New(WithBlock("wait", matcher)))
+	p := pool.Get()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Parse([]byte("a\n\nWAIT\n"), "t")
+	}()
+	<-matcher.started
+
+	panics := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s did not panic", name)
+			}
+		}()
+		f()
+	}
+	panics("Put during a parse", func() { pool.Put(p) })
+	panics("a second parse at once", func() { p.Parse([]byte("b\n"), "u") })
+	panics("Put of another parser", func() { pool.Put(New()) })
+
+	close(matcher.release)
+	<-done
+	pool.Put(p)
+}
+
+func BenchmarkParse(b *testing.B) {
+	parser := New()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parser.Parse(poolContent, "t")
+	}
+}
+
+func BenchmarkParserPool(b *testing.B) {
+	pool := NewParserPool(New())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pool.Parse(poolContent, "t")
+	}
+}
@@ -0,0 +1,188 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// unportable tells, for the constructs a renderer can not write with an equivalent in its
+// format, why, it is empty for the ones it can. Every renderer has its own next to it, so that
+// AnalyzePortability follows the renderers.
+type unportable struct {
+	underline    string
+	html         string
+	php          string
+	windowsShare string
+	plugin       string
+	// interWiki is for the links whose shortcut is not in the interwiki map of the target.
+	interWiki string
+}
+
+// portabilityTargets are the targets of AnalyzePortability, named like the formats of the
+// dokuwiki command.
+var portabilityTargets = map[string]unportable{
+	"html":     htmlUnportable,
+	"markdown": markdownUnportable,
+	"text":     textUnportable,
+	"latex":    latexUnportable,
+	"dokuwiki": dokuWikiUnportable,
+}
+
+// PortabilityTargets returns the targets AnalyzePortability knows, sorted.
+func PortabilityTargets() []string {
+	targets := make([]string, 0, len(portabilityTargets))
+	for target := range portabilityTargets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// PortabilityOptions configures AnalyzePortabilityWithOptions.
+type PortabilityOptions struct {
+	// InterWiki is the interwiki map of the target, the interwiki links whose shortcut it does
+	// not have are reported, all of them when it is nil.
+	InterWiki InterWikiMap
+}
+
+// AnalyzePortability reports the constructs of unit that the renderer of target, one of
+// PortabilityTargets, can not write with an equivalent: underlined text, embedded html and php,
+// windows share links, the tags and macros of plugins and the interwiki links. An unknown target
+// writes none of them. The findings are warnings, one per construct with its position, of the
+// rules portability-underline, portability-html, portability-php, portability-windows-share,
+// portability-plugin and portability-interwiki.
+func AnalyzePortability(unit *ParseUnit, target string) []Finding {
+	return AnalyzePortabilityWithOptions(unit, target, PortabilityOptions{})
+}
+
+// AnalyzePortabilityWithOptions is AnalyzePortability with the interwiki map of the target
+// given in opts.
+func AnalyzePortabilityWithOptions(unit *ParseUnit, target string, opts PortabilityOptions) []Finding {
+	return Lint(unit, PortabilityRule(target, opts))
+}
+
+// PortabilityRule is the lint rule of AnalyzePortabilityWithOptions, with the ID portability, to
+// run with other rules.
+func PortabilityRule(target string, opts PortabilityOptions) Rule {
+	return &portabilityRule{target: target, opts: opts}
+}
+
+type portabilityRule struct {
+	target string
+	opts   PortabilityOptions
+}
+
+func (r *portabilityRule) ID() string {
+	return "portability"
+}
+
+func (r *portabilityRule) Check(unit *ParseUnit) []Finding {
+	reasons, ok := portabilityTargets[r.target]
+	if !ok {
+		unknown := fmt.Sprintf("%s is not a known target", r.target)
+		reasons = unportable{unknown, unknown, unknown, unknown, unknown, unknown}
+	}
+	findings := make([]Finding, 0)
+	report := func(rule, reason string, pos Position, format string, args ...interface{}) {
+		if reason != "" {
+			message := fmt.Sprintf(format, args...) + ": " + reason
+			findings = append(findings, Finding{Rule: "portability-" + rule, Severity: SeverityWarning, Message: message, Position: pos})
+		}
+	}
+
+	// the tags of php and plugins are text, they are looked for in the source.
+	pluginLines := make(map[int]bool)
+	for _, finding := range sourceTags(unit.source) {
+		reason := reasons.php
+		if finding.Rule == "portability-plugin" {
+			reason = reasons.plugin
+			pluginLines[finding.Position.Line] = true
+		}
+		if reason != "" {
+			finding.Severity, finding.Message = SeverityWarning, finding.Message+": "+reason
+			findings = append(findings, finding)
+		}
+	}
+
+	Walk(unit, func(c Context) bool {
+		pos := Position{}
+		if positioned, ok := c.(interface{ GetPosition() Position }); ok {
+			pos = positioned.GetPosition()
+		}
+		switch c := c.(type) {
+		case *TextEffectContext:
+			if c.EffectType&TextEffectUnderline != 0 && strings.TrimSpace(c.Text) != "" {
+				report("underline", reasons.underline, pos, "underlined text %q", c.Text)
+			}
+		case *HTMLContext:
+			report("html", reasons.html, pos, "embedded html")
+		case *HyperLinkContext:
+			if c.EffectType&TextEffectUnderline != 0 {
+				report("underline", reasons.underline, pos, "underlined link %s", c.HyperLink)
+			}
+			switch c.Kind {
+			case LinkWindowsShare:
+				report("windows-share", reasons.windowsShare, pos, "windows share link %s", c.HyperLink)
+			case LinkInterwiki:
+				if _, known := r.opts.InterWiki.URL(c.HyperLink); !known {
+					shortcut, _, _ := strings.Cut(c.HyperLink, ">")
+					report("interwiki", reasons.interWiki, pos, "interwiki link %s, %s is not in the interwiki map", c.HyperLink, shortcut)
+				}
+			}
+		case ExtensionContext:
+			// the plugins with tags are reported once, from the source.
+			if !pluginLines[pos.Line] {
+				report("plugin", reasons.plugin, pos, "plugin syntax %s", c.Kind())
+			}
+		}
+		return true
+	})
+	return findings
+}
+
+// sourceTags returns the php tags, of the rule portability-php, and the tags and macros the parser
+// does not know, of the rule portability-plugin, of the source outside of code, file, html and
+// nowiki tags. Their messages name them.
+func sourceTags(source string) []Finding {
+	text := []byte(source)
+	regions := scanTagRegions(text, false)
+	ends := findEndTags(text)
+	findings := make([]Finding, 0)
+	// line is the line of the offset reached, which starts at lineStart.
+	line, lineStart := 1, 0
+	advance := func(offset, to int) int {
+		for ; offset < to; offset++ {
+			if text[offset] == '\n' {
+				line, lineStart = line+1, offset+1
+			}
+		}
+		return to
+	}
+	add := func(rule string, start, end int, message string) {
+		findings = append(findings, Finding{Rule: rule, Message: message, Position: Position{Line: line}, Span: Span{Start: start, End: end}, Column: start - lineStart + 1})
+	}
+	for offset := 0; offset < len(text); {
+		switch {
+		case len(regions) > 0 && offset >= regions[0].start:
+			offset, regions = advance(offset, regions[0].end), regions[1:]
+		case text[offset] == '<' && ends.tagLength(text, offset) > 0:
+			n := ends.tagLength(text, offset)
+			name := string(validUnknownTag.FindSubmatch(text[offset:])[1])
+			if strings.EqualFold(name, "php") {
+				add("portability-php", offset, offset+n, "embedded php")
+			} else {
+				add("portability-plugin", offset, offset+n, fmt.Sprintf("plugin tag <%s>", name))
+			}
+			offset = advance(offset, offset+n)
+		case text[offset] == '~' && isUnknownMacro(text[offset:]):
+			// a macro is on one line.
+			n := len(validUnknownMacro.Find(text[offset:]))
+			add("portability-plugin", offset, offset+n, fmt.Sprintf("plugin macro %s", text[offset:offset+n]))
+			offset += n
+		default:
+			offset = advance(offset, offset+1)
+		}
+	}
+	return findings
+}
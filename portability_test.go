@@ -0,0 +1,74 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzePortability(t *testing.T) {
+	content := "====== Page ======\n" +
+		"__under__ and [[start|__link__]]\n" +
+		"\n" +
+		"<html><b>x</b></html>\n" +
+		"\n" +
+		"<php>echo 1;</php> %%<php>%%\n" +
+		"\n" +
+		"[[\\\\server\\share]] [[wp>DokuWiki]] [[doku>wiki:syntax]]\n" +
+		"\n" +
+		"<wrap>x</wrap> ~~DISCUSSION~~ ~~NOTOC~~\n"
+	unit := Parse([]byte(content), "page")
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"markdown", []string{
+			"2:1 portability-underline",
+			"2:1 portability-underline",
+			"4:1 portability-html",
+			"6:1 portability-php",
+			"8:1 portability-windows-share",
+			"8:1 portability-interwiki",
+			"10:1 portability-plugin",
+			"10:16 portability-plugin",
+		}},
+		{"latex", []string{
+			"4:1 portability-html",
+			"6:1 portability-php",
+			"8:1 portability-windows-share",
+			"8:1 portability-interwiki",
+			"10:1 portability-plugin",
+			"10:16 portability-plugin",
+		}},
+		{"html", []string{
+			"6:1 portability-php",
+			"8:1 portability-interwiki",
+			"10:1 portability-plugin",
+			"10:16 portability-plugin",
+		}},
+		{"dokuwiki", []string{
+			"4:1 portability-html",
+			"6:1 portability-php",
+		}},
+	}
+	for _, test := range tests {
+		got := make([]string, 0)
+		for _, finding := range AnalyzePortabilityWithOptions(unit, test.target, PortabilityOptions{InterWiki: InterWikiMap{"doku": "https://www.dokuwiki.org/"}}) {
+			if finding.Severity != SeverityWarning || !strings.Contains(finding.Message, ": ") {
+				t.Errorf("%s: got %+v", test.target, finding)
+			}
+			got = append(got, fmt.Sprintf("%d:%d %s", finding.Position.Line, finding.Column, finding.Rule))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.target, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+
+	if findings := AnalyzePortability(unit, "pdf"); len(findings) != 9 || findings[0].Message != `underlined text "under": pdf is not a known target` {
+		t.Errorf("unknown target: got %+v", findings)
+	}
+	if got := strings.Join(PortabilityTargets(), " "); got != "dokuwiki html latex markdown text" {
+		t.Errorf("got targets %s", got)
+	}
+}
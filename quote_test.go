@@ -0,0 +1,99 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// quoteFixtures are the golden fixtures of quotes in lists, lists in quotes and both.
+var quoteFixtures = []string{"quote_in_list", "list_in_quote", "list_in_quote_in_list", "nested_quotes", "quote_continuation"}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, name := range quoteFixtures {
+		content, err := os.ReadFile("testdata/golden/" + name + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := Parse(content, name)
+		if errs := first.Validate(); errs != nil {
+			t.Errorf("%s: %v", name, errs)
+		}
+		var serialized bytes.Buffer
+		if err := NewDokuWikiRenderer(RendererOptions{}).Render(&serialized, first); err != nil {
+			t.Fatal(err)
+		}
+		second := Parse(serialized.Bytes(), name)
+		if got, want := dumpString(second), dumpString(first); got != want {
+			t.Errorf("%s: round trip changed the tree, serialized:\n%s\ngot\n%s\nwant\n%s", name, serialized.String(), got, want)
+		}
+	}
+}
+
+func TestQuoteRenderers(t *testing.T) {
+	content := "  * item\n  > a quote in the item\n  > * a list in that quote\n  >> and a deeper quote\n  * next item\n"
+	tests := []struct {
+		name     string
+		renderer Renderer
+		want     string
+	}{
+		{"dokuwiki", NewDokuWikiRenderer(RendererOptions{}), content},
+		{"markdown", NewMarkdownRenderer(RendererOptions{}), "- item\n  > a quote in the item\n  >\n  > - a list in that quote\n  >\n  > > and a deeper quote\n- next item\n"},
+		{"text", NewTextRenderer(RendererOptions{}), "* item\n  > a quote in the item\n  > * a list in that quote\n  >> and a deeper quote\n* next item\n"},
+		{"latex", NewLaTeXRenderer(RendererOptions{}), `\begin{itemize}
+\item item
+\begin{quote}
+a quote in the item
+\begin{itemize}
+\item a list in that quote
+\end{itemize}
+\begin{quote}
+and a deeper quote
+\end{quote}
+\end{quote}
+\item next item
+\end{itemize}
+
+`},
+	}
+
+	unit := Parse([]byte(content), "quotes")
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.renderer.Render(&buf, unit); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestQuoteIndentedLikeItems(t *testing.T) {
+	// the depth of a quote in a list is the one of an item indented the same.
+	for _, content := range []string{
+		"   * item\n   > a\n   >> b\n",
+		"\t* item\n\t> a\n\t>> b\n",
+		"  * item\n     > a\n  >> b\n",
+	} {
+		unit := Parse([]byte(content), "t")
+		want := "ParseUnit \"t\"\n  List level=1 unordered\n"
+		if got := dumpString(unit); !strings.HasPrefix(got, want) || len(unit.Sections) != 1 || strings.Count(got, "Quote level=") != 2 {
+			t.Errorf("%q: got\n%s", content, got)
+		}
+		var md bytes.Buffer
+		if err := NewMarkdownRenderer(RendererOptions{}).Render(&md, unit); err != nil {
+			t.Fatal(err)
+		}
+		if want := "- item\n  > a\n  >\n  > > b\n"; md.String() != want {
+			t.Errorf("%q: got markdown %q, want %q", content, md.String(), want)
+		}
+	}
+	// a single space is not an indentation.
+	if unit := Parse([]byte(" > a\n"), "t"); len(unit.Sections) != 1 {
+		t.Errorf("got %d sections", len(unit.Sections))
+	} else if _, ok := unit.Sections[0].(*ParaContext); !ok {
+		t.Errorf("got a %T", unit.Sections[0])
+	}
+}
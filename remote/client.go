@@ -0,0 +1,264 @@
+// Package remote fetches pages from a running DokuWiki over its XML-RPC interface
+// (lib/exe/xmlrpc.php), so a hosted wiki can be parsed without access to its files.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// Doer sends an HTTP request, *http.Client implements it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// ClientOptions configures a Client, the zero value is usable for a public wiki.
+type ClientOptions struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient Doer
+	// User and Password are sent as HTTP basic authentication with every call,
+	// Login can be used instead to get a session cookie.
+	User     string
+	Password string
+	// MinInterval is the least time between the start of two calls,
+	// calls are not limited when it is zero.
+	MinInterval time.Duration
+}
+
+// Client calls the XML-RPC interface of one wiki, it is safe for concurrent use.
+type Client struct {
+	endpoint string
+	opts     ClientOptions
+
+	mu       sync.Mutex
+	cookies  []*http.Cookie
+	lastCall time.Time
+}
+
+// NewClient returns a client for the wiki at baseURL, like "https://wiki.example.com/",
+// the XML-RPC endpoint below it is added by the client.
+func NewClient(baseURL string, opts ClientOptions) *Client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &Client{
+		endpoint: strings.TrimRight(baseURL, "/") + "/lib/exe/xmlrpc.php",
+		opts:     opts,
+	}
+}
+
+// PageInfo describes a page listed by ListPages.
+type PageInfo struct {
+	ID           string
+	Size         int64
+	LastModified time.Time
+}
+
+// AttachmentInfo describes a media file listed by GetAttachments.
+type AttachmentInfo struct {
+	ID           string
+	Size         int64
+	LastModified time.Time
+	IsImage      bool
+}
+
+// Login calls dokuwiki.login and keeps the session cookie the wiki sends back for later calls.
+func (c *Client) Login(ctx context.Context, user, password string) error {
+	result, err := c.call(ctx, "dokuwiki.login", user, password)
+	if err != nil {
+		return err
+	}
+	if ok, _ := result.(bool); !ok {
+		return fmt.Errorf("remote: login as %q failed", user)
+	}
+	return nil
+}
+
+// GetPageSource returns the raw wiki text of the page with the given ID.
+func (c *Client) GetPageSource(ctx context.Context, id string) (string, error) {
+	result, err := c.call(ctx, "wiki.getPage", id)
+	if err != nil {
+		return "", err
+	}
+	source, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("remote: wiki.getPage returned %T", result)
+	}
+	return source, nil
+}
+
+// GetPage fetches the page with the given ID and parses it, the page ID is used as title and
+// as ParseOptions.PageID, so the relative media of the page are resolved against its namespace.
+func (c *Client) GetPage(ctx context.Context, id string) (*dokuwiki.ParseUnit, error) {
+	source, err := c.GetPageSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return dokuwiki.ParseWithOptions([]byte(source), id, dokuwiki.ParseOptions{PageID: id}), nil
+}
+
+// ListPages lists the pages below namespace and its sub namespaces, all pages when namespace is empty.
+func (c *Client) ListPages(ctx context.Context, namespace string) ([]PageInfo, error) {
+	result, err := c.call(ctx, "dokuwiki.getPagelist", namespace, map[string]interface{}{"depth": 0})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("remote: dokuwiki.getPagelist returned %T", result)
+	}
+	pages := make([]PageInfo, 0, len(items))
+	for _, item := range items {
+		members, _ := item.(map[string]interface{})
+		pages = append(pages, PageInfo{
+			ID:           stringMember(members, "id"),
+			Size:         intMember(members, "size"),
+			LastModified: timeMember(members, "mtime"),
+		})
+	}
+	return pages, nil
+}
+
+// GetAttachments lists the media files below namespace and its sub namespaces.
+func (c *Client) GetAttachments(ctx context.Context, namespace string) ([]AttachmentInfo, error) {
+	result, err := c.call(ctx, "wiki.getAttachments", namespace, map[string]interface{}{"depth": 0})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("remote: wiki.getAttachments returned %T", result)
+	}
+	attachments := make([]AttachmentInfo, 0, len(items))
+	for _, item := range items {
+		members, _ := item.(map[string]interface{})
+		isImage, _ := members["isimg"].(bool)
+		attachments = append(attachments, AttachmentInfo{
+			ID:           stringMember(members, "id"),
+			Size:         intMember(members, "size"),
+			LastModified: timeMember(members, "lastModified"),
+			IsImage:      isImage,
+		})
+	}
+	return attachments, nil
+}
+
+// call sends one method call and decodes its result, waiting for the rate limit first.
+func (c *Client) call(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	body, err := encodeCall(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if c.opts.User != "" {
+		req.SetBasicAuth(c.opts.User, c.opts.Password)
+	}
+	c.mu.Lock()
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	c.mu.Unlock()
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote: %s: %s", method, resp.Status)
+	}
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		c.mu.Lock()
+		c.cookies = mergeCookies(c.cookies, cookies)
+		c.mu.Unlock()
+	}
+
+	result, err := decodeResponse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("remote: %s: %w", method, err)
+	}
+	return result, nil
+}
+
+// wait blocks until MinInterval has passed since the previous call, or ctx is done.
+func (c *Client) wait(ctx context.Context) error {
+	if c.opts.MinInterval <= 0 {
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	next := c.lastCall.Add(c.opts.MinInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	// reserve the slot before sleeping so concurrent calls queue up behind each other.
+	c.lastCall = next
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func mergeCookies(old, received []*http.Cookie) []*http.Cookie {
+	merged := make([]*http.Cookie, 0, len(old)+len(received))
+	for _, cookie := range old {
+		replaced := false
+		for _, r := range received {
+			if r.Name == cookie.Name {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, cookie)
+		}
+	}
+	for _, cookie := range received {
+		if cookie.MaxAge >= 0 && cookie.Value != "deleted" {
+			merged = append(merged, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+	return merged
+}
+
+func stringMember(members map[string]interface{}, name string) string {
+	s, _ := members[name].(string)
+	return s
+}
+
+func intMember(members map[string]interface{}, name string) int64 {
+	n, _ := members[name].(int64)
+	return n
+}
+
+// timeMember reads a time that is either a dateTime.iso8601 value or a unix timestamp.
+func timeMember(members map[string]interface{}, name string) time.Time {
+	switch v := members[name].(type) {
+	case time.Time:
+		return v
+	case int64:
+		return time.Unix(v, 0).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,158 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// fixtureServer answers every call with the recorded response in testdata named after the method.
+func fixtureServer(t *testing.T, calls *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lib/exe/xmlrpc.php" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		method := string(body)
+		method = method[strings.Index(method, "<methodName>")+len("<methodName>") : strings.Index(method, "</methodName>")]
+		*calls = append(*calls, string(body))
+
+		fixture := "testdata/" + method[strings.IndexByte(method, '.')+1:] + ".xml"
+		if strings.Contains(string(body), "secret") {
+			fixture = "testdata/fault.xml"
+		}
+		if method == "dokuwiki.login" {
+			http.SetCookie(w, &http.Cookie{Name: "DokuWiki", Value: "session"})
+		}
+		content, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Errorf("no fixture for %s", method)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(content)
+	}))
+}
+
+func TestGetPage(t *testing.T) {
+	var calls []string
+	server := fixtureServer(t, &calls)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", ClientOptions{})
+	unit, err := client.GetPage(context.Background(), "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Title != "start" || len(unit.Sections) != 2 {
+		t.Fatalf("unexpected unit %+v", unit)
+	}
+	if header := unit.Sections[0].(*dokuwiki.SectionHeaderContext); header.HeaderText != "Welcome" {
+		t.Errorf("unexpected header %q", header.HeaderText)
+	}
+	if want := "<methodName>wiki.getPage</methodName><params><param><value><string>start</string></value></param></params>"; !strings.Contains(calls[0], want) {
+		t.Errorf("unexpected request %s", calls[0])
+	}
+
+	// the relative media are resolved against the namespace of the page.
+	unit, err = client.GetPage(context.Background(), "wiki:start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := unit.MediaRefs(); len(refs) != 1 || refs[0].ID != "wiki:logo.png" {
+		t.Errorf("got media %+v", refs)
+	}
+
+	_, err = client.GetPage(context.Background(), "secret")
+	var fault *Fault
+	if !errors.As(err, &fault) || fault.Code != 111 {
+		t.Errorf("expected a fault, got %v", err)
+	}
+}
+
+func TestListPagesAndAttachments(t *testing.T) {
+	var calls []string
+	server := fixtureServer(t, &calls)
+	defer server.Close()
+	client := NewClient(server.URL, ClientOptions{})
+
+	pages, err := client.ListPages(context.Background(), "wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[1].ID != "wiki:syntax" || pages[1].Size != 21492 || pages[1].LastModified.Unix() != 1700000100 {
+		t.Errorf("unexpected pages %+v", pages)
+	}
+	if want := "<struct><member><name>depth</name><value><int>0</int></value></member></struct>"; !strings.Contains(calls[0], want) {
+		t.Errorf("unexpected request %s", calls[0])
+	}
+
+	attachments, err := client.GetAttachments(context.Background(), "wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AttachmentInfo{ID: "wiki:dokuwiki-128.png", Size: 30290, LastModified: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), IsImage: true}
+	if len(attachments) != 1 || attachments[0] != want {
+		t.Errorf("unexpected attachments %+v", attachments)
+	}
+}
+
+func TestLoginKeepsSession(t *testing.T) {
+	var cookies []string
+	var calls []string
+	server := fixtureServer(t, &calls)
+	defer server.Close()
+
+	client := NewClient(server.URL, ClientOptions{HTTPClient: doerFunc(func(req *http.Request) (*http.Response, error) {
+		cookies = append(cookies, req.Header.Get("Cookie"))
+		return http.DefaultClient.Do(req)
+	})})
+	if err := client.Login(context.Background(), "admin", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetPageSource(context.Background(), "start"); err != nil {
+		t.Fatal(err)
+	}
+	if cookies[0] != "" || cookies[1] != "DokuWiki=session" {
+		t.Errorf("unexpected cookies %q", cookies)
+	}
+}
+
+func TestRateLimitAndContext(t *testing.T) {
+	var calls []string
+	server := fixtureServer(t, &calls)
+	defer server.Close()
+	client := NewClient(server.URL, ClientOptions{MinInterval: 50 * time.Millisecond})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetPageSource(context.Background(), "start"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three calls took %v, expected at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetPageSource(ctx, "start"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,257 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault is an XML-RPC fault returned by the wiki, like a missing permission.
+type Fault struct {
+	Code    int
+	Message string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("xmlrpc fault %d: %s", f.Code, f.Message)
+}
+
+// iso8601 is the dateTime.iso8601 layout DokuWiki uses.
+const iso8601 = "20060102T15:04:05"
+
+// encodeCall builds a methodCall document. Supported parameter types are string, int, int64, bool,
+// float64, time.Time, []byte, []interface{} and map[string]interface{}.
+func encodeCall(method string, params ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodCall><methodName>")
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString("</methodName><params>")
+	for _, param := range params {
+		buf.WriteString("<param>")
+		if err := encodeValue(&buf, param); err != nil {
+			return nil, err
+		}
+		buf.WriteString("</param>")
+	}
+	buf.WriteString("</params></methodCall>")
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, value interface{}) error {
+	buf.WriteString("<value>")
+	switch v := value.(type) {
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<int>%d</int>", v)
+	case int64:
+		fmt.Fprintf(buf, "<int>%d</int>", v)
+	case bool:
+		if v {
+			buf.WriteString("<boolean>1</boolean>")
+		} else {
+			buf.WriteString("<boolean>0</boolean>")
+		}
+	case float64:
+		fmt.Fprintf(buf, "<double>%s</double>", strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		fmt.Fprintf(buf, "<dateTime.iso8601>%s</dateTime.iso8601>", v.UTC().Format(iso8601))
+	case []byte:
+		fmt.Fprintf(buf, "<base64>%s</base64>", base64.StdEncoding.EncodeToString(v))
+	case []interface{}:
+		buf.WriteString("<array><data>")
+		for _, item := range v {
+			if err := encodeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	case map[string]interface{}:
+		// sorted so the request body is stable.
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf.WriteString("<struct>")
+		for _, name := range names {
+			buf.WriteString("<member><name>")
+			xml.EscapeText(buf, []byte(name))
+			buf.WriteString("</name>")
+			if err := encodeValue(buf, v[name]); err != nil {
+				return err
+			}
+			buf.WriteString("</member>")
+		}
+		buf.WriteString("</struct>")
+	default:
+		return fmt.Errorf("xmlrpc: can not encode %T", value)
+	}
+	buf.WriteString("</value>")
+	return nil
+}
+
+// decodeResponse reads a methodResponse document and returns its single value,
+// a fault response is returned as a *Fault error.
+func decodeResponse(r io.Reader) (interface{}, error) {
+	d := xml.NewDecoder(r)
+	isFault := false
+	for {
+		token, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("xmlrpc: response has no value")
+			}
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "fault":
+			isFault = true
+		case "value":
+			value, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			if isFault {
+				return nil, toFault(value)
+			}
+			return value, nil
+		}
+	}
+}
+
+func toFault(value interface{}) *Fault {
+	fault := &Fault{}
+	if members, ok := value.(map[string]interface{}); ok {
+		if code, ok := members["faultCode"].(int64); ok {
+			fault.Code = int(code)
+		}
+		fault.Message, _ = members["faultString"].(string)
+	}
+	return fault
+}
+
+// decodeValue decodes the content of a <value> element, the start element has already been read.
+func decodeValue(d *xml.Decoder) (interface{}, error) {
+	var text strings.Builder
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			// a value without a type element is a string.
+			return text.String(), nil
+		case xml.StartElement:
+			value, err := decodeTyped(d, t.Name.Local)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+			return value, nil
+		}
+	}
+}
+
+func decodeTyped(d *xml.Decoder, kind string) (interface{}, error) {
+	switch kind {
+	case "array":
+		items := make([]interface{}, 0)
+		for {
+			token, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "value" {
+					item, err := decodeValue(d)
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+				}
+			case xml.EndElement:
+				if t.Name.Local == "array" {
+					return items, nil
+				}
+			}
+		}
+	case "struct":
+		members := make(map[string]interface{})
+		name := ""
+		for {
+			token, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "name":
+					var n string
+					if err := d.DecodeElement(&n, &t); err != nil {
+						return nil, err
+					}
+					name = strings.TrimSpace(n)
+				case "value":
+					value, err := decodeValue(d)
+					if err != nil {
+						return nil, err
+					}
+					members[name] = value
+				}
+			case xml.EndElement:
+				if t.Name.Local == "struct" {
+					return members, nil
+				}
+			}
+		}
+	}
+
+	var text string
+	if err := d.DecodeElement(&text, &xml.StartElement{Name: xml.Name{Local: kind}}); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "string":
+		return text, nil
+	case "int", "i4", "i8":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "boolean":
+		return strings.TrimSpace(text) == "1", nil
+	case "double":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "dateTime.iso8601":
+		text = strings.TrimSpace(text)
+		for _, layout := range []string{iso8601, "2006-01-02T15:04:05Z07:00", "20060102T15:04:05Z07:00"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("xmlrpc: bad dateTime.iso8601 %q", text)
+	case "base64":
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	case "nil":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("xmlrpc: unknown value type %q", kind)
+}
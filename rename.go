@@ -0,0 +1,135 @@
+package dokuwiki
+
+import (
+	"sort"
+	"strings"
+)
+
+// RewriteOptions configures RewriteLinksWithOptions, the zero value is usable.
+type RewriteOptions struct {
+	// Media applies the renames to media IDs too.
+	Media bool
+}
+
+// RewriteResult tells what RewriteLinks changed in a page.
+type RewriteResult struct {
+	// NewID is the ID of the page after the renames, the same as before when it was not renamed.
+	NewID string
+	// LinksChanged and MediaChanged count the rewritten link targets and media IDs.
+	LinksChanged int
+	MediaChanged int
+}
+
+// NeedsWrite is true when the content of the page changed and it has to be saved.
+func (r RewriteResult) NeedsWrite() bool {
+	return r.LinksChanged > 0 || r.MediaChanged > 0
+}
+
+// RewriteLinks updates the internal links of pages, which are keyed by page ID, after the pages
+// in renames, which maps old IDs to new ones, have been moved. The units are changed in place,
+// write them back with DokuWikiRenderer.
+//
+// Links to a renamed page get its new ID, and the relative links of a page that moves to another
+// namespace are fixed so they still point to the same pages. A link that was relative stays
+// relative when its target is in the namespace of the page, a link to a namespace is left alone.
+// The result has an entry for every page, keyed by its old ID. It fails with ErrFrozen, before
+// changing any page, when one of them is frozen.
+func RewriteLinks(pages map[string]*ParseUnit, renames map[string]string) (map[string]RewriteResult, error) {
+	return RewriteLinksWithOptions(pages, renames, RewriteOptions{})
+}
+
+func RewriteLinksWithOptions(pages map[string]*ParseUnit, renames map[string]string, opts RewriteOptions) (map[string]RewriteResult, error) {
+	exists := func(id string) bool {
+		_, ok := pages[id]
+		return ok
+	}
+	renamed := func(id string) string {
+		if newID, ok := renames[id]; ok {
+			return cleanID(newID)
+		}
+		return id
+	}
+
+	ids := make([]string, 0, len(pages))
+	for id, unit := range pages {
+		if unit.frozen {
+			return nil, ErrFrozen
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make(map[string]RewriteResult, len(pages))
+	for _, from := range ids {
+		result := RewriteResult{NewID: renamed(from)}
+		// rewrite returns the target to write on the page after the renames, ok is false when
+		// the target needs no change.
+		rewrite := func(target string) (string, bool) {
+			if target == "" || strings.HasPrefix(target, "#") || strings.HasSuffix(strings.SplitN(target, "#", 2)[0], ":") {
+				return "", false
+			}
+			oldID, anchor := resolveID(from, target, exists)
+			newID := renamed(oldID)
+			if id, _ := resolveID(result.NewID, target, nil); id == newID {
+				return "", false
+			}
+			newTarget := relativeTarget(result.NewID, newID, target)
+			if anchor != "" {
+				newTarget += "#" + anchor
+			}
+			return newTarget, true
+		}
+
+		Walk(pages[from], func(c Context) bool {
+			switch c := c.(type) {
+			case *HyperLinkContext:
+				if c.Kind != LinkInternal {
+					break
+				}
+				if target, ok := rewrite(c.HyperLink); ok {
+					if c.Text == c.HyperLink {
+						c.Text = target
+					}
+					c.SetTarget(target)
+					result.LinksChanged++
+				}
+			case *MediaContext:
+				if !opts.Media || c.IsExternal {
+					break
+				}
+				if target, ok := rewrite(c.MediaResouce); ok {
+					c.MediaResouce = target
+					if c.ResolvedID != "" {
+						c.ResolvedID = ResolvePageID(result.NewID, target)
+					}
+					result.MediaChanged++
+				}
+			}
+			return true
+		})
+		results[from] = result
+	}
+	return results, nil
+}
+
+// relativeTarget writes the link to targetID found on page pageID, in the same style as original:
+// relative when original was and targetID is in the namespace of the page, absolute otherwise.
+func relativeTarget(pageID, targetID, original string) string {
+	namespace := ""
+	if i := strings.LastIndexByte(pageID, ':'); i != -1 {
+		namespace = pageID[:i]
+	}
+	wasRelative := strings.HasPrefix(original, ".") || (!strings.HasPrefix(original, ":") && !strings.Contains(strings.SplitN(original, "#", 2)[0], ":"))
+	if wasRelative {
+		if name, ok := strings.CutPrefix(targetID, joinID(namespace, "")); ok && (namespace != "" || !strings.Contains(targetID, ":")) {
+			if strings.HasPrefix(original, ".") || strings.Contains(name, ":") {
+				return ".:" + name
+			}
+			return name
+		}
+	}
+	if strings.HasPrefix(original, ":") || !strings.Contains(targetID, ":") {
+		return ":" + targetID
+	}
+	return targetID
+}
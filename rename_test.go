@@ -0,0 +1,67 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:start":  Parse([]byte("[[old]] [[old#Intro|intro]] [[:wiki:old]] [[wiki:old]] [[.:old]] [[other]] [[wiki:]] {{old.png}}\n"), "start"),
+		"wiki:old":    Parse([]byte("[[start]] [[:top]] [[sub:deep]]\n"), "old"),
+		"elsewhere":   Parse([]byte("[[wiki:old|the page]]\n"), "elsewhere"),
+		"wiki:other":  Parse([]byte("nothing to change\n"), "other"),
+		"wiki:sub:in": Parse([]byte("[[..:old]]\n"), "in"),
+	}
+	renames := map[string]string{"wiki:old": "wiki:new", "wiki:old.png": "wiki:new.png"}
+	results, err := RewriteLinksWithOptions(pages, renames, RewriteOptions{Media: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"wiki:start":  "[[new]] [[new#Intro|intro]] [[:wiki:new]] [[wiki:new]] [[.:new]] [[other]] [[wiki:]] {{new.png}}\n",
+		"wiki:old":    "[[start]] [[:top]] [[sub:deep]]\n",
+		"elsewhere":   "[[wiki:new|the page]]\n",
+		"wiki:other":  "nothing to change\n",
+		"wiki:sub:in": "[[wiki:new]]\n",
+	}
+	for id, content := range want {
+		var out bytes.Buffer
+		NewDokuWikiRenderer(RendererOptions{}).Render(&out, pages[id])
+		if out.String() != content {
+			t.Errorf("%s: got %q, want %q", id, out.String(), content)
+		}
+	}
+	if r := results["wiki:start"]; r.LinksChanged != 5 || r.MediaChanged != 1 || !r.NeedsWrite() {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := results["wiki:old"]; r.NewID != "wiki:new" || r.NeedsWrite() {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestRewriteLinksMovedPage(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"wiki:page":   Parse([]byte("[[sibling]] [[.:sibling#top]] [[:start]] [[ns:abs]]\n"), "page"),
+		"wiki:target": Parse([]byte("[[page]]\n"), "target"),
+	}
+	results, err := RewriteLinks(pages, map[string]string{"wiki:page": "archive:page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&out, pages["wiki:page"])
+	if want := "[[wiki:sibling]] [[wiki:sibling#top]] [[:start]] [[ns:abs]]\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+	out.Reset()
+	NewDokuWikiRenderer(RendererOptions{}).Render(&out, pages["wiki:target"])
+	if want := "[[archive:page]]\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+	if results["wiki:page"].LinksChanged != 2 {
+		t.Errorf("unexpected result %+v", results["wiki:page"])
+	}
+}
@@ -0,0 +1,308 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Renderer writes a parsed unit out in some output format.
+type Renderer interface {
+	Render(w io.Writer, unit *ParseUnit) error
+}
+
+// SanitizeMode decides what renderers do with the content of html tags.
+type SanitizeMode int
+
+const (
+	// SanitizeEscape shows embedded html as text, like DokuWiki does when htmlok is off.
+	SanitizeEscape SanitizeMode = iota
+	// SanitizeStrip drops embedded html.
+	SanitizeStrip
+	// SanitizeNone passes embedded html through untouched, only use it for trusted input.
+	SanitizeNone
+)
+
+var sanitizeModeNames = []string{"escape", "strip", "none"}
+
+func (m SanitizeMode) String() string {
+	if m >= 0 && int(m) < len(sanitizeModeNames) {
+		return sanitizeModeNames[m]
+	}
+	return fmt.Sprintf("SanitizeMode(%d)", int(m))
+}
+
+// ParseSanitizeMode is the inverse of SanitizeMode.String.
+func ParseSanitizeMode(name string) (SanitizeMode, error) {
+	for i, n := range sanitizeModeNames {
+		if n == name {
+			return SanitizeMode(i), nil
+		}
+	}
+	return SanitizeEscape, fmt.Errorf("unknown sanitize mode %q", name)
+}
+
+// ExtensionMode decides what renderers do with the extension contexts they have no node
+// renderer for.
+type ExtensionMode int
+
+const (
+	// ExtensionSkip leaves them out.
+	ExtensionSkip ExtensionMode = iota
+	// ExtensionSource writes the DokuWiki source of those implementing SourceContext as text,
+	// and leaves the others out.
+	ExtensionSource
+	// ExtensionError makes Render fail.
+	ExtensionError
+)
+
+var extensionModeNames = []string{"skip", "source", "error"}
+
+func (m ExtensionMode) String() string {
+	if m >= 0 && int(m) < len(extensionModeNames) {
+		return extensionModeNames[m]
+	}
+	return fmt.Sprintf("ExtensionMode(%d)", int(m))
+}
+
+// ParseExtensionMode is the inverse of ExtensionMode.String.
+func ParseExtensionMode(name string) (ExtensionMode, error) {
+	for i, n := range extensionModeNames {
+		if n == name {
+			return ExtensionMode(i), nil
+		}
+	}
+	return ExtensionSkip, fmt.Errorf("unknown extension mode %q", name)
+}
+
+// RendererOptions are shared by all renderers, each one uses what makes sense for its format.
+// The zero value is usable.
+type RendererOptions struct {
+	// BaseURL is put in front of internal page IDs to build links, media is linked
+	// below BaseURL + "_media/" like DokuWiki does with URL rewriting on.
+	BaseURL string
+	// HeadingOffset pushes headings down, with 1 a ====== heading is rendered as a second level heading.
+	HeadingOffset int
+	// Sanitize decides what happens to the content of html tags.
+	Sanitize SanitizeMode
+
+	// LinkResolver, when set, builds the URL of internal page links instead of BaseURL.
+	LinkResolver LinkResolver
+	// MediaResolver, when set, builds the URL of internal media instead of BaseURL. The html
+	// renderer links images to their detail page, built by the resolver when it is a
+	// MediaDetailResolver too, or else below BaseURL + "lib/exe/detail.php" like DokuWiki does.
+	MediaResolver MediaResolver
+	// InterWiki resolves interwiki links, the target of those it does not know is used as is.
+	// Those pointing to a page of the wiki are resolved like internal links.
+	InterWiki InterWikiMap
+	// Acronyms are marked up with their meaning in the text by the html renderer, like
+	// <abbr title="HyperText Markup Language">HTML</abbr>.
+	Acronyms Acronyms
+	// UnknownExtension decides what happens to the extension contexts of syntax plugins that
+	// have no node renderer registered.
+	UnknownExtension ExtensionMode
+	// NumberSections puts the number of every heading in the section tree before its text, like
+	// "1.2 Setup", numbered from the depths the headings are rendered at, so after HeadingOffset.
+	// The numbers start at the first depth used in the page and restart below every heading,
+	// NumberSkipTopLevel leaves the headings of depth 1 without one. Anchors stay made of the
+	// text without the number. The LaTeX renderer leaves numbering to LaTeX, the DokuWiki one
+	// writes the headings as they are.
+	NumberSections     bool
+	NumberSkipTopLevel bool
+	// TOC makes the html renderer write the table of contents box of DokuWiki before the page,
+	// unless the page has ~~NOTOC~~ or no heading in the table.
+	TOC bool
+	// TOCTopLevel and TOCMaxLevel are the levels of the headings in the table of contents, the
+	// toptoclevel and maxtoclevel of DokuWiki, 1 for ====== down to 5 for ==. They are 1 and 3
+	// when zero, and a ~~TOC:2-4~~ macro of the page sets them for that page.
+	TOCTopLevel int
+	TOCMaxLevel int
+	// Compact makes the html renderer leave out the white space that does not change how the
+	// page looks: the new lines and the indentation between the tags are left out and the runs
+	// of white space of the text are written as one space, kept between inline elements. The
+	// content of pre, code and textarea tags is written as is.
+	Compact bool
+	// Validate makes Render check the unit with ParseUnit.Validate first, it fails with the
+	// problems found without writing anything. It is meant for debugging the code that builds
+	// or changes trees.
+	Validate bool
+}
+
+// LinkResolver maps the ID of an internal page link to a URL, the anchor, if any,
+// is appended to the result by the renderer.
+type LinkResolver interface {
+	PageHref(pageID string) string
+}
+
+// LinkResolverFunc is an ordinary function used as a LinkResolver.
+type LinkResolverFunc func(pageID string) string
+
+func (f LinkResolverFunc) PageHref(pageID string) string {
+	return f(pageID)
+}
+
+// MediaResolver maps the ID of an internal media file to a URL.
+type MediaResolver interface {
+	MediaHref(mediaID string) string
+}
+
+// MediaDetailResolver is implemented by the MediaResolvers that map the ID of an internal media
+// file to the URL of its detail page too.
+type MediaDetailResolver interface {
+	DetailHref(mediaID string) string
+}
+
+// MediaResolverFunc is an ordinary function used as a MediaResolver.
+type MediaResolverFunc func(mediaID string) string
+
+func (f MediaResolverFunc) MediaHref(mediaID string) string {
+	return f(mediaID)
+}
+
+// headingLabels are what the renderers write with the text of the headings, their ids and numbers.
+type headingLabels struct {
+	ids     map[*SectionHeaderContext]string
+	numbers map[*SectionHeaderContext]string
+}
+
+// headingDepth maps a DokuWiki header level (6 for ======) to a heading depth starting at 1,
+// taking the heading offset into account.
+func (o RendererOptions) headingDepth(headerLevel int) int {
+	depth := 7 - headerLevel + o.HeadingOffset
+	if depth < 1 {
+		depth = 1
+	} else if depth > 6 {
+		depth = 6
+	}
+	return depth
+}
+
+func (o RendererOptions) linkURL(link *HyperLinkContext) string {
+	switch link.Kind {
+	case LinkEmail:
+		return "mailto:" + strings.TrimPrefix(link.HyperLink, "mailto:")
+	case LinkWindowsShare:
+		return "file:" + strings.Replace(link.HyperLink, `\`, "/", -1)
+	case LinkInterwiki:
+		target, ok := o.InterWiki.URL(link.HyperLink)
+		if !ok {
+			return link.HyperLink
+		}
+		if !strings.HasPrefix(target, ":") {
+			return target
+		}
+		return o.pageURL(strings.TrimPrefix(target, ":"))
+	case LinkExternal:
+		// an invalid URL is not written out, Parse reports it.
+		if _, err := url.Parse(link.HyperLink); err != nil {
+			return ""
+		}
+		return link.HyperLink
+	}
+	return o.pageURL(link.HyperLink)
+}
+
+// pageURL builds the URL of an internal link target, which can have an anchor.
+func (o RendererOptions) pageURL(target string) string {
+	if o.LinkResolver == nil {
+		return o.BaseURL + target
+	}
+	pageID, anchor := target, ""
+	if i := strings.IndexByte(pageID, '#'); i != -1 {
+		pageID, anchor = pageID[:i], pageID[i:]
+	}
+	return o.LinkResolver.PageHref(pageID) + anchor
+}
+
+func (o RendererOptions) mediaURL(media *MediaContext) string {
+	if media.IsExternal {
+		return media.MediaResouce
+	}
+	id := mediaID(media)
+	if o.MediaResolver != nil {
+		return o.MediaResolver.MediaHref(id)
+	}
+	return o.BaseURL + "_media/" + id
+}
+
+// mediaDetailURL builds the URL of the detail page of internal media, with the ID of the page
+// when the unit was parsed with one, external media has none and links to itself.
+func (o RendererOptions) mediaDetailURL(media *MediaContext) string {
+	if media.IsExternal {
+		return media.MediaResouce
+	}
+	id := mediaID(media)
+	if resolver, ok := o.MediaResolver.(MediaDetailResolver); ok {
+		return resolver.DetailHref(id)
+	}
+	detail := o.BaseURL + "lib/exe/detail.php?media=" + url.QueryEscape(id)
+	if unit := unitOf(media); unit != nil && unit.pageID != "" {
+		detail += "&id=" + url.QueryEscape(unit.pageID)
+	}
+	return detail
+}
+
+// mediaID is the ID of internal media, resolved when the page was parsed with its ID.
+func mediaID(media *MediaContext) string {
+	if media.ResolvedID != "" {
+		return media.ResolvedID
+	}
+	return media.MediaResouce
+}
+
+// renderUnknownExtension handles an extension context without node renderer, text writes
+// its source in the format of the renderer.
+func (o RendererOptions) renderUnknownExtension(rw *renderWriter, node ExtensionContext, text func(string)) {
+	switch o.UnknownExtension {
+	case ExtensionSource:
+		if source, ok := node.(SourceContext); ok {
+			text(source.WikiSource())
+		}
+	case ExtensionError:
+		if rw.err == nil {
+			rw.err = fmt.Errorf("no node renderer for extension %q", node.Kind())
+		}
+	}
+}
+
+// renderNode calls the node renderer fn, its error is kept like a write error.
+func (rw *renderWriter) renderNode(fn func(w io.Writer) error) {
+	if rw.err == nil {
+		rw.err = fn(rw.w)
+	}
+}
+
+// effectOrder is the nesting order of text effects, outermost first.
+var effectOrder = []uint32{TextEffectBold, TextEffectItalic, TextEffectUnderline, TextEffectMonoSpace}
+
+// renderWriter keeps the first write error, so renderers can write without checking every call.
+type renderWriter struct {
+	w   io.Writer
+	err error
+	// effects are the delimiters of the registered effects of the unit written.
+	effects map[TextEffect]string
+	// anchors are the ids of the explicit anchors of the unit written.
+	anchors map[*AnchorContext]string
+}
+
+// anchorID returns the id of the explicit anchor in the unit written, its own one when it is
+// rendered without its unit.
+func (rw *renderWriter) anchorID(c *AnchorContext) string {
+	if id, ok := rw.anchors[c]; ok {
+		return id
+	}
+	return c.ID()
+}
+
+func (rw *renderWriter) write(s string) {
+	if rw.err == nil {
+		_, rw.err = io.WriteString(rw.w, s)
+	}
+}
+
+func (rw *renderWriter) printf(format string, args ...interface{}) {
+	if rw.err == nil {
+		_, rw.err = fmt.Fprintf(rw.w, format, args...)
+	}
+}
@@ -0,0 +1,262 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const renderDoc = "====== Title ======\nSome **bold** and [[page|a link]].\n\n  * one\n    * two\n  - ord\n\n<code go>\nx := 1\n</code>\n"
+
+func TestRenderers(t *testing.T) {
+	opts := RendererOptions{BaseURL: "/wiki/", HeadingOffset: 1}
+	tests := []struct {
+		name     string
+		renderer Renderer
+		want     string
+	}{
+		{"html", NewHTMLRenderer(opts), `
+<h2 id="title">Title</h2>
+
+<p>
+Some <strong>bold</strong> and <a href="/wiki/page" class="wikilink1" title="page">a link</a>.
+</p>
+<ul>
+<li class="level1 node"><div class="li">one</div>
+<ul>
+<li class="level2"><div class="li">two</div></li>
+</ul>
+</li>
+</ul>
+<ol>
+<li class="level1"><div class="li">ord</div></li>
+</ol>
+<pre class="code go">x := 1
+</pre>
+`},
+		{"markdown", NewMarkdownRenderer(opts), "## Title\n\nSome **bold** and [a link](/wiki/page).\n\n- one\n  - two\n\n1. ord\n\n```go\nx := 1\n```\n"},
+		{"text", NewTextRenderer(opts), "Title\n\nSome bold and a link.\n\n* one\n  * two\n\n1. ord\n\nx := 1\n\n"},
+		{"latex", NewLaTeXRenderer(opts), `\subsection{Title}\label{title}
+
+Some \textbf{bold} and \href{/wiki/page}{a link}.
+
+\begin{itemize}
+\item one
+\begin{itemize}
+\item two
+\end{itemize}
+\end{itemize}
+
+\begin{enumerate}
+\item ord
+\end{enumerate}
+
+\begin{verbatim}
+x := 1
+\end{verbatim}
+
+
+`},
+	}
+
+	unit := Parse([]byte(renderDoc), "doc")
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.renderer.Render(&buf, unit); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestHTMLSanitize(t *testing.T) {
+	unit := Parse([]byte("a <html><b>x</b></html> b"), "doc")
+	want := map[SanitizeMode]string{
+		SanitizeEscape: "\n<p>\na <code class=\"code html4strict\">&lt;b&gt;x&lt;/b&gt;</code> b\n</p>\n",
+		SanitizeStrip:  "\n<p>\na  b\n</p>\n",
+		SanitizeNone:   "\n<p>\na <b>x</b> b\n</p>\n",
+	}
+	for mode, html := range want {
+		var buf bytes.Buffer
+		NewHTMLRenderer(RendererOptions{Sanitize: mode}).Render(&buf, unit)
+		if buf.String() != html {
+			t.Errorf("%s: got %q, want %q", mode, buf.String(), html)
+		}
+	}
+}
+
+func TestHTMLBlock(t *testing.T) {
+	unit := Parse([]byte("a <HTML><div>x</div></HTML> b"), "doc")
+	if html := unit.Sections[0].(*ParaContext).InnerContexts[1].(*HTMLContext); !html.IsBlock {
+		t.Errorf("<HTML> is not a block")
+	}
+	want := map[SanitizeMode]string{
+		SanitizeEscape: "\n<p>\na \n</p>\n<pre class=\"code html4strict\">&lt;div&gt;x&lt;/div&gt;</pre>\n\n<p>\n b\n</p>\n",
+		SanitizeNone:   "\n<p>\na \n</p>\n<div>x</div>\n<p>\n b\n</p>\n",
+	}
+	for mode, html := range want {
+		var buf bytes.Buffer
+		NewHTMLRenderer(RendererOptions{Sanitize: mode}).Render(&buf, unit)
+		if buf.String() != html {
+			t.Errorf("%s: got %q, want %q", mode, buf.String(), html)
+		}
+	}
+
+	var wiki bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+	if wiki.String() != "a <HTML><div>x</div></HTML> b\n" {
+		t.Errorf("serialized %q", wiki.String())
+	}
+}
+
+func TestTOCAnchors(t *testing.T) {
+	unit := Parse([]byte("== Section ==\n== Section ==\n== Section ==\n== 1. Intro: a.b ==\n"), "doc")
+	want := []string{"section", "section1", "section2", "introab"}
+	toc := unit.TOCWithOptions(RendererOptions{TOCMaxLevel: 5})
+	if len(toc) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(toc), len(want))
+	}
+	for i, entry := range toc {
+		if entry.Anchor != want[i] {
+			t.Errorf("entry %d: got anchor %q, want %q", i, entry.Anchor, want[i])
+		}
+	}
+}
+
+func TestTOCLevels(t *testing.T) {
+	content := "====== A ======\n\n===== B =====\n\n=== C ===\n\n== D ==\n"
+	anchors := func(entries []TOCEntry) string {
+		ids := make([]string, 0)
+		for _, entry := range entries {
+			ids = append(ids, entry.Anchor)
+		}
+		return strings.Join(ids, " ")
+	}
+	unit := Parse([]byte(content), "doc")
+	tests := []struct {
+		opts RendererOptions
+		want string
+	}{
+		{RendererOptions{}, "a b"},
+		{RendererOptions{TOCMaxLevel: 4}, "a b c"},
+		{RendererOptions{TOCTopLevel: 2, TOCMaxLevel: 5}, "b c d"},
+		{RendererOptions{TOCTopLevel: 3}, ""},
+	}
+	for _, test := range tests {
+		if got := anchors(unit.TOCWithOptions(test.opts)); got != test.want {
+			t.Errorf("%d-%d: got %q, want %q", test.opts.TOCTopLevel, test.opts.TOCMaxLevel, got, test.want)
+		}
+	}
+	if got := anchors(unit.TOC()); got != "a b" || len(unit.Outline()) != 4 {
+		t.Errorf("got %q and an outline of %d", got, len(unit.Outline()))
+	}
+
+	// the macro of the page wins over the options.
+	unit = Parse([]byte("~~TOC:2-4~~\n"+content), "doc")
+	if got := anchors(unit.TOCWithOptions(RendererOptions{TOCMaxLevel: 5})); got != "b c" {
+		t.Errorf("with ~~TOC:2-4~~: got %q", got)
+	}
+	var out bytes.Buffer
+	NewHTMLRenderer(RendererOptions{TOC: true}).Render(&out, unit)
+	want := "<!-- TOC START -->\n<div id=\"dw__toc\" class=\"dw__toc\">\n<h3 class=\"toggle\">Table of Contents</h3>\n<div>\n\n" +
+		"<ul class=\"toc\">\n<li class=\"level1\"><div class=\"li\"><a href=\"#b\">B</a></div>\n" +
+		"<ul class=\"toc\">\n<li class=\"clear\">\n<ul class=\"toc\">\n<li class=\"level3\"><div class=\"li\"><a href=\"#c\">C</a></div></li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n" +
+		"</div>\n</div>\n<!-- TOC END -->\n\n<h1 id=\"a\">A</h1>\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got\n%s\nwant it to start with\n%s", out.String(), want)
+	}
+	var wiki bytes.Buffer
+	NewDokuWikiRenderer(RendererOptions{}).Render(&wiki, unit)
+	if !strings.HasPrefix(wiki.String(), "~~TOC:2-4~~\n") {
+		t.Errorf("serialized %q", wiki.String())
+	}
+
+	for _, content := range []string{"~~NOTOC~~\n" + content, "=== C ===\n"} {
+		out.Reset()
+		NewHTMLRenderer(RendererOptions{TOC: true}).Render(&out, Parse([]byte(content), "doc"))
+		if strings.Contains(out.String(), "TOC START") {
+			t.Errorf("%q: got a table of contents in %s", content, out.String())
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	Dump(&buf, Parse([]byte("== H ==\n  * **a**\n"), "doc"))
+	want := `ParseUnit "doc"
+  SectionHeader level=2 "H"
+    Text "H"
+  List level=1 unordered
+    ListItem depth=1 indent=2
+      Para
+        Text effect=bold "a"
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestHTMLAcronyms(t *testing.T) {
+	acronyms := Acronyms{"HTML": "HyperText Markup Language", "CSS": "Cascading Style Sheets", "CSS3": "CSS level 3", "e.g.": "for example", "ÄÖ": "umlauts"}
+	unit := Parse([]byte("HTML5, **HTML** and CSS3/CSS e.g. here, html and xHTML or ÄÖx [[css|CSS]] ''CSS'' http://example.com/CSS\n"), "doc")
+	var buf bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{Acronyms: acronyms}).Render(&buf, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `HTML5, <strong><abbr title="HyperText Markup Language">HTML</abbr></strong> and <abbr title="CSS level 3">CSS3</abbr>/<abbr title="Cascading Style Sheets">CSS</abbr> <abbr title="for example">e.g.</abbr> here, html and xHTML or ÄÖx <a href="css" class="wikilink1" title="css">CSS</a> &#39;&#39;<abbr title="Cascading Style Sheets">CSS</abbr>&#39;&#39; <a href="http://example.com/CSS"`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+// TestHTMLWellFormed renders the pages of the test corpora, pretty and compact, and reads the
+// html back as XML.
+func TestHTMLWellFormed(t *testing.T) {
+	names, err := filepath.Glob("testdata/*/*.txt")
+	if err != nil || len(names) == 0 {
+		t.Fatalf("no pages: %v", err)
+	}
+	for _, name := range names {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := ParseWithOptions(content, name, ParseOptions{DefinitionLists: true})
+		for _, compact := range []bool{false, true} {
+			var out bytes.Buffer
+			if err := NewHTMLRenderer(RendererOptions{Compact: compact}).Render(&out, unit); err != nil {
+				t.Fatal(err)
+			}
+			decoder := xml.NewDecoder(strings.NewReader("<body>" + out.String() + "</body>"))
+			for {
+				if _, err := decoder.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("%s, compact %t: %v in\n%s", name, compact, err, out.String())
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestHTMLCompact(t *testing.T) {
+	content := "====== Title ======\nSome **bold**   //and//  text\n\n  * one\n  * two ``a  b``\n\n<code text>\n  indented\n    more\n</code>\n"
+	unit := Parse([]byte(content), "compact")
+	var out bytes.Buffer
+	if err := NewHTMLRenderer(RendererOptions{Compact: true}).Render(&out, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `<h1 id="title">Title</h1><p>Some <strong>bold</strong> <em>and</em> text</p>` +
+		`<ul><li class="level1"><div class="li">one</div></li><li class="level1"><div class="li">two <code>a  b</code></div></li></ul>` +
+		"<pre class=\"code text\">  indented\n    more\n</pre>"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
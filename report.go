@@ -0,0 +1,64 @@
+package dokuwiki
+
+import (
+	"sort"
+	"time"
+)
+
+// ReportOptions configures ReportWithOptions, the zero value gives the report of Report.
+type ReportOptions struct {
+	// WordsPerMinute is the reading speed the reading time is computed with, 200 when 0.
+	WordsPerMinute int
+	// Exists tells whether a page exists, for counting the broken links like CheckLinks does.
+	// When nil a page exists if it is in the report.
+	Exists func(pageID string) bool
+}
+
+// PageReport is the health of a page, as given by Report.
+type PageReport struct {
+	PageID string
+	// Words counts the words like Stats, code left out, and ReadingTime is the time they take
+	// to read.
+	Words       int
+	ReadingTime time.Duration
+	// Fixmes are the FIXME and DELETEME markers, like in the metadata.
+	Fixmes []Fixme
+	// BrokenLinks are the internal links to a page or a section that does not exist.
+	BrokenLinks []BrokenLink
+	// HeadingWarnings are the findings of the heading-levels and top-heading lint rules.
+	HeadingWarnings []Finding
+}
+
+// Report gives a PageReport for every page of pages, which are keyed by page ID, sorted by page ID.
+func Report(pages map[string]*ParseUnit) []PageReport {
+	return ReportWithOptions(pages, ReportOptions{})
+}
+
+// ReportWithOptions is Report with the reading speed and the pages that exist set by opts.
+func ReportWithOptions(pages map[string]*ParseUnit, opts ReportOptions) []PageReport {
+	wpm := opts.WordsPerMinute
+	if wpm <= 0 {
+		wpm = 200
+	}
+	broken := make(map[string][]BrokenLink)
+	for _, link := range CheckLinks(pages, opts.Exists) {
+		broken[link.From] = append(broken[link.From], link)
+	}
+
+	reports := make([]PageReport, 0, len(pages))
+	for id, unit := range pages {
+		words := unit.Stats().Words
+		reports = append(reports, PageReport{
+			PageID:          id,
+			Words:           words,
+			ReadingTime:     time.Duration(words) * time.Minute / time.Duration(wpm),
+			Fixmes:          unit.Metadata().Fixmes,
+			BrokenLinks:     broken[id],
+			HeadingWarnings: Lint(unit, headingLevelsRule, topHeadingRule),
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].PageID < reports[j].PageID
+	})
+	return reports
+}
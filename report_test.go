@@ -0,0 +1,76 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"guide": Parse([]byte("==== Intro ====\n"+strings.Repeat("word ", 300)+"FIXME\n"+
+			"<code go>\nnot counted at all\n</code>\n\n"+
+			"====== Guide ======\nSee [[start]], [[missing]] and [[start#nowhere]].\n"), "guide"),
+		"start": Parse([]byte("some text DELETEME\n"), "start"),
+	}
+	reports := Report(pages)
+	if len(reports) != 2 || reports[0].PageID != "guide" || reports[1].PageID != "start" {
+		t.Fatalf("got %+v", reports)
+	}
+
+	guide := reports[0]
+	// the code is left out.
+	if guide.Words != pages["guide"].Stats().Words || guide.Words < 300 || guide.Words > 310 {
+		t.Errorf("words: got %d", guide.Words)
+	}
+	if want := time.Duration(guide.Words) * time.Minute / 200; guide.ReadingTime != want {
+		t.Errorf("reading time: got %v, want %v", guide.ReadingTime, want)
+	}
+	if len(guide.Fixmes) != 1 || guide.Fixmes[0].Text != "FIXME" || guide.Fixmes[0].Position.Line != 2 {
+		t.Errorf("fixmes: got %+v", guide.Fixmes)
+	}
+	targets := make([]string, 0)
+	for _, link := range guide.BrokenLinks {
+		targets = append(targets, link.Target)
+	}
+	if !reflect.DeepEqual(targets, []string{"missing", "start#nowhere"}) {
+		t.Errorf("broken links: got %v", targets)
+	}
+	if !reflect.DeepEqual(guide.HeadingWarnings, Lint(pages["guide"], headingLevelsRule, topHeadingRule)) ||
+		len(guide.HeadingWarnings) != 1 || guide.HeadingWarnings[0].Rule != "top-heading" {
+		t.Errorf("heading warnings: got %+v", guide.HeadingWarnings)
+	}
+
+	start := reports[1]
+	if len(start.Fixmes) != 1 || start.BrokenLinks != nil || len(start.HeadingWarnings) != 1 ||
+		start.HeadingWarnings[0].Message != "page has no heading" {
+		t.Errorf("start: got %+v", start)
+	}
+
+	slow := ReportWithOptions(pages, ReportOptions{WordsPerMinute: 60, Exists: func(string) bool { return true }})
+	if slow[0].ReadingTime != time.Duration(guide.Words)*time.Second {
+		t.Errorf("at 60 words a minute: got %v", slow[0].ReadingTime)
+	}
+	if len(slow[0].BrokenLinks) != 1 || !slow[0].BrokenLinks[0].MissingAnchor {
+		t.Errorf("with every page existing: got %+v", slow[0].BrokenLinks)
+	}
+}
+
+func TestTopHeadingRule(t *testing.T) {
+	for content, want := range map[string]string{
+		"====== A ======\n==== B ====\n": "",
+		"==== A ====\n====== B ======\n": `1 first heading "A" is smaller than heading "B"`,
+		"text\n":                         "1 page has no heading",
+		"":                               "",
+	} {
+		got := ""
+		for _, finding := range Lint(Parse([]byte(content), "page"), topHeadingRule) {
+			got = fmt.Sprintf("%d %s", finding.Position.Line, finding.Message)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package dokuwiki
+
+import (
+	"encoding/gob"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Index is an in-memory inverted index over a set of pages, built by BuildIndex.
+// Its fields are exported so it can be saved with Save and read back with LoadIndex.
+type Index struct {
+	// Postings maps a token to the pages it appears in and a weighted count of its occurrences.
+	Postings map[string]map[string]int
+	// Pages holds what is needed to show a hit, keyed by page ID.
+	Pages map[string]IndexedPage
+	// Stopwords are the tokens left out of the index and of queries.
+	Stopwords map[string]bool
+}
+
+// IndexedPage is the text of one page kept in an Index.
+type IndexedPage struct {
+	Title    string
+	Headings []string
+	Body     string
+}
+
+// IndexOptions configures BuildIndexWithOptions.
+type IndexOptions struct {
+	// Stopwords are words not worth indexing, like "the", compared in lower case.
+	Stopwords []string
+}
+
+// Hit is a page found by Search.
+type Hit struct {
+	PageID string
+	Score  float64
+	// Heading is the first heading of the page containing a word of the query, if any.
+	Heading string
+	// Snippet is some text around the first match in the body of the page, if any.
+	Snippet string
+}
+
+// headingWeight is how much more an occurrence in a heading or the title counts than one in the body.
+const headingWeight = 3
+
+// BuildIndex indexes the text of pages, which are keyed by page ID.
+func BuildIndex(pages map[string]*ParseUnit) *Index {
+	return BuildIndexWithOptions(pages, IndexOptions{})
+}
+
+func BuildIndexWithOptions(pages map[string]*ParseUnit, opts IndexOptions) *Index {
+	index := &Index{
+		Postings:  make(map[string]map[string]int),
+		Pages:     make(map[string]IndexedPage, len(pages)),
+		Stopwords: make(map[string]bool),
+	}
+	for _, word := range opts.Stopwords {
+		index.Stopwords[strings.ToLower(word)] = true
+	}
+
+	for id, unit := range pages {
+		page := IndexedPage{
+			Title: unit.Title,
+			Body:  unit.PlainText(TextExtractOptions{Fields: FieldBody}),
+		}
+		for _, entry := range unit.Outline() {
+			page.Headings = append(page.Headings, entry.Text)
+		}
+		index.Pages[id] = page
+
+		index.add(id, page.Title, headingWeight)
+		for _, heading := range page.Headings {
+			index.add(id, heading, headingWeight)
+		}
+		index.add(id, page.Body, 1)
+	}
+	return index
+}
+
+func (index *Index) add(id, text string, weight int) {
+	for _, token := range index.tokens(text) {
+		postings := index.Postings[token]
+		if postings == nil {
+			postings = make(map[string]int)
+			index.Postings[token] = postings
+		}
+		postings[id] += weight
+	}
+}
+
+// tokens splits text into lower case words, leaving out stopwords.
+func (index *Index) tokens(text string) []string {
+	tokens := make([]string, 0)
+	for _, token := range tokenize(text) {
+		if !index.Stopwords[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// tokenize splits text into lower case words following the word boundary rules of Unicode
+// (UAX #29) in a simplified form: a word is a run of letters and digits, which may contain
+// a "." or "," between digits, like 1.2, and an apostrophe between letters, like don't.
+// Scripts written without spaces, like Chinese, have every character taken as a word.
+func tokenize(text string) []string {
+	tokens := make([]string, 0)
+	scanWords(text, false, func(start, end int) {
+		tokens = append(tokens, strings.ToLower(text[start:end]))
+	})
+	return tokens
+}
+
+// scanWords calls fn with the byte offsets of the words of text, found like tokenize does.
+// With hyphens a hyphen between letters keeps a word together too, like in well-known.
+func scanWords(text string, hyphens bool, fn func(start, end int)) {
+	isWord := func(r rune) bool {
+		return !isIdeograph(r) && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))
+	}
+	isLetter := func(r rune) bool {
+		return unicode.IsLetter(r) && !isIdeograph(r)
+	}
+	// joins tells whether r, between before and after, keeps the word around it together.
+	joins := func(before, r, after rune) bool {
+		switch r {
+		case '.', ',':
+			return unicode.IsDigit(before) && unicode.IsDigit(after)
+		case '\'', '’':
+			return isLetter(before) && isLetter(after)
+		case '-':
+			return hyphens && isLetter(before) && isLetter(after)
+		}
+		return false
+	}
+
+	// start is the offset of the word being scanned, -1 between words.
+	start := -1
+	var before rune
+	for offset, r := range text {
+		size := utf8.RuneLen(r)
+		if r == utf8.RuneError {
+			_, size = utf8.DecodeRuneInString(text[offset:])
+		}
+		after, _ := utf8.DecodeRuneInString(text[offset+size:])
+		inWord := isWord(r) || (start != -1 && joins(before, r, after))
+		if start != -1 && !inWord {
+			fn(start, offset)
+			start = -1
+		}
+		if isIdeograph(r) {
+			fn(offset, offset+size)
+		} else if inWord && start == -1 {
+			start = offset
+		}
+		before = r
+	}
+	if start != -1 {
+		fn(start, len(text))
+	}
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// Search returns at most limit pages containing any word of query, best first. Pages are
+// scored by tf-idf, words in the title and headings count more. A limit <= 0 means no limit.
+func (index *Index) Search(query string, limit int) []Hit {
+	queryTokens := index.tokens(query)
+	scores := make(map[string]float64)
+	for _, token := range queryTokens {
+		postings := index.Postings[token]
+		idf := math.Log(1 + float64(len(index.Pages))/float64(len(postings)+1))
+		for id, count := range postings {
+			scores[id] += float64(count) * idf
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{PageID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].PageID < hits[j].PageID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	wanted := make(map[string]bool, len(queryTokens))
+	for _, token := range queryTokens {
+		wanted[token] = true
+	}
+	for i := range hits {
+		page := index.Pages[hits[i].PageID]
+		for _, heading := range page.Headings {
+			if containsToken(heading, wanted) {
+				hits[i].Heading = heading
+				break
+			}
+		}
+		hits[i].Snippet = snippet(page.Body, wanted)
+	}
+	return hits
+}
+
+func containsToken(text string, wanted map[string]bool) bool {
+	for _, token := range tokenize(text) {
+		if wanted[token] {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet returns the words of text around the first one containing a wanted token.
+func snippet(text string, wanted map[string]bool) string {
+	const before, after = 5, 10
+	words := strings.Fields(text)
+	for i, word := range words {
+		if !containsToken(word, wanted) {
+			continue
+		}
+		start, end := max(i-before, 0), min(i+after, len(words))
+		s := strings.Join(words[start:end], " ")
+		if start > 0 {
+			s = "... " + s
+		}
+		if end < len(words) {
+			s += " ..."
+		}
+		return s
+	}
+	return ""
+}
+
+// Save writes the index to w in gob format.
+func (index *Index) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(index)
+}
+
+// LoadIndex reads an index written by Save.
+func LoadIndex(r io.Reader) (*Index, error) {
+	index := &Index{}
+	if err := gob.NewDecoder(r).Decode(index); err != nil {
+		return nil, err
+	}
+	if index.Stopwords == nil {
+		index.Stopwords = make(map[string]bool)
+	}
+	return index, nil
+}
@@ -0,0 +1,59 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var searchPages = map[string]*ParseUnit{
+	"wiki:install": Parse([]byte("====== Installing ======\nDownload the tarball and unpack it on the server.\n"), "Installing"),
+	"wiki:upgrade": Parse([]byte("====== Upgrade ======\nBefore you upgrade, make a backup of the server. Then unpack the new tarball over the old one.\n"), "Upgrade"),
+	"wiki:faq":     Parse([]byte("====== FAQ ======\n===== Why is the server slow? =====\nCheck the cache.\n"), "FAQ"),
+	"zh:start":     Parse([]byte("欢迎使用维基\n"), "start"),
+}
+
+func TestTokenize(t *testing.T) {
+	want := []string{"café", "v2", "is", "naïve", "欢", "迎", "ok", "1.2", "don't", "end", "1", "x"}
+	if got := tokenize("Café v2 -- is NAÏVE! 欢迎ok 1.2 don't 'end'. 1. x"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	index := BuildIndexWithOptions(searchPages, IndexOptions{Stopwords: []string{"the"}})
+
+	hits := index.Search("Upgrade", 10)
+	if len(hits) != 1 || hits[0].PageID != "wiki:upgrade" || hits[0].Heading != "Upgrade" {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	if want := "Before you upgrade, make a backup of the server. Then unpack the ..."; hits[0].Snippet != want {
+		t.Errorf("got snippet %q, want %q", hits[0].Snippet, want)
+	}
+
+	hits = index.Search("server", 2)
+	if len(hits) != 2 || hits[0].PageID != "wiki:faq" || hits[0].Heading != "Why is the server slow?" {
+		t.Errorf("unexpected hits %+v", hits)
+	}
+	if hits := index.Search("the", 0); len(hits) != 0 {
+		t.Errorf("stopwords should not match, got %+v", hits)
+	}
+	if hits := index.Search("维基", 0); len(hits) != 1 || hits[0].PageID != "zh:start" {
+		t.Errorf("unexpected hits %+v", hits)
+	}
+}
+
+func TestSaveLoadIndex(t *testing.T) {
+	index := BuildIndex(searchPages)
+	var buf bytes.Buffer
+	if err := index.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.Search("tarball unpack", 0), index.Search("tarball unpack", 0); !reflect.DeepEqual(got, want) || len(got) != 2 {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package dokuwiki
+
+import "fmt"
+
+// Section returns a copy of the section whose heading has the given anchor, like DokuWiki's
+// section editing does: the heading and the blocks after it, up to the next heading of the
+// same or a bigger level. Anchors are the ones listed by TOC, so of identical headings the
+// second one is found with the numbered anchor, like "section1".
+func (unit *ParseUnit) Section(anchor string) (*ParseUnit, error) {
+	start, end, err := unit.sectionBounds(anchor)
+	if err != nil {
+		return nil, err
+	}
+	section := &ParseUnit{Title: unit.Title, Sections: make([]BlockContext, 0, end-start), effects: unit.effects, pageID: unit.pageID}
+	for _, block := range unit.Sections[start:end] {
+		section.Sections = append(section.Sections, cloneBlock(block, section))
+	}
+	return section, nil
+}
+
+// ReplaceSection replaces the section found like Section does with the blocks of replacement.
+// The blocks are moved, not copied, so replacement should not be used afterwards. It fails with
+// ErrFrozen when unit is frozen, a frozen replacement has its blocks copied.
+func (unit *ParseUnit) ReplaceSection(anchor string, replacement *ParseUnit) error {
+	if unit.frozen {
+		return ErrFrozen
+	}
+	start, end, err := unit.sectionBounds(anchor)
+	if err != nil {
+		return err
+	}
+	sections := make([]BlockContext, 0, len(unit.Sections)-(end-start)+len(replacement.Sections))
+	sections = append(sections, unit.Sections[:start]...)
+	for _, block := range replacement.Sections {
+		if replacement.frozen {
+			block = cloneBlock(block, unit)
+		} else {
+			block.SetParentContext(unit)
+		}
+		// the blocks were not parsed from the input of unit.
+		Walk(block, func(c Context) bool {
+			if spanned, ok := c.(interface{ setSpan(Span) }); ok {
+				spanned.setSpan(Span{})
+			}
+			return true
+		})
+		sections = append(sections, block)
+	}
+	sections = append(sections, unit.Sections[end:]...)
+	unit.Sections = sections
+	if !replacement.frozen {
+		replacement.Sections = nil
+	}
+	return nil
+}
+
+// sectionBounds returns the range of unit.Sections holding the section with the given anchor.
+func (unit *ParseUnit) sectionBounds(anchor string) (int, int, error) {
+	anchors := unit.headingIDs()
+	for start, block := range unit.Sections {
+		header, ok := block.(*SectionHeaderContext)
+		if !ok || anchors[header] != anchor {
+			continue
+		}
+		end := start + 1
+		for ; end < len(unit.Sections); end++ {
+			if next, ok := unit.Sections[end].(*SectionHeaderContext); ok && next.HeaderLevel >= header.HeaderLevel {
+				break
+			}
+		}
+		return start, end, nil
+	}
+	return 0, 0, fmt.Errorf("no section with anchor %q", anchor)
+}
+
+// cloneBlock copies block and everything below it, the copy is put in parent.
+func cloneBlock(block BlockContext, parent Context) BlockContext {
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		c := *b
+		c.SetParentContext(parent)
+		if b.InnerContexts != nil {
+			c.InnerContexts = make([]InlineContext, 0, len(b.InnerContexts))
+			for _, inline := range b.InnerContexts {
+				c.InnerContexts = append(c.InnerContexts, cloneInline(inline, &c))
+			}
+		}
+		return &c
+	case *ListContext:
+		c := *b
+		c.SetParentContext(parent)
+		c.InnerContexts = make([]BlockContext, 0, len(b.InnerContexts))
+		for _, inner := range b.InnerContexts {
+			c.InnerContexts = append(c.InnerContexts, cloneBlock(inner, &c))
+		}
+		return &c
+	case *ListItemContext:
+		c := *b
+		c.SetParentContext(parent)
+		c.InnerContexts = make([]BlockContext, 0, len(b.InnerContexts))
+		for _, inner := range b.InnerContexts {
+			c.InnerContexts = append(c.InnerContexts, cloneBlock(inner, &c))
+		}
+		return &c
+	case *QuoteContext:
+		c := *b
+		c.SetParentContext(parent)
+		c.InnerContexts = make([]BlockContext, 0, len(b.InnerContexts))
+		for _, inner := range b.InnerContexts {
+			c.InnerContexts = append(c.InnerContexts, cloneBlock(inner, &c))
+		}
+		return &c
+	case *DefinitionListContext:
+		c := *b
+		c.SetParentContext(parent)
+		c.Entries = make([]DefinitionEntry, 0, len(b.Entries))
+		for _, entry := range b.Entries {
+			var copied DefinitionEntry
+			if entry.Term != nil {
+				copied.Term = cloneBlock(entry.Term, &c).(*ParaContext)
+			}
+			for _, definition := range entry.Definitions {
+				copied.Definitions = append(copied.Definitions, cloneBlock(definition, &c).(*ParaContext))
+			}
+			c.Entries = append(c.Entries, copied)
+		}
+		return &c
+	case *ParaContext:
+		c := *b
+		c.SetParentContext(parent)
+		c.InnerContexts = make([]InlineContext, 0, len(b.InnerContexts))
+		for _, inline := range b.InnerContexts {
+			c.InnerContexts = append(c.InnerContexts, cloneInline(inline, &c))
+		}
+		return &c
+	}
+	return block
+}
+
+func cloneInline(inline InlineContext, parent Context) InlineContext {
+	var c InlineContext
+	switch i := inline.(type) {
+	case *TextEffectContext:
+		copied := *i
+		c = &copied
+	case *HyperLinkContext:
+		copied := *i
+		if i.Image != nil {
+			copied.Image = cloneInline(i.Image, &copied).(*MediaContext)
+		}
+		if i.InnerContexts != nil {
+			copied.InnerContexts = make([]InlineContext, len(i.InnerContexts))
+			for j, inner := range i.InnerContexts {
+				copied.InnerContexts[j] = cloneInline(inner, &copied)
+			}
+		}
+		c = &copied
+	case *MediaContext:
+		copied := *i
+		if i.Params != nil {
+			copied.Params = make(map[string]string, len(i.Params))
+			for key, value := range i.Params {
+				copied.Params[key] = value
+			}
+		}
+		c = &copied
+	case *CodeFileContext:
+		copied := *i
+		if i.Attributes != nil {
+			copied.Attributes = make(map[string]string, len(i.Attributes))
+			for key, value := range i.Attributes {
+				copied.Attributes[key] = value
+			}
+		}
+		c = &copied
+	case *HTMLContext:
+		copied := *i
+		c = &copied
+	case *NoWikiContext:
+		copied := *i
+		c = &copied
+	case *MacroContext:
+		copied := *i
+		c = &copied
+	case *AnchorContext:
+		copied := *i
+		c = &copied
+	case *LineBreakContext:
+		copied := *i
+		c = &copied
+	default:
+		return inline
+	}
+	c.SetParentContext(parent)
+	return c
+}
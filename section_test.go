@@ -0,0 +1,78 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"testing"
+)
+
+const sectionPage = `====== Guide ======
+intro
+===== Setup =====
+setup text
+==== Details ====
+  * detail
+===== Setup =====
+again
+====== End ======
+last
+`
+
+func TestSection(t *testing.T) {
+	unit := Parse([]byte(sectionPage), "guide")
+	tests := map[string]string{
+		"setup":  "===== Setup =====\n\nsetup text\n\n==== Details ====\n\n  * detail\n",
+		"setup1": "===== Setup =====\n\nagain\n",
+		"end":    "====== End ======\n\nlast\n",
+	}
+	for anchor, want := range tests {
+		section, err := unit.Section(anchor)
+		if err != nil {
+			t.Errorf("%s: %v", anchor, err)
+			continue
+		}
+		if got := serialize(t, section); got != want {
+			t.Errorf("%s: got %q, want %q", anchor, got, want)
+		}
+		Walk(section, func(c Context) bool {
+			for _, child := range children(c) {
+				if child.GetParentContext() != c {
+					t.Errorf("%s: %T has a wrong parent", anchor, child)
+				}
+			}
+			return true
+		})
+	}
+	if _, err := unit.Section("missing"); err == nil {
+		t.Error("no error for a missing section")
+	}
+	if unit.Sections[2].GetParentContext() != unit {
+		t.Error("Section changed the original unit")
+	}
+}
+
+func TestReplaceSection(t *testing.T) {
+	unit := Parse([]byte(sectionPage), "guide")
+	if err := unit.ReplaceSection("setup1", Parse([]byte("===== Setup again =====\nnew text\n"), "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := unit.ReplaceSection("end", Parse([]byte("====== The end ======\n"), "")); err != nil {
+		t.Fatal(err)
+	}
+	want := "====== Guide ======\n\nintro\n\n===== Setup =====\n\nsetup text\n\n==== Details ====\n\n  * detail\n\n" +
+		"===== Setup again =====\n\nnew text\n\n====== The end ======\n"
+	if got := serialize(t, unit); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := unit.ReplaceSection("setup1", &ParseUnit{}); err == nil {
+		t.Error("no error for a replaced anchor")
+	}
+}
+
+func serialize(t *testing.T, unit *ParseUnit) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := NewDokuWikiRenderer(RendererOptions{}).Render(&buf, unit); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
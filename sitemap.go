@@ -0,0 +1,141 @@
+package dokuwiki
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NamespaceIndexOptions configures BuildNamespaceIndex, the zero value is usable.
+type NamespaceIndexOptions struct {
+	// HidePages leaves out the pages it matches, like DokuWiki's hidepages setting,
+	// it is matched against the page ID with a colon in front, like ":wiki:secret".
+	HidePages *regexp.Regexp
+	// LastModified returns when a page was last changed, it may be nil.
+	LastModified func(pageID string) time.Time
+}
+
+// NamespaceNode is a namespace of a NamespaceIndex tree, the root has an empty ID.
+type NamespaceNode struct {
+	// ID is the full ID of the namespace, like "wiki:plugins", Name its last part.
+	ID   string
+	Name string
+	// Namespaces and Pages are sorted by name.
+	Namespaces []*NamespaceNode
+	Pages      []IndexPage
+}
+
+// IndexPage is a page of a NamespaceNode.
+type IndexPage struct {
+	// ID is the full page ID, Name its last part.
+	ID   string
+	Name string
+	// Title is the text of the first heading, the name when the page has no headings.
+	Title        string
+	Headings     int
+	LastModified time.Time
+}
+
+// BuildNamespaceIndex arranges pages, which are keyed by page ID, in a tree of namespaces
+// like DokuWiki's index page.
+func BuildNamespaceIndex(pages map[string]*ParseUnit, opts NamespaceIndexOptions) *NamespaceNode {
+	root := &NamespaceNode{}
+	for id, unit := range pages {
+		if opts.HidePages != nil && opts.HidePages.MatchString(":"+id) {
+			continue
+		}
+		node := root
+		parts := strings.Split(id, ":")
+		for _, name := range parts[:len(parts)-1] {
+			node = node.namespace(name)
+		}
+
+		page := IndexPage{ID: id, Name: parts[len(parts)-1], Title: parts[len(parts)-1]}
+		outline := unit.Outline()
+		if len(outline) > 0 {
+			page.Title = outline[0].Text
+		}
+		page.Headings = len(outline)
+		if opts.LastModified != nil {
+			page.LastModified = opts.LastModified(id)
+		}
+		node.Pages = append(node.Pages, page)
+	}
+	root.sort()
+	return root
+}
+
+// namespace returns the child namespace with the given name, creating it when needed.
+func (n *NamespaceNode) namespace(name string) *NamespaceNode {
+	for _, child := range n.Namespaces {
+		if child.Name == name {
+			return child
+		}
+	}
+	child := &NamespaceNode{ID: joinID(n.ID, name), Name: name}
+	n.Namespaces = append(n.Namespaces, child)
+	return child
+}
+
+func (n *NamespaceNode) sort() {
+	sort.Slice(n.Namespaces, func(i, j int) bool { return n.Namespaces[i].Name < n.Namespaces[j].Name })
+	sort.Slice(n.Pages, func(i, j int) bool { return n.Pages[i].Name < n.Pages[j].Name })
+	for _, child := range n.Namespaces {
+		child.sort()
+	}
+}
+
+// Walk calls fn for every page below n, namespaces before pages, in sorted order.
+func (n *NamespaceNode) Walk(fn func(page IndexPage)) {
+	for _, child := range n.Namespaces {
+		child.Walk(fn)
+	}
+	for _, page := range n.Pages {
+		fn(page)
+	}
+}
+
+// WriteHTML writes the tree as nested lists like DokuWiki's index, namespaces first.
+// pageURL maps a page ID to the URL its link points to.
+func (n *NamespaceNode) WriteHTML(w io.Writer, pageURL func(pageID string) string) error {
+	rw := &renderWriter{w: w}
+	n.writeHTML(rw, pageURL, 1)
+	return rw.err
+}
+
+func (n *NamespaceNode) writeHTML(rw *renderWriter, pageURL func(pageID string) string, level int) {
+	rw.write("<ul class=\"idx\">\n")
+	for _, child := range n.Namespaces {
+		rw.printf("<li class=\"open\"><div class=\"li\"><strong>%s</strong></div>\n", html.EscapeString(child.Name))
+		child.writeHTML(rw, pageURL, level+1)
+		rw.write("</li>\n")
+	}
+	for _, page := range n.Pages {
+		rw.printf("<li class=\"level%d\"><div class=\"li\"><a href=\"%s\" class=\"wikilink1\" title=\"%s\">%s</a></div></li>\n",
+			level, html.EscapeString(pageURL(page.ID)), html.EscapeString(page.ID), html.EscapeString(page.Title))
+	}
+	rw.write("</ul>\n")
+}
+
+// WriteSitemap writes the pages below n in the sitemap.xml format, pageURL maps a page ID
+// to its absolute URL.
+func (n *NamespaceNode) WriteSitemap(w io.Writer, pageURL func(pageID string) string) error {
+	rw := &renderWriter{w: w}
+	rw.write(xml.Header)
+	rw.write("<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n")
+	n.Walk(func(page IndexPage) {
+		var loc strings.Builder
+		xml.EscapeText(&loc, []byte(pageURL(page.ID)))
+		rw.printf("  <url>\n    <loc>%s</loc>\n", loc.String())
+		if !page.LastModified.IsZero() {
+			rw.printf("    <lastmod>%s</lastmod>\n", page.LastModified.UTC().Format(time.RFC3339))
+		}
+		rw.write("  </url>\n")
+	})
+	rw.write("</urlset>\n")
+	return rw.err
+}
@@ -0,0 +1,85 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNamespaceIndex(t *testing.T) {
+	pages := map[string]*ParseUnit{
+		"start":          Parse([]byte("====== Welcome ======\n===== More =====\n"), "start"),
+		"about":          Parse([]byte("no heading\n"), "about"),
+		"wiki:syntax":    Parse([]byte("====== Formatting Syntax ======\n"), "syntax"),
+		"wiki:plugin:a":  Parse([]byte("====== A & B ======\n"), "a"),
+		"private:secret": Parse([]byte("====== Secret ======\n"), "secret"),
+	}
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	root := BuildNamespaceIndex(pages, NamespaceIndexOptions{
+		HidePages: regexp.MustCompile(`^:private:`),
+		LastModified: func(pageID string) time.Time {
+			if pageID == "start" {
+				return modified
+			}
+			return time.Time{}
+		},
+	})
+
+	if len(root.Namespaces) != 1 || root.Namespaces[0].ID != "wiki" || root.Namespaces[0].Namespaces[0].ID != "wiki:plugin" {
+		t.Fatalf("unexpected namespaces %+v", root.Namespaces)
+	}
+	want := IndexPage{ID: "start", Name: "start", Title: "Welcome", Headings: 2, LastModified: modified}
+	if len(root.Pages) != 2 || root.Pages[0].Title != "about" || root.Pages[1] != want {
+		t.Errorf("unexpected pages %+v", root.Pages)
+	}
+
+	pageURL := func(pageID string) string { return "https://wiki.example.com/doku.php?id=" + pageID }
+	var out bytes.Buffer
+	if err := root.WriteHTML(&out, pageURL); err != nil {
+		t.Fatal(err)
+	}
+	wantHTML := `<ul class="idx">
+<li class="open"><div class="li"><strong>wiki</strong></div>
+<ul class="idx">
+<li class="open"><div class="li"><strong>plugin</strong></div>
+<ul class="idx">
+<li class="level3"><div class="li"><a href="https://wiki.example.com/doku.php?id=wiki:plugin:a" class="wikilink1" title="wiki:plugin:a">A &amp; B</a></div></li>
+</ul>
+</li>
+<li class="level2"><div class="li"><a href="https://wiki.example.com/doku.php?id=wiki:syntax" class="wikilink1" title="wiki:syntax">Formatting Syntax</a></div></li>
+</ul>
+</li>
+<li class="level1"><div class="li"><a href="https://wiki.example.com/doku.php?id=about" class="wikilink1" title="about">about</a></div></li>
+<li class="level1"><div class="li"><a href="https://wiki.example.com/doku.php?id=start" class="wikilink1" title="start">Welcome</a></div></li>
+</ul>
+`
+	if out.String() != wantHTML {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), wantHTML)
+	}
+
+	out.Reset()
+	if err := root.WriteSitemap(&out, func(pageID string) string { return pageURL(pageID) + "&do=show" }); err != nil {
+		t.Fatal(err)
+	}
+	wantXML := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://wiki.example.com/doku.php?id=wiki:plugin:a&amp;do=show</loc>
+  </url>
+  <url>
+    <loc>https://wiki.example.com/doku.php?id=wiki:syntax&amp;do=show</loc>
+  </url>
+  <url>
+    <loc>https://wiki.example.com/doku.php?id=about&amp;do=show</loc>
+  </url>
+  <url>
+    <loc>https://wiki.example.com/doku.php?id=start&amp;do=show</loc>
+    <lastmod>2024-05-01T12:00:00Z</lastmod>
+  </url>
+</urlset>
+`
+	if out.String() != wantXML {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), wantXML)
+	}
+}
@@ -0,0 +1,138 @@
+package dokuwiki
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Stats are numbers about the text of a page, for writing quality reports.
+type Stats struct {
+	// Words counts the words of headings and text, code, nowiki and html content left out.
+	// Words are found like the search index does, so every ideograph is a word.
+	Words int
+	// Sentences counts the sentences of paragraphs and list items, a sentence ends with
+	// one of .!? followed by a space, a CJK full stop, or the end of the paragraph.
+	Sentences int
+	// Sections has an entry for every heading in document order, preceded by one for the
+	// text before the first heading when there is any.
+	Sections []SectionStats
+	// CodeLines counts the lines of code and file tags by language, "" for no language.
+	CodeLines     map[string]int
+	InternalLinks int
+	// ExternalLinks counts every link that is not internal, including interwiki and email links.
+	ExternalLinks int
+	// Images counts the media with an image file extension.
+	Images int
+	// MaxListDepth is the deepest list nesting, 1 for a list without sub lists, 0 without lists.
+	MaxListDepth int
+}
+
+// SectionStats are the word counts of a section.
+type SectionStats struct {
+	// Heading and Anchor are empty for the text before the first heading.
+	Heading     string
+	Anchor      string
+	HeaderLevel int
+	// Words counts the words of the section itself, heading included, and TotalWords adds
+	// the words of its sub sections.
+	Words      int
+	TotalWords int
+}
+
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".bmp": true, ".ico": true}
+
+// Stats computes statistics about the unit.
+func (unit *ParseUnit) Stats() Stats {
+	stats := Stats{Sections: make([]SectionStats, 0), CodeLines: make(map[string]int)}
+
+	// open holds the indices in stats.Sections of the current section and its parents.
+	open := make([]int, 0)
+	anchors := unit.headingIDs()
+	addWords := func(n int) {
+		if len(open) == 0 {
+			if n == 0 {
+				return
+			}
+			stats.Sections = append(stats.Sections, SectionStats{})
+			open = append(open, 0)
+		}
+		stats.Sections[open[len(open)-1]].Words += n
+		for _, i := range open {
+			stats.Sections[i].TotalWords += n
+		}
+	}
+
+	for _, block := range unit.Sections {
+		if header, ok := block.(*SectionHeaderContext); ok {
+			// a heading closes the sections of the same or a lower level, higher levels are bigger.
+			for len(open) > 0 && stats.Sections[open[len(open)-1]].HeaderLevel <= header.HeaderLevel {
+				open = open[:len(open)-1]
+			}
+			stats.Sections = append(stats.Sections, SectionStats{
+				Heading:     header.HeaderText,
+				Anchor:      anchors[header],
+				HeaderLevel: header.HeaderLevel,
+			})
+			open = append(open, len(stats.Sections)-1)
+			addWords(len(tokenize(header.HeaderText)))
+			continue
+		}
+
+		blockUnit := &ParseUnit{Sections: []BlockContext{block}}
+		addWords(len(tokenize(blockUnit.PlainText(TextExtractOptions{Fields: FieldBody}))))
+		Walk(block, func(c Context) bool {
+			switch c := c.(type) {
+			case *ParaContext:
+				stats.Sentences += countSentences(paraText(c))
+			case *ListContext:
+				depth := 1
+				for p := c.GetParentContext(); p != nil; p = p.GetParentContext() {
+					if _, ok := p.(*ListContext); ok {
+						depth++
+					}
+				}
+				stats.MaxListDepth = max(stats.MaxListDepth, depth)
+			case *CodeFileContext:
+				stats.CodeLines[c.Language] += strings.Count(strings.Trim(c.Text, "\n"), "\n") + 1
+			case *HyperLinkContext:
+				if c.Kind == LinkInternal {
+					stats.InternalLinks++
+				} else {
+					stats.ExternalLinks++
+				}
+			case *MediaContext:
+				if imageExtensions[strings.ToLower(path.Ext(c.MediaResouce))] {
+					stats.Images++
+				}
+			}
+			return true
+		})
+	}
+	stats.Words = len(tokenize(unit.PlainText(TextExtractOptions{Fields: FieldHeadings | FieldBody})))
+	return stats
+}
+
+// countSentences counts the sentences in text, see Stats.Sentences.
+func countSentences(text string) int {
+	count := 0
+	inSentence := false
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case strings.ContainsRune(".!?。！？", r):
+			// CJK full stops are not followed by a space.
+			atEnd := r > unicode.MaxASCII || i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+			if inSentence && atEnd {
+				count++
+				inSentence = false
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+	}
+	if inSentence {
+		count++
+	}
+	return count
+}
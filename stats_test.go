@@ -0,0 +1,71 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	content := `Intro text. Second sentence!
+
+====== Guide ======
+Read this first. Version 1.2 is out
+
+===== Install =====
+Run it, see [[wiki:setup]] or [[https://example.com|the site]].
+<code bash>
+make
+make install
+</code>
+
+  * one item
+    * nested item
+      - deeper item.
+{{logo.png}} {{manual.pdf}}
+
+===== Use =====
+Just use it?
+
+====== FAQ ======
+None.
+`
+	stats := Parse([]byte(content), "guide").Stats()
+	want := Stats{
+		Words:     31,
+		Sentences: 10,
+		Sections: []SectionStats{
+			{Words: 4, TotalWords: 4},
+			{Heading: "Guide", Anchor: "guide", HeaderLevel: 6, Words: 8, TotalWords: 25},
+			{Heading: "Install", Anchor: "install", HeaderLevel: 5, Words: 13, TotalWords: 13},
+			{Heading: "Use", Anchor: "use", HeaderLevel: 5, Words: 4, TotalWords: 4},
+			{Heading: "FAQ", Anchor: "faq", HeaderLevel: 6, Words: 2, TotalWords: 2},
+		},
+		CodeLines:     map[string]int{"bash": 2},
+		InternalLinks: 1,
+		ExternalLinks: 1,
+		Images:        1,
+		MaxListDepth:  3,
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v\nwant %+v", stats, want)
+	}
+}
+
+func TestCountSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"One. Two! Three? ", 3},
+		{"Version 1.2 is out", 1},
+		{"e.g. this", 2},
+		{"... !", 0},
+		{"你好。世界", 2},
+	}
+	for _, test := range tests {
+		if got := countSentences(test.text); got != test.want {
+			t.Errorf("countSentences(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package dokuwiki
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// ParseStream parses the content read from r with the default options, see Parser.ParseStream.
+// The default parser has no hooks, its diagnostics are dropped.
+func ParseStream(r io.Reader, handler func(BlockContext) error) error {
+	return defaultParser.ParseStream(r, handler)
+}
+
+// ParseStream parses the content read from r and hands every top level block to handler
+// as soon as it is complete, with its inline elements already parsed. The blocks are the ones
+// Parse gives with the options and syntax of the parser, but for the unknown tags spanning
+// several lines, whose end tag can not be looked for ahead of the line being read.
+//
+// Emitted blocks are not retained, so memory stays proportional to the largest single block
+// (a list, or a long code section) rather than the whole document. The parent of emitted
+// blocks is a ParseUnit that only ever holds the blocks still being built. The diagnostics are
+// not kept either, they go to the OnDiagnostic hook of the parser as they are found, without
+// their span since the input is not kept. The other hooks are not called.
+//
+// A handler error aborts the parse and is returned as is.
+func (p *Parser) ParseStream(r io.Reader, handler func(BlockContext) error) error {
+	unit := &ParseUnit{effects: p.Options.Effects, pageID: p.Options.PageID}
+	states := parserStates{parseunit: unit, options: p.Options, parser: p, effects: sortEffects(p.Options.Effects)}
+
+	// report hands the diagnostics found so far to the hook and forgets them.
+	report := func() {
+		if p.Hooks.OnDiagnostic != nil {
+			for _, diagnostic := range unit.Diagnostics {
+				p.Hooks.OnDiagnostic(diagnostic)
+			}
+		}
+		clear(unit.Diagnostics)
+		unit.Diagnostics = unit.Diagnostics[:0]
+	}
+
+	var handlerErr error
+	// flush emits the completed blocks, all of them but the last one can still grow
+	// unless the input is over.
+	flush := func(all bool) {
+		keep := 1
+		if all {
+			keep = 0
+		}
+		for handlerErr == nil && len(unit.Sections) > keep {
+			block := unit.Sections[0]
+			unit.Sections[0] = nil
+			unit.Sections = unit.Sections[1:]
+			parseInlines(&states, []BlockContext{block})
+			report()
+			handlerErr = handler(block)
+		}
+	}
+
+	classifier := newLineClassifier(&states, func(block wholeBlock) {
+		processLine(&states, block)
+		flush(false)
+	})
+
+	reader := bufio.NewReader(r)
+	// readLine returns the next physical line without the new line, ok is false at the end of input.
+	// The content is treated as if a new line was appended to it, like generateLines does.
+	eof := false
+	readLine := func() ([]byte, bool, error) {
+		if eof {
+			return nil, false, nil
+		}
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			eof = true
+			return line, true, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		return bytes.TrimSuffix(line, []byte{'\n'}), true, nil
+	}
+
+	line, ok, err := readLine()
+	if err != nil {
+		return err
+	}
+	for ok && handlerErr == nil {
+		next, nextOK, err := readLine()
+		if err != nil {
+			return err
+		}
+		if !nextOK {
+			next = []byte("")
+			// the appended new line gives one more, empty, physical line.
+			classifier.feed(line, next)
+			if handlerErr == nil {
+				classifier.feed(next, []byte(""))
+			}
+			break
+		}
+		classifier.feed(line, next)
+		line = next
+	}
+	if handlerErr == nil {
+		classifier.finish()
+	}
+	flush(true)
+	report()
+
+	return handlerErr
+}
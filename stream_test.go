@@ -0,0 +1,115 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const streamDoc = `====== Title ======
+first paragraph
+still first
+
+  * item **one**
+    * nested
+  * item two
+second paragraph
+<code go>
+func main() {
+
+}
+</code>
+== Last ==
+`
+
+func TestParseStreamMatchesParse(t *testing.T) {
+	var blocks []BlockContext
+	err := ParseStream(strings.NewReader(streamDoc), func(block BlockContext) error {
+		blocks = append(blocks, block)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unit := Parse([]byte(streamDoc), "")
+	if len(blocks) != len(unit.Sections) {
+		t.Fatalf("got %d blocks, want %d", len(blocks), len(unit.Sections))
+	}
+	for i, block := range blocks {
+		switch want := unit.Sections[i].(type) {
+		case *SectionHeaderContext:
+			got, ok := block.(*SectionHeaderContext)
+			if !ok || got.HeaderText != want.HeaderText || got.HeaderLevel != want.HeaderLevel {
+				t.Errorf("block %d: got %#v, want %#v", i, block, want)
+			}
+		case *ListContext:
+			got, ok := block.(*ListContext)
+			if !ok || len(got.InnerContexts) != len(want.InnerContexts) {
+				t.Errorf("block %d: got %#v, want %#v", i, block, want)
+			}
+		case *ParaContext:
+			got, ok := block.(*ParaContext)
+			if !ok || got.rawText != want.rawText || len(got.InnerContexts) != len(want.InnerContexts) {
+				t.Errorf("block %d: got %#v, want %#v", i, block, want)
+			}
+		}
+	}
+}
+
+func TestParseStreamHandlerError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ParseStream(strings.NewReader(streamDoc), func(block BlockContext) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("got %v after %d calls, want the handler error after 1 call", err, calls)
+	}
+}
+
+// TestParserParseStream checks that the blocks are parsed with the options of the parser and the
+// diagnostics go to its hook.
+func TestParserParseStream(t *testing.T) {
+	content := "{{img.png}} an **unclosed\nline\n\n  ; term : definition\n<del>x</del>\n"
+	var diagnostics []string
+	options := ParseOptions{PageID: "wiki:start", LineJoin: LineJoinNone, DefinitionLists: true}
+	parser := New(WithOptions(options), WithHooks(Hooks{OnDiagnostic: func(d Diagnostic) {
+		diagnostics = append(diagnostics, d.Message)
+	}}))
+	var got []string
+	err := parser.ParseStream(strings.NewReader(content), func(block BlockContext) error {
+		var dump bytes.Buffer
+		if err := Dump(&dump, block); err != nil {
+			return err
+		}
+		got = append(got, dump.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unit := ParseWithOptions([]byte(content), "t", options)
+	want := make([]string, 0, len(unit.Sections))
+	for _, block := range unit.Sections {
+		var dump bytes.Buffer
+		if err := Dump(&dump, block); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, dump.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed\n%s\nparsed\n%s", strings.Join(got, ""), strings.Join(want, ""))
+	}
+	wantDiagnostics := make([]string, 0, len(unit.Diagnostics))
+	for _, d := range unit.Diagnostics {
+		wantDiagnostics = append(wantDiagnostics, d.Message)
+	}
+	if len(wantDiagnostics) == 0 || !reflect.DeepEqual(diagnostics, wantDiagnostics) {
+		t.Errorf("got diagnostics %q, want %q", diagnostics, wantDiagnostics)
+	}
+}
@@ -0,0 +1,189 @@
+package dokuwiki
+
+import "bytes"
+
+// tagKind is the kind of a protected tag, whose content is not parsed.
+type tagKind int
+
+const (
+	noTag tagKind = iota
+	codeTag
+	fileTag
+	// htmlBlockTag is <HTML> in capitals, htmlTag the inline form in any other case.
+	htmlBlockTag
+	htmlTag
+	noWikiTag
+)
+
+// tagRegion is a protected tag by its offsets in the text it was found in, the bytes are left
+// as they are. The content is text[contentStart:contentEnd], an unterminated tag has no end
+// tag and takes the rest of the text. attributes is what the start tag of a code or file tag
+// holds after its name, like ` java [enable_line_numbers="true"]`.
+type tagRegion struct {
+	kind         tagKind
+	start        int
+	contentStart int
+	contentEnd   int
+	end          int
+	attributes   string
+}
+
+// tagScanner follows the protected tags of a text that grows one byte at a time, it is told
+// about every > appended. A start tag is only looked for outside of protected tags and after
+// the last tag, and an end tag only closes the tag of its kind, the tags of other kinds are
+// content.
+type tagScanner struct {
+	disableEmbeds bool
+	// open is the tag being scanned, its kind is noTag outside of protected tags.
+	open tagRegion
+	// from is where the text after the last closed tag starts.
+	from int
+	// seen is how much of the text was looked at for a <, and lastLess the offset of the last
+	// < in it, the start tags of code and file are only matched from there.
+	seen, lastLess int
+	regions        []tagRegion
+}
+
+func (s *tagScanner) protected() bool {
+	return s.open.kind != noTag
+}
+
+// scan looks at the tags ending text, which ends with a >. It returns the length of an end tag
+// outside of protected tags that closes no tag, 0 for any other.
+func (s *tagScanner) scan(text []byte) (stray int) {
+	last := s.lastTag(text)
+	if matchedLen := bytesEndsWithRegexp(last, validCodeStartTag); matchedLen > 0 && !s.protected() {
+		s.start(text, matchedLen, codeTag)
+	} else if matchedLen := endsWithTag(text, validCodeEndTag); matchedLen > 0 {
+		return s.end(text, matchedLen, s.open.kind == codeTag)
+	} else if matchedLen := bytesEndsWithRegexp(last, validFileStartTag); matchedLen > 0 && !s.protected() {
+		s.start(text, matchedLen, fileTag)
+	} else if matchedLen := endsWithTag(text, validFileEndTag); matchedLen > 0 {
+		return s.end(text, matchedLen, s.open.kind == fileTag)
+	} else if matchedLen := endsWithTag(text, validHTMLStartTag); matchedLen > 0 && !s.protected() && !s.disableEmbeds {
+		// only <HTML> in capitals is the block form, other cases fall back to inline.
+		if bytes.HasPrefix(text[len(text)-matchedLen:], []byte("<HTML")) {
+			s.start(text, matchedLen, htmlBlockTag)
+		} else {
+			s.start(text, matchedLen, htmlTag)
+		}
+	} else if matchedLen := endsWithTag(text, validHTMLEndTag); matchedLen > 0 && !s.disableEmbeds {
+		// the end tag closes the html tag that is open whatever its case.
+		return s.end(text, matchedLen, s.open.kind == htmlBlockTag || s.open.kind == htmlTag)
+	} else if matchedLen := endsWithTag(text, validNoWikiStartTag); matchedLen > 0 && !s.protected() {
+		s.start(text, matchedLen, noWikiTag)
+	} else if matchedLen := endsWithTag(text, validNoWikiEndTag); matchedLen > 0 {
+		return s.end(text, matchedLen, s.open.kind == noWikiTag)
+	}
+	return 0
+}
+
+// lastTag returns text from its last < on, nil when there is none after the last closed tag. Only
+// the bytes since the previous call are looked at, so every > costs the length of its tag.
+func (s *tagScanner) lastTag(text []byte) []byte {
+	if i := bytes.LastIndexByte(text[s.seen:], '<'); i != -1 {
+		s.lastLess = s.seen + i
+	}
+	s.seen = len(text)
+	if s.lastLess < s.from || text[s.lastLess] != '<' {
+		return nil
+	}
+	return text[s.lastLess:]
+}
+
+// start opens a tag of kind, its start tag is the last length bytes of text.
+func (s *tagScanner) start(text []byte, length int, kind tagKind) {
+	s.open = tagRegion{kind: kind, start: len(text) - length, contentStart: len(text)}
+	if kind == codeTag || kind == fileTag {
+		// the name of both tags is 4 bytes long after the <.
+		s.open.attributes = string(text[s.open.start+5 : len(text)-1])
+	}
+}
+
+// end closes the open tag when closes is true, the end tag is the last length bytes of text.
+func (s *tagScanner) end(text []byte, length int, closes bool) (stray int) {
+	if !closes {
+		if s.protected() {
+			return 0
+		}
+		return length
+	}
+	s.open.contentEnd, s.open.end = len(text)-length, len(text)
+	s.regions = append(s.regions, s.open)
+	s.open, s.from = tagRegion{}, len(text)
+	return 0
+}
+
+// finish ends the scanning of text of length n, a tag still open takes the rest of it.
+func (s *tagScanner) finish(n int) []tagRegion {
+	if s.protected() {
+		s.open.contentEnd, s.open.end = n, n
+		s.regions = append(s.regions, s.open)
+		s.open = tagRegion{}
+	}
+	return s.regions
+}
+
+// scanTagRegions returns the protected tags of text in order, like generateLines finds them.
+func scanTagRegions(text []byte, disableEmbeds bool) []tagRegion {
+	s := tagScanner{disableEmbeds: disableEmbeds}
+	for i, b := range text {
+		if b == '>' {
+			s.scan(text[:i+1])
+		}
+	}
+	return s.finish(len(text))
+}
+
+// tagRegions returns the protected tags of the raw text of c, see ParaContext.tags.
+func (c *ParaContext) tagRegions(disableEmbeds bool) []tagRegion {
+	if c.tagsFound {
+		return c.tags
+	}
+	return scanTagRegions([]byte(c.rawText), disableEmbeds)
+}
+
+// offsetIn returns the offset of part in text when part is a slice of the bytes of text, -1
+// otherwise. The bytes of part are looked for in text, the text of a block follows no more
+// than its marks so the first ones found are it.
+func offsetIn(text, part []byte) int {
+	if len(part) == 0 {
+		return -1
+	}
+	for from := 0; ; {
+		i := bytes.Index(text[from:], part)
+		if i == -1 {
+			return -1
+		}
+		if &text[from+i] == &part[0] {
+			return from + i
+		}
+		from += i + 1
+	}
+}
+
+// shiftTags returns tags moved by delta bytes.
+func shiftTags(tags []tagRegion, delta int) []tagRegion {
+	shifted := make([]tagRegion, 0, len(tags))
+	for _, tag := range tags {
+		tag.start, tag.contentStart, tag.contentEnd, tag.end = tag.start+delta, tag.contentStart+delta, tag.contentEnd+delta, tag.end+delta
+		shifted = append(shifted, tag)
+	}
+	return shifted
+}
+
+// tagsBetween returns the regions that start between from and to, with offsets from from, an
+// unterminated tag or one that ends after to is cut at to.
+func tagsBetween(regions []tagRegion, from, to int) []tagRegion {
+	var tags []tagRegion
+	for _, tag := range regions {
+		if tag.start < from || tag.start >= to {
+			continue
+		}
+		n := to - from
+		tag.start, tag.contentStart = tag.start-from, min(tag.contentStart-from, n)
+		tag.contentEnd, tag.end = min(tag.contentEnd-from, n), min(tag.end-from, n)
+		tags = append(tags, tag)
+	}
+	return tags
+}
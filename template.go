@@ -0,0 +1,123 @@
+package dokuwiki
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TemplateVars are the values of the placeholders of a namespace template.
+type TemplateVars struct {
+	// ID is the ID of the new page, @ID@, the placeholders @NS@, @CURNS@, @FILE@ and @PAGE@
+	// are taken from it.
+	ID string
+	// User, Name and Mail are the login, full name and mail address of the user creating the
+	// page, for @USER@, @NAME@ and @MAIL@.
+	User, Name, Mail string
+	// Date is the time for @DATE@, the current time when zero. It is written with DateFormat,
+	// "2006/01/02 15:04" by default like DokuWiki's.
+	Date       time.Time
+	DateFormat string
+	// Extra adds placeholders, keyed by their name without the @ like "PROJECT".
+	Extra map[string]string
+}
+
+var templatePlaceholder = regexp.MustCompile(`@(!{0,2})([A-Z]+)(!?)@`)
+
+// Expand substitutes the placeholders of text. Like DokuWiki, @!PAGE@ capitalizes the first
+// letter of the value, @!!PAGE@ the first letter of every word and @!PAGE!@ all of it. Unknown
+// placeholders are left as they are.
+func (vars TemplateVars) Expand(text string) string {
+	values := vars.values()
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		groups := templatePlaceholder.FindStringSubmatch(placeholder)
+		value, ok := values[groups[2]]
+		if !ok {
+			return placeholder
+		}
+		switch {
+		case groups[3] == "!":
+			return strings.ToUpper(value)
+		case groups[1] == "!":
+			return upperFirst(value)
+		case groups[1] == "!!":
+			words := strings.Split(value, " ")
+			for i, word := range words {
+				words[i] = upperFirst(word)
+			}
+			return strings.Join(words, " ")
+		}
+		return value
+	})
+}
+
+func (vars TemplateVars) values() map[string]string {
+	ns, file := "", vars.ID
+	if i := strings.LastIndexByte(vars.ID, ':'); i != -1 {
+		ns, file = vars.ID[:i], vars.ID[i+1:]
+	}
+	curNS := ns[strings.LastIndexByte(ns, ':')+1:]
+	date, format := vars.Date, vars.DateFormat
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if format == "" {
+		format = "2006/01/02 15:04"
+	}
+
+	values := make(map[string]string, len(vars.Extra)+9)
+	for name, value := range vars.Extra {
+		values[name] = value
+	}
+	values["ID"] = vars.ID
+	values["NS"] = ns
+	values["CURNS"] = curNS
+	values["FILE"] = file
+	values["PAGE"] = strings.Replace(file, "_", " ", -1)
+	values["USER"] = vars.User
+	values["NAME"] = vars.Name
+	values["MAIL"] = vars.Mail
+	values["DATE"] = date.Format(format)
+	return values
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// ExpandTemplate returns a copy of unit, a parsed namespace template, with its placeholders
+// substituted by TemplateVars.Expand. Code, file, nowiki and html blocks are copied as they are,
+// a placeholder in them is meant to be shown, not replaced.
+func ExpandTemplate(unit *ParseUnit, vars TemplateVars) *ParseUnit {
+	expanded := &ParseUnit{Title: unit.Title, Sections: make([]BlockContext, 0, len(unit.Sections)), effects: unit.effects, pageID: unit.pageID}
+	for _, block := range unit.Sections {
+		expanded.Sections = append(expanded.Sections, cloneBlock(block, expanded))
+	}
+	expanded.Diagnostics = append(expanded.Diagnostics, unit.Diagnostics...)
+
+	Walk(expanded, func(c Context) bool {
+		switch c := c.(type) {
+		case *SectionHeaderContext:
+			c.HeaderText = vars.Expand(c.HeaderText)
+		case *TextEffectContext:
+			c.Text = vars.Expand(c.Text)
+		case *HyperLinkContext:
+			c.SetTarget(vars.Expand(c.HyperLink))
+			c.Text = vars.Expand(c.Text)
+		case *MediaContext:
+			c.MediaResouce = vars.Expand(c.MediaResouce)
+			if c.ResolvedID != "" {
+				c.ResolvedID = ResolvePageID(vars.ID, c.MediaResouce)
+			}
+			c.Title = vars.Expand(c.Title)
+		}
+		return true
+	})
+	return expanded
+}
@@ -0,0 +1,50 @@
+package dokuwiki
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	template := "====== @!!PAGE@ ======\n" +
+		"Created by @NAME@ (@USER@) on @DATE@ in @CURNS@, see [[@NS@:start|@!CURNS!@]].\n" +
+		"Unknown @FOO@ and extra @PROJECT@.\n" +
+		"<code text>@PAGE@</code>\n" +
+		"<nowiki>@USER@</nowiki>\n"
+	vars := TemplateVars{
+		ID:    "projects:go:release_notes",
+		User:  "jdoe",
+		Name:  "Jane Doe",
+		Date:  time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC),
+		Extra: map[string]string{"PROJECT": "parser"},
+	}
+	unit := Parse([]byte(template), "template")
+	expanded := ExpandTemplate(unit, vars)
+	want := "====== Release Notes ======\n" +
+		"\n" +
+		"Created by Jane Doe (jdoe) on 2024/03/05 14:07 in go, see [[projects:go:start|GO]]. Unknown @FOO@ and extra parser. <code text>@PAGE@</code> <nowiki>@USER@</nowiki>\n"
+	if got := serialize(t, expanded); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := serialize(t, unit); got == want {
+		t.Error("the template was changed")
+	}
+}
+
+func TestTemplateVarsExpand(t *testing.T) {
+	vars := TemplateVars{ID: "wiki:my_page", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), DateFormat: "2006-01-02"}
+	tests := []struct {
+		text, want string
+	}{
+		{"@ID@", "wiki:my_page"},
+		{"@FILE@ @!FILE@ @!FILE!@", "my_page My_page MY_PAGE"},
+		{"@PAGE@ @!PAGE@ @!!PAGE@ @!PAGE!@", "my page My page My Page MY PAGE"},
+		{"@NS@ @CURNS@ @DATE@", "wiki wiki 2024-01-02"},
+		{"mail@example.com @user@", "mail@example.com @user@"},
+	}
+	for _, test := range tests {
+		if got := vars.Expand(test.text); got != test.want {
+			t.Errorf("Expand(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
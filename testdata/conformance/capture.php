@@ -0,0 +1,25 @@
+<?php
+// capture.php renders the pages of this directory with a DokuWiki install and writes their
+// .xhtml files, then prints the version of the install to note in the README:
+//
+//     php testdata/conformance/capture.php /path/to/dokuwiki
+//
+// The install is the one of a release left as it is unpacked, only userewrite is set to 0 and
+// the pages are named "conformance", like TestConformance parses them.
+if ($argc != 2) {
+    fwrite(STDERR, "usage: php capture.php /path/to/dokuwiki\n");
+    exit(2);
+}
+define('DOKU_INC', rtrim($argv[1], '/') . '/');
+define('NOSESSION', true);
+require_once DOKU_INC . 'inc/init.php';
+
+global $ID, $conf;
+$ID = 'conformance';
+$conf['userewrite'] = 0;
+foreach (glob(__DIR__ . '/*.txt') as $input) {
+    $info = [];
+    $xhtml = p_render('xhtml', p_get_instructions(file_get_contents($input)), $info);
+    file_put_contents(substr($input, 0, -strlen('.txt')) . '.xhtml', $xhtml);
+}
+echo getVersion(), "\n";
@@ -0,0 +1,156 @@
+package dokuwiki
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TextRenderer renders a unit to plain text, formatting is dropped and the
+// block structure is kept with blank lines and list bullets.
+type TextRenderer struct {
+	Options RendererOptions
+}
+
+// textUnportable are the constructs TextRenderer can not write, see AnalyzePortability.
+var textUnportable = unportable{
+	underline:    "plain text has no underline, it is dropped",
+	html:         "it is written as its source",
+	php:          "php is not run, it is written as text",
+	windowsShare: "the share is written as text, with nothing to open it",
+	plugin:       "it is written as its source unless a node renderer is registered for it",
+	interWiki:    "the link is written with its target as written",
+}
+
+func NewTextRenderer(opts RendererOptions) *TextRenderer {
+	return &TextRenderer{Options: opts}
+}
+
+func (r *TextRenderer) Render(w io.Writer, unit *ParseUnit) error {
+	if err := r.Options.validate(unit); err != nil {
+		return err
+	}
+	rw := &renderWriter{w: w}
+	numbers := r.Options.sectionNumbers(unit)
+	for i, block := range unit.Sections {
+		if i > 0 {
+			rw.write("\n")
+		}
+		switch b := block.(type) {
+		case *SectionHeaderContext:
+			if number := numbers[b]; number != "" {
+				rw.write(number + " ")
+			}
+			rw.write(b.HeaderTextPlain() + "\n")
+		case *ParaContext:
+			r.renderInlines(rw, b.InnerContexts)
+			rw.write("\n")
+		case *ListContext:
+			r.renderList(rw, b, "")
+		case *QuoteContext:
+			r.renderQuote(rw, b, "")
+		case *DefinitionListContext:
+			for _, entry := range b.Entries {
+				if entry.Term != nil {
+					r.renderInlines(rw, entry.Term.InnerContexts)
+					rw.write("\n")
+				}
+				for _, definition := range entry.Definitions {
+					rw.write("  ")
+					r.renderInlines(rw, definition.InnerContexts)
+					rw.write("\n")
+				}
+			}
+		}
+	}
+	return rw.err
+}
+
+func (r *TextRenderer) renderList(rw *renderWriter, list *ListContext, indent string) {
+	number := 0
+	for _, inner := range list.InnerContexts {
+		item, ok := inner.(*ListItemContext)
+		if !ok {
+			continue
+		}
+		bullet := "* "
+		if list.Ordered {
+			number++
+			bullet = strconv.Itoa(number) + ". "
+		}
+		for _, block := range item.InnerContexts {
+			switch c := block.(type) {
+			case *ParaContext:
+				// the text of the item after its bullet, a paragraph after it is indented like it.
+				rw.write(indent + bullet)
+				bullet = "  "
+				r.renderInlines(rw, c.InnerContexts)
+				rw.write("\n")
+			case *ListContext:
+				r.renderList(rw, c, indent+"  ")
+			case *QuoteContext:
+				r.renderQuote(rw, c, indent+"  ")
+			}
+		}
+	}
+}
+
+// renderQuote writes the lines of a quote after indent and the > of its level.
+func (r *TextRenderer) renderQuote(rw *renderWriter, quote *QuoteContext, indent string) {
+	prefix := indent + strings.Repeat(">", quote.Level) + " "
+	for _, inner := range quote.InnerContexts {
+		switch c := inner.(type) {
+		case *ParaContext:
+			rw.write(prefix)
+			r.renderInlines(rw, c.InnerContexts)
+			rw.write("\n")
+		case *QuoteContext:
+			r.renderQuote(rw, c, indent)
+		case *ListContext:
+			r.renderList(rw, c, prefix)
+		}
+	}
+}
+
+func (r *TextRenderer) renderInlines(rw *renderWriter, inlines []InlineContext) {
+	for i, inline := range inlines {
+		if i > 0 && isCodeFile(inline) {
+			rw.write("\n")
+		}
+		switch c := inline.(type) {
+		case *TextEffectContext:
+			rw.write(c.Text)
+		case *HyperLinkContext:
+			if c.Image != nil {
+				rw.write(mediaText(c.Image))
+			} else if c.InnerContexts != nil {
+				r.renderInlines(rw, c.InnerContexts)
+			} else {
+				rw.write(c.Text)
+			}
+			if !c.IsInternal && c.Text != c.HyperLink {
+				rw.write(" <" + c.HyperLink + ">")
+			}
+		case *MediaContext:
+			rw.write(mediaText(c))
+		case *CodeFileContext:
+			rw.write(strings.Trim(c.Text, "\n") + "\n")
+		case *HTMLContext:
+			if r.Options.Sanitize != SanitizeStrip {
+				rw.write(c.Text)
+			}
+		case *NoWikiContext:
+			rw.write(c.Text)
+		case *LineBreakContext:
+			rw.write("\n")
+		}
+	}
+}
+
+// mediaText is what stands for a media file in text, its title or else its ID.
+func mediaText(media *MediaContext) string {
+	if media.Title != "" {
+		return media.Title
+	}
+	return media.MediaResouce
+}
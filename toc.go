@@ -0,0 +1,163 @@
+package dokuwiki
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TOCEntry is one heading of a page.
+type TOCEntry struct {
+	// HeaderLevel is the level as written in the source, 6 for ====== down to 1 for =.
+	HeaderLevel int
+	Text        string
+	// Anchor is the id the html renderer gives to the heading.
+	Anchor string
+	// Number is the number of the heading, like "1.2", when the sections are numbered.
+	Number string
+}
+
+// TOC lists the headings of the table of contents of the unit in document order, the ones of
+// the levels 1 to 3, ====== to ====, like DokuWiki, or of the levels of the ~~TOC:2-4~~ macro of
+// the page. Outline lists all of them.
+func (unit *ParseUnit) TOC() []TOCEntry {
+	if unit.frozen {
+		return append(make([]TOCEntry, 0, len(unit.toc)), unit.toc...)
+	}
+	return unit.TOCWithOptions(RendererOptions{})
+}
+
+// TOCWithOptions lists the headings like TOC, of the levels of opts.TOCTopLevel and
+// opts.TOCMaxLevel unless the page sets them, numbered like the renderers with opts number them.
+func (unit *ParseUnit) TOCWithOptions(opts RendererOptions) []TOCEntry {
+	top, bottom := unit.tocLevels(opts)
+	numbers := opts.sectionNumbers(unit)
+	entries := make([]TOCEntry, 0)
+	for _, anchor := range unit.Anchors() {
+		if anchor.Heading == nil {
+			continue
+		}
+		if level := 7 - anchor.Heading.HeaderLevel; level < top || level > bottom {
+			continue
+		}
+		entries = append(entries, TOCEntry{
+			HeaderLevel: anchor.Heading.HeaderLevel,
+			Text:        anchor.Text,
+			Anchor:      anchor.ID,
+			Number:      numbers[anchor.Heading],
+		})
+	}
+	return entries
+}
+
+// tocLevels returns the levels of the headings in the table of contents, 1 for ======, from the
+// last ~~TOC:n-m~~ macro of the unit or else from opts.
+func (unit *ParseUnit) tocLevels(opts RendererOptions) (top, bottom int) {
+	top, bottom = opts.TOCTopLevel, opts.TOCMaxLevel
+	if top <= 0 {
+		top = 1
+	}
+	if bottom <= 0 {
+		bottom = 3
+	}
+	Walk(unit, func(c Context) bool {
+		if macro, ok := c.(*MacroContext); ok && macro.Name == "TOC" {
+			fmt.Sscanf(macro.Args, "%d-%d", &top, &bottom)
+		}
+		return true
+	})
+	return top, bottom
+}
+
+// hasMacro tells whether the unit has the macro name, like NOTOC.
+func (unit *ParseUnit) hasMacro(name string) bool {
+	found := false
+	Walk(unit, func(c Context) bool {
+		if macro, ok := c.(*MacroContext); ok && macro.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// sectionNumbers returns the numbers of the headings of the unit, see RendererOptions.NumberSections,
+// nil when they are not numbered.
+func (opts RendererOptions) sectionNumbers(unit *ParseUnit) map[*SectionHeaderContext]string {
+	if !opts.NumberSections {
+		return nil
+	}
+	first := 1
+	if opts.NumberSkipTopLevel {
+		first = 2
+	}
+	headers := make([]*SectionHeaderContext, 0)
+	start := 7
+	for _, block := range unit.Sections {
+		if header, ok := block.(*SectionHeaderContext); ok {
+			headers = append(headers, header)
+			if depth := opts.headingDepth(header.HeaderLevel); depth >= first {
+				start = min(start, depth)
+			}
+		}
+	}
+
+	numbers := make(map[*SectionHeaderContext]string, len(headers))
+	// counters[d] is the number of the last heading of depth d in its section.
+	counters := make([]int, 7)
+	for _, header := range headers {
+		depth := opts.headingDepth(header.HeaderLevel)
+		counters[depth]++
+		clear(counters[depth+1:])
+		if depth < first {
+			continue
+		}
+		parts := make([]string, 0, depth-start+1)
+		for _, n := range counters[start : depth+1] {
+			parts = append(parts, strconv.Itoa(n))
+		}
+		numbers[header] = strings.Join(parts, ".")
+	}
+	return numbers
+}
+
+// HeaderTextPlain returns the text of the heading without its formatting, like "Project Plan" for
+// "**Project** Plan". Anchors and tables of contents are made from it.
+func (c *SectionHeaderContext) HeaderTextPlain() string {
+	if c.InnerContexts == nil {
+		return stripMarkers(c.HeaderText)
+	}
+	var text strings.Builder
+	for _, inline := range c.InnerContexts {
+		switch inline := inline.(type) {
+		case *TextEffectContext:
+			text.WriteString(inline.Text)
+		case *NoWikiContext:
+			text.WriteString(inline.Text)
+		}
+	}
+	return text.String()
+}
+
+// sectionID turns a heading into the id of its anchor the way DokuWiki does, the title is cleaned
+// like a page ID without colons and dots. IDs already in seen get a number appended, so three
+// identical headings give section, section1 and section2.
+func sectionID(title string, seen map[string]bool) string {
+	cleaned := strings.NewReplacer(":", "", ".", "").Replace(cleanID(title))
+	id := strings.TrimLeft(cleaned, "0123456789_-")
+	if id == "" {
+		id = "section" + strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, cleaned)
+	}
+
+	candidate := id
+	for suffix := 1; seen[candidate]; suffix++ {
+		candidate = id + strconv.Itoa(suffix)
+	}
+	seen[candidate] = true
+	return candidate
+}
@@ -0,0 +1,149 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Strip is returned by ParseOptions.UnknownTag and UnknownMacro to remove the syntax from the page.
+var Strip InlineContext = &stripContext{}
+
+type stripContext struct {
+	BaseInlineContext
+}
+
+// stripBlock is returned by the block matcher of unknown tags for the stripped ones.
+var stripBlock BlockContext = &ParaContext{}
+
+var (
+	validUnknownTag   = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9_-]*)(?:\s[^<>]*)?>`)
+	validUnknownMacro = regexp.MustCompile(`^~~([A-Za-z][A-Za-z0-9_]*)(?::[^~\n]*)?~~`)
+	validTOCMacro     = regexp.MustCompile(`^~~TOC:[1-5]-[1-5]~~$`)
+	validClosingTag   = regexp.MustCompile(`</([a-zA-Z][a-zA-Z0-9_-]*)>`)
+)
+
+// knownTags are the tags the parser handles, with or without a language for code and file. Their
+// names are matched without case.
+var knownTags = map[string]bool{"code": true, "file": true, "html": true, "nowiki": true}
+
+// unknownTagLength returns the length of the unknown tag at the start of text, up to its end
+// tag, 0 when there is no such tag. A tag like <tag/> has no end tag.
+func unknownTagLength(text []byte) int {
+	groups := validUnknownTag.FindSubmatch(text)
+	if groups == nil || knownTags[strings.ToLower(string(groups[1]))] {
+		return 0
+	}
+	if bytes.HasSuffix(groups[0], []byte("/>")) {
+		return len(groups[0])
+	}
+	end := []byte("</" + string(groups[1]) + ">")
+	i := bytes.Index(text[len(groups[0]):], end)
+	if i == -1 {
+		return 0
+	}
+	return len(groups[0]) + i + len(end)
+}
+
+// endTags are the offsets of the end tags of a text by name, so the end of every unknown tag
+// of the text is found without searching the rest of it again.
+type endTags map[string][]int
+
+// findEndTags returns the end tags of text.
+func findEndTags(text []byte) endTags {
+	ends := make(endTags)
+	for _, m := range validClosingTag.FindAllSubmatchIndex(text, -1) {
+		name := string(text[m[2]:m[3]])
+		ends[name] = append(ends[name], m[0])
+	}
+	return ends
+}
+
+// tagLength is unknownTagLength for the tag at offset in text, the text ends were found in.
+func (ends endTags) tagLength(text []byte, offset int) int {
+	groups := validUnknownTag.FindSubmatch(text[offset:])
+	if groups == nil || knownTags[strings.ToLower(string(groups[1]))] {
+		return 0
+	}
+	if bytes.HasSuffix(groups[0], []byte("/>")) {
+		return len(groups[0])
+	}
+	name := string(groups[1])
+	offsets := ends[name]
+	i := sort.SearchInts(offsets, offset+len(groups[0]))
+	if i == len(offsets) {
+		return 0
+	}
+	return offsets[i] + len("</"+name+">") - offset
+}
+
+// isUnknownMacro tells whether text starts with a macro other than ~~NOTOC~~, ~~NOCACHE~~ and
+// ~~TOC:n-m~~.
+func isUnknownMacro(text []byte) bool {
+	groups := validUnknownMacro.FindSubmatch(text)
+	if groups == nil {
+		return false
+	}
+	raw := string(groups[0])
+	return raw != "~~NOTOC~~" && raw != "~~NOCACHE~~" && !validTOCMacro.MatchString(raw)
+}
+
+// unknownTagMatcher claims the lines of an unknown tag whose end tag is on another line, it is
+// used by a single classifier and remembers the name of the tag it started until its end.
+type unknownTagMatcher struct {
+	callback func(name, raw string) InlineContext
+	// ends are the end tags of the input, a tag with none after it never starts a block, which
+	// would take the rest of the input.
+	ends endTags
+	// offset is where the line given to Start starts in the input, the classifier sets it.
+	offset int
+	name   string
+}
+
+func newUnknownTagMatcher(callback func(name, raw string) InlineContext, content []byte) *unknownTagMatcher {
+	return &unknownTagMatcher{callback: callback, ends: findEndTags(content)}
+}
+
+// closedAfter tells whether an end tag of the name is at offset or after it in the input.
+func (m *unknownTagMatcher) closedAfter(name string, offset int) bool {
+	offsets := m.ends[name]
+	return sort.SearchInts(offsets, offset) < len(offsets)
+}
+
+func (m *unknownTagMatcher) Start(line []byte) bool {
+	groups := validUnknownTag.FindSubmatch(line)
+	if groups == nil || knownTags[strings.ToLower(string(groups[1]))] || bytes.HasSuffix(groups[0], []byte("/>")) {
+		return false
+	}
+	if !m.closedAfter(string(groups[1]), m.offset+len(groups[0])) {
+		return false
+	}
+	// a tag ending on its own line is left to the paragraph.
+	if bytes.Contains(line, []byte("</"+string(groups[1])+">")) {
+		return false
+	}
+	m.name = string(groups[1])
+	return true
+}
+
+func (m *unknownTagMatcher) End(line, next []byte) bool {
+	return bytes.Contains(line, []byte("</"+m.name+">"))
+}
+
+// Block makes the tag a paragraph holding the context returned by the callback, or its text.
+func (m *unknownTagMatcher) Block(lines []string) BlockContext {
+	// blocks are built once all of them are found, the name is the one of the first line.
+	name := validUnknownTag.FindStringSubmatch(lines[0])[1]
+	inline := m.callback(name, strings.Join(lines, "\n"))
+	if inline == Strip {
+		return stripBlock
+	}
+	para := &ParaContext{}
+	if inline == nil {
+		inline = &TextEffectContext{Text: strings.Join(lines, " ")}
+	}
+	inline.SetParentContext(para)
+	para.InnerContexts = []InlineContext{inline}
+	return para
+}
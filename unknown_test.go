@@ -0,0 +1,86 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnknownTagAndMacro(t *testing.T) {
+	tags := make([]string, 0)
+	macros := make([]string, 0)
+	options := ParseOptions{
+		UnknownTag: func(name, raw string) InlineContext {
+			tags = append(tags, raw)
+			switch name {
+			case "color", "note":
+				return nil
+			case "tag":
+				return &NoWikiContext{Text: raw}
+			}
+			return Strip
+		},
+		UnknownMacro: func(name, raw string) InlineContext {
+			macros = append(macros, name)
+			if name == "KEEP" {
+				return nil
+			}
+			return Strip
+		},
+	}
+	content := "~~NOTOC~~ Some <color red>red</color> text<del>gone</del> and <tag a/>.\n" +
+		"~~DISCUSSION:off~~ ~~KEEP~~ end\n" +
+		"\n" +
+		"<WRAP center>\n" +
+		"  * item\n" +
+		"\n" +
+		"</WRAP>\n" +
+		"<note>\n" +
+		"kept\n" +
+		"</note>\n" +
+		"<code text><del>x</del></code> <unclosed>\n"
+	unit := ParseWithOptions([]byte(content), "unknown", options)
+
+	var dump bytes.Buffer
+	if err := Dump(&dump, unit); err != nil {
+		t.Fatal(err)
+	}
+	want := `ParseUnit "unknown"
+  Para
+    Macro "NOTOC"
+    Text " Some <color red>red</color> text and "
+    NoWiki "<tag a/>"
+    Text ".  ~~KEEP~~ end"
+  Para
+    Text "<note> kept </note>"
+  Para
+    Code lang="text" "<del>x</del>"
+    Text " <unclosed>"
+`
+	if dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+	wantTags := []string{"<WRAP center>\n  * item\n\n</WRAP>", "<note>\nkept\n</note>", "<color red>red</color>", "<del>gone</del>", "<tag a/>"}
+	if len(tags) != len(wantTags) {
+		t.Fatalf("UnknownTag called with %q, want %q", tags, wantTags)
+	}
+	for i := range tags {
+		if tags[i] != wantTags[i] {
+			t.Errorf("UnknownTag called with %q, want %q", tags[i], wantTags[i])
+		}
+	}
+	if len(macros) != 2 || macros[0] != "DISCUSSION" || macros[1] != "KEEP" {
+		t.Errorf("UnknownMacro called with %q", macros)
+	}
+	if note := unit.Sections[1].(*ParaContext); note.GetPosition().Line != 8 || note.InnerContexts[0].(*TextEffectContext).GetPosition().Line != 8 {
+		t.Errorf("the note is at line %d", note.GetPosition().Line)
+	}
+
+	// an end tag before the tag does not close it.
+	dump.Reset()
+	if err := Dump(&dump, ParseWithOptions([]byte("</box>\n\n<box>\nrest\n"), "t", options)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ParseUnit \"t\"\n  Para\n    Text \"</box>\"\n  Para\n    Text \"<box> rest\"\n"; dump.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", dump.String(), want)
+	}
+}
@@ -0,0 +1,249 @@
+package dokuwiki
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ValidationError is a context of a tree breaking one of the invariants checked by Validate.
+type ValidationError struct {
+	// Path is where the context is in the unit, like "Sections[2].InnerContexts[0]".
+	Path    string
+	Context Context
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s (%T): %s", e.Path, e.Context, e.Message)
+}
+
+// Validate checks the invariants of the tree, the ones every unit made by the parser keeps and
+// the renderers rely on. Trees built or changed in code should keep them too:
+//
+//   - no context in the tree is nil
+//   - every context has the context it is in as its parent, the unit for the blocks
+//   - a list only holds items, all of the depth of its level
+//   - an item holds paragraphs, lists deeper than its own, its sub lists, and quotes
+//   - a quote holds paragraphs, lists and quotes one level deeper than itself
+//   - the level of a list or a quote is at least 1, the one of a heading from 1 to 6
+//   - the terms and definitions of a definition list are paragraphs
+//   - the text of a heading and the label of a link have no links nor media
+//   - the image of a link is not external to the link: its parent is the link
+//   - an effect mask only has the bits of the built in effects and of the ones registered on
+//     the parser of the unit
+//   - the blocks are in the order of their lines and no context starts before the one it is
+//     in, the unknown line 0 aside
+//   - the inline contexts are in the order of their lines too
+//   - no context is in the tree twice
+//
+// It returns an error, a *ValidationError, for every context breaking one, nil for a valid tree.
+func (unit *ParseUnit) Validate() []error {
+	v := &validator{seen: make(map[Context]string), effects: unit.effects}
+	for i, block := range unit.Sections {
+		v.block(unit, block, "Sections["+strconv.Itoa(i)+"]")
+	}
+	v.order("Sections", unit.Sections)
+	return v.errs
+}
+
+type validator struct {
+	errs []error
+	// seen are the paths of the contexts checked so far.
+	seen map[Context]string
+	// effects are the registered effects of the unit.
+	effects map[TextEffect]string
+}
+
+func (v *validator) report(path string, c Context, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Context: c, Message: fmt.Sprintf(format, args...)})
+}
+
+// context checks what every context must have, it returns false for a nil one and for one seen
+// before, whose content was checked then.
+func (v *validator) context(parent, c Context, path string) bool {
+	if c == nil {
+		v.report(path, c, "nil context")
+		return false
+	}
+	if c.GetParentContext() != parent {
+		v.report(path, c, "parent is %T, not the %T it is in", c.GetParentContext(), parent)
+	}
+	if positioned, ok := c.(interface{ GetPosition() Position }); ok {
+		if outer, ok := parent.(interface{ GetPosition() Position }); ok {
+			line, outerLine := positioned.GetPosition().Line, outer.GetPosition().Line
+			if line != 0 && outerLine != 0 && line < outerLine {
+				v.report(path, c, "starts on line %d, before line %d of the %T it is in", line, outerLine, parent)
+			}
+		}
+	}
+	if first, ok := v.seen[c]; ok {
+		v.report(path, c, "in the tree twice, at %s too", first)
+		return false
+	}
+	v.seen[c] = path
+	return true
+}
+
+func (v *validator) block(parent Context, block BlockContext, path string) {
+	if !v.context(parent, block, path) {
+		return
+	}
+	switch b := block.(type) {
+	case *SectionHeaderContext:
+		if b.HeaderLevel < 1 || b.HeaderLevel > 6 {
+			v.report(path, b, "heading level %d is not from 1 to 6", b.HeaderLevel)
+		}
+		v.inlines(b, b.InnerContexts, path, "heading")
+	case *ParaContext:
+		v.inlines(b, b.InnerContexts, path, "")
+	case *ListContext:
+		if b.Level < 1 {
+			v.report(path, b, "list level %d is below 1", b.Level)
+		}
+		for i, inner := range b.InnerContexts {
+			innerPath := path + ".InnerContexts[" + strconv.Itoa(i) + "]"
+			switch c := inner.(type) {
+			case *ListItemContext:
+				if c.Depth != b.Level {
+					v.report(innerPath, c, "item depth %d is not level %d of its list", c.Depth, b.Level)
+				}
+			default:
+				if inner != nil {
+					v.report(innerPath, inner, "a list only holds items")
+				}
+			}
+			v.block(b, inner, innerPath)
+		}
+		v.order(path+".InnerContexts", b.InnerContexts)
+	case *ListItemContext:
+		for i, inner := range b.InnerContexts {
+			innerPath := path + ".InnerContexts[" + strconv.Itoa(i) + "]"
+			switch c := inner.(type) {
+			case *ParaContext, *QuoteContext:
+			case *ListContext:
+				if c.Level <= b.Depth {
+					v.report(innerPath, c, "sub list level %d is not deeper than depth %d of its item", c.Level, b.Depth)
+				}
+			default:
+				if inner != nil {
+					v.report(innerPath, inner, "an item only holds paragraphs, lists and quotes")
+				}
+			}
+			v.block(b, inner, innerPath)
+		}
+		v.order(path+".InnerContexts", b.InnerContexts)
+	case *QuoteContext:
+		if b.Level < 1 {
+			v.report(path, b, "quote level %d is below 1", b.Level)
+		}
+		for i, inner := range b.InnerContexts {
+			innerPath := path + ".InnerContexts[" + strconv.Itoa(i) + "]"
+			switch c := inner.(type) {
+			case *ParaContext, *ListContext:
+			case *QuoteContext:
+				if c.Level != b.Level+1 {
+					v.report(innerPath, c, "quote level %d is not one deeper than level %d of its quote", c.Level, b.Level)
+				}
+			default:
+				if inner != nil {
+					v.report(innerPath, inner, "a quote only holds paragraphs, lists and quotes")
+				}
+			}
+			v.block(b, inner, innerPath)
+		}
+		v.order(path+".InnerContexts", b.InnerContexts)
+	case *DefinitionListContext:
+		for i, entry := range b.Entries {
+			entryPath := path + ".Entries[" + strconv.Itoa(i) + "]"
+			if entry.Term != nil {
+				v.block(b, entry.Term, entryPath+".Term")
+			}
+			for j, definition := range entry.Definitions {
+				definitionPath := entryPath + ".Definitions[" + strconv.Itoa(j) + "]"
+				if definition == nil {
+					v.report(definitionPath, nil, "nil context")
+					continue
+				}
+				v.block(b, definition, definitionPath)
+			}
+		}
+	}
+}
+
+// inlines checks the inline contexts of c, in is "heading" or "label" where links and media
+// are not allowed.
+func (v *validator) inlines(c Context, inlines []InlineContext, path, in string) {
+	last := 0
+	for i, inline := range inlines {
+		inlinePath := path + ".InnerContexts[" + strconv.Itoa(i) + "]"
+		if !v.context(c, inline, inlinePath) {
+			continue
+		}
+		if positioned, ok := inline.(interface{ GetPosition() Position }); ok && positioned.GetPosition().Line != 0 {
+			line := positioned.GetPosition().Line
+			if line < last {
+				v.report(inlinePath, inline, "starts on line %d, before line %d of the context before it", line, last)
+			}
+			last = line
+		}
+		switch inline := inline.(type) {
+		case *TextEffectContext:
+			v.effect(inlinePath, inline, inline.EffectType)
+		case *NoWikiContext:
+			v.effect(inlinePath, inline, inline.EffectType)
+		case *HyperLinkContext:
+			if in != "" {
+				v.report(inlinePath, inline, "a link in a %s", in)
+			}
+			v.effect(inlinePath, inline, inline.EffectType)
+			if inline.Image != nil {
+				v.context(inline, inline.Image, inlinePath+".Image")
+			}
+			v.inlines(inline, inline.InnerContexts, inlinePath, "label")
+		case *MediaContext:
+			if in != "" {
+				v.report(inlinePath, inline, "media in a %s", in)
+			}
+			v.effect(inlinePath, inline, inline.EffectType)
+		}
+	}
+}
+
+// effect checks that an effect mask only has known bits.
+func (v *validator) effect(path string, c Context, effectType uint32) {
+	rest := TextEffect(effectType) &^ builtinEffects
+	for bit := TextEffect(1 << 4); rest != 0 && bit != 0; bit <<= 1 {
+		if rest&bit != 0 && v.effects[bit] == "" {
+			v.report(path, c, "unknown effect %#x", uint32(bit))
+		}
+		rest &^= bit
+	}
+}
+
+// order checks that the blocks are in the order of their lines.
+func (v *validator) order(path string, blocks []BlockContext) {
+	last := 0
+	for i, block := range blocks {
+		positioned, ok := block.(interface{ GetPosition() Position })
+		if !ok {
+			continue
+		}
+		line := positioned.GetPosition().Line
+		if line == 0 {
+			continue
+		}
+		if line < last {
+			v.report(path+"["+strconv.Itoa(i)+"]", block, "starts on line %d, before line %d of the block before it", line, last)
+		}
+		last = line
+	}
+}
+
+// validate fails with the problems Validate finds in unit when opts.Validate is set.
+func (opts RendererOptions) validate(unit *ParseUnit) error {
+	if !opts.Validate {
+		return nil
+	}
+	return errors.Join(unit.Validate()...)
+}
@@ -0,0 +1,109 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	content := `====== Title with **bold** ======
+
+Some //text// with [[page|a **label**]], [[page|{{logo.png}}]] and {{ pic.jpg?20x30|pic}}.
+
+  * one
+    * two
+  - three
+
+  ; term : definition
+  : more
+`
+	unit := ParseWithOptions([]byte(content), "t", ParseOptions{DefinitionLists: true})
+	if errs := unit.Validate(); errs != nil {
+		t.Fatalf("a parsed unit is not valid: %v", errs)
+	}
+
+	unit = Parse([]byte("= h =\n\npara\n\n  * item\n"), "t")
+	heading := unit.Sections[0].(*SectionHeaderContext)
+	list := unit.Sections[2].(*ListContext)
+	list.InnerContexts = append(list.InnerContexts, heading)
+	para := unit.Sections[1].(*ParaContext)
+	para.InnerContexts = append(para.InnerContexts, &TextEffectContext{EffectType: 1 << 20, Text: "x"})
+	unit.Sections[0], unit.Sections[1] = unit.Sections[1], unit.Sections[0]
+
+	want := []string{
+		"Sections[0].InnerContexts[1] (*dokuwiki.TextEffectContext): parent is <nil>, not the *dokuwiki.ParaContext it is in",
+		"Sections[0].InnerContexts[1] (*dokuwiki.TextEffectContext): unknown effect 0x100000",
+		"Sections[2].InnerContexts[1] (*dokuwiki.SectionHeaderContext): a list only holds items",
+		"Sections[2].InnerContexts[1] (*dokuwiki.SectionHeaderContext): parent is *dokuwiki.ParseUnit, not the *dokuwiki.ListContext it is in",
+		"Sections[2].InnerContexts[1] (*dokuwiki.SectionHeaderContext): starts on line 1, before line 5 of the *dokuwiki.ListContext it is in",
+		"Sections[2].InnerContexts[1] (*dokuwiki.SectionHeaderContext): in the tree twice, at Sections[1] too",
+		"Sections[2].InnerContexts[1] (*dokuwiki.SectionHeaderContext): starts on line 1, before line 5 of the block before it",
+		"Sections[1] (*dokuwiki.SectionHeaderContext): starts on line 1, before line 3 of the block before it",
+	}
+	errs := unit.Validate()
+	got := make([]string, len(errs))
+	for i, err := range errs {
+		got[i] = err.Error()
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%v is not a *ValidationError", err)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got errors:\n%s", strings.Join(got, "\n"))
+	}
+
+	var out bytes.Buffer
+	err := NewHTMLRenderer(RendererOptions{Validate: true}).Render(&out, unit)
+	if err == nil || !strings.Contains(err.Error(), "unknown effect 0x100000") {
+		t.Errorf("got render error %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("the renderer wrote %q for an invalid unit", out.String())
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	// autolinking splits the text around every URL, the contexts after it were overwritten once.
+	unit := Parse([]byte("see http://a.example.com **bold** http://b.example.com and **more** http://c.example.com end\n"), "t")
+	want := `ParseUnit "t"
+  Para
+    Text "see "
+    Link external target="http://a.example.com" "http://a.example.com"
+    Text " "
+    Text effect=bold "bold"
+    Text " "
+    Link external target="http://b.example.com" "http://b.example.com"
+    Text " and "
+    Text effect=bold "more"
+    Text " "
+    Link external target="http://c.example.com" "http://c.example.com"
+    Text " end"
+`
+	if got := dumpString(unit); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if errs := unit.Validate(); errs != nil {
+		t.Errorf("got %v", errs)
+	}
+
+	para := unit.Sections[0].(*ParaContext)
+	para.InnerContexts = append(para.InnerContexts, para.InnerContexts[1])
+	late := &TextEffectContext{Text: "late"}
+	late.SetParentContext(para)
+	late.setPosition(Position{Line: 3})
+	para.InnerContexts = append([]InlineContext{late}, para.InnerContexts...)
+	got := make([]string, 0)
+	for _, err := range unit.Validate() {
+		got = append(got, err.Error())
+	}
+	wantErrs := []string{
+		"Sections[0].InnerContexts[1] (*dokuwiki.TextEffectContext): starts on line 1, before line 3 of the context before it",
+		"Sections[0].InnerContexts[12] (*dokuwiki.HyperLinkContext): in the tree twice, at Sections[0].InnerContexts[2] too",
+	}
+	if strings.Join(got, "\n") != strings.Join(wantErrs, "\n") {
+		t.Errorf("got errors:\n%s", strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,13 @@
+package dokuwiki
+
+// ASTVersion is the version of the shapes of the contexts, it goes up with every change of the
+// exported context types, a field or a kind of context, and of how the parser nests them. It is
+// written by EncodeUnit and EncodeUnitJSON, the units of another version fail to decode, and the
+// compat package upgrades the trees of the versions before. The versions are
+//
+//  1. the tree of EncodeUnit as it first was, the items of a list were its paragraphs, followed
+//     by their sub lists and quotes.
+//  2. list items are ListItemContext, holding the paragraph of their text and the blocks below it.
+//  3. the raw text of paragraphs keeps the bytes of protected tags, which were NUL markers.
+//  4. macros have Args, ~~TOC:2-4~~ is a macro where it was text.
+const ASTVersion = 4
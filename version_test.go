@@ -0,0 +1,118 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+)
+
+// contextTypes returns the exported type declarations of contexts.go with their exported fields
+// and methods only and without comments, the shapes ASTVersion versions.
+func contextTypes(t *testing.T) string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "contexts.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if !spec.Name.IsExported() {
+				continue
+			}
+			switch typ := spec.Type.(type) {
+			case *ast.StructType:
+				typ.Fields.List = exportedFields(typ.Fields.List)
+			case *ast.InterfaceType:
+				typ.Methods.List = exportedFields(typ.Methods.List)
+			}
+			var decl bytes.Buffer
+			if err := format.Node(&decl, fset, spec); err != nil {
+				t.Fatal(err)
+			}
+			// the blank lines and alignment the comments left are dropped.
+			b.WriteString("type ")
+			for _, line := range strings.Split(decl.String(), "\n") {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					if strings.HasPrefix(line, "\t") {
+						b.WriteString("\t")
+					}
+					b.WriteString(strings.Join(fields, " ") + "\n")
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// exportedFields returns the exported fields of a struct, or methods of an interface, among
+// fields, the embedded ones included when their type is exported.
+func exportedFields(fields []*ast.Field) []*ast.Field {
+	exported := make([]*ast.Field, 0, len(fields))
+	for _, field := range fields {
+		field.Doc, field.Comment = nil, nil
+		if len(field.Names) == 0 {
+			name := field.Type
+			if star, ok := name.(*ast.StarExpr); ok {
+				name = star.X
+			}
+			if ident, ok := name.(*ast.Ident); ok && !ident.IsExported() {
+				continue
+			}
+			exported = append(exported, field)
+			continue
+		}
+		names := field.Names[:0]
+		for _, name := range field.Names {
+			if name.IsExported() {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			field.Names = names
+			exported = append(exported, field)
+		}
+	}
+	return exported
+}
+
+// TestASTVersion fails when the exported context types changed but ASTVersion did not, record the
+// new types after the bump with DOKUWIKITEST_UPDATE=1 go test -run TestASTVersion.
+func TestASTVersion(t *testing.T) {
+	const recordFile = "testdata/ast_version.txt"
+	types := contextTypes(t)
+	content, err := os.ReadFile(recordFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var version int
+	recorded := ""
+	if header, rest, found := strings.Cut(string(content), "\n\n"); found {
+		fmt.Sscanf(header, "ASTVersion %d", &version)
+		recorded = rest
+	}
+	if recorded != types && version == ASTVersion {
+		t.Fatalf("the exported types of contexts.go changed but ASTVersion is still %d, bump it and run DOKUWIKITEST_UPDATE=1 go test -run TestASTVersion", ASTVersion)
+	}
+	if recorded == types && version == ASTVersion {
+		return
+	}
+	if os.Getenv("DOKUWIKITEST_UPDATE") == "" {
+		t.Fatalf("%s is not of ASTVersion %d, run DOKUWIKITEST_UPDATE=1 go test -run TestASTVersion", recordFile, ASTVersion)
+	}
+	if err := os.WriteFile(recordFile, []byte(fmt.Sprintf("ASTVersion %d\n\n%s", ASTVersion, types)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package dokuwiki
+
+// Walk visits c and every context below it in document order, depth first.
+// When fn returns false the children of that context are skipped.
+func Walk(c Context, fn func(Context) bool) {
+	if !fn(c) {
+		return
+	}
+	for _, child := range children(c) {
+		Walk(child, fn)
+	}
+}
+
+// children returns the direct children of a container context, nil for leaves.
+func children(c Context) []Context {
+	var result []Context
+	switch c := c.(type) {
+	case *ParseUnit:
+		for _, block := range c.Sections {
+			result = append(result, block)
+		}
+	case *ListContext:
+		for _, block := range c.InnerContexts {
+			result = append(result, block)
+		}
+	case *ListItemContext:
+		for _, block := range c.InnerContexts {
+			result = append(result, block)
+		}
+	case *QuoteContext:
+		for _, block := range c.InnerContexts {
+			result = append(result, block)
+		}
+	case *DefinitionListContext:
+		for _, entry := range c.Entries {
+			if entry.Term != nil {
+				result = append(result, entry.Term)
+			}
+			for _, definition := range entry.Definitions {
+				result = append(result, definition)
+			}
+		}
+	case *SectionHeaderContext:
+		for _, inline := range c.InnerContexts {
+			result = append(result, inline)
+		}
+	case *ParaContext:
+		for _, inline := range c.InnerContexts {
+			result = append(result, inline)
+		}
+	case *HyperLinkContext:
+		if c.Image != nil {
+			result = append(result, c.Image)
+		}
+		for _, inline := range c.InnerContexts {
+			result = append(result, inline)
+		}
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+//go:build js && wasm
+
+package wasm
+
+import "syscall/js"
+
+// Register sets the function ParseAndRenderHTML of the global object, the window of a page, to
+// ParseAndRenderHTML. It is called with the source and the options JSON, which can be left out,
+// and returns the html.
+func Register() {
+	js.Global().Set("ParseAndRenderHTML", js.FuncOf(func(this js.Value, args []js.Value) any {
+		var source, optionsJSON string
+		if len(args) > 0 && args[0].Type() == js.TypeString {
+			source = args[0].String()
+		}
+		if len(args) > 1 && args[1].Type() == js.TypeString {
+			optionsJSON = args[1].String()
+		}
+		return ParseAndRenderHTML(source, optionsJSON)
+	}))
+}
@@ -0,0 +1,28 @@
+//go:build js && wasm
+
+package wasm
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	Register()
+	render := js.Global().Get("ParseAndRenderHTML")
+	if render.Type() != js.TypeFunction {
+		t.Fatalf("ParseAndRenderHTML is a %s", render.Type())
+	}
+	got := render.Invoke("====== Title ======\n**bold**\n", `{"compact": true}`).String()
+	if want := `<h1 id="title">Title</h1><p><strong>bold</strong></p>`; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+	// the options can be left out.
+	if got := render.Invoke("//it//").String(); !strings.Contains(got, "<em>it</em>") {
+		t.Errorf("got %q without options", got)
+	}
+	if got := render.Invoke().String(); strings.Contains(got, "error") {
+		t.Errorf("got %q without arguments", got)
+	}
+}
@@ -0,0 +1,119 @@
+// Package wasm renders DokuWiki pages to html in the browser, for a live preview of a page while
+// it is edited. Built with GOOS=js GOARCH=wasm, Register makes ParseAndRenderHTML a function of
+// the page, examples/wasm is a page using it.
+//
+// ParseAndRenderHTML runs on every key stroke, so it keeps its parsers, its renderer and its
+// buffers from one call to the next, as long as the options do not change.
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sync"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+// Options are the options of ParseAndRenderHTML, given as JSON like {"compact": true}, an empty
+// string is the zero value.
+type Options struct {
+	// BaseURL, HeadingOffset, Compact and NumberSections are the same as the fields of
+	// dokuwiki.RendererOptions, Sanitize is the name of a dokuwiki.SanitizeMode, "escape"
+	// when empty.
+	BaseURL        string `json:"baseURL"`
+	HeadingOffset  int    `json:"headingOffset"`
+	Sanitize       string `json:"sanitize"`
+	Compact        bool   `json:"compact"`
+	NumberSections bool   `json:"numberSections"`
+	// DefinitionLists, Anchors and NoAutolink are the same as the fields of dokuwiki.ParseOptions.
+	DefinitionLists bool `json:"definitionLists"`
+	Anchors         bool `json:"anchors"`
+	NoAutolink      bool `json:"noAutolink"`
+}
+
+// previewer parses and renders with the options of one options JSON.
+type previewer struct {
+	optionsJSON string
+	// err is the problem with the options, the others are nil with it.
+	err      error
+	pool     *dokuwiki.ParserPool
+	renderer *dokuwiki.HTMLRenderer
+	// source and out are kept for the next call.
+	source []byte
+	out    bytes.Buffer
+}
+
+var (
+	mu      sync.Mutex
+	current *previewer
+)
+
+// ParseAndRenderHTML parses source and returns its html, rendered with the options of optionsJSON.
+// It does not fail: bad options, or a bug of the parser, give a <div class="error"> with the
+// problem in place of the page.
+func ParseAndRenderHTML(source string, optionsJSON string) (result string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil || current.optionsJSON != optionsJSON {
+		current = newPreviewer(optionsJSON)
+	}
+	if current.err != nil {
+		return errorHTML(current.err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			// the buffers may be in any state, start over on the next call.
+			current = nil
+			result = errorHTML(fmt.Errorf("dokuwiki: %v", r))
+		}
+	}()
+	return current.render(source)
+}
+
+func newPreviewer(optionsJSON string) *previewer {
+	p := &previewer{optionsJSON: optionsJSON}
+	var opts Options
+	if optionsJSON != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			p.err = fmt.Errorf("options: %w", err)
+			return p
+		}
+	}
+	rendererOpts := dokuwiki.RendererOptions{
+		BaseURL:        opts.BaseURL,
+		HeadingOffset:  opts.HeadingOffset,
+		Compact:        opts.Compact,
+		NumberSections: opts.NumberSections,
+	}
+	if opts.Sanitize != "" {
+		mode, err := dokuwiki.ParseSanitizeMode(opts.Sanitize)
+		if err != nil {
+			p.err = fmt.Errorf("options: %w", err)
+			return p
+		}
+		rendererOpts.Sanitize = mode
+	}
+	p.pool = dokuwiki.NewParserPool(dokuwiki.New(dokuwiki.WithOptions(dokuwiki.ParseOptions{
+		DefinitionLists: opts.DefinitionLists,
+		Anchors:         opts.Anchors,
+		NoAutolink:      opts.NoAutolink,
+	})))
+	p.renderer = dokuwiki.NewHTMLRenderer(rendererOpts)
+	return p
+}
+
+func (p *previewer) render(source string) string {
+	p.source = append(p.source[:0], source...)
+	unit := p.pool.Parse(p.source, "")
+	p.out.Reset()
+	if err := p.renderer.Render(&p.out, unit); err != nil {
+		return errorHTML(err)
+	}
+	return p.out.String()
+}
+
+func errorHTML(err error) string {
+	return `<div class="error">` + html.EscapeString(err.Error()) + "</div>\n"
+}
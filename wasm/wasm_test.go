@@ -0,0 +1,46 @@
+package wasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	dokuwiki "github.com/321cyb/dokuwiki-parser"
+)
+
+func TestParseAndRenderHTML(t *testing.T) {
+	tests := []struct {
+		source, options string
+		want            string
+	}{
+		{"**bold** text\n", "", "<p>\n<strong>bold</strong> text\n</p>\n"},
+		{"**bold** text\n", `{"compact": true}`, "<p><strong>bold</strong> text</p>"},
+		{"<html><b>x</b></html>\n", `{"sanitize": "strip"}`, "<p>\n\n</p>\n"},
+		{"{{anchor:here}}\n", `{"anchors": true}`, `<span id="here"></span>`},
+		{"x", `{"sanitize": "all"}`, `<div class="error">options: `},
+		{"x", `{"compact": 1}`, `<div class="error">options: `},
+	}
+	for _, test := range tests {
+		// twice, the second time with what the first one kept.
+		for i := 0; i < 2; i++ {
+			if got := ParseAndRenderHTML(test.source, test.options); !strings.Contains(got, test.want) {
+				t.Errorf("ParseAndRenderHTML(%q, %q) = %q, want it to contain %q", test.source, test.options, got, test.want)
+			}
+		}
+	}
+}
+
+func TestParseAndRenderHTMLAllocations(t *testing.T) {
+	source := strings.Repeat("====== Title ======\nSome **bold** and //italic// text with a [[link]].\n\n", 20)
+	ParseAndRenderHTML(source, "")
+	allocs := testing.AllocsPerRun(20, func() { ParseAndRenderHTML(source, "") })
+	fresh := testing.AllocsPerRun(20, func() {
+		var out bytes.Buffer
+		dokuwiki.NewHTMLRenderer(dokuwiki.RendererOptions{}).Render(&out, dokuwiki.Parse([]byte(source), ""))
+		_ = out.String()
+	})
+	// the tree is allocated anew, the parser and the buffers are kept from call to call.
+	if allocs >= fresh {
+		t.Errorf("got %v allocations, not less than the %v of a new parser and buffer", allocs, fresh)
+	}
+}
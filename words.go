@@ -0,0 +1,96 @@
+package dokuwiki
+
+import (
+	"iter"
+	"strings"
+)
+
+// WordToken is a word of the text of a page, as written.
+type WordToken struct {
+	Text string
+	// Span is where the word is in the content the unit was parsed from. It is the zero Span
+	// when the word could not be found there, like for units built by hand.
+	Span     Span
+	Position Position
+}
+
+// Words yields the words a reader sees on the page, for spellchecking: those of headings,
+// paragraphs, list items, link labels and media titles. Code, file, nowiki and html tags, URLs
+// and link targets are skipped. Words are found like for the search index, except that
+// hyphenated words like well-known are kept whole, and don't is one word.
+func (unit *ParseUnit) Words() iter.Seq[WordToken] {
+	return func(yield func(WordToken) bool) {
+		source := unit.source
+		// lineStarts are the offsets of the lines of source.
+		lineStarts := []int{0}
+		for i := 0; i < len(source); i++ {
+			if source[i] == '\n' {
+				lineStarts = append(lineStarts, i+1)
+			}
+		}
+
+		// cursor is how far in source the words have been found, it only moves forward.
+		cursor := 0
+		skip := func(text string) {
+			if i := strings.Index(source[cursor:], text); text != "" && i != -1 {
+				cursor += i + len(text)
+			}
+		}
+		stopped := false
+		words := func(text string, pos Position) {
+			scanWords(text, true, func(start, end int) {
+				if stopped {
+					return
+				}
+				word := WordToken{Text: text[start:end], Position: pos}
+				if i := strings.Index(source[cursor:], word.Text); i != -1 {
+					word.Span = Span{cursor + i, cursor + i + len(word.Text)}
+					cursor = word.Span.End
+				}
+				stopped = !yield(word)
+			})
+		}
+
+		Walk(unit, func(c Context) bool {
+			if stopped {
+				return false
+			}
+			switch c := c.(type) {
+			case *SectionHeaderContext:
+				cursor = max(cursor, lineStart(lineStarts, c.GetPosition()))
+				// a parsed heading has its words in its text.
+				if c.InnerContexts == nil {
+					words(c.HeaderText, c.GetPosition())
+				}
+			case *ParaContext:
+				cursor = max(cursor, lineStart(lineStarts, c.GetPosition()))
+			case *TextEffectContext:
+				words(c.Text, c.GetPosition())
+			case *HyperLinkContext:
+				skip(c.HyperLink)
+				// a label is parsed, its words come with its text.
+				if c.Image == nil && c.InnerContexts == nil && !c.IsAutoLink && c.Text != c.HyperLink {
+					words(c.Text, c.GetPosition())
+				}
+			case *MediaContext:
+				skip(c.MediaResouce)
+				words(c.Title, c.GetPosition())
+			case *CodeFileContext:
+				skip(c.Text)
+			case *NoWikiContext:
+				skip(c.Text)
+			case *HTMLContext:
+				skip(c.Text)
+			}
+			return true
+		})
+	}
+}
+
+// lineStart returns the offset of the line of pos, 0 when it is unknown.
+func lineStart(lineStarts []int, pos Position) int {
+	if pos.Line < 1 || pos.Line > len(lineStarts) {
+		return 0
+	}
+	return lineStarts[pos.Line-1]
+}
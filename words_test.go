@@ -0,0 +1,37 @@
+package dokuwiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	content := "===== Don't panic =====\n" +
+		"A **well-known** fact, see [[wiki:page|the page]] or http://example.com/page.\n" +
+		"<code text>\npage code\n</code>\n" +
+		"  * item {{page.png|A page}}\n"
+	unit := Parse([]byte(content), "doc")
+
+	got := make([]string, 0)
+	for word := range unit.Words() {
+		if content[word.Span.Start:word.Span.End] != word.Text {
+			t.Errorf("%q: span %v points to %q", word.Text, word.Span, content[word.Span.Start:word.Span.End])
+		}
+		got = append(got, word.Text)
+	}
+	want := []string{"Don't", "panic", "A", "well-known", "fact", "see", "the", "page", "or", "item", "A", "page"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var page WordToken
+	for word := range unit.Words() {
+		if word.Text == "page" {
+			page = word
+			break
+		}
+	}
+	if want := (WordToken{Text: "page", Span: Span{67, 71}, Position: Position{Line: 2}}); page != want {
+		t.Errorf("got %+v, want %+v", page, want)
+	}
+}